@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/api/handlers"
+)
+
+// APIAuth holds the credentials the management API requires. Basic auth and
+// a bearer token can be set together, in which case either is accepted.
+type APIAuth struct {
+	User     string
+	Password string
+	Token    string
+}
+
+// ParseAPIAuth checks the API_AUTH_USER, API_AUTH_PASS and API_AUTH_TOKEN
+// values. All empty means the API is unauthenticated.
+func ParseAPIAuth(user, password, token string) (APIAuth, error) {
+	if (user == "") != (password == "") {
+		return APIAuth{}, fmt.Errorf("API_AUTH_USER and API_AUTH_PASS must be set together")
+	}
+	return APIAuth{User: user, Password: password, Token: token}, nil
+}
+
+// Enabled reports whether any credentials are configured
+func (a APIAuth) Enabled() bool {
+	return a.basic() || a.Token != ""
+}
+
+func (a APIAuth) basic() bool {
+	return a.User != "" && a.Password != ""
+}
+
+// String names the enabled methods, for logging
+func (a APIAuth) String() string {
+	var methods []string
+	if a.basic() {
+		methods = append(methods, "basic")
+	}
+	if a.Token != "" {
+		methods = append(methods, "bearer token")
+	}
+	return strings.Join(methods, ", ")
+}
+
+// requireAuth returns a handler that rejects requests without valid
+// credentials with 401
+func requireAuth(auth APIAuth) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.authorized(c.Request) {
+			c.Next()
+			return
+		}
+
+		// Ask browsers for a password only when basic auth can succeed
+		if auth.basic() {
+			c.Header("WWW-Authenticate", `Basic realm="Middleware Manager", charset="UTF-8"`)
+		} else {
+			c.Header("WWW-Authenticate", `Bearer realm="Middleware Manager"`)
+		}
+		handlers.ResponseWithError(c, http.StatusUnauthorized, "Authentication required")
+		c.Abort()
+	}
+}
+
+// authorized reports whether a request carries valid credentials
+func (a APIAuth) authorized(r *http.Request) bool {
+	if a.Token != "" {
+		header := r.Header.Get("Authorization")
+		if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") &&
+			secureEqual(strings.TrimSpace(header[7:]), a.Token) {
+			return true
+		}
+	}
+	if a.basic() {
+		if user, password, ok := r.BasicAuth(); ok {
+			// Evaluate both so timing doesn't reveal which one was wrong
+			userOK := secureEqual(user, a.User)
+			passwordOK := secureEqual(password, a.Password)
+			return userOK && passwordOK
+		}
+	}
+	return false
+}
+
+// secureEqual compares two secrets in constant time. Hashing first keeps the
+// comparison from leaking the expected length.
+func secureEqual(given, expected string) bool {
+	givenSum := sha256.Sum256([]byte(given))
+	expectedSum := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(givenSum[:], expectedSum[:]) == 1
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/services"
+)
+
+// CheckHandler handles configuration health check requests
+type CheckHandler struct {
+	ConfigGenerator *services.ConfigGenerator
+}
+
+// NewCheckHandler creates a new check handler
+func NewCheckHandler(configGenerator *services.ConfigGenerator) *CheckHandler {
+	return &CheckHandler{ConfigGenerator: configGenerator}
+}
+
+// CheckConsistency runs all consistency validations and returns the report
+func (h *CheckHandler) CheckConsistency(c *gin.Context) {
+	report, err := h.ConfigGenerator.CheckConsistency()
+	if err != nil {
+		log.Printf("Error running consistency check: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to run consistency check")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
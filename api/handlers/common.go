@@ -24,6 +24,10 @@ func ResponseWithError(c *gin.Context, statusCode int, message string) {
 	})
 }
 
+// RawJSONKey marks a response whose JSON keys must be sent as they are, even
+// when the client asked for camelCase field names
+const RawJSONKey = "raw_json"
+
 // generateID generates a random 16-character hex string
 func generateID() (string, error) {
 	bytes := make([]byte, 8)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -41,7 +42,7 @@ func (h *ConfigHandler) UpdateRouterPriority(c *gin.Context) {
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", id).Scan(&exists, &status)
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -105,6 +106,85 @@ func (h *ConfigHandler) UpdateRouterPriority(c *gin.Context) {
     })
 }
 
+// UpdateAuthConfig sets whether a resource is excluded from the Pangolin auth middleware
+func (h *ConfigHandler) UpdateAuthConfig(c *gin.Context) {
+    id := c.Param("id")
+    if id == "" {
+        ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+        return
+    }
+    
+    var input struct {
+        SkipAuth *bool `json:"skip_auth" binding:"required"`
+    }
+    
+    if err := c.ShouldBindJSON(&input); err != nil {
+        ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+        return
+    }
+    
+    // Verify resource exists and is active
+    var exists int
+    var status string
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status)
+    if err == sql.ErrNoRows {
+        ResponseWithError(c, http.StatusNotFound, "Resource not found")
+        return
+    } else if err != nil {
+        log.Printf("Error checking resource existence: %v", err)
+        ResponseWithError(c, http.StatusInternalServerError, "Database error")
+        return
+    }
+    
+    // Don't allow updating disabled resources
+    if status == "disabled" {
+        ResponseWithError(c, http.StatusBadRequest, "Cannot update a disabled resource")
+        return
+    }
+    
+    // Update the resource within a transaction
+    tx, err := h.DB.Begin()
+    if err != nil {
+        log.Printf("Error beginning transaction: %v", err)
+        ResponseWithError(c, http.StatusInternalServerError, "Database error")
+        return
+    }
+    
+    var txErr error
+    defer func() {
+        if txErr != nil {
+            tx.Rollback()
+            log.Printf("Transaction rolled back due to error: %v", txErr)
+        }
+    }()
+    
+    log.Printf("Setting skip_auth for resource %s to %t", id, *input.SkipAuth)
+    
+    _, txErr = tx.Exec(
+        "UPDATE resources SET skip_auth = ?, updated_at = ? WHERE id = ?",
+        *input.SkipAuth, time.Now(), id,
+    )
+    
+    if txErr != nil {
+        log.Printf("Error updating skip_auth: %v", txErr)
+        ResponseWithError(c, http.StatusInternalServerError, "Failed to update auth configuration")
+        return
+    }
+    
+    // Commit the transaction
+    if txErr = tx.Commit(); txErr != nil {
+        log.Printf("Error committing transaction: %v", txErr)
+        ResponseWithError(c, http.StatusInternalServerError, "Database error")
+        return
+    }
+    
+    log.Printf("Successfully updated auth configuration for resource %s", id)
+    c.JSON(http.StatusOK, gin.H{
+        "id":        id,
+        "skip_auth": *input.SkipAuth,
+    })
+}
+
 // UpdateHTTPConfig updates the HTTP router entrypoints configuration
 func (h *ConfigHandler) UpdateHTTPConfig(c *gin.Context) {
     id := c.Param("id")
@@ -125,7 +205,7 @@ func (h *ConfigHandler) UpdateHTTPConfig(c *gin.Context) {
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", id).Scan(&exists, &status)
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -206,7 +286,8 @@ func (h *ConfigHandler) UpdateTLSConfig(c *gin.Context) {
     }
     
     var input struct {
-        TLSDomains string `json:"tls_domains"`
+        TLSDomains     *string `json:"tls_domains"`      // Left unchanged when omitted
+        TLSAutoDomains *bool   `json:"tls_auto_domains"` // Left unchanged when omitted
     }
     
     if err := c.ShouldBindJSON(&input); err != nil {
@@ -217,7 +298,9 @@ func (h *ConfigHandler) UpdateTLSConfig(c *gin.Context) {
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", id).Scan(&exists, &status)
+    var tlsDomains string
+    var autoDomains bool
+    err := h.DB.QueryRow("SELECT 1, status, COALESCE(tls_domains, ''), COALESCE(tls_auto_domains, 0) FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status, &tlsDomains, &autoDomains)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -249,11 +332,18 @@ func (h *ConfigHandler) UpdateTLSConfig(c *gin.Context) {
         }
     }()
     
-    log.Printf("Updating TLS domains for resource %s: %s", id, input.TLSDomains)
+    if input.TLSDomains != nil {
+        tlsDomains = *input.TLSDomains
+    }
+    if input.TLSAutoDomains != nil {
+        autoDomains = *input.TLSAutoDomains
+    }
+    
+    log.Printf("Updating TLS domains for resource %s: %s (auto: %t)", id, tlsDomains, autoDomains)
     
     result, txErr := tx.Exec(
-        "UPDATE resources SET tls_domains = ?, updated_at = ? WHERE id = ?",
-        input.TLSDomains, time.Now(), id,
+        "UPDATE resources SET tls_domains = ?, tls_auto_domains = ?, updated_at = ? WHERE id = ?",
+        tlsDomains, autoDomains, time.Now(), id,
     )
     
     if txErr != nil {
@@ -280,7 +370,8 @@ func (h *ConfigHandler) UpdateTLSConfig(c *gin.Context) {
     log.Printf("Successfully updated TLS domains for resource %s", id)
     c.JSON(http.StatusOK, gin.H{
         "id": id,
-        "tls_domains": input.TLSDomains,
+        "tls_domains": tlsDomains,
+        "tls_auto_domains": autoDomains,
     })
 }
 
@@ -306,7 +397,7 @@ func (h *ConfigHandler) UpdateTCPConfig(c *gin.Context) {
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", id).Scan(&exists, &status)
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -395,8 +486,16 @@ func (h *ConfigHandler) UpdateHeadersConfig(c *gin.Context) {
         return
     }
     
+    // custom_headers sets headers to fixed values (an empty value removes the header).
+    // header_rules make the intent explicit with a "set" or "remove" action per header
+    // and are applied on top of custom_headers.
     var input struct {
-        CustomHeaders map[string]string `json:"custom_headers" binding:"required"`
+        CustomHeaders map[string]string `json:"custom_headers"`
+        HeaderRules   []struct {
+            Name   string `json:"name" binding:"required"`
+            Action string `json:"action"`
+            Value  string `json:"value"`
+        } `json:"header_rules"`
     }
     
     if err := c.ShouldBindJSON(&input); err != nil {
@@ -404,10 +503,37 @@ func (h *ConfigHandler) UpdateHeadersConfig(c *gin.Context) {
         return
     }
     
+    if input.CustomHeaders == nil && input.HeaderRules == nil {
+        ResponseWithError(c, http.StatusBadRequest, "Either custom_headers or header_rules is required")
+        return
+    }
+    
+    headers := make(map[string]string, len(input.CustomHeaders)+len(input.HeaderRules))
+    for name, value := range input.CustomHeaders {
+        headers[name] = value
+    }
+    for _, rule := range input.HeaderRules {
+        switch rule.Action {
+        case "", headerActionSet:
+            if rule.Value == "" {
+                ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Header %s: use action \"remove\" to remove a header", rule.Name))
+                return
+            }
+            headers[rule.Name] = rule.Value
+        case headerActionRemove:
+            // Traefik removes a request header when its value is empty
+            headers[rule.Name] = ""
+        default:
+            ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid action %q for header %s", rule.Action, rule.Name))
+            return
+        }
+    }
+    input.CustomHeaders = headers
+    
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", id).Scan(&exists, &status)
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists, &status)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -491,5 +617,32 @@ func (h *ConfigHandler) UpdateHeadersConfig(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{
         "id": id,
         "custom_headers": input.CustomHeaders,
+        "header_rules": headerRulesFromMap(input.CustomHeaders),
     })
+}
+
+// Header actions accepted in header_rules
+const (
+    headerActionSet    = "set"
+    headerActionRemove = "remove"
+)
+
+// headerRulesFromMap describes stored custom headers as explicit set/remove rules
+func headerRulesFromMap(headers map[string]string) []map[string]string {
+    names := make([]string, 0, len(headers))
+    for name := range headers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    
+    rules := make([]map[string]string, 0, len(names))
+    for _, name := range names {
+        value := headers[name]
+        if value == "" {
+            rules = append(rules, map[string]string{"name": name, "action": headerActionRemove})
+        } else {
+            rules = append(rules, map[string]string{"name": name, "action": headerActionSet, "value": value})
+        }
+    }
+    return rules
 }
\ No newline at end of file
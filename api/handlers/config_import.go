@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+	"github.com/hhftechnology/middleware-manager/services"
+	"gopkg.in/yaml.v3"
+)
+
+// Largest export document accepted by ImportConfig
+const maxImportSize = 10 << 20
+
+// ImportHandler restores middlewares and services from a configuration export
+type ImportHandler struct {
+	DB *sql.DB
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(db *sql.DB) *ImportHandler {
+	return &ImportHandler{DB: db}
+}
+
+// importResult is the outcome of importing one middleware or service
+type importResult struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Status string `json:"status"` // created, updated, skipped or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportConfig reads a document produced by GET /api/export and upserts its
+// middlewares, services and resources, with their assignments, in a single
+// transaction. Existing entries are skipped unless ?overwrite=true. Invalid
+// entries are reported as failed and the rest are still imported; a database
+// error imports nothing. Routers and the middlewares generated for resources
+// are not imported; they're generated again from the resources.
+func (h *ImportHandler) ImportConfig(c *gin.Context) {
+	overwrite := c.Query("overwrite") == "true"
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
+		return
+	}
+	var export services.ConfigExport
+	if err := yaml.Unmarshal(body, &export); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid export document: %v", err))
+		return
+	}
+	if export.Version == 0 {
+		ResponseWithError(c, http.StatusBadRequest, "Not a Middleware Manager export: version is missing")
+		return
+	}
+	if export.Version > services.ExportVersion {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Export version %d is newer than this version supports (%d)", export.Version, services.ExportVersion))
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	var results []importResult
+	for _, id := range sortedKeys(export.HTTP.Middlewares) {
+		// Every stored middleware is exported with metadata; the ones without
+		// are generated for resources (custom headers, error pages)
+		meta, ok := export.Metadata.Middlewares[id]
+		if !ok {
+			continue
+		}
+		result, err := importMiddleware(tx, id, export.HTTP.Middlewares[id], meta, overwrite)
+		if err != nil {
+			txErr = err
+			log.Printf("Error importing middleware %s: %v", id, err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error, nothing was imported")
+			return
+		}
+		results = append(results, result)
+	}
+
+	// Weighted services go last, so the services they reference are imported first
+	for _, weightedPass := range []bool{false, true} {
+		for _, section := range []map[string]interface{}{export.HTTP.Services, export.TCP.Services, export.UDP.Services} {
+			for _, id := range sortedKeys(section) {
+				if typ, _, _ := exportedDefinition(section[id]); (typ == string(models.WeightedType)) != weightedPass {
+					continue
+				}
+				result, err := importService(tx, id, section[id], export.Metadata.Services[id], overwrite)
+				if err != nil {
+					txErr = err
+					log.Printf("Error importing service %s: %v", id, err)
+					ResponseWithError(c, http.StatusInternalServerError, "Database error, nothing was imported")
+					return
+				}
+				results = append(results, result)
+			}
+		}
+	}
+
+	// Resources last, so their assignments can refer to what was just imported
+	resourceIDs := make([]string, 0, len(export.Metadata.Resources))
+	for id := range export.Metadata.Resources {
+		resourceIDs = append(resourceIDs, id)
+	}
+	sort.Strings(resourceIDs)
+	for _, id := range resourceIDs {
+		result, err := importResource(tx, id, export.Metadata.Resources[id], overwrite)
+		if err != nil {
+			txErr = err
+			log.Printf("Error importing resource %s: %v", id, err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error, nothing was imported")
+			return
+		}
+		results = append(results, result)
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to commit import")
+		return
+	}
+
+	counts := map[string]int{"created": 0, "updated": 0, "skipped": 0, "failed": 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	if results == nil {
+		results = []importResult{}
+	}
+	log.Printf("Imported configuration export: %d created, %d updated, %d skipped, %d failed",
+		counts["created"], counts["updated"], counts["skipped"], counts["failed"])
+	c.JSON(http.StatusOK, gin.H{
+		"created": counts["created"],
+		"updated": counts["updated"],
+		"skipped": counts["skipped"],
+		"failed":  counts["failed"],
+		"results": results,
+	})
+}
+
+// importMiddleware upserts one exported middleware. A non-nil error is a
+// database failure; invalid definitions are reported in the result.
+func importMiddleware(tx *sql.Tx, id string, entry interface{}, meta services.ExportedMiddleware, overwrite bool) (importResult, error) {
+	result := importResult{Kind: "middleware", ID: id}
+	typ, config, err := exportedDefinition(entry)
+	if err == nil && !isValidMiddlewareType(typ) {
+		err = fmt.Errorf("invalid middleware type %q", typ)
+	}
+	if err == nil && !meta.Unsafe {
+		err = models.ValidateMiddlewareConfig(typ, config)
+	}
+	if err != nil {
+		result.Status, result.Error = "failed", err.Error()
+		return result, nil
+	}
+	name := meta.Name
+	if name == "" {
+		name = id
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		result.Status, result.Error = "failed", err.Error()
+		return result, nil
+	}
+
+	exists, err := rowExists(tx, "SELECT 1 FROM middlewares WHERE id = ?", id)
+	if err != nil {
+		return result, err
+	}
+	// A middleware in the trash is replaced and restored rather than skipped
+	live, err := rowExists(tx, "SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", id)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case live && !overwrite:
+		result.Status = "skipped"
+	case exists:
+		_, err = tx.Exec(
+			"UPDATE middlewares SET name = ?, type = ?, config = ?, unsafe = ?, display_name = ?, icon = ?, category = ?, deleted_at = NULL, updated_at = ? WHERE id = ?",
+			name, typ, string(configJSON), meta.Unsafe, meta.DisplayName, meta.Icon, meta.Category, time.Now(), id,
+		)
+		result.Status = "updated"
+	default:
+		_, err = tx.Exec(
+			"INSERT INTO middlewares (id, name, type, config, unsafe, display_name, icon, category) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			id, name, typ, string(configJSON), meta.Unsafe, meta.DisplayName, meta.Icon, meta.Category,
+		)
+		result.Status = "created"
+	}
+	return result, err
+}
+
+// importService upserts one exported service, like importMiddleware. The
+// config is processed and validated as CreateService does.
+func importService(tx *sql.Tx, id string, entry interface{}, meta services.ExportedService, overwrite bool) (importResult, error) {
+	result := importResult{Kind: "service", ID: id}
+	typ, config, err := exportedDefinition(entry)
+	if err == nil && !models.IsValidServiceType(typ) {
+		err = fmt.Errorf("invalid service type %q", typ)
+	}
+	if err == nil {
+		config = models.ProcessServiceConfig(typ, config)
+		err = models.ValidateServiceConfig(typ, config)
+	}
+	if err == nil {
+		missing, dbErr := missingServiceReferences(tx, typ, config)
+		if dbErr != nil {
+			return result, dbErr
+		}
+		if len(missing) > 0 {
+			err = fmt.Errorf("referenced services not found: %s", strings.Join(missing, ", "))
+		}
+	}
+	if err != nil {
+		result.Status, result.Error = "failed", err.Error()
+		return result, nil
+	}
+	name := meta.Name
+	if name == "" {
+		name = id
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		result.Status, result.Error = "failed", err.Error()
+		return result, nil
+	}
+
+	exists, err := rowExists(tx, "SELECT 1 FROM services WHERE id = ?", id)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case exists && !overwrite:
+		result.Status = "skipped"
+	case exists:
+		_, err = tx.Exec(
+			"UPDATE services SET name = ?, type = ?, config = ?, updated_at = ? WHERE id = ?",
+			name, typ, string(configJSON), time.Now(), id,
+		)
+		result.Status = "updated"
+	default:
+		_, err = tx.Exec(
+			"INSERT INTO services (id, name, type, config) VALUES (?, ?, ?, ?)",
+			id, name, typ, string(configJSON),
+		)
+		result.Status = "created"
+	}
+	return result, err
+}
+
+// importResource upserts one exported resource with its custom service and
+// middleware assignments, which replace the existing ones. Assignments of
+// middlewares or services that don't exist are left out.
+func importResource(tx *sql.Tx, id string, r services.ExportedResource, overwrite bool) (importResult, error) {
+	result := importResult{Kind: "resource", ID: id}
+	if r.Host == "" || r.ServiceID == "" {
+		result.Status, result.Error = "failed", "host and service_id are required"
+		return result, nil
+	}
+	if r.Status != "active" && r.Status != "disabled" {
+		result.Status, result.Error = "failed", fmt.Sprintf("invalid status %q", r.Status)
+		return result, nil
+	}
+
+	exists, err := rowExists(tx, "SELECT 1 FROM resources WHERE id = ?", id)
+	if err != nil {
+		return result, err
+	}
+	// Like middlewares, a resource in the trash is replaced and restored
+	live, err := rowExists(tx, "SELECT 1 FROM resources WHERE id = ? AND deleted_at IS NULL", id)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case live && !overwrite:
+		result.Status = "skipped"
+		return result, nil
+	case exists:
+		_, err = tx.Exec(`
+			UPDATE resources SET host = ?, service_id = ?, org_id = ?, site_id = ?, status = ?,
+				origin = ?, source_type = ?, entrypoints = ?, tls_domains = ?, tls_auto_domains = ?,
+				tcp_enabled = ?, tcp_entrypoints = ?, tcp_sni_rule = ?, custom_headers = ?,
+				error_pages = ?, router_priority = ?, skip_auth = ?, deleted_at = NULL, updated_at = ?
+			WHERE id = ?`,
+			r.Host, r.ServiceID, r.OrgID, r.SiteID, r.Status,
+			r.Origin, r.SourceType, r.Entrypoints, r.TLSDomains, r.TLSAutoDomains,
+			r.TCPEnabled, r.TCPEntrypoints, r.TCPSNIRule, r.CustomHeaders,
+			r.ErrorPages, r.RouterPriority, r.SkipAuth, time.Now(), id,
+		)
+		result.Status = "updated"
+	default:
+		_, err = tx.Exec(`
+			INSERT INTO resources (id, host, service_id, org_id, site_id, status,
+				origin, source_type, entrypoints, tls_domains, tls_auto_domains,
+				tcp_enabled, tcp_entrypoints, tcp_sni_rule, custom_headers,
+				error_pages, router_priority, skip_auth)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, r.Host, r.ServiceID, r.OrgID, r.SiteID, r.Status,
+			r.Origin, r.SourceType, r.Entrypoints, r.TLSDomains, r.TLSAutoDomains,
+			r.TCPEnabled, r.TCPEntrypoints, r.TCPSNIRule, r.CustomHeaders,
+			r.ErrorPages, r.RouterPriority, r.SkipAuth,
+		)
+		result.Status = "created"
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM resource_services WHERE resource_id = ?", id); err != nil {
+		return result, err
+	}
+	if r.CustomService != "" {
+		found, err := rowExists(tx, "SELECT 1 FROM services WHERE id = ?", r.CustomService)
+		if err != nil {
+			return result, err
+		}
+		if found {
+			if _, err := tx.Exec("INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)", id, r.CustomService); err != nil {
+				return result, err
+			}
+		} else {
+			log.Printf("Import: service %s of resource %s does not exist, not assigning it", r.CustomService, id)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM resource_middlewares WHERE resource_id = ?", id); err != nil {
+		return result, err
+	}
+	for _, assignment := range r.Middlewares {
+		found, err := rowExists(tx, "SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", assignment.ID)
+		if err != nil {
+			return result, err
+		}
+		if !found {
+			log.Printf("Import: middleware %s of resource %s does not exist, not assigning it", assignment.ID, id)
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO resource_middlewares (resource_id, middleware_id, priority) VALUES (?, ?, ?)",
+			id, assignment.ID, assignment.Priority); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// exportedDefinition splits a Traefik-layout entry such as
+// {"rateLimit": {"average": 10}} into its type and config
+func exportedDefinition(entry interface{}) (string, map[string]interface{}, error) {
+	definition, ok := entry.(map[string]interface{})
+	if !ok || len(definition) != 1 {
+		return "", nil, fmt.Errorf("expected a single type key with its config")
+	}
+	for typ, value := range definition {
+		config, ok := value.(map[string]interface{})
+		if !ok {
+			if value != nil {
+				return "", nil, fmt.Errorf("config of %s must be an object", typ)
+			}
+			config = map[string]interface{}{}
+		}
+		// Round trip through JSON so numbers are typed as they are for API input
+		data, err := json.Marshal(config)
+		if err != nil {
+			return "", nil, err
+		}
+		var normalized map[string]interface{}
+		if err := json.Unmarshal(data, &normalized); err != nil {
+			return "", nil, err
+		}
+		return typ, normalized, nil
+	}
+	return "", nil, fmt.Errorf("expected a single type key with its config")
+}
+
+// rowExists reports whether a query returns a row
+func rowExists(tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	var exists int
+	err := tx.QueryRow(query, args...).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sortedKeys returns the keys of a map in order, so imports are deterministic
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportConfigValidatesServices(t *testing.T) {
+	h := NewImportHandler(newTestDB(t))
+	router := gin.New()
+	router.POST("/api/import", h.ImportConfig)
+
+	export := `version: 1
+http:
+  services:
+    a-split:
+      weighted:
+        services:
+          - name: b-app
+            weight: 1
+    b-app:
+      loadBalancer:
+        servers:
+          - url: http://app:80
+    empty-lb:
+      loadBalancer:
+        servers: []
+    orphan-split:
+      weighted:
+        services:
+          - name: missing
+            weight: 1
+`
+	w := doRequest(t, router, http.MethodPost, "/api/import", export)
+	if w.Code != http.StatusOK {
+		t.Fatalf("import: got %d %s", w.Code, w.Body.String())
+	}
+	want := map[string]string{"a-split": "created", "b-app": "created", "empty-lb": "failed", "orphan-split": "failed"}
+	results, _ := decodeJSON(t, w)["results"].([]interface{})
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %s", len(results), len(want), w.Body.String())
+	}
+	for _, r := range results {
+		result, _ := r.(map[string]interface{})
+		id, _ := result["id"].(string)
+		if status, _ := result["status"].(string); status != want[id] {
+			t.Errorf("service %s: status %q, want %q (%v)", id, status, want[id], result["error"])
+		}
+	}
+}
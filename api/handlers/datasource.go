@@ -77,6 +77,39 @@ func (h *DataSourceHandler) SetActiveDataSource(c *gin.Context) {
     })
 }
 
+// ActivateDataSource makes a data source the active one after checking that
+// it is reachable. Watchers switch to it right away instead of on their next tick.
+func (h *DataSourceHandler) ActivateDataSource(c *gin.Context) {
+    name := c.Param("name")
+    sourceConfig, ok := h.ConfigManager.GetDataSources()[name]
+    if !ok {
+        ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Data source not found: %s", name))
+        return
+    }
+    
+    ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+    defer cancel()
+    
+    if err := testDataSourceConnection(ctx, sourceConfig); err != nil {
+        log.Printf("Not activating data source %s: %v", name, err)
+        ResponseWithError(c, http.StatusBadGateway, fmt.Sprintf("Data source %s is not reachable: %v", name, err))
+        return
+    }
+    
+    previous := h.ConfigManager.GetActiveSourceName()
+    if err := h.ConfigManager.SetActiveDataSource(name); err != nil {
+        ResponseWithError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "message":  "Data source activated successfully",
+        "name":     name,
+        "previous": previous,
+        "changed":  previous != name,
+    })
+}
+
 // UpdateDataSource updates a data source configuration
 func (h *DataSourceHandler) UpdateDataSource(c *gin.Context) {
     name := c.Param("name")
@@ -138,9 +171,7 @@ func (h *DataSourceHandler) TestDataSourceConnection(c *gin.Context) {
 // testDataSourceConnection tests the connection to a data source using different endpoints
 // based on the data source type
 func testDataSourceConnection(ctx context.Context, config models.DataSourceConfig) error {
-    client := &http.Client{
-        Timeout: 5 * time.Second,
-    }
+    client := services.NewDataSourceHTTPClient(config, 5*time.Second)
     
     var url string
     switch config.Type {
@@ -150,6 +181,11 @@ func testDataSourceConnection(ctx context.Context, config models.DataSourceConfi
     case models.TraefikAPI:
         // Use http/routers endpoint to test Traefik
         url = config.URL + "/api/http/routers"
+    case models.DockerAPI:
+        // The Docker API may be a unix socket, which needs its own client
+        return services.PingDocker(ctx, config)
+    case models.TraefikFile:
+        return services.CheckFileSource(config)
     default:
         return fmt.Errorf("unsupported data source type: %s", config.Type)
     }
@@ -159,10 +195,8 @@ func testDataSourceConnection(ctx context.Context, config models.DataSourceConfi
         return fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if config.BasicAuth.Username != "" {
-        req.SetBasicAuth(config.BasicAuth.Username, config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    config.ApplyToRequest(req)
     
     resp, err := client.Do(req)
     if err != nil {
@@ -175,4 +209,50 @@ func testDataSourceConnection(ctx context.Context, config models.DataSourceConfi
     }
     
     return nil
-}
\ No newline at end of file
+}
+
+// GetRawDataSourceResponse fetches from the active data source and returns the
+// upstream response unprocessed, with credentials redacted, to help diagnose
+// resources that are skipped or mis-parsed
+func (h *DataSourceHandler) GetRawDataSourceResponse(c *gin.Context) {
+    sourceConfig, err := h.ConfigManager.GetActiveDataSourceConfig()
+    if err != nil {
+        ResponseWithError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    
+    fetcher, err := services.NewResourceFetcher(sourceConfig)
+    if err != nil {
+        ResponseWithError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    
+    rawFetcher, ok := fetcher.(services.RawResourceFetcher)
+    if !ok {
+        ResponseWithError(c, http.StatusNotImplemented, fmt.Sprintf("Raw responses are not supported for data source type %s", sourceConfig.Type))
+        return
+    }
+    
+    ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+    defer cancel()
+    
+    responses, err := rawFetcher.FetchRaw(ctx)
+    if err != nil {
+        log.Printf("Error fetching raw data source response: %v", err)
+        ResponseWithError(c, http.StatusBadGateway, fmt.Sprintf("Failed to fetch from data source: %v", err))
+        return
+    }
+    
+    redacted := make(map[string]interface{}, len(responses))
+    for path, payload := range responses {
+        redacted[path] = services.RedactSecrets(payload)
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "name":       h.ConfigManager.GetActiveSourceName(),
+        "type":       sourceConfig.Type,
+        "url":        services.RedactURL(sourceConfig.URL),
+        "fetched_at": time.Now(),
+        "responses":  redacted,
+    })
+}
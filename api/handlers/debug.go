@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/services"
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// DebugHandler exposes internals that help diagnose matching problems
+type DebugHandler struct {
+	DB *sql.DB
+}
+
+// NewDebugHandler creates a new debug handler
+func NewDebugHandler(db *sql.DB) *DebugHandler {
+	return &DebugHandler{DB: db}
+}
+
+// NormalizeID shows how an ID is normalized, the names derived from it, and
+// the stored resources and services it would be matched with
+func (h *DebugHandler) NormalizeID(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		ResponseWithError(c, http.StatusBadRequest, "id query parameter is required")
+		return
+	}
+
+	result := services.NormalizeIDForDebug(id)
+
+	// Resources are matched on the normalized ID
+	matchingResources, err := h.matchingIDs("SELECT id FROM resources WHERE deleted_at IS NULL", func(resourceID string) bool {
+		return util.NormalizeID(resourceID) == result.NormalizedID
+	})
+	if err != nil {
+		log.Printf("Error fetching resources: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch resources")
+		return
+	}
+
+	// Service references resolve on the name without provider suffix
+	matchingServices, err := h.matchingIDs("SELECT id FROM services", func(serviceID string) bool {
+		return services.NormalizeIDForDebug(serviceID).ServiceName == result.ServiceName
+	})
+	if err != nil {
+		log.Printf("Error fetching services: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch services")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"normalization":      result,
+		"matching_resources": matchingResources,
+		"matching_services":  matchingServices,
+	})
+}
+
+// matchingIDs returns the IDs selected by query that match
+func (h *DebugHandler) matchingIDs(query string, match func(string) bool) ([]string, error) {
+	rows, err := h.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if match(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
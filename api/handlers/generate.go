@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/services"
+)
+
+// GenerateHandler handles manually triggered configuration generation and the
+// last-known-good configuration
+type GenerateHandler struct {
+	ConfigGenerator *services.ConfigGenerator
+}
+
+// NewGenerateHandler creates a new generate handler
+func NewGenerateHandler(configGenerator *services.ConfigGenerator) *GenerateHandler {
+	return &GenerateHandler{ConfigGenerator: configGenerator}
+}
+
+// GenerateConfig regenerates the Traefik configuration immediately and returns
+// a summary of what was emitted and whether the file changed
+func (h *GenerateHandler) GenerateConfig(c *gin.Context) {
+	summary, err := h.ConfigGenerator.Generate(c.Request.Context())
+	if err != nil {
+		log.Printf("Error generating configuration: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate configuration: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// PreviewConfig returns the YAML the next generation run would write, without
+// writing it. The X-Data-Source-Type header names the data source it was built
+// for, which decides whether Pangolin's badger@http middleware is added.
+func (h *GenerateHandler) PreviewConfig(c *gin.Context) {
+	yamlData, err := h.ConfigGenerator.BuildConfigYAML()
+	if err != nil {
+		log.Printf("Error building configuration preview: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to build configuration: "+err.Error())
+		return
+	}
+
+	c.Header("X-Data-Source-Type", string(h.ConfigGenerator.ActiveDataSourceType()))
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", yamlData)
+}
+
+// GetTraefikDynamicConfig returns the generated configuration as JSON in
+// Traefik's dynamic configuration format. Secret references are left
+// unresolved, so Traefik's HTTP provider should poll ServeHTTPProvider instead.
+func (h *GenerateHandler) GetTraefikDynamicConfig(c *gin.Context) {
+	dynamic, err := h.ConfigGenerator.BuildDynamicConfig()
+	if err != nil {
+		log.Printf("Error building dynamic configuration: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to build configuration: "+err.Error())
+		return
+	}
+
+	// Keys are Traefik's and middleware names, never API field names
+	c.Set(RawJSONKey, true)
+	c.Header("X-Data-Source-Type", string(h.ConfigGenerator.ActiveDataSourceType()))
+	c.JSON(http.StatusOK, dynamic)
+}
+
+// ServeHTTPProvider serves the generated configuration for Traefik's HTTP
+// provider to poll. The ETag is a hash of the body, so pollers that send
+// If-None-Match get 304 Not Modified until the configuration changes.
+func (h *GenerateHandler) ServeHTTPProvider(c *gin.Context) {
+	body, err := h.ConfigGenerator.BuildHTTPProviderConfig()
+	if err != nil {
+		log.Printf("Error building HTTP provider configuration: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to build configuration: "+err.Error())
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	// Pollers may keep the body but must revalidate it on every poll
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Data-Source-Type", string(h.ConfigGenerator.ActiveDataSourceType()))
+	c.Set(RawJSONKey, true)
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators are compared by their opaque tag, as RFC 7232 says for GET.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportConfig serves a YAML snapshot of every middleware, service and router
+// as a download, for backup or migration to another instance
+func (h *GenerateHandler) ExportConfig(c *gin.Context) {
+	data, err := h.ConfigGenerator.BuildExport()
+	if err != nil {
+		log.Printf("Error building configuration export: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to export configuration: "+err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("middleware-manager-export-%s.yml", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", data)
+}
+
+// GetLastGoodConfig returns the last config Traefik was confirmed to have
+// loaded. Pass ?include=config to include the YAML itself.
+func (h *GenerateHandler) GetLastGoodConfig(c *gin.Context) {
+	lastGood, err := h.ConfigGenerator.LastGoodConfig(c.Query("include") == "config")
+	if errors.Is(err, services.ErrNoLastGoodConfig) {
+		ResponseWithError(c, http.StatusNotFound, "No last-known-good configuration recorded yet")
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading last-known-good config: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to load last-known-good configuration")
+		return
+	}
+
+	c.JSON(http.StatusOK, lastGood)
+}
+
+// ConfirmGoodConfig records the currently generated config as last-known-good,
+// for external checks that confirm Traefik loaded it
+func (h *GenerateHandler) ConfirmGoodConfig(c *gin.Context) {
+	lastGood, err := h.ConfigGenerator.ConfirmCurrentConfig()
+	if err != nil {
+		log.Printf("Error confirming config: %v", err)
+		ResponseWithError(c, http.StatusConflict, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, lastGood)
+}
+
+// RestoreLastGoodConfig re-writes the last-known-good config
+func (h *GenerateHandler) RestoreLastGoodConfig(c *gin.Context) {
+	lastGood, err := h.ConfigGenerator.RestoreLastGood(c.Request.Context())
+	if errors.Is(err, services.ErrNoLastGoodConfig) {
+		ResponseWithError(c, http.StatusNotFound, "No last-known-good configuration recorded yet")
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring last-known-good config: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to restore last-known-good configuration: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Last-known-good configuration restored",
+		"last_good": lastGood,
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits for fetching middleware definitions from a URL
+const (
+	middlewareImportTimeout = 10 * time.Second
+	middlewareImportMaxSize = 1024 * 1024 // 1MB
+	middlewareImportMaxHops = 5           // Redirects followed
+)
+
+// errInternalAddress is returned when an import would connect to an internal address
+var errInternalAddress = errors.New("refusing to fetch from an internal address")
+
+// importedMiddleware is a middleware definition fetched from a URL
+type importedMiddleware struct {
+	Name   string                 `yaml:"name"`
+	Type   string                 `yaml:"type"`
+	Config map[string]interface{} `yaml:"config"`
+}
+
+// ImportMiddlewaresFromURL fetches a YAML or JSON middleware definition, or a
+// bundle of them in the templates.yaml format, and creates the middlewares.
+// Nothing is saved unless every definition is valid.
+func (h *MiddlewareHandler) ImportMiddlewaresFromURL(c *gin.Context) {
+	var request struct {
+		URL string `json:"url" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	parsedURL, err := url.Parse(request.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		ResponseWithError(c, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		return
+	}
+
+	data, err := fetchMiddlewareDefinitions(request.URL)
+	if errors.Is(err, errInternalAddress) {
+		ResponseWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		LogError("fetching middleware definitions", err)
+		ResponseWithError(c, http.StatusBadGateway, fmt.Sprintf("Failed to fetch middleware definitions: %v", err))
+		return
+	}
+
+	middlewares, err := parseMiddlewareDefinitions(data)
+	if err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid middleware definitions: %v", err))
+		return
+	}
+
+	// Validate everything before touching the database
+	var problems []string
+	for i, middleware := range middlewares {
+		label := fmt.Sprintf("middleware %d", i+1)
+		if middleware.Name != "" {
+			label = fmt.Sprintf("middleware %q", middleware.Name)
+		}
+		switch {
+		case middleware.Name == "":
+			problems = append(problems, fmt.Sprintf("%s: name is required", label))
+		case !isValidMiddlewareType(middleware.Type):
+			problems = append(problems, fmt.Sprintf("%s: invalid middleware type %q", label, middleware.Type))
+		case middleware.Config == nil:
+			problems = append(problems, fmt.Sprintf("%s: config is required", label))
+		default:
+			var validationErr *models.ConfigValidationError
+			if err := models.ValidateMiddlewareConfig(middleware.Type, middleware.Config); errors.As(err, &validationErr) {
+				for _, problem := range validationErr.Problems {
+					problems = append(problems, fmt.Sprintf("%s: %s", label, problem))
+				}
+			} else if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "Middleware definitions failed validation",
+			"errors":  problems,
+		})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	imported := make([]gin.H, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		id, err := generateID()
+		if err != nil {
+			txErr = err
+			log.Printf("Error generating ID: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+			return
+		}
+
+		configJSON, err := json.Marshal(middleware.Config)
+		if err != nil {
+			txErr = err
+			log.Printf("Error encoding config: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to encode config")
+			return
+		}
+
+		_, txErr = tx.Exec(
+			"INSERT INTO middlewares (id, name, type, config) VALUES (?, ?, ?, ?)",
+			id, middleware.Name, middleware.Type, string(configJSON),
+		)
+		if txErr != nil {
+			log.Printf("Error inserting middleware: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to save middleware")
+			return
+		}
+
+		imported = append(imported, gin.H{"id": id, "name": middleware.Name, "type": middleware.Type})
+	}
+
+	// Commit the transaction
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Imported %d middlewares from %s", len(imported), request.URL)
+	c.JSON(http.StatusCreated, gin.H{
+		"source":   request.URL,
+		"imported": imported,
+	})
+}
+
+// fetchMiddlewareDefinitions downloads a definition file, enforcing the timeout
+// and size limit. The URL comes from an API caller, so connections to
+// loopback, private and link-local addresses are refused once the host is
+// resolved, and redirects are only followed to other http(s) URLs.
+func fetchMiddlewareDefinitions(sourceURL string) ([]byte, error) {
+	dialer := &net.Dialer{Timeout: middlewareImportTimeout, Control: refuseInternalAddress}
+	client := &http.Client{
+		Timeout: middlewareImportTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: middlewareImportTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= middlewareImportMaxHops {
+				return fmt.Errorf("stopped after %d redirects", middlewareImportMaxHops)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing to follow redirect to %s URL", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("source returned status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, middlewareImportMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > middlewareImportMaxSize {
+		return nil, fmt.Errorf("definition is larger than %d bytes", middlewareImportMaxSize)
+	}
+	return data, nil
+}
+
+// refuseInternalAddress is a net.Dialer Control function that only lets
+// connections to public addresses through. It runs after name resolution, for
+// every connection, so redirects and DNS tricks can't reach internal hosts.
+func refuseInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isInternalIP(ip) {
+		return fmt.Errorf("%w %s", errInternalAddress, host)
+	}
+	return nil
+}
+
+// Shared address space (RFC 6598), used by carrier-grade NAT and some VPNs
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isInternalIP reports whether ip is loopback, private, link-local,
+// unspecified, multicast or in the shared address space
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// parseMiddlewareDefinitions parses a single middleware definition or a bundle
+// with a top-level middlewares list. YAML parsing also accepts JSON.
+func parseMiddlewareDefinitions(data []byte) ([]importedMiddleware, error) {
+	var bundle struct {
+		Middlewares []importedMiddleware `yaml:"middlewares"`
+	}
+	if err := yaml.Unmarshal(data, &bundle); err == nil && len(bundle.Middlewares) > 0 {
+		return bundle.Middlewares, nil
+	}
+
+	var single importedMiddleware
+	if err := yaml.Unmarshal(data, &single); err != nil {
+		return nil, err
+	}
+	if single.Name == "" && single.Type == "" && single.Config == nil {
+		return nil, fmt.Errorf("no middleware definitions found")
+	}
+	return []importedMiddleware{single}, nil
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware IDs become Traefik names, so they are kept to characters that
+// are safe in a reference such as rate-limit@file
+var middlewareIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// RenameMiddleware changes the ID of a middleware. In one transaction it
+// copies the middleware under the new ID, points every resource and policy
+// group assignment at it, updates the chain middlewares that reference the old
+// ID, and deletes the old row.
+func (h *MiddlewareHandler) RenameMiddleware(c *gin.Context) {
+	oldID := c.Param("id")
+	var request struct {
+		NewID string `json:"new_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	newID := strings.TrimSpace(request.NewID)
+	if !middlewareIDPattern.MatchString(newID) {
+		ResponseWithError(c, http.StatusBadRequest, "New ID may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit")
+		return
+	}
+	if newID == oldID {
+		ResponseWithError(c, http.StatusBadRequest, "New ID is the same as the current one")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	for _, check := range []struct {
+		id     string
+		exists bool
+	}{{oldID, true}, {newID, false}} {
+		var live bool
+		err := tx.QueryRow("SELECT deleted_at IS NULL FROM middlewares WHERE id = ?", check.id).Scan(&live)
+		if err != nil && err != sql.ErrNoRows {
+			txErr = err
+			log.Printf("Error checking middleware existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		// A middleware in the trash can't be renamed but still holds its ID
+		if found := err == nil && (live || !check.exists); found != check.exists {
+			tx.Rollback()
+			if check.exists {
+				ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Middleware not found: %s", check.id))
+			} else if !live {
+				ResponseWithError(c, http.StatusConflict, fmt.Sprintf("A middleware with ID %s is in the trash", check.id))
+			} else {
+				ResponseWithError(c, http.StatusConflict, fmt.Sprintf("A middleware with ID %s already exists", check.id))
+			}
+			return
+		}
+	}
+
+	// The copy no longer matches a default template, so it isn't marked as one
+	if _, txErr = tx.Exec(`
+		INSERT INTO middlewares (id, name, type, config, unsafe, display_name, icon, category, from_template, created_at, updated_at)
+		SELECT ?, name, type, config, unsafe, display_name, icon, category, 0, created_at, CURRENT_TIMESTAMP
+		FROM middlewares WHERE id = ?
+	`, newID, oldID); txErr != nil {
+		log.Printf("Error copying middleware %s to %s: %v", oldID, newID, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to rename middleware")
+		return
+	}
+
+	resources, _, txErr := swapMiddlewareAssignments(tx, "resource_middlewares", "resource_id", oldID, newID)
+	if txErr != nil {
+		log.Printf("Error moving resource assignments to %s: %v", newID, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to rename middleware")
+		return
+	}
+	groups, _, txErr := swapMiddlewareAssignments(tx, "policy_group_middlewares", "group_id", oldID, newID)
+	if txErr != nil {
+		log.Printf("Error moving policy group assignments to %s: %v", newID, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to rename middleware")
+		return
+	}
+	chains, txErr := renameChainMembers(tx, oldID, newID)
+	if txErr != nil {
+		log.Printf("Error updating chain references to %s: %v", oldID, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to rename middleware")
+		return
+	}
+
+	if _, txErr = tx.Exec("DELETE FROM middlewares WHERE id = ?", oldID); txErr != nil {
+		log.Printf("Error deleting middleware %s: %v", oldID, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to rename middleware")
+		return
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Renamed middleware %s to %s, updating %d resources, %d policy groups and %d chains",
+		oldID, newID, len(resources), len(groups), len(chains))
+	c.JSON(http.StatusOK, gin.H{
+		"old_id":        oldID,
+		"new_id":        newID,
+		"resources":     resources,
+		"policy_groups": groups,
+		"chains":        chains,
+	})
+}
+
+// renameChainMembers rewrites the members of chain middlewares that refer to
+// oldID, bare or as oldID@file, to newID. References to other providers are
+// left alone. It returns the IDs of the chains that changed.
+func renameChainMembers(tx *sql.Tx, oldID, newID string) ([]string, error) {
+	rows, err := tx.Query("SELECT id, config FROM middlewares WHERE type = 'chain' AND id != ? ORDER BY id", oldID)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(map[string]string)
+	chains := []string{}
+	for rows.Next() {
+		var id, configStr string
+		if err := rows.Scan(&id, &configStr); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+			log.Printf("Skipping chain %s with unreadable config: %v", id, err)
+			continue
+		}
+		members, _ := config["middlewares"].([]interface{})
+		changed := false
+		for i, member := range members {
+			if renamed, ok := renameChainMember(member, oldID, newID); ok {
+				members[i] = renamed
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		data, err := json.Marshal(config)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		updates[id] = string(data)
+		chains = append(chains, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range chains {
+		if _, err := tx.Exec("UPDATE middlewares SET config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", updates[id], id); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+// renameChainMember returns member with its reference to oldID replaced, and
+// whether it referred to oldID. Members are either a name string or an object
+// with a name and optional provider.
+func renameChainMember(member interface{}, oldID, newID string) (interface{}, bool) {
+	switch m := member.(type) {
+	case string:
+		switch m {
+		case oldID:
+			return newID, true
+		case oldID + "@file":
+			return newID + "@file", true
+		}
+	case map[string]interface{}:
+		name, _ := m["name"].(string)
+		provider, _ := m["provider"].(string)
+		switch {
+		case name == oldID && (provider == "" || provider == "file"):
+			m["name"] = newID
+			return m, true
+		case name == oldID+"@file":
+			m["name"] = newID + "@file"
+			return m, true
+		}
+	}
+	return member, false
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// GetUnusedDefaultMiddlewares lists the middlewares created from the default
+// templates that nothing uses: no resource or policy group has them assigned
+// and no chain middleware includes them. They are candidates for pruning.
+func (h *MiddlewareHandler) GetUnusedDefaultMiddlewares(c *gin.Context) {
+	used, err := h.usedMiddlewareIDs()
+	if err != nil {
+		log.Printf("Error collecting middleware usage: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to collect middleware usage")
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT id, name, type, COALESCE(category, '')
+		FROM middlewares
+		WHERE COALESCE(from_template, 0) = 1 AND deleted_at IS NULL
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Printf("Error fetching default middlewares: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch middlewares")
+		return
+	}
+	defer rows.Close()
+
+	unused := []map[string]interface{}{}
+	defaults := 0
+	for rows.Next() {
+		var id, name, typ, category string
+		if err := rows.Scan(&id, &name, &typ, &category); err != nil {
+			log.Printf("Error scanning middleware row: %v", err)
+			continue
+		}
+		defaults++
+		if used[id] {
+			continue
+		}
+		unused = append(unused, map[string]interface{}{
+			"id":       id,
+			"name":     name,
+			"type":     typ,
+			"category": category,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating middleware rows: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error while fetching middlewares")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"unused":        unused,
+		"unused_count":  len(unused),
+		"default_count": defaults,
+	})
+}
+
+// GetMiddlewareResources lists the resources a middleware is assigned to,
+// with their hosts and the assignment priorities, so the impact of deleting
+// or changing it can be previewed. An unused middleware gives an empty list.
+func (h *MiddlewareHandler) GetMiddlewareResources(c *gin.Context) {
+	id := c.Param("id")
+
+	var exists int
+	err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Middleware not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking middleware existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT r.id, r.host, r.status, rm.priority
+		FROM resource_middlewares rm
+		JOIN resources r ON rm.resource_id = r.id
+		WHERE rm.middleware_id = ? AND r.deleted_at IS NULL
+		ORDER BY rm.priority DESC, r.host, r.id
+	`, id)
+	if err != nil {
+		log.Printf("Error fetching resources for middleware %s: %v", id, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch resources")
+		return
+	}
+	defer rows.Close()
+
+	resources := []map[string]interface{}{}
+	for rows.Next() {
+		var resourceID, host, status string
+		var priority int
+		if err := rows.Scan(&resourceID, &host, &status, &priority); err != nil {
+			log.Printf("Error scanning resource row: %v", err)
+			continue
+		}
+		resources = append(resources, map[string]interface{}{
+			"resource_id": resourceID,
+			"host":        host,
+			"status":      status,
+			"priority":    priority,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating resource rows: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error while fetching resources")
+		return
+	}
+
+	c.JSON(http.StatusOK, resources)
+}
+
+// usedMiddlewareIDs returns the IDs of middlewares assigned to a resource or
+// a policy group, or included in a chain. Chain members may be qualified with
+// a provider (rate-limit@file); only the name is compared.
+func (h *MiddlewareHandler) usedMiddlewareIDs() (map[string]bool, error) {
+	used := make(map[string]bool)
+
+	rows, err := h.DB.Query(`
+		SELECT middleware_id FROM resource_middlewares
+		UNION
+		SELECT middleware_id FROM policy_group_middlewares
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		used[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = h.DB.Query("SELECT config FROM middlewares WHERE type = 'chain' AND deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var configStr string
+		if err := rows.Scan(&configStr); err != nil {
+			return nil, err
+		}
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+			continue
+		}
+		members, _ := config["middlewares"].([]interface{})
+		for _, member := range members {
+			if ref, ok := models.ChainMemberReference(member); ok {
+				used[strings.SplitN(ref, "@", 2)[0]] = true
+			}
+		}
+	}
+	return used, rows.Err()
+}
@@ -3,27 +3,92 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
 )
 
 // MiddlewareHandler handles middleware-related requests
 type MiddlewareHandler struct {
-	DB *sql.DB
+	DB            *sql.DB
+	PluginHandler *PluginHandler // Used to check plugin middlewares against installed plugins
 }
 
 // NewMiddlewareHandler creates a new middleware handler
-func NewMiddlewareHandler(db *sql.DB) *MiddlewareHandler {
-	return &MiddlewareHandler{DB: db}
+func NewMiddlewareHandler(db *sql.DB, pluginHandler *PluginHandler) *MiddlewareHandler {
+	return &MiddlewareHandler{DB: db, PluginHandler: pluginHandler}
 }
 
-// GetMiddlewares returns all middleware configurations
+// GetMiddlewares returns all middleware configurations. They can be filtered
+// by ?category=, ?type= and ?name= (a case-insensitive substring). With ?limit=
+// or ?offset= a page sorted by name is returned along with the total number of
+// matching middlewares; without them the plain list is returned as before.
 func (h *MiddlewareHandler) GetMiddlewares(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, type, config FROM middlewares")
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	if category := c.Query("category"); category != "" {
+		conditions = append(conditions, "category = ?")
+		args = append(args, category)
+	}
+	if typ := c.Query("type"); typ != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, typ)
+	}
+	if name := c.Query("name"); name != "" {
+		conditions = append(conditions, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(name)+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limitParam, offsetParam := c.Query("limit"), c.Query("offset")
+	paginated := limitParam != "" || offsetParam != ""
+	limit, offset := -1, 0 // SQLite treats a negative LIMIT as no limit
+	if limitParam != "" {
+		value, err := strconv.Atoi(limitParam)
+		if err != nil || value < 0 {
+			ResponseWithError(c, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = value
+	}
+	if offsetParam != "" {
+		value, err := strconv.Atoi(offsetParam)
+		if err != nil || value < 0 {
+			ResponseWithError(c, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = value
+	}
+
+	var total int
+	if paginated {
+		if err := h.DB.QueryRow("SELECT COUNT(*) FROM middlewares"+where, args...).Scan(&total); err != nil {
+			log.Printf("Error counting middlewares: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch middlewares")
+			return
+		}
+	}
+
+	query := `SELECT id, name, type, config, COALESCE(unsafe, 0),
+		COALESCE(display_name, ''), COALESCE(icon, ''), COALESCE(category, '')
+		FROM middlewares` + where
+	if paginated {
+		query += " ORDER BY name, id LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := h.DB.Query(query, args...)
 	if err != nil {
 		log.Printf("Error fetching middlewares: %v", err)
 		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch middlewares")
@@ -33,8 +98,9 @@ func (h *MiddlewareHandler) GetMiddlewares(c *gin.Context) {
 
 	middlewares := []map[string]interface{}{}
 	for rows.Next() {
-		var id, name, typ, configStr string
-		if err := rows.Scan(&id, &name, &typ, &configStr); err != nil {
+		var id, name, typ, configStr, displayName, icon, category string
+		var unsafe bool
+		if err := rows.Scan(&id, &name, &typ, &configStr, &unsafe, &displayName, &icon, &category); err != nil {
 			log.Printf("Error scanning middleware row: %v", err)
 			continue
 		}
@@ -46,10 +112,14 @@ func (h *MiddlewareHandler) GetMiddlewares(c *gin.Context) {
 		}
 
 		middlewares = append(middlewares, map[string]interface{}{
-			"id":     id,
-			"name":   name,
-			"type":   typ,
-			"config": config,
+			"id":           id,
+			"name":         name,
+			"type":         typ,
+			"config":       config,
+			"unsafe":       unsafe,
+			"display_name": displayName,
+			"icon":         icon,
+			"category":     category,
 		})
 	}
 
@@ -59,15 +129,55 @@ func (h *MiddlewareHandler) GetMiddlewares(c *gin.Context) {
 		return
 	}
 
+	if paginated {
+		page := gin.H{
+			"middlewares": middlewares,
+			"total":       total,
+			"limit":       nil,
+			"offset":      offset,
+		}
+		if limit >= 0 {
+			page["limit"] = limit
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
 	c.JSON(http.StatusOK, middlewares)
 }
 
+// responseWithConfigErrors answers 422 with every problem found in a
+// middleware config, so they can all be fixed at once. Configs with a regex
+// that doesn't compile keep getting 400, as they did before the other checks.
+func responseWithConfigErrors(c *gin.Context, middlewareType string, err error) {
+	status := http.StatusUnprocessableEntity
+	problems := []string{err.Error()}
+	var validationErr *models.ConfigValidationError
+	if errors.As(err, &validationErr) {
+		problems = validationErr.Problems
+		if validationErr.InvalidRegex {
+			status = http.StatusBadRequest
+		}
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": fmt.Sprintf("Invalid %s config: %v", middlewareType, err),
+		"errors":  problems,
+	})
+}
+
+// likeEscaper escapes the LIKE wildcards in a substring filter
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // CreateMiddleware creates a new middleware configuration
 func (h *MiddlewareHandler) CreateMiddleware(c *gin.Context) {
 	var middleware struct {
-		Name   string                 `json:"name" binding:"required"`
-		Type   string                 `json:"type" binding:"required"`
-		Config map[string]interface{} `json:"config" binding:"required"`
+		Name        string                 `json:"name" binding:"required"`
+		Type        string                 `json:"type" binding:"required"`
+		Config      map[string]interface{} `json:"config" binding:"required"`
+		Unsafe      bool                   `json:"unsafe"`
+		DisplayName string                 `json:"display_name"`
+		Icon        string                 `json:"icon"`
+		Category    string                 `json:"category"`
 	}
 
 	if err := c.ShouldBindJSON(&middleware); err != nil {
@@ -81,6 +191,14 @@ func (h *MiddlewareHandler) CreateMiddleware(c *gin.Context) {
 		return
 	}
 
+	// Validate type-specific config, unless the middleware opts out
+	if !middleware.Unsafe {
+		if err := models.ValidateMiddlewareConfig(middleware.Type, middleware.Config); err != nil {
+			responseWithConfigErrors(c, middleware.Type, err)
+			return
+		}
+	}
+
 	// Generate a unique ID
 	id, err := generateID()
 	if err != nil {
@@ -118,8 +236,9 @@ func (h *MiddlewareHandler) CreateMiddleware(c *gin.Context) {
 		id, middleware.Name, middleware.Type)
 	
 	result, txErr := tx.Exec(
-		"INSERT INTO middlewares (id, name, type, config) VALUES (?, ?, ?, ?)",
-		id, middleware.Name, middleware.Type, string(configJSON),
+		"INSERT INTO middlewares (id, name, type, config, unsafe, display_name, icon, category) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, middleware.Name, middleware.Type, string(configJSON), middleware.Unsafe,
+		middleware.DisplayName, middleware.Icon, middleware.Category,
 	)
 	
 	if txErr != nil {
@@ -141,12 +260,23 @@ func (h *MiddlewareHandler) CreateMiddleware(c *gin.Context) {
 	}
 
 	log.Printf("Successfully created middleware %s (%s)", middleware.Name, id)
-	c.JSON(http.StatusCreated, gin.H{
-		"id":     id,
-		"name":   middleware.Name,
-		"type":   middleware.Type,
-		"config": middleware.Config,
-	})
+	if middleware.Unsafe {
+		log.Printf("Middleware %s is marked unsafe, strict validation is skipped for it", id)
+	}
+	response := gin.H{
+		"id":           id,
+		"name":         middleware.Name,
+		"type":         middleware.Type,
+		"config":       middleware.Config,
+		"unsafe":       middleware.Unsafe,
+		"display_name": middleware.DisplayName,
+		"icon":         middleware.Icon,
+		"category":     middleware.Category,
+	}
+	if warnings := h.middlewareWarnings(middleware.Type, middleware.Config); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetMiddleware returns a specific middleware configuration
@@ -157,8 +287,11 @@ func (h *MiddlewareHandler) GetMiddleware(c *gin.Context) {
 		return
 	}
 
-	var name, typ, configStr string
-	err := h.DB.QueryRow("SELECT name, type, config FROM middlewares WHERE id = ?", id).Scan(&name, &typ, &configStr)
+	var name, typ, configStr, displayName, icon, category string
+	var unsafe bool
+	err := h.DB.QueryRow(`SELECT name, type, config, COALESCE(unsafe, 0),
+		COALESCE(display_name, ''), COALESCE(icon, ''), COALESCE(category, '')
+		FROM middlewares WHERE id = ? AND deleted_at IS NULL`, id).Scan(&name, &typ, &configStr, &unsafe, &displayName, &icon, &category)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Middleware not found")
 		return
@@ -174,12 +307,34 @@ func (h *MiddlewareHandler) GetMiddleware(c *gin.Context) {
 		config = map[string]interface{}{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":     id,
-		"name":   name,
-		"type":   typ,
-		"config": config,
-	})
+	response := gin.H{
+		"id":           id,
+		"name":         name,
+		"type":         typ,
+		"config":       config,
+		"unsafe":       unsafe,
+		"display_name": displayName,
+		"icon":         icon,
+		"category":     category,
+	}
+
+	// Optionally describe the type of each config field for the UI
+	if c.Query("describe") == "true" {
+		response["fields"] = models.DescribeMiddlewareConfig(typ, config)
+	}
+
+	// Optionally list the values processing changes before generation
+	if c.Query("explain") == "true" {
+		coercions, err := models.ExplainMiddlewareConfig(typ, config)
+		if err != nil {
+			log.Printf("Error explaining middleware config: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to explain middleware config")
+			return
+		}
+		response["coercions"] = coercions
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateMiddleware updates a middleware configuration
@@ -193,7 +348,11 @@ func (h *MiddlewareHandler) UpdateMiddleware(c *gin.Context) {
 	var middleware struct {
 		Name   string                 `json:"name" binding:"required"`
 		Type   string                 `json:"type" binding:"required"`
-		Config map[string]interface{} `json:"config" binding:"required"`
+		Config      map[string]interface{} `json:"config" binding:"required"`
+		Unsafe      *bool                  `json:"unsafe"`       // Keeps the stored flag when omitted
+		DisplayName *string                `json:"display_name"` // Display metadata keeps its stored value when omitted
+		Icon        *string                `json:"icon"`
+		Category    *string                `json:"category"`
 	}
 
 	if err := c.ShouldBindJSON(&middleware); err != nil {
@@ -208,8 +367,11 @@ func (h *MiddlewareHandler) UpdateMiddleware(c *gin.Context) {
 	}
 
 	// Check if middleware exists
-	var exists int
-	err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ?", id).Scan(&exists)
+	var unsafe bool
+	var displayName, icon, category string
+	err := h.DB.QueryRow(`SELECT COALESCE(unsafe, 0),
+		COALESCE(display_name, ''), COALESCE(icon, ''), COALESCE(category, '')
+		FROM middlewares WHERE id = ? AND deleted_at IS NULL`, id).Scan(&unsafe, &displayName, &icon, &category)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Middleware not found")
 		return
@@ -218,6 +380,26 @@ func (h *MiddlewareHandler) UpdateMiddleware(c *gin.Context) {
 		ResponseWithError(c, http.StatusInternalServerError, "Database error")
 		return
 	}
+	if middleware.Unsafe != nil {
+		unsafe = *middleware.Unsafe
+	}
+	if middleware.DisplayName != nil {
+		displayName = *middleware.DisplayName
+	}
+	if middleware.Icon != nil {
+		icon = *middleware.Icon
+	}
+	if middleware.Category != nil {
+		category = *middleware.Category
+	}
+
+	// Validate type-specific config, unless the middleware opts out
+	if !unsafe {
+		if err := models.ValidateMiddlewareConfig(middleware.Type, middleware.Config); err != nil {
+			responseWithConfigErrors(c, middleware.Type, err)
+			return
+		}
+	}
 
 	// Convert config to JSON string
 	configJSON, err := json.Marshal(middleware.Config)
@@ -248,8 +430,8 @@ func (h *MiddlewareHandler) UpdateMiddleware(c *gin.Context) {
 		id, middleware.Name, middleware.Type)
 	
 	result, txErr := tx.Exec(
-		"UPDATE middlewares SET name = ?, type = ?, config = ?, updated_at = ? WHERE id = ?",
-		middleware.Name, middleware.Type, string(configJSON), time.Now(), id,
+		"UPDATE middlewares SET name = ?, type = ?, config = ?, unsafe = ?, display_name = ?, icon = ?, category = ?, updated_at = ? WHERE id = ?",
+		middleware.Name, middleware.Type, string(configJSON), unsafe, displayName, icon, category, time.Now(), id,
 	)
 	
 	if txErr != nil {
@@ -285,15 +467,67 @@ func (h *MiddlewareHandler) UpdateMiddleware(c *gin.Context) {
 	}
 
 	// Return the updated middleware
-	c.JSON(http.StatusOK, gin.H{
-		"id":     id,
-		"name":   middleware.Name,
-		"type":   middleware.Type,
-		"config": middleware.Config,
-	})
+	response := gin.H{
+		"id":           id,
+		"name":         middleware.Name,
+		"type":         middleware.Type,
+		"config":       middleware.Config,
+		"unsafe":       unsafe,
+		"display_name": displayName,
+		"icon":         icon,
+		"category":     category,
+	}
+	if warnings := h.middlewareWarnings(middleware.Type, middleware.Config); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// DeleteMiddleware deletes a middleware configuration
+// middlewareWarnings collects the non-blocking warnings returned with a saved
+// middleware: soft lints of its config and, for plugins, missing installations
+func (h *MiddlewareHandler) middlewareWarnings(middlewareType string, config map[string]interface{}) []string {
+	warnings := models.LintMiddlewareConfig(middlewareType, config)
+	return append(warnings, h.pluginWarnings(middlewareType, config)...)
+}
+
+// pluginWarnings returns a warning for each plugin a plugin middleware uses that
+// isn't installed in Traefik's static configuration. Traefik fails to load
+// middlewares for missing plugins, so this is surfaced when saving. Nothing is
+// reported if the static configuration can't be read.
+func (h *MiddlewareHandler) pluginWarnings(middlewareType string, config map[string]interface{}) []string {
+	if middlewareType != "plugin" || h.PluginHandler == nil {
+		return nil
+	}
+
+	// Without the static configuration there's nothing to compare against
+	if _, err := os.Stat(h.PluginHandler.TraefikStaticConfigPath); err != nil {
+		return nil
+	}
+
+	installed, err := h.PluginHandler.getLocalInstalledPlugins()
+	if err != nil {
+		log.Printf("Could not check plugin middleware against installed plugins: %v", err)
+		return nil
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	for _, name := range names {
+		if _, ok := installed[name]; !ok {
+			warning := fmt.Sprintf("Plugin '%s' is not installed in the Traefik static configuration (%s); Traefik will not be able to load this middleware", name, h.PluginHandler.TraefikStaticConfigPath)
+			log.Printf("Warning: %s", warning)
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// DeleteMiddleware moves a middleware configuration to the trash
 func (h *MiddlewareHandler) DeleteMiddleware(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -301,9 +535,14 @@ func (h *MiddlewareHandler) DeleteMiddleware(c *gin.Context) {
 		return
 	}
 
-	// Check for dependencies first
+	// Check for dependencies first. Resources in the trash don't count; if
+	// one is restored, its assignment is ignored while the middleware is gone.
 	var count int
-	err := h.DB.QueryRow("SELECT COUNT(*) FROM resource_middlewares WHERE middleware_id = ?", id).Scan(&count)
+	err := h.DB.QueryRow(`
+		SELECT COUNT(*) FROM resource_middlewares rm
+		JOIN resources r ON r.id = rm.resource_id
+		WHERE rm.middleware_id = ? AND r.deleted_at IS NULL
+	`, id).Scan(&count)
 	if err != nil {
 		log.Printf("Error checking middleware dependencies: %v", err)
 		ResponseWithError(c, http.StatusInternalServerError, "Database error")
@@ -315,6 +554,18 @@ func (h *MiddlewareHandler) DeleteMiddleware(c *gin.Context) {
 		return
 	}
 
+	err = h.DB.QueryRow("SELECT COUNT(*) FROM policy_group_middlewares WHERE middleware_id = ?", id).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking middleware dependencies: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if count > 0 {
+		ResponseWithError(c, http.StatusConflict, fmt.Sprintf("Cannot delete middleware because it is used by %d policy groups", count))
+		return
+	}
+
 	// Delete from database using a transaction
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -332,9 +583,12 @@ func (h *MiddlewareHandler) DeleteMiddleware(c *gin.Context) {
 		}
 	}()
 	
-	log.Printf("Attempting to delete middleware %s", id)
+	log.Printf("Attempting to move middleware %s to the trash", id)
 	
-	result, txErr := tx.Exec("DELETE FROM middlewares WHERE id = ?", id)
+	result, txErr := tx.Exec(
+		"UPDATE middlewares SET deleted_at = CURRENT_TIMESTAMP, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now(), id,
+	)
 	if txErr != nil {
 		log.Printf("Error deleting middleware: %v", txErr)
 		ResponseWithError(c, http.StatusInternalServerError, "Failed to delete middleware")
@@ -362,6 +616,119 @@ func (h *MiddlewareHandler) DeleteMiddleware(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Successfully deleted middleware %s", id)
-	c.JSON(http.StatusOK, gin.H{"message": "Middleware deleted successfully"})
-}
\ No newline at end of file
+	log.Printf("Successfully moved middleware %s to the trash", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Middleware moved to the trash"})
+}
+
+// ReplaceMiddleware moves every assignment of a middleware to another one, in
+// one transaction. Resources and policy groups keep their priorities; where
+// the new middleware is already assigned, that assignment is kept and the old
+// one is dropped.
+func (h *MiddlewareHandler) ReplaceMiddleware(c *gin.Context) {
+	oldID := c.Param("id")
+	newID := c.Param("newId")
+	if oldID == newID {
+		ResponseWithError(c, http.StatusBadRequest, "A middleware can't be replaced with itself")
+		return
+	}
+
+	for _, id := range []string{oldID, newID} {
+		var exists int
+		err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists)
+		if err == sql.ErrNoRows {
+			ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Middleware not found: %s", id))
+			return
+		} else if err != nil {
+			log.Printf("Error checking middleware existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	replaced, merged, txErr := swapMiddlewareAssignments(tx, "resource_middlewares", "resource_id", oldID, newID)
+	if txErr != nil {
+		log.Printf("Error replacing middleware on resources: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to replace middleware")
+		return
+	}
+	groupsReplaced, groupsMerged, txErr := swapMiddlewareAssignments(tx, "policy_group_middlewares", "group_id", oldID, newID)
+	if txErr != nil {
+		log.Printf("Error replacing middleware in policy groups: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to replace middleware")
+		return
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Replaced middleware %s with %s on %d resources and %d policy groups",
+		oldID, newID, len(replaced)+len(merged), len(groupsReplaced)+len(groupsMerged))
+	c.JSON(http.StatusOK, gin.H{
+		"old_id":               oldID,
+		"new_id":               newID,
+		"resources":            replaced,
+		"merged_resources":     merged,
+		"policy_groups":        groupsReplaced,
+		"merged_policy_groups": groupsMerged,
+	})
+}
+
+// swapMiddlewareAssignments points the rows of an assignment table that use
+// oldID at newID. It returns the owners whose row was moved and the owners
+// that already had newID, whose oldID row was deleted instead.
+func swapMiddlewareAssignments(tx *sql.Tx, table, ownerColumn, oldID, newID string) ([]string, []string, error) {
+	rows, err := tx.Query(fmt.Sprintf(
+		"SELECT o.%[2]s, n.%[2]s IS NOT NULL FROM %[1]s o LEFT JOIN %[1]s n ON n.%[2]s = o.%[2]s AND n.middleware_id = ? WHERE o.middleware_id = ? ORDER BY o.%[2]s",
+		table, ownerColumn), newID, oldID)
+	if err != nil {
+		return nil, nil, err
+	}
+	replaced, merged := []string{}, []string{}
+	for rows.Next() {
+		var owner string
+		var hasNew bool
+		if err := rows.Scan(&owner, &hasNew); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if hasNew {
+			merged = append(merged, owner)
+		} else {
+			replaced = append(replaced, owner)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, owner := range merged {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND middleware_id = ?", table, ownerColumn), owner, oldID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(replaced) > 0 {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET middleware_id = ? WHERE middleware_id = ?", table), newID, oldID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return replaced, merged, nil
+}
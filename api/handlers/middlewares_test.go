@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newMiddlewareRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	h := NewMiddlewareHandler(newTestDB(t), nil)
+	router := gin.New()
+	router.POST("/api/middlewares", h.CreateMiddleware)
+	router.PUT("/api/middlewares/:id", h.UpdateMiddleware)
+	return router
+}
+
+func TestCreateMiddlewareConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{
+			name:   "regex that doesn't compile",
+			body:   `{"name": "old-paths", "type": "redirectRegex", "config": {"regex": "^/foo/(", "replacement": "/bar"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "stripPrefixRegex list with a bad pattern",
+			body:   `{"name": "strip", "type": "stripPrefixRegex", "config": {"regex": ["^/api", "^/v[0-9"]}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "bad regex alongside other problems",
+			body:   `{"name": "rewrite", "type": "replacePathRegex", "config": {"regex": "("}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "wrong value type",
+			body:   `{"name": "limit", "type": "rateLimit", "config": {"average": "100"}}`,
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newMiddlewareRouter(t)
+			w := doRequest(t, router, http.MethodPost, "/api/middlewares", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			body := decodeJSON(t, w)
+			if errs, _ := body["errors"].([]interface{}); len(errs) == 0 {
+				t.Errorf("response lists no errors: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateMiddlewareInvalidRegex(t *testing.T) {
+	router := newMiddlewareRouter(t)
+
+	w := doRequest(t, router, http.MethodPost, "/api/middlewares",
+		`{"name": "old-paths", "type": "redirectRegex", "config": {"regex": "^/foo/(.*)", "replacement": "/bar/${1}"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	id, _ := decodeJSON(t, w)["id"].(string)
+
+	w = doRequest(t, router, http.MethodPut, "/api/middlewares/"+id,
+		`{"name": "old-paths", "type": "redirectRegex", "config": {"regex": "^/foo/(", "replacement": "/bar"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("update: got %d, want 400: %s", w.Code, w.Body.String())
+	}
+}
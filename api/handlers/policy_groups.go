@@ -0,0 +1,671 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PolicyGroupHandler handles policy groups: named sets of middleware
+// assignments that are materialized into resource_middlewares for every
+// resource they're applied to
+type PolicyGroupHandler struct {
+	DB *sql.DB
+}
+
+// NewPolicyGroupHandler creates a new policy group handler
+func NewPolicyGroupHandler(db *sql.DB) *PolicyGroupHandler {
+	return &PolicyGroupHandler{DB: db}
+}
+
+// policyGroupMiddleware is one middleware assignment of a policy group
+type policyGroupMiddleware struct {
+	MiddlewareID string `json:"middleware_id" binding:"required"`
+	Priority     int    `json:"priority"`
+}
+
+// policyGroupInput is the body of create and update requests
+type policyGroupInput struct {
+	Name        string                  `json:"name" binding:"required"`
+	Description string                  `json:"description"`
+	Middlewares []policyGroupMiddleware `json:"middlewares"`
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// GetPolicyGroups returns all policy groups with their middlewares and resources
+func (h *PolicyGroupHandler) GetPolicyGroups(c *gin.Context) {
+	rows, err := h.DB.Query("SELECT id FROM policy_groups ORDER BY name, id")
+	if err != nil {
+		log.Printf("Error fetching policy groups: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch policy groups")
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Error scanning policy group row: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	groups := []gin.H{}
+	for _, id := range ids {
+		group, err := h.loadPolicyGroup(id)
+		if err != nil {
+			log.Printf("Error loading policy group %s: %v", id, err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch policy groups")
+			return
+		}
+		groups = append(groups, group)
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetPolicyGroup returns one policy group
+func (h *PolicyGroupHandler) GetPolicyGroup(c *gin.Context) {
+	id := c.Param("id")
+	group, err := h.loadPolicyGroup(id)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Policy group not found")
+		return
+	} else if err != nil {
+		log.Printf("Error loading policy group %s: %v", id, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch policy group")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// CreatePolicyGroup creates a policy group
+func (h *PolicyGroupHandler) CreatePolicyGroup(c *gin.Context) {
+	var input policyGroupInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if !h.checkMiddlewaresExist(c, input.Middlewares) {
+		return
+	}
+
+	id, err := generateID()
+	if err != nil {
+		log.Printf("Error generating ID: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	_, txErr = tx.Exec(
+		"INSERT INTO policy_groups (id, name, description) VALUES (?, ?, ?)",
+		id, input.Name, input.Description,
+	)
+	if txErr != nil {
+		log.Printf("Error inserting policy group: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to save policy group")
+		return
+	}
+	if txErr = replacePolicyGroupMiddlewares(tx, id, input.Middlewares); txErr != nil {
+		log.Printf("Error saving policy group middlewares: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to save policy group")
+		return
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Created policy group %s (%s) with %d middlewares", input.Name, id, len(input.Middlewares))
+	group, err := h.loadPolicyGroup(id)
+	if err != nil {
+		log.Printf("Error loading policy group %s: %v", id, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch policy group")
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdatePolicyGroup replaces a policy group's name, description and
+// middlewares. With reapply=true, member resources are brought in line: the
+// group's middlewares are (re)assigned and ones dropped from the group are
+// removed from them, unless they were assigned by hand or by another group.
+func (h *PolicyGroupHandler) UpdatePolicyGroup(c *gin.Context) {
+	id := c.Param("id")
+	reapply := c.Query("reapply") == "true"
+
+	var input policyGroupInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if !h.checkMiddlewaresExist(c, input.Middlewares) {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	result, txErr := tx.Exec(
+		"UPDATE policy_groups SET name = ?, description = ?, updated_at = ? WHERE id = ?",
+		input.Name, input.Description, time.Now(), id,
+	)
+	if txErr != nil {
+		log.Printf("Error updating policy group: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to update policy group")
+		return
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		tx.Rollback()
+		ResponseWithError(c, http.StatusNotFound, "Policy group not found")
+		return
+	}
+
+	previous, txErr := loadPolicyGroupMiddlewares(tx, id)
+	if txErr != nil {
+		log.Printf("Error loading policy group middlewares: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if txErr = replacePolicyGroupMiddlewares(tx, id, input.Middlewares); txErr != nil {
+		log.Printf("Error saving policy group middlewares: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to update policy group")
+		return
+	}
+
+	reapplied := []string{}
+	if reapply {
+		members, err := loadPolicyGroupResources(tx, id, true)
+		if err != nil {
+			txErr = err
+			log.Printf("Error loading policy group resources: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		kept := make(map[string]bool, len(input.Middlewares))
+		for _, mw := range input.Middlewares {
+			kept[mw.MiddlewareID] = true
+		}
+		for _, resourceID := range members {
+			for _, mw := range previous {
+				if kept[mw.MiddlewareID] {
+					continue
+				}
+				if txErr = unassignPolicyGroupMiddleware(tx, id, resourceID, mw.MiddlewareID); txErr != nil {
+					log.Printf("Error removing middleware dropped from policy group: %v", txErr)
+					ResponseWithError(c, http.StatusInternalServerError, "Failed to reapply policy group")
+					return
+				}
+			}
+			if txErr = materializePolicyGroup(tx, id, resourceID, input.Middlewares); txErr != nil {
+				log.Printf("Error reapplying policy group to resource %s: %v", resourceID, txErr)
+				ResponseWithError(c, http.StatusInternalServerError, "Failed to reapply policy group")
+				return
+			}
+			reapplied = append(reapplied, resourceID)
+		}
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Updated policy group %s, reapplied to %d resources", id, len(reapplied))
+	group, err := h.loadPolicyGroup(id)
+	if err != nil {
+		log.Printf("Error loading policy group %s: %v", id, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch policy group")
+		return
+	}
+	group["reapplied_resources"] = reapplied
+	c.JSON(http.StatusOK, group)
+}
+
+// DeletePolicyGroup deletes a policy group. Middleware assignments it already
+// materialized on resources are left in place, as if assigned by hand.
+func (h *PolicyGroupHandler) DeletePolicyGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	for _, table := range []string{"policy_group_middlewares", "policy_group_resources", "policy_group_resource_middlewares"} {
+		if _, txErr = tx.Exec("DELETE FROM "+table+" WHERE group_id = ?", id); txErr != nil {
+			log.Printf("Error deleting from %s: %v", table, txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to delete policy group")
+			return
+		}
+	}
+	result, txErr := tx.Exec("DELETE FROM policy_groups WHERE id = ?", id)
+	if txErr != nil {
+		log.Printf("Error deleting policy group: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to delete policy group")
+		return
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		tx.Rollback()
+		ResponseWithError(c, http.StatusNotFound, "Policy group not found")
+		return
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Deleted policy group %s", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Policy group deleted successfully"})
+}
+
+// ApplyPolicyGroup assigns a policy group's middlewares to resources and
+// records them as members. Missing and disabled resources are skipped.
+func (h *PolicyGroupHandler) ApplyPolicyGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var input struct {
+		ResourceIDs []string `json:"resource_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	var exists int
+	err := h.DB.QueryRow("SELECT 1 FROM policy_groups WHERE id = ?", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Policy group not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking policy group existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	middlewares, txErr := loadPolicyGroupMiddlewares(tx, id)
+	if txErr != nil {
+		log.Printf("Error loading policy group middlewares: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	applied := []string{}
+	skipped := []gin.H{}
+	for _, resourceID := range input.ResourceIDs {
+		var status string
+		err := tx.QueryRow("SELECT status FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&status)
+		if err == sql.ErrNoRows {
+			skipped = append(skipped, gin.H{"resource_id": resourceID, "reason": "Resource not found"})
+			continue
+		} else if err != nil {
+			txErr = err
+			log.Printf("Error checking resource existence: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		// Don't allow attaching middlewares to disabled resources
+		if status == "disabled" {
+			skipped = append(skipped, gin.H{"resource_id": resourceID, "reason": "Cannot assign middlewares to a disabled resource"})
+			continue
+		}
+
+		if txErr = materializePolicyGroup(tx, id, resourceID, middlewares); txErr != nil {
+			log.Printf("Error applying policy group to resource %s: %v", resourceID, txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to apply policy group")
+			return
+		}
+		applied = append(applied, resourceID)
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Applied policy group %s to %d resources (%d skipped)", id, len(applied), len(skipped))
+	c.JSON(http.StatusOK, gin.H{
+		"group_id": id,
+		"applied":  applied,
+		"skipped":  skipped,
+	})
+}
+
+// DetachPolicyGroup removes a resource from a policy group's members. With
+// remove_middlewares=true the middlewares the group assigned are also
+// unassigned from it, except ones another group still provides. Otherwise
+// they stay, as if assigned by hand.
+func (h *PolicyGroupHandler) DetachPolicyGroup(c *gin.Context) {
+	id := c.Param("id")
+	resourceID := c.Param("resourceId")
+	removeMiddlewares := c.Query("remove_middlewares") == "true"
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	result, txErr := tx.Exec("DELETE FROM policy_group_resources WHERE group_id = ? AND resource_id = ?", id, resourceID)
+	if txErr != nil {
+		log.Printf("Error detaching policy group: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to detach policy group")
+		return
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		tx.Rollback()
+		ResponseWithError(c, http.StatusNotFound, "Resource is not a member of this policy group")
+		return
+	}
+
+	added, txErr := loadPolicyGroupAssignments(tx, id, resourceID)
+	if txErr != nil {
+		log.Printf("Error loading policy group assignments: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to detach policy group")
+		return
+	}
+	for _, middlewareID := range added {
+		if removeMiddlewares {
+			txErr = unassignPolicyGroupMiddleware(tx, id, resourceID, middlewareID)
+		} else {
+			_, txErr = tx.Exec(
+				"DELETE FROM policy_group_resource_middlewares WHERE group_id = ? AND resource_id = ? AND middleware_id = ?",
+				id, resourceID, middlewareID,
+			)
+		}
+		if txErr != nil {
+			log.Printf("Error removing policy group middlewares: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to detach policy group")
+			return
+		}
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Detached resource %s from policy group %s", resourceID, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Resource detached from policy group successfully"})
+}
+
+// checkMiddlewaresExist responds with 404 and returns false if any of the
+// middlewares doesn't exist
+func (h *PolicyGroupHandler) checkMiddlewaresExist(c *gin.Context, middlewares []policyGroupMiddleware) bool {
+	for _, mw := range middlewares {
+		var exists int
+		err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", mw.MiddlewareID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Middleware not found: %s", mw.MiddlewareID))
+			return false
+		} else if err != nil {
+			log.Printf("Error checking middleware existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return false
+		}
+	}
+	return true
+}
+
+// loadPolicyGroup returns a policy group with its middlewares and member
+// resources, or sql.ErrNoRows
+func (h *PolicyGroupHandler) loadPolicyGroup(id string) (gin.H, error) {
+	var name, description string
+	var createdAt, updatedAt time.Time
+	err := h.DB.QueryRow(
+		"SELECT name, COALESCE(description, ''), created_at, updated_at FROM policy_groups WHERE id = ?", id,
+	).Scan(&name, &description, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	middlewares, err := loadPolicyGroupMiddlewares(h.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := loadPolicyGroupResources(h.DB, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"id":          id,
+		"name":        name,
+		"description": description,
+		"middlewares": middlewares,
+		"resources":   resources,
+		"created_at":  createdAt,
+		"updated_at":  updatedAt,
+	}, nil
+}
+
+// loadPolicyGroupMiddlewares returns a policy group's middlewares by descending priority
+func loadPolicyGroupMiddlewares(q rowQuerier, groupID string) ([]policyGroupMiddleware, error) {
+	rows, err := q.Query(
+		"SELECT middleware_id, priority FROM policy_group_middlewares WHERE group_id = ? ORDER BY priority DESC, middleware_id",
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	middlewares := []policyGroupMiddleware{}
+	for rows.Next() {
+		var mw policyGroupMiddleware
+		if err := rows.Scan(&mw.MiddlewareID, &mw.Priority); err != nil {
+			return nil, err
+		}
+		middlewares = append(middlewares, mw)
+	}
+	return middlewares, rows.Err()
+}
+
+// loadPolicyGroupResources returns the IDs of a policy group's member
+// resources, optionally only the active ones
+func loadPolicyGroupResources(q rowQuerier, groupID string, activeOnly bool) ([]string, error) {
+	query := `SELECT pgr.resource_id FROM policy_group_resources pgr
+		JOIN resources r ON r.id = pgr.resource_id
+		WHERE pgr.group_id = ? AND r.deleted_at IS NULL`
+	if activeOnly {
+		query += " AND r.status = 'active'"
+	}
+	rows, err := q.Query(query+" ORDER BY pgr.resource_id", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []string{}
+	for rows.Next() {
+		var resourceID string
+		if err := rows.Scan(&resourceID); err != nil {
+			return nil, err
+		}
+		resources = append(resources, resourceID)
+	}
+	return resources, rows.Err()
+}
+
+// replacePolicyGroupMiddlewares sets a policy group's middlewares
+func replacePolicyGroupMiddlewares(tx *sql.Tx, groupID string, middlewares []policyGroupMiddleware) error {
+	if _, err := tx.Exec("DELETE FROM policy_group_middlewares WHERE group_id = ?", groupID); err != nil {
+		return err
+	}
+	for _, mw := range middlewares {
+		// Default priority is 100 if not specified
+		if mw.Priority <= 0 {
+			mw.Priority = 100
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO policy_group_middlewares (group_id, middleware_id, priority) VALUES (?, ?, ?)",
+			groupID, mw.MiddlewareID, mw.Priority,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializePolicyGroup assigns a policy group's middlewares to a resource,
+// replacing the priority of ones already assigned, and records the membership.
+// The assignments the group adds are recorded too; ones that were assigned by
+// hand stay that way, so detaching the group leaves them.
+func materializePolicyGroup(tx *sql.Tx, groupID, resourceID string, middlewares []policyGroupMiddleware) error {
+	for _, mw := range middlewares {
+		if mw.Priority <= 0 {
+			mw.Priority = 100
+		}
+		assigned, err := rowExists(tx, "SELECT 1 FROM resource_middlewares WHERE resource_id = ? AND middleware_id = ?", resourceID, mw.MiddlewareID)
+		if err != nil {
+			return err
+		}
+		byGroup, err := rowExists(tx, "SELECT 1 FROM policy_group_resource_middlewares WHERE resource_id = ? AND middleware_id = ?", resourceID, mw.MiddlewareID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO resource_middlewares (resource_id, middleware_id, priority) VALUES (?, ?, ?)",
+			resourceID, mw.MiddlewareID, mw.Priority,
+		); err != nil {
+			return err
+		}
+		if !assigned || byGroup {
+			if _, err := tx.Exec(
+				"INSERT OR IGNORE INTO policy_group_resource_middlewares (group_id, resource_id, middleware_id) VALUES (?, ?, ?)",
+				groupID, resourceID, mw.MiddlewareID,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := tx.Exec(
+		"INSERT OR REPLACE INTO policy_group_resources (group_id, resource_id, applied_at) VALUES (?, ?, ?)",
+		groupID, resourceID, time.Now(),
+	)
+	return err
+}
+
+// loadPolicyGroupAssignments returns the middlewares a policy group assigned to a resource
+func loadPolicyGroupAssignments(q rowQuerier, groupID, resourceID string) ([]string, error) {
+	rows, err := q.Query(
+		"SELECT middleware_id FROM policy_group_resource_middlewares WHERE group_id = ? AND resource_id = ? ORDER BY middleware_id",
+		groupID, resourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	middlewares := []string{}
+	for rows.Next() {
+		var middlewareID string
+		if err := rows.Scan(&middlewareID); err != nil {
+			return nil, err
+		}
+		middlewares = append(middlewares, middlewareID)
+	}
+	return middlewares, rows.Err()
+}
+
+// unassignPolicyGroupMiddleware removes a middleware from a resource if the
+// policy group assigned it and no other group also provides it. Assignments
+// made by hand are left alone.
+func unassignPolicyGroupMiddleware(tx *sql.Tx, groupID, resourceID, middlewareID string) error {
+	result, err := tx.Exec(
+		"DELETE FROM policy_group_resource_middlewares WHERE group_id = ? AND resource_id = ? AND middleware_id = ?",
+		groupID, resourceID, middlewareID,
+	)
+	if err != nil {
+		return err
+	}
+	if removed, err := result.RowsAffected(); err != nil || removed == 0 {
+		return err
+	}
+	providedByOther, err := rowExists(tx,
+		"SELECT 1 FROM policy_group_resource_middlewares WHERE resource_id = ? AND middleware_id = ?",
+		resourceID, middlewareID,
+	)
+	if err != nil || providedByOther {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM resource_middlewares WHERE resource_id = ? AND middleware_id = ?", resourceID, middlewareID)
+	return err
+}
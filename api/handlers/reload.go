@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/services"
+)
+
+// ReloadHandler triggers out-of-band refreshes from the data source
+type ReloadHandler struct {
+	Reloader *services.Reloader
+}
+
+// NewReloadHandler creates a new reload handler
+func NewReloadHandler(reloader *services.Reloader) *ReloadHandler {
+	return &ReloadHandler{Reloader: reloader}
+}
+
+// Reload starts a resource check, service check and config generation in the
+// background and answers 202 with the reload status. GET /api/reload/status
+// reports when it finished and any error.
+func (h *ReloadHandler) Reload(c *gin.Context) {
+	if h.Reloader == nil {
+		ResponseWithError(c, http.StatusServiceUnavailable, "Reload not available")
+		return
+	}
+	c.JSON(http.StatusAccepted, h.Reloader.Trigger())
+}
+
+// GetReloadStatus returns the state of the current or last reload
+func (h *ReloadHandler) GetReloadStatus(c *gin.Context) {
+	if h.Reloader == nil {
+		ResponseWithError(c, http.StatusServiceUnavailable, "Reload not available")
+		return
+	}
+	c.JSON(http.StatusOK, h.Reloader.Status())
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Columns of the resource middleware assignments CSV
+var assignmentsCSVHeader = []string{"resource_id", "host", "middleware_id", "middleware_name", "priority"}
+
+// ExportAssignmentsCSV exports every resource middleware assignment as CSV
+func (h *ResourceHandler) ExportAssignmentsCSV(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT r.id, r.host, m.id, m.name, rm.priority
+		FROM resource_middlewares rm
+		JOIN resources r ON r.id = rm.resource_id
+		JOIN middlewares m ON m.id = rm.middleware_id
+		WHERE r.deleted_at IS NULL AND m.deleted_at IS NULL
+		ORDER BY r.id, rm.priority DESC, m.id
+	`)
+	if err != nil {
+		log.Printf("Error fetching assignments: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch assignments")
+		return
+	}
+	defer rows.Close()
+
+	records := [][]string{assignmentsCSVHeader}
+	for rows.Next() {
+		var resourceID, host, middlewareID, middlewareName string
+		var priority int
+		if err := rows.Scan(&resourceID, &host, &middlewareID, &middlewareName, &priority); err != nil {
+			log.Printf("Error scanning assignment: %v", err)
+			continue
+		}
+		records = append(records, []string{resourceID, host, middlewareID, middlewareName, strconv.Itoa(priority)})
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error during assignment rows iteration: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch assignments")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="assignments.csv"`)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.WriteAll(records); err != nil {
+		log.Printf("Error writing assignments CSV: %v", err)
+	}
+}
+
+// ImportAssignmentsCSV applies resource middleware assignments from a CSV file.
+// The CSV needs resource_id and middleware_id columns and may have a priority column;
+// other columns (such as those in the export) are ignored. With ?replace=true, every
+// resource in the file has its existing assignments replaced by the ones in the file.
+func (h *ResourceHandler) ImportAssignmentsCSV(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if file, _, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+	}
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid CSV: %v", err))
+		return
+	}
+	if len(records) == 0 {
+		ResponseWithError(c, http.StatusBadRequest, "CSV is empty")
+		return
+	}
+
+	// Locate columns by header name
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	resourceCol, hasResource := columns["resource_id"]
+	middlewareCol, hasMiddleware := columns["middleware_id"]
+	priorityCol, hasPriority := columns["priority"]
+	if !hasResource || !hasMiddleware {
+		ResponseWithError(c, http.StatusBadRequest, "CSV header must include resource_id and middleware_id columns")
+		return
+	}
+
+	type assignment struct {
+		line         int
+		resourceID   string
+		middlewareID string
+		priority     int
+	}
+
+	var assignments []assignment
+	skipped := make([]map[string]interface{}, 0)
+	skip := func(line int, reason string) {
+		skipped = append(skipped, map[string]interface{}{"line": line, "reason": reason})
+	}
+
+	// Validate every row before touching the database
+	resourceStatus := make(map[string]string)
+	middlewareExists := make(map[string]bool)
+	for i, record := range records[1:] {
+		line := i + 2
+		field := func(col int) string {
+			if col < len(record) {
+				return strings.TrimSpace(record[col])
+			}
+			return ""
+		}
+
+		a := assignment{line: line, resourceID: field(resourceCol), middlewareID: field(middlewareCol), priority: 100}
+		if a.resourceID == "" || a.middlewareID == "" {
+			skip(line, "resource_id and middleware_id are required")
+			continue
+		}
+		if hasPriority && field(priorityCol) != "" {
+			priority, err := strconv.Atoi(field(priorityCol))
+			if err != nil {
+				skip(line, fmt.Sprintf("invalid priority %q", field(priorityCol)))
+				continue
+			}
+			if priority > 0 {
+				a.priority = priority
+			}
+		}
+
+		status, checked := resourceStatus[a.resourceID]
+		if !checked {
+			err := h.DB.QueryRow("SELECT status FROM resources WHERE id = ? AND deleted_at IS NULL", a.resourceID).Scan(&status)
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("Error checking resource existence: %v", err)
+				ResponseWithError(c, http.StatusInternalServerError, "Database error")
+				return
+			}
+			resourceStatus[a.resourceID] = status
+		}
+		if status == "" {
+			skip(line, fmt.Sprintf("resource %s not found", a.resourceID))
+			continue
+		}
+		if status == "disabled" {
+			skip(line, fmt.Sprintf("resource %s is disabled", a.resourceID))
+			continue
+		}
+
+		exists, checked := middlewareExists[a.middlewareID]
+		if !checked {
+			var found int
+			err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", a.middlewareID).Scan(&found)
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("Error checking middleware existence: %v", err)
+				ResponseWithError(c, http.StatusInternalServerError, "Database error")
+				return
+			}
+			exists = err == nil
+			middlewareExists[a.middlewareID] = exists
+		}
+		if !exists {
+			skip(line, fmt.Sprintf("middleware %s not found", a.middlewareID))
+			continue
+		}
+
+		assignments = append(assignments, a)
+	}
+
+	replace := c.Query("replace") == "true"
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	if replace {
+		cleared := make(map[string]bool)
+		for _, a := range assignments {
+			if cleared[a.resourceID] {
+				continue
+			}
+			log.Printf("Replacing middleware assignments for resource %s", a.resourceID)
+			_, txErr = tx.Exec("DELETE FROM resource_middlewares WHERE resource_id = ?", a.resourceID)
+			if txErr != nil {
+				log.Printf("Error removing existing assignments: %v", txErr)
+				ResponseWithError(c, http.StatusInternalServerError, "Database error")
+				return
+			}
+			cleared[a.resourceID] = true
+		}
+	}
+
+	for _, a := range assignments {
+		_, txErr = tx.Exec(
+			"DELETE FROM resource_middlewares WHERE resource_id = ? AND middleware_id = ?",
+			a.resourceID, a.middlewareID,
+		)
+		if txErr != nil {
+			log.Printf("Error removing existing relationship: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		_, txErr = tx.Exec(
+			"INSERT INTO resource_middlewares (resource_id, middleware_id, priority) VALUES (?, ?, ?)",
+			a.resourceID, a.middlewareID, a.priority,
+		)
+		if txErr != nil {
+			log.Printf("Error assigning middleware: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to assign middleware")
+			return
+		}
+	}
+
+	// Commit the transaction
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Imported %d middleware assignments from CSV (%d rows skipped)", len(assignments), len(skipped))
+	c.JSON(http.StatusOK, gin.H{
+		"applied":  len(assignments),
+		"replaced": replace,
+		"skipped":  skipped,
+	})
+}
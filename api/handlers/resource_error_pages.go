@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// UpdateErrorPagesConfig sets a resource's custom error pages. They are
+// generated as an errors middleware for the resource alone, so no shared
+// middleware has to be created and assigned. An empty status list and
+// service remove the error pages.
+func (h *ConfigHandler) UpdateErrorPagesConfig(c *gin.Context) {
+	id := c.Param("id")
+
+	var input models.ErrorPages
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	input.Service = strings.TrimSpace(input.Service)
+	remove := len(input.Status) == 0 && input.Service == ""
+	if !remove {
+		if err := input.Validate(); err != nil {
+			ResponseWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var status string
+	err := h.DB.QueryRow("SELECT status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Don't allow updating disabled resources
+	if status == "disabled" {
+		ResponseWithError(c, http.StatusBadRequest, "Cannot update a disabled resource")
+		return
+	}
+
+	// A service without a provider must be one of ours, as the middleware is
+	// generated in the file provider
+	if !remove && !strings.Contains(input.Service, "@") {
+		var exists int
+		err := h.DB.QueryRow("SELECT 1 FROM services WHERE id = ?", input.Service).Scan(&exists)
+		if err == sql.ErrNoRows {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Service %s not found; use name@provider for a service defined outside Middleware Manager", input.Service))
+			return
+		} else if err != nil {
+			log.Printf("Error checking service existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	stored := ""
+	if !remove {
+		if input.Query == "" {
+			input.Query = models.DefaultErrorPagesQuery
+		}
+		encoded, err := json.Marshal(input)
+		if err != nil {
+			log.Printf("Error encoding error pages: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to encode error pages")
+			return
+		}
+		stored = string(encoded)
+	}
+
+	if _, err := h.DB.Exec(
+		"UPDATE resources SET error_pages = ?, updated_at = ? WHERE id = ?",
+		stored, time.Now(), id,
+	); err != nil {
+		log.Printf("Error updating error pages: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to update error pages")
+		return
+	}
+
+	if remove {
+		log.Printf("Removed error pages for resource %s", id)
+		c.JSON(http.StatusOK, gin.H{"id": id, "error_pages": nil})
+		return
+	}
+	log.Printf("Updated error pages for resource %s: status %s from service %s",
+		id, strings.Join(input.Status, ","), input.Service)
+	c.JSON(http.StatusOK, gin.H{
+		"id":          id,
+		"error_pages": input,
+		"middleware":  fmt.Sprintf("%s-errorpages@file", id),
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// Limits for probing a resource through Traefik
+const (
+	resourceProbeTimeout  = 10 * time.Second
+	resourceProbeBodyRead = 64 * 1024 // Bytes of the response body read for timing
+)
+
+// ProbeResource sends a real HTTP request to a resource's router so the whole
+// middleware chain (auth, redirects, headers) can be checked end to end. The
+// URL is built from the resource's host and the scheme of its entrypoints and
+// is sent to Traefik's entrypoint address when one is known; redirects are
+// reported rather than followed.
+func (h *ResourceHandler) ProbeResource(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+
+	var request struct {
+		Method   string            `json:"method"`
+		Path     string            `json:"path"`
+		Headers  map[string]string `json:"headers"`
+		Address  string            `json:"address"`  // Optional host:port to connect to; must be Traefik's entrypoint address
+		Insecure bool              `json:"insecure"` // Skip TLS certificate verification
+	}
+	// The body is optional; an empty one probes GET /
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+			return
+		}
+	}
+
+	var host, entrypoints, status string
+	err := h.DB.QueryRow("SELECT host, entrypoints, status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&host, &entrypoints, &status)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error fetching resource for probe: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if status != "active" {
+		ResponseWithError(c, http.StatusConflict, "Resource is not active, so it has no router to probe")
+		return
+	}
+
+	scheme := "https"
+	var traefikAddress string
+	if h.ConfigGenerator != nil {
+		scheme = h.ConfigGenerator.RouterScheme(entrypoints)
+		traefikAddress = h.ConfigGenerator.EntrypointAddress(scheme)
+	}
+
+	// The probe has to go through Traefik to exercise the middlewares, so the
+	// address override can only name its entrypoint and not arbitrary hosts
+	if request.Address != "" && util.NormalizeServerAddress(request.Address) != traefikAddress {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Address %s is not Traefik's entrypoint address", request.Address))
+		return
+	}
+
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := request.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	targetURL := fmt.Sprintf("%s://%s%s", scheme, host, path)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), resourceProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, nil)
+	if err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid probe request: %v", err))
+		return
+	}
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+
+	dialer := &net.Dialer{Timeout: resourceProbeTimeout}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: request.Insecure},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// Connect to Traefik while keeping the resource's host for Host and SNI
+			if traefikAddress != "" {
+				addr = traefikAddress
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"url":        targetURL,
+			"method":     method,
+			"address":    traefikAddress,
+			"error":      err.Error(),
+			"elapsed_ms": time.Since(start).Milliseconds(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+	firstByte := time.Since(start)
+	bodyBytes, _ := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, resourceProbeBodyRead))
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":                targetURL,
+		"method":             method,
+		"address":            traefikAddress,
+		"status":             resp.StatusCode,
+		"protocol":           resp.Proto,
+		"headers":            resp.Header,
+		"location":           resp.Header.Get("Location"),
+		"time_to_headers_ms": firstByte.Milliseconds(),
+		"elapsed_ms":         time.Since(start).Milliseconds(),
+		"body_bytes_read":    bodyBytes,
+	})
+}
@@ -1,22 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+	"github.com/hhftechnology/middleware-manager/services"
 )
 
 // ResourceHandler handles resource-related requests
 type ResourceHandler struct {
-	DB *sql.DB
+	DB              *sql.DB
+	ConfigGenerator *services.ConfigGenerator
+	ResourceWatcher *services.ResourceWatcher
 }
 
 // NewResourceHandler creates a new resource handler
-func NewResourceHandler(db *sql.DB) *ResourceHandler {
-	return &ResourceHandler{DB: db}
+func NewResourceHandler(db *sql.DB, configGenerator *services.ConfigGenerator, resourceWatcher *services.ResourceWatcher) *ResourceHandler {
+	return &ResourceHandler{DB: db, ConfigGenerator: configGenerator, ResourceWatcher: resourceWatcher}
 }
 
 // GetResources returns all resources and their assigned middlewares
@@ -25,13 +35,16 @@ func (h *ResourceHandler) GetResources(c *gin.Context) {
 	rows, err := h.DB.Query(`
 		SELECT r.id, r.host, r.service_id, r.org_id, r.site_id, r.status, 
 		       r.entrypoints, r.tls_domains, r.tcp_enabled, r.tcp_entrypoints, r.tcp_sni_rule,
-		       r.custom_headers, r.router_priority, r.source_type,
-		       GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares
+		       r.custom_headers, r.router_priority, r.source_type, COALESCE(r.origin, ''), COALESCE(r.skip_auth, 0), COALESCE(r.tls_auto_domains, 0),
+		       GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares,
+		       (SELECT COUNT(*) FROM resource_status_transitions t
+		        WHERE t.resource_id = r.id AND t.changed_at >= ?) as flap_count
 		FROM resources r
 		LEFT JOIN resource_middlewares rm ON r.id = rm.resource_id
-		LEFT JOIN middlewares m ON rm.middleware_id = m.id
+		LEFT JOIN middlewares m ON rm.middleware_id = m.id AND m.deleted_at IS NULL
+		WHERE r.deleted_at IS NULL
 		GROUP BY r.id
-	`)
+	`, time.Now().Add(-services.FlapWindow()))
 	if err != nil {
 		log.Printf("Error fetching resources: %v", err)
 		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch resources")
@@ -41,15 +54,15 @@ func (h *ResourceHandler) GetResources(c *gin.Context) {
 
 	var resources []map[string]interface{}
 	for rows.Next() {
-		var id, host, serviceID, orgID, siteID, status, entrypoints, tlsDomains, tcpEntrypoints, tcpSNIRule, customHeaders, sourceType string
-		var tcpEnabled int
+		var id, host, serviceID, orgID, siteID, status, entrypoints, tlsDomains, tcpEntrypoints, tcpSNIRule, customHeaders, sourceType, origin string
+		var tcpEnabled, skipAuth, tlsAutoDomains, flapCount int
 		var routerPriority sql.NullInt64
 		var middlewares sql.NullString
 		
 		// Fixed scan operation to match the exact order and number of columns in the query
 		if err := rows.Scan(&id, &host, &serviceID, &orgID, &siteID, &status, 
 				&entrypoints, &tlsDomains, &tcpEnabled, &tcpEntrypoints, &tcpSNIRule, 
-				&customHeaders, &routerPriority, &sourceType, &middlewares); err != nil {
+				&customHeaders, &routerPriority, &sourceType, &origin, &skipAuth, &tlsAutoDomains, &middlewares, &flapCount); err != nil {
 			log.Printf("Error scanning resource row: %v", err)
 			continue
 		}
@@ -61,20 +74,24 @@ func (h *ResourceHandler) GetResources(c *gin.Context) {
 		}
 		
 		resource := map[string]interface{}{
-			"id":              id,
-			"host":            host,
-			"service_id":      serviceID,
-			"org_id":          orgID,
-			"site_id":         siteID,
-			"status":          status,
-			"entrypoints":     entrypoints,
-			"tls_domains":     tlsDomains,
-			"tcp_enabled":     tcpEnabled > 0,
-			"tcp_entrypoints": tcpEntrypoints,
-			"tcp_sni_rule":    tcpSNIRule,
-			"custom_headers":  customHeaders,
-			"router_priority": priority,
-			"source_type":     sourceType, // Make sure this is included in the returned resource
+			"id":               id,
+			"host":             host,
+			"service_id":       serviceID,
+			"org_id":           orgID,
+			"site_id":          siteID,
+			"status":           status,
+			"entrypoints":      entrypoints,
+			"tls_domains":      tlsDomains,
+			"tls_auto_domains": tlsAutoDomains > 0,
+			"tcp_enabled":      tcpEnabled > 0,
+			"tcp_entrypoints":  tcpEntrypoints,
+			"tcp_sni_rule":     tcpSNIRule,
+			"custom_headers":   customHeaders,
+			"router_priority":  priority,
+			"source_type":      sourceType, // Make sure this is included in the returned resource
+			"origin":           origin,
+			"skip_auth":        skipAuth > 0,
+			"flap_count":       flapCount,
 		}
 		
 		if middlewares.Valid {
@@ -104,24 +121,26 @@ func (h *ResourceHandler) GetResource(c *gin.Context) {
         return
     }
 
-    var host, serviceID, orgID, siteID, status, entrypoints, tlsDomains, tcpEntrypoints, tcpSNIRule, customHeaders, sourceType string
-    var tcpEnabled int
+    var host, serviceID, orgID, siteID, status, entrypoints, tlsDomains, tcpEntrypoints, tcpSNIRule, customHeaders, errorPages, sourceType, origin string
+    var tcpEnabled, skipAuth, tlsAutoDomains, flapCount int
     var routerPriority sql.NullInt64
     var middlewares sql.NullString
 
     err := h.DB.QueryRow(`
         SELECT r.host, r.service_id, r.org_id, r.site_id, r.status,
                r.entrypoints, r.tls_domains, r.tcp_enabled, r.tcp_entrypoints, r.tcp_sni_rule,
-               r.custom_headers, r.router_priority, r.source_type,
-               GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares
+               r.custom_headers, COALESCE(r.error_pages, ''), r.router_priority, r.source_type, COALESCE(r.origin, ''), COALESCE(r.skip_auth, 0), COALESCE(r.tls_auto_domains, 0),
+               GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares,
+               (SELECT COUNT(*) FROM resource_status_transitions t
+                WHERE t.resource_id = r.id AND t.changed_at >= ?) as flap_count
         FROM resources r
         LEFT JOIN resource_middlewares rm ON r.id = rm.resource_id
-        LEFT JOIN middlewares m ON rm.middleware_id = m.id
-        WHERE r.id = ?
+        LEFT JOIN middlewares m ON rm.middleware_id = m.id AND m.deleted_at IS NULL
+        WHERE r.id = ? AND r.deleted_at IS NULL
         GROUP BY r.id
-    `, id).Scan(&host, &serviceID, &orgID, &siteID, &status, 
+    `, time.Now().Add(-services.FlapWindow()), id).Scan(&host, &serviceID, &orgID, &siteID, &status, 
             &entrypoints, &tlsDomains, &tcpEnabled, &tcpEntrypoints, &tcpSNIRule, 
-            &customHeaders, &routerPriority, &sourceType, &middlewares)
+            &customHeaders, &errorPages, &routerPriority, &sourceType, &origin, &skipAuth, &tlsAutoDomains, &middlewares, &flapCount)
 
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Resource not found: %s", id))
@@ -139,20 +158,25 @@ func (h *ResourceHandler) GetResource(c *gin.Context) {
     }
 
     resource := map[string]interface{}{
-        "id":              id,
-        "host":            host,
-        "service_id":      serviceID,
-        "org_id":          orgID,
-        "site_id":         siteID,
-        "status":          status,
-        "entrypoints":     entrypoints,
-        "tls_domains":     tlsDomains,
-        "tcp_enabled":     tcpEnabled > 0,
-        "tcp_entrypoints": tcpEntrypoints,
-        "tcp_sni_rule":    tcpSNIRule,
-        "custom_headers":  customHeaders,
-        "router_priority": priority,
-        "source_type":     sourceType, // Make sure this is included
+        "id":               id,
+        "host":             host,
+        "service_id":       serviceID,
+        "org_id":           orgID,
+        "site_id":          siteID,
+        "status":           status,
+        "entrypoints":      entrypoints,
+        "tls_domains":      tlsDomains,
+        "tls_auto_domains": tlsAutoDomains > 0,
+        "tcp_enabled":      tcpEnabled > 0,
+        "tcp_entrypoints":  tcpEntrypoints,
+        "tcp_sni_rule":     tcpSNIRule,
+        "custom_headers":   customHeaders,
+        "error_pages":      errorPages,
+        "router_priority":  priority,
+        "source_type":      sourceType, // Make sure this is included
+        "origin":           origin,
+        "skip_auth":        skipAuth > 0,
+        "flap_count":       flapCount,
     }
 
     if middlewares.Valid {
@@ -161,10 +185,172 @@ func (h *ResourceHandler) GetResource(c *gin.Context) {
         resource["middlewares"] = ""
     }
 
+    // The middlewares string above is kept for existing clients; this is the
+    // parsed chain in the order the generator applies it
+    if h.ConfigGenerator != nil {
+        chain, err := h.ConfigGenerator.EffectiveMiddlewares(id)
+        if err != nil {
+            log.Printf("Error building effective middlewares for resource %s: %v", id, err)
+        } else {
+            resource["effective_middlewares"] = chain
+        }
+    }
+
     c.JSON(http.StatusOK, resource)
 }
 
-// DeleteResource deletes a resource from the database
+// CreateResource creates a manually-managed resource that is not tied to a data source
+func (h *ResourceHandler) CreateResource(c *gin.Context) {
+	var input struct {
+		ID              string `json:"id"`
+		Host            string `json:"host" binding:"required"`
+		ServiceID       string `json:"service_id" binding:"required"`
+		CustomServiceID string `json:"custom_service_id"`
+		OrgID           string `json:"org_id"`
+		SiteID          string `json:"site_id"`
+		Entrypoints     string `json:"entrypoints"`
+		TLSDomains      string `json:"tls_domains"`
+		TLSAutoDomains  bool   `json:"tls_auto_domains"`
+		TCPEnabled      bool   `json:"tcp_enabled"`
+		TCPEntrypoints  string `json:"tcp_entrypoints"`
+		TCPSNIRule      string `json:"tcp_sni_rule"`
+		RouterPriority  *int   `json:"router_priority"`
+		SkipAuth        bool   `json:"skip_auth"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Provider suffixes are reserved for resources discovered from a data source
+	if strings.Contains(input.ID, "@") {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID must not contain '@'")
+		return
+	}
+
+	if input.ID == "" {
+		suffix, err := generateID()
+		if err != nil {
+			log.Printf("Error generating ID: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+			return
+		}
+		input.ID = fmt.Sprintf("manual-%s", suffix)
+	}
+
+	// Apply defaults
+	if input.Entrypoints == "" {
+		input.Entrypoints = "websecure"
+	}
+	if input.TCPEntrypoints == "" {
+		input.TCPEntrypoints = "tcp"
+	}
+	routerPriority := 100
+	if input.RouterPriority != nil {
+		routerPriority = *input.RouterPriority
+	}
+
+	// Make sure the ID isn't taken
+	var exists int
+	err := h.DB.QueryRow("SELECT 1 FROM resources WHERE id = ?", input.ID).Scan(&exists)
+	if err == nil {
+		ResponseWithError(c, http.StatusConflict, fmt.Sprintf("Resource %s already exists", input.ID))
+		return
+	} else if err != sql.ErrNoRows {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Verify the custom service exists if one was given
+	if input.CustomServiceID != "" {
+		err := h.DB.QueryRow("SELECT 1 FROM services WHERE id = ?", input.CustomServiceID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			ResponseWithError(c, http.StatusNotFound, "Service not found")
+			return
+		} else if err != nil {
+			log.Printf("Error checking service existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	log.Printf("Creating manual resource %s with host %s", input.ID, input.Host)
+
+	_, txErr = tx.Exec(`
+		INSERT INTO resources (
+			id, host, service_id, org_id, site_id, status,
+			entrypoints, tls_domains, tls_auto_domains, tcp_enabled, tcp_entrypoints, tcp_sni_rule,
+			router_priority, skip_auth, origin, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, 'active', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, input.ID, input.Host, input.ServiceID, input.OrgID, input.SiteID,
+		input.Entrypoints, input.TLSDomains, input.TLSAutoDomains, input.TCPEnabled, input.TCPEntrypoints, input.TCPSNIRule,
+		routerPriority, input.SkipAuth, models.ResourceOriginManual, time.Now(), time.Now())
+	if txErr != nil {
+		log.Printf("Error inserting resource: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to create resource")
+		return
+	}
+
+	if input.CustomServiceID != "" {
+		_, txErr = tx.Exec(
+			"INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)",
+			input.ID, input.CustomServiceID,
+		)
+		if txErr != nil {
+			log.Printf("Error assigning service to resource: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to create resource")
+			return
+		}
+	}
+
+	// Commit the transaction
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Successfully created manual resource %s", input.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":                input.ID,
+		"host":              input.Host,
+		"service_id":        input.ServiceID,
+		"custom_service_id": input.CustomServiceID,
+		"entrypoints":       input.Entrypoints,
+		"tls_domains":       input.TLSDomains,
+		"tls_auto_domains":  input.TLSAutoDomains,
+		"tcp_enabled":       input.TCPEnabled,
+		"tcp_entrypoints":   input.TCPEntrypoints,
+		"tcp_sni_rule":      input.TCPSNIRule,
+		"router_priority":   routerPriority,
+		"skip_auth":         input.SkipAuth,
+		"status":            "active",
+		"origin":            models.ResourceOriginManual,
+	})
+}
+
+// DeleteResource moves a resource to the trash. It keeps its configuration
+// and assignments until it is restored or purged. Resources from a data
+// source must be disabled first; manual ones (created or cloned through the
+// API) are never disabled by the watcher, so they can be deleted at any time.
 func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -172,9 +358,9 @@ func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 		return
 	}
 
-	// Check if resource exists and its status
-	var status string
-	err := h.DB.QueryRow("SELECT status FROM resources WHERE id = ?", id).Scan(&status)
+	// Check if resource exists, its status and where it came from
+	var status, origin string
+	err := h.DB.QueryRow("SELECT status, COALESCE(origin, '') FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&status, &origin)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Resource not found")
 		return
@@ -184,20 +370,94 @@ func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 		return
 	}
 
-	// Only allow deletion of disabled resources
-	if status != "disabled" {
-		ResponseWithError(c, http.StatusBadRequest, "Only disabled resources can be deleted")
+	// Only allow deletion of disabled resources, or of manual ones which
+	// would otherwise stay active for good
+	if status != "disabled" && origin != models.ResourceOriginManual {
+		ResponseWithError(c, http.StatusBadRequest, "Only disabled or manually created resources can be deleted")
+		return
+	}
+
+	// Move the resource to the trash; its middleware, service and policy
+	// group assignments stay so restoring it brings them back
+	log.Printf("Moving resource %s to the trash", id)
+	result, err := h.DB.Exec(
+		"UPDATE resources SET deleted_at = CURRENT_TIMESTAMP, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now(), id,
+	)
+	if err != nil {
+		log.Printf("Error deleting resource: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to delete resource")
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
 		return
 	}
+	
+	if rowsAffected == 0 {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	log.Printf("Successfully moved resource %s to the trash", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Resource moved to the trash"})
+}
+
+// CloneResource copies a resource's configuration, middlewares and service
+// assignment to a new manually-managed resource with a different host. Like
+// other manual resources, the clone can be deleted at any time, e.g. once a
+// staging copy is no longer needed.
+func (h *ResourceHandler) CloneResource(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+
+	var input struct {
+		Host string `json:"host" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Verify resource exists
+	var exists int
+	err := h.DB.QueryRow("SELECT 1 FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	suffix, err := generateID()
+	if err != nil {
+		log.Printf("Error generating ID: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+		return
+	}
+	// Drop any provider suffix so the clone isn't mistaken for a provider resource
+	baseID := id
+	if idx := strings.Index(baseID, "@"); idx > 0 {
+		baseID = baseID[:idx]
+	}
+	newID := fmt.Sprintf("%s-clone-%s", baseID, suffix[:8])
 
-	// Delete the resource using a transaction
 	tx, err := h.DB.Begin()
 	if err != nil {
 		log.Printf("Error beginning transaction: %v", err)
 		ResponseWithError(c, http.StatusInternalServerError, "Database error")
 		return
 	}
-	
+
 	// If something goes wrong, rollback
 	var txErr error
 	defer func() {
@@ -206,48 +466,189 @@ func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 			log.Printf("Transaction rolled back due to error: %v", txErr)
 		}
 	}()
-	
-	// First delete any middleware relationships
-	log.Printf("Removing middleware relationships for resource %s", id)
-	_, txErr = tx.Exec("DELETE FROM resource_middlewares WHERE resource_id = ?", id)
+
+	log.Printf("Cloning resource %s to %s with host %s", id, newID, input.Host)
+
+	// Copy the resource row, overriding id, host, status and origin. The
+	// original host in a custom SNI rule (HostSNI(`host`), char(96) being a
+	// backtick) becomes the clone's host, so the clone doesn't match the
+	// original's TCP traffic.
+	_, txErr = tx.Exec(`
+		INSERT INTO resources (
+			id, host, service_id, org_id, site_id, status,
+			entrypoints, tls_domains, tls_auto_domains, tcp_enabled, tcp_entrypoints, tcp_sni_rule,
+			custom_headers, error_pages, router_priority, source_type, skip_auth, origin, created_at, updated_at
+		)
+		SELECT ?, ?, service_id, org_id, site_id, 'active',
+			entrypoints, tls_domains, tls_auto_domains, tcp_enabled, tcp_entrypoints,
+			REPLACE(COALESCE(tcp_sni_rule, ''), char(96) || host || char(96), char(96) || ? || char(96)),
+			custom_headers, error_pages, router_priority, source_type, skip_auth, ?, ?, ?
+		FROM resources WHERE id = ?
+	`, newID, input.Host, input.Host, models.ResourceOriginManual, time.Now(), time.Now(), id)
 	if txErr != nil {
-		log.Printf("Error removing resource middlewares: %v", txErr)
-		ResponseWithError(c, http.StatusInternalServerError, "Failed to delete resource")
+		log.Printf("Error cloning resource: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to clone resource")
 		return
 	}
-	
-	// Then delete the resource
-	log.Printf("Deleting resource %s", id)
-	result, txErr := tx.Exec("DELETE FROM resources WHERE id = ?", id)
+
+	// Copy middleware assignments
+	_, txErr = tx.Exec(`
+		INSERT INTO resource_middlewares (resource_id, middleware_id, priority)
+		SELECT ?, middleware_id, priority FROM resource_middlewares WHERE resource_id = ?
+	`, newID, id)
 	if txErr != nil {
-		log.Printf("Error deleting resource: %v", txErr)
-		ResponseWithError(c, http.StatusInternalServerError, "Failed to delete resource")
+		log.Printf("Error cloning middleware assignments: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to clone resource")
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
+	// Copy the custom service assignment
+	_, txErr = tx.Exec(`
+		INSERT INTO resource_services (resource_id, service_id)
+		SELECT ?, service_id FROM resource_services WHERE resource_id = ?
+	`, newID, id)
+	if txErr != nil {
+		log.Printf("Error cloning service assignment: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to clone resource")
+		return
+	}
+
+	// Commit the transaction
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
 		ResponseWithError(c, http.StatusInternalServerError, "Database error")
 		return
 	}
-	
-	if rowsAffected == 0 {
+
+	log.Printf("Successfully cloned resource %s to %s", id, newID)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":          newID,
+		"host":        input.Host,
+		"origin":      models.ResourceOriginManual,
+		"cloned_from": id,
+	})
+}
+
+// RefreshResource re-fetches a single resource from the data source and returns it
+func (h *ResourceHandler) RefreshResource(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+
+	if h.ResourceWatcher == nil {
+		ResponseWithError(c, http.StatusServiceUnavailable, "Resource watcher not available")
+		return
+	}
+
+	// Verify resource exists
+	var exists int
+	err := h.DB.QueryRow("SELECT 1 FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists)
+	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Resource not found")
 		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
 	}
-	
-	log.Printf("Delete affected %d rows", rowsAffected)
-	
-	// Commit the transaction
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.ResourceWatcher.RefreshResource(ctx, id); err != nil {
+		if errors.Is(err, services.ErrResourceNotInDataSource) {
+			ResponseWithError(c, http.StatusNotFound, "Resource not found in data source")
+			return
+		}
+		log.Printf("Error refreshing resource %s: %v", id, err)
+		ResponseWithError(c, http.StatusBadGateway, fmt.Sprintf("Failed to refresh resource: %v", err))
+		return
+	}
+
+	h.GetResource(c)
+}
+
+// ResetResource clears a resource's router customizations (custom headers, error pages, TLS
+// domains, TCP routing, priority and entrypoints) back to their defaults. With
+// remove_middlewares=true its middleware assignments are removed as well.
+func (h *ResourceHandler) ResetResource(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+	removeMiddlewares := c.Query("remove_middlewares") == "true"
+
+	// Verify resource exists and is active
+	var status string
+	err := h.DB.QueryRow("SELECT status FROM resources WHERE id = ? AND deleted_at IS NULL", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Don't allow updating disabled resources
+	if status == "disabled" {
+		ResponseWithError(c, http.StatusBadRequest, "Cannot update a disabled resource")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	// Same defaults as the resources table
+	_, txErr = tx.Exec(`
+		UPDATE resources
+		SET custom_headers = '', error_pages = '', tls_domains = '', tls_auto_domains = 0, tcp_enabled = 0, tcp_entrypoints = 'tcp',
+		    tcp_sni_rule = '', router_priority = 100, entrypoints = 'websecure', updated_at = ?
+		WHERE id = ?`,
+		time.Now(), id,
+	)
+	if txErr != nil {
+		log.Printf("Error resetting resource %s: %v", id, txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to reset resource")
+		return
+	}
+
+	if removeMiddlewares {
+		result, err := tx.Exec("DELETE FROM resource_middlewares WHERE resource_id = ?", id)
+		if err != nil {
+			txErr = err
+			log.Printf("Error removing middlewares of resource %s: %v", id, txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to remove middlewares")
+			return
+		}
+		if removed, err := result.RowsAffected(); err == nil {
+			log.Printf("Removed %d middleware assignments from resource %s", removed, id)
+		}
+	}
+
 	if txErr = tx.Commit(); txErr != nil {
 		log.Printf("Error committing transaction: %v", txErr)
 		ResponseWithError(c, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	log.Printf("Successfully deleted resource %s", id)
-	c.JSON(http.StatusOK, gin.H{"message": "Resource deleted successfully"})
+	log.Printf("Reset configuration of resource %s to defaults", id)
+	h.GetResource(c)
 }
 
 // AssignMiddleware assigns a middleware to a resource
@@ -259,14 +660,19 @@ func (h *ResourceHandler) AssignMiddleware(c *gin.Context) {
 	}
 
 	var input struct {
-		MiddlewareID string `json:"middleware_id" binding:"required"`
-		Priority     int    `json:"priority"`
+		MiddlewareID   string `json:"middleware_id"`
+		MiddlewareName string `json:"middleware_name"` // Used when middleware_id is empty
+		Priority       int    `json:"priority"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
 		return
 	}
+	if input.MiddlewareID == "" && input.MiddlewareName == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Either middleware_id or middleware_name is required")
+		return
+	}
 
 	// Default priority is 100 if not specified
 	if input.Priority <= 0 {
@@ -276,7 +682,7 @@ func (h *ResourceHandler) AssignMiddleware(c *gin.Context) {
 	// Verify resource exists
 	var exists int
 	var status string
-	err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", resourceID).Scan(&exists, &status)
+	err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&exists, &status)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Resource not found")
 		return
@@ -292,8 +698,16 @@ func (h *ResourceHandler) AssignMiddleware(c *gin.Context) {
 		return
 	}
 
+	if input.MiddlewareID == "" {
+		id, ok := h.resolveMiddlewareName(c, input.MiddlewareName)
+		if !ok {
+			return
+		}
+		input.MiddlewareID = id
+	}
+
 	// Verify middleware exists
-	err = h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ?", input.MiddlewareID).Scan(&exists)
+	err = h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", input.MiddlewareID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Middleware not found")
 		return
@@ -367,6 +781,49 @@ func (h *ResourceHandler) AssignMiddleware(c *gin.Context) {
 	})
 }
 
+// resolveMiddlewareName looks up the ID of the middleware with the given name.
+// If there is no single match it responds with 404 or, when several
+// middlewares share the name, 409 listing their IDs, and returns false.
+func (h *ResourceHandler) resolveMiddlewareName(c *gin.Context, name string) (string, bool) {
+	rows, err := h.DB.Query("SELECT id FROM middlewares WHERE name = ? AND deleted_at IS NULL ORDER BY id", name)
+	if err != nil {
+		log.Printf("Error looking up middleware by name: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return "", false
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Error scanning middleware ID: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return "", false
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error looking up middleware by name: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return "", false
+	}
+
+	switch len(ids) {
+	case 0:
+		ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("No middleware named %q", name))
+		return "", false
+	case 1:
+		return ids[0], true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"code":       http.StatusConflict,
+		"message":    fmt.Sprintf("%d middlewares are named %q, use middleware_id instead", len(ids), name),
+		"candidates": ids,
+	})
+	return "", false
+}
+
 // AssignMultipleMiddlewares assigns multiple middlewares to a resource in one operation
 func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
     resourceID := c.Param("id")
@@ -377,8 +834,9 @@ func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
 
     var input struct {
         Middlewares []struct {
-            MiddlewareID string `json:"middleware_id" binding:"required"`
-            Priority     int    `json:"priority"`
+            MiddlewareID   string `json:"middleware_id"`
+            MiddlewareName string `json:"middleware_name"` // Used when middleware_id is empty
+            Priority       int    `json:"priority"`
         } `json:"middlewares" binding:"required"`
     }
 
@@ -386,11 +844,17 @@ func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
         ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
         return
     }
+    for _, mw := range input.Middlewares {
+        if mw.MiddlewareID == "" && mw.MiddlewareName == "" {
+            ResponseWithError(c, http.StatusBadRequest, "Each middleware needs a middleware_id or middleware_name")
+            return
+        }
+    }
 
     // Verify resource exists and is active
     var exists int
     var status string
-    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", resourceID).Scan(&exists, &status)
+    err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&exists, &status)
     if err == sql.ErrNoRows {
         ResponseWithError(c, http.StatusNotFound, "Resource not found")
         return
@@ -406,6 +870,17 @@ func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
         return
     }
 
+    // Resolve names up front so an ambiguous one fails the request before anything is written
+    for i, mw := range input.Middlewares {
+        if mw.MiddlewareID == "" {
+            id, ok := h.resolveMiddlewareName(c, mw.MiddlewareName)
+            if !ok {
+                return
+            }
+            input.Middlewares[i].MiddlewareID = id
+        }
+    }
+
     // Start a transaction
     tx, err := h.DB.Begin()
     if err != nil {
@@ -435,7 +910,7 @@ func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
 
         // Verify middleware exists
         var middlewareExists int
-        err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ?", mw.MiddlewareID).Scan(&middlewareExists)
+        err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", mw.MiddlewareID).Scan(&middlewareExists)
         if err == sql.ErrNoRows {
             // Skip this middleware but don't fail the entire request
             log.Printf("Middleware %s not found, skipping", mw.MiddlewareID)
@@ -499,6 +974,219 @@ func (h *ResourceHandler) AssignMultipleMiddlewares(c *gin.Context) {
     })
 }
 
+// PreviewMiddlewareAssignment returns the diff of a resource's generated routers
+// for a proposed middleware assignment without persisting anything
+func (h *ResourceHandler) PreviewMiddlewareAssignment(c *gin.Context) {
+	resourceID := c.Param("id")
+	if resourceID == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+
+	var input struct {
+		Middlewares []struct {
+			MiddlewareID string `json:"middleware_id" binding:"required"`
+			Priority     int    `json:"priority"`
+		} `json:"middlewares" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	if h.ConfigGenerator == nil {
+		ResponseWithError(c, http.StatusServiceUnavailable, "Config generator not available")
+		return
+	}
+
+	// Verify resource exists and is active
+	var exists int
+	var status string
+	err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&exists, &status)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if status == "disabled" {
+		ResponseWithError(c, http.StatusBadRequest, "Cannot assign middlewares to a disabled resource")
+		return
+	}
+
+	proposed := make([]services.MiddlewareWithPriority, 0, len(input.Middlewares))
+	for _, mw := range input.Middlewares {
+		// Default priority is 100 if not specified
+		if mw.Priority <= 0 {
+			mw.Priority = 100
+		}
+
+		var middlewareExists int
+		err := h.DB.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", mw.MiddlewareID).Scan(&middlewareExists)
+		if err == sql.ErrNoRows {
+			ResponseWithError(c, http.StatusNotFound, fmt.Sprintf("Middleware not found: %s", mw.MiddlewareID))
+			return
+		} else if err != nil {
+			log.Printf("Error checking middleware existence: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		proposed = append(proposed, services.MiddlewareWithPriority{
+			ID:       mw.MiddlewareID,
+			Priority: mw.Priority,
+		})
+	}
+
+	before, after, err := h.ConfigGenerator.PreviewMiddlewareAssignment(resourceID, proposed)
+	if err != nil {
+		log.Printf("Error previewing middleware assignment for resource %s: %v", resourceID, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate router preview")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resource_id": resourceID,
+		"before":      before,
+		"after":       after,
+		"changes":     diffRouters(before, after),
+	})
+}
+
+// PreviewServiceAssignment shows how a resource's routers would change if the
+// given custom service were assigned to it, including the provider suffix of
+// the service reference, without persisting anything
+func (h *ResourceHandler) PreviewServiceAssignment(c *gin.Context) {
+	resourceID := c.Param("id")
+	if resourceID == "" {
+		ResponseWithError(c, http.StatusBadRequest, "Resource ID is required")
+		return
+	}
+
+	var input struct {
+		ServiceID string `json:"service_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	if h.ConfigGenerator == nil {
+		ResponseWithError(c, http.StatusServiceUnavailable, "Config generator not available")
+		return
+	}
+
+	// Verify resource exists and is active
+	var status string
+	err := h.DB.QueryRow("SELECT status FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&status)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Resource not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking resource existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if status == "disabled" {
+		ResponseWithError(c, http.StatusBadRequest, "Cannot assign service to a disabled resource")
+		return
+	}
+
+	// Verify service exists
+	var exists int
+	err = h.DB.QueryRow("SELECT 1 FROM services WHERE id = ?", input.ServiceID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Service not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking service existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	before, after, isHTTP, err := h.ConfigGenerator.PreviewServiceAssignment(resourceID, input.ServiceID)
+	if err != nil {
+		log.Printf("Error previewing service assignment for resource %s: %v", resourceID, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate router preview")
+		return
+	}
+
+	response := gin.H{
+		"resource_id": resourceID,
+		"before":      before,
+		"after":       after,
+		"changes":     diffRouters(before, after),
+	}
+	if !isHTTP {
+		response["warnings"] = []string{fmt.Sprintf("Service %s is not generated as an HTTP service, so the router can't use it", input.ServiceID)}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// diffRouters returns the changed fields of every router in before or after,
+// keyed by router ID. A router only in one of them has all its fields changed.
+func diffRouters(before, after map[string]interface{}) []map[string]interface{} {
+	ids := make(map[string]bool)
+	for id := range before {
+		ids[id] = true
+	}
+	for id := range after {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	changes := make([]map[string]interface{}, 0)
+	for _, id := range sorted {
+		beforeRouter, _ := before[id].(map[string]interface{})
+		afterRouter, _ := after[id].(map[string]interface{})
+		for _, change := range diffRouterConfig(beforeRouter, afterRouter) {
+			change["router"] = id
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// diffRouterConfig returns the top-level router fields that differ between two router blocks
+func diffRouterConfig(before, after map[string]interface{}) []map[string]interface{} {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	fields := make([]string, 0, len(keys))
+	for k := range keys {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	changes := make([]map[string]interface{}, 0)
+	for _, field := range fields {
+		if reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		changes = append(changes, map[string]interface{}{
+			"field":  field,
+			"before": before[field],
+			"after":  after[field],
+		})
+	}
+	return changes
+}
+
 // RemoveMiddleware removes a middleware from a resource
 func (h *ResourceHandler) RemoveMiddleware(c *gin.Context) {
     resourceID := c.Param("id")
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/database"
+	"github.com/hhftechnology/middleware-manager/services"
+)
+
+func newResourceRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	h := NewResourceHandler(newTestDB(t), nil, nil)
+	router := gin.New()
+	router.POST("/api/resources", h.CreateResource)
+	router.GET("/api/resources/:id", h.GetResource)
+	router.DELETE("/api/resources/:id", h.DeleteResource)
+	router.POST("/api/resources/:id/clone", h.CloneResource)
+	return router
+}
+
+func TestDeleteManualResource(t *testing.T) {
+	router := newResourceRouter(t)
+
+	w := doRequest(t, router, http.MethodPost, "/api/resources", `{"id": "staging", "host": "staging.example.com", "service_id": "app"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, router, http.MethodDelete, "/api/resources/staging", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(t, router, http.MethodGet, "/api/resources/staging", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: got %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteActiveDiscoveredResourceRefused(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, status)
+		VALUES ('app-router', 'app.example.com', 'app-service', 'o', 's', 'active')`); err != nil {
+		t.Fatal(err)
+	}
+	h := NewResourceHandler(db, nil, nil)
+	router := gin.New()
+	router.DELETE("/api/resources/:id", h.DeleteResource)
+
+	if w := doRequest(t, router, http.MethodDelete, "/api/resources/app-router", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("delete active discovered resource: got %d, want 400", w.Code)
+	}
+}
+
+func TestDeleteClonedResource(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, status)
+		VALUES ('app-router', 'app.example.com', 'app-service', 'o', 's', 'active')`); err != nil {
+		t.Fatal(err)
+	}
+	h := NewResourceHandler(db, nil, nil)
+	router := gin.New()
+	router.GET("/api/resources/:id", h.GetResource)
+	router.DELETE("/api/resources/:id", h.DeleteResource)
+	router.POST("/api/resources/:id/clone", h.CloneResource)
+
+	w := doRequest(t, router, http.MethodPost, "/api/resources/app-router/clone", `{"host": "staging.example.com"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("clone: got %d %s", w.Code, w.Body.String())
+	}
+	cloneID, _ := decodeJSON(t, w)["id"].(string)
+	if cloneID == "" {
+		t.Fatalf("clone response has no id: %s", w.Body.String())
+	}
+
+	if w = doRequest(t, router, http.MethodDelete, "/api/resources/"+cloneID, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete clone: got %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(t, router, http.MethodGet, "/api/resources/"+cloneID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("get clone after delete: got %d, want 404", w.Code)
+	}
+	if w = doRequest(t, router, http.MethodGet, "/api/resources/app-router", ""); w.Code != http.StatusOK {
+		t.Fatalf("source resource after deleting clone: got %d, want 200", w.Code)
+	}
+}
+
+func TestCloneResourceRewritesSNIRule(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec("INSERT INTO resources (id, host, service_id, org_id, site_id, status, tcp_enabled, tcp_sni_rule) " +
+		"VALUES ('db-router', 'db.example.com', 'db-service', 'o', 's', 'active', 1, 'HostSNI(`db.example.com`)')"); err != nil {
+		t.Fatal(err)
+	}
+	h := NewResourceHandler(db, nil, nil)
+	router := gin.New()
+	router.POST("/api/resources/:id/clone", h.CloneResource)
+
+	w := doRequest(t, router, http.MethodPost, "/api/resources/db-router/clone", `{"host": "db-staging.example.com"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("clone: got %d %s", w.Code, w.Body.String())
+	}
+	cloneID, _ := decodeJSON(t, w)["id"].(string)
+
+	var rule string
+	if err := db.QueryRow("SELECT tcp_sni_rule FROM resources WHERE id = ?", cloneID).Scan(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule != "HostSNI(`db-staging.example.com`)" {
+		t.Errorf("clone SNI rule = %q, want HostSNI(`db-staging.example.com`)", rule)
+	}
+}
+
+func TestProbeResourceGoesThroughTraefik(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, status, entrypoints)
+		VALUES ('app-router', 'app.example.com', 'app-service', 'o', 's', 'active', 'web')`); err != nil {
+		t.Fatal(err)
+	}
+	hosts := make(chan string, 1)
+	traefik := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer traefik.Close()
+
+	configGenerator := services.NewConfigGenerator(&database.DB{DB: db}, t.TempDir(), nil)
+	configGenerator.SetTLSEntrypoints([]string{"websecure"})
+	configGenerator.SetEntrypointAddress(traefik.Listener.Addr().String())
+	h := NewResourceHandler(db, configGenerator, nil)
+	router := gin.New()
+	router.POST("/api/resources/:id/probe", h.ProbeResource)
+
+	w := doRequest(t, router, http.MethodPost, "/api/resources/app-router/probe", `{"address": "169.254.169.254:80"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("probe of a foreign address: got %d, want 400: %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, router, http.MethodPost, "/api/resources/app-router/probe", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("probe: got %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if status, _ := decodeJSON(t, w)["status"].(float64); status != http.StatusUnauthorized {
+		t.Errorf("probe status = %v, want Traefik's 401", status)
+	}
+	if host := <-hosts; host != "app.example.com" {
+		t.Errorf("Traefik saw Host %q, want app.example.com", host)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bulkServiceFilter selects resources by their fields. Empty fields match
+// everything; host is a glob such as *.example.com.
+type bulkServiceFilter struct {
+	Host   string `json:"host"`
+	OrgID  string `json:"org_id"`
+	SiteID string `json:"site_id"`
+}
+
+// AssignServiceToResources assigns a custom service to many resources in one
+// transaction. Resources are given as resource_ids or selected by a filter.
+// Missing and disabled resources, and those whose routers can't use the
+// service's protocol, are skipped and reported.
+func (h *ServiceHandler) AssignServiceToResources(c *gin.Context) {
+	serviceID := c.Param("id")
+
+	var input struct {
+		ResourceIDs []string           `json:"resource_ids"`
+		Filter      *bulkServiceFilter `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if (len(input.ResourceIDs) == 0) == (input.Filter == nil) {
+		ResponseWithError(c, http.StatusBadRequest, "Provide either resource_ids or a filter")
+		return
+	}
+	if input.Filter != nil && *input.Filter == (bulkServiceFilter{}) {
+		ResponseWithError(c, http.StatusBadRequest, "Filter must set at least one of host, org_id or site_id")
+		return
+	}
+
+	var serviceType, serviceConfig string
+	err := h.DB.QueryRow("SELECT type, config FROM services WHERE id = ?", serviceID).Scan(&serviceType, &serviceConfig)
+	if err == sql.ErrNoRows {
+		ResponseWithError(c, http.StatusNotFound, "Service not found")
+		return
+	} else if err != nil {
+		log.Printf("Error checking service existence: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	resourceIDs := input.ResourceIDs
+	if input.Filter != nil {
+		resourceIDs, txErr = filterResourceIDs(tx, *input.Filter)
+		if txErr != nil {
+			log.Printf("Error selecting resources: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	assigned := []string{}
+	skipped := []gin.H{}
+	seen := make(map[string]bool)
+	for _, resourceID := range resourceIDs {
+		if seen[resourceID] {
+			continue
+		}
+		seen[resourceID] = true
+
+		var status string
+		var tcpEnabled bool
+		err := tx.QueryRow("SELECT status, COALESCE(tcp_enabled, 0) FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&status, &tcpEnabled)
+		if err == sql.ErrNoRows {
+			skipped = append(skipped, gin.H{"resource_id": resourceID, "reason": "Resource not found"})
+			continue
+		} else if err != nil {
+			txErr = err
+			log.Printf("Error checking resource existence: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		// Don't allow attaching services to disabled resources
+		if status == "disabled" {
+			skipped = append(skipped, gin.H{"resource_id": resourceID, "reason": "Cannot assign service to a disabled resource"})
+			continue
+		}
+		if problem := serviceProtocolMismatch(serviceID, serviceType, serviceConfig, tcpEnabled); problem != "" {
+			skipped = append(skipped, gin.H{"resource_id": resourceID, "reason": problem})
+			continue
+		}
+
+		if _, txErr = tx.Exec(`
+			INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)
+			ON CONFLICT(resource_id) DO UPDATE SET service_id = excluded.service_id, created_at = CURRENT_TIMESTAMP
+		`, resourceID, serviceID); txErr != nil {
+			log.Printf("Error assigning service %s to resource %s: %v", serviceID, resourceID, txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to assign service")
+			return
+		}
+		assigned = append(assigned, resourceID)
+	}
+
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Assigned service %s to %d resources (%d skipped)", serviceID, len(assigned), len(skipped))
+	c.JSON(http.StatusOK, gin.H{
+		"service_id": serviceID,
+		"assigned":   assigned,
+		"skipped":    skipped,
+	})
+}
+
+// filterResourceIDs returns the IDs of the resources matching a filter, in order
+func filterResourceIDs(tx *sql.Tx, filter bulkServiceFilter) ([]string, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	if filter.Host != "" {
+		conditions = append(conditions, "LOWER(host) GLOB ?")
+		args = append(args, strings.ToLower(filter.Host))
+	}
+	if filter.OrgID != "" {
+		conditions = append(conditions, "org_id = ?")
+		args = append(args, filter.OrgID)
+	}
+	if filter.SiteID != "" {
+		conditions = append(conditions, "site_id = ?")
+		args = append(args, filter.SiteID)
+	}
+
+	query := "SELECT id FROM resources WHERE " + strings.Join(conditions, " AND ")
+	rows, err := tx.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
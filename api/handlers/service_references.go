@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// checkServiceReferences makes sure the services a weighted service splits
+// traffic between exist, so routers don't end up pointing at a dangling
+// service. It answers 400 listing the missing ones and returns false if any
+// are missing.
+func (h *ServiceHandler) checkServiceReferences(c *gin.Context, serviceType string, config map[string]interface{}) bool {
+	missing, err := missingServiceReferences(h.DB, serviceType, config)
+	if err != nil {
+		log.Printf("Error checking service references: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code":    http.StatusBadRequest,
+		"message": fmt.Sprintf("Invalid %s config: referenced services not found: %s", serviceType, strings.Join(missing, ", ")),
+		"missing": missing,
+	})
+	return false
+}
+
+// missingServiceReferences returns the references of a weighted service that
+// don't match a service in the database. References without a provider or
+// with @file are generated by us and must exist; services of other providers
+// (e.g. whoami@docker) are defined outside Middleware Manager and aren't checked.
+func missingServiceReferences(db rowQuerier, serviceType string, config map[string]interface{}) ([]string, error) {
+	missing := []string{}
+	if serviceType != string(models.WeightedType) {
+		return missing, nil
+	}
+
+	entries, _ := config["services"].([]interface{})
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := e["name"].(string)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		suffix := util.GetProviderSuffix(name)
+		if suffix != "" && suffix != "@file" {
+			continue
+		}
+		var exists int
+		err := db.QueryRow("SELECT 1 FROM services WHERE id = ?", strings.TrimSuffix(name, suffix)).Scan(&exists)
+		if err == sql.ErrNoRows {
+			missing = append(missing, name)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
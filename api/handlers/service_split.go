@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// WeightedSplitBackend is one backend of a weighted split. An omitted weight
+// is 1, so backends without one share traffic evenly.
+type WeightedSplitBackend struct {
+	Name    string        `json:"name"`
+	Servers []interface{} `json:"servers" binding:"required"`
+	Weight  *int          `json:"weight"`
+}
+
+// CreateWeightedSplit creates a loadBalancer service for each backend and a
+// weighted service splitting traffic between them, all in one transaction.
+// Every service is processed and validated as CreateService does.
+func (h *ServiceHandler) CreateWeightedSplit(c *gin.Context) {
+	var request struct {
+		Name     string                 `json:"name" binding:"required"`
+		Backends []WeightedSplitBackend `json:"backends" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	if len(request.Backends) < 2 {
+		ResponseWithError(c, http.StatusBadRequest, "A weighted split needs at least two backends")
+		return
+	}
+
+	type childService struct {
+		id     string
+		name   string
+		config map[string]interface{}
+		weight int
+	}
+
+	children := make([]childService, 0, len(request.Backends))
+	totalWeight := 0
+	for i, backend := range request.Backends {
+		weight := 1
+		if backend.Weight != nil {
+			weight = *backend.Weight
+		}
+		if weight < 0 {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d has a negative weight", i+1))
+			return
+		}
+		totalWeight += weight
+		if len(backend.Servers) == 0 {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d has no servers", i+1))
+			return
+		}
+
+		// Servers may be given as plain URLs or as full server objects
+		servers := make([]interface{}, 0, len(backend.Servers))
+		for _, server := range backend.Servers {
+			switch s := server.(type) {
+			case string:
+				if strings.TrimSpace(s) == "" {
+					ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d has an empty server URL", i+1))
+					return
+				}
+				servers = append(servers, map[string]interface{}{"url": strings.TrimSpace(s)})
+			case map[string]interface{}:
+				if _, hasURL := s["url"]; !hasURL {
+					ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d has a server without a url", i+1))
+					return
+				}
+				servers = append(servers, s)
+			default:
+				ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d has an invalid server entry", i+1))
+				return
+			}
+		}
+
+		id, err := generateID()
+		if err != nil {
+			log.Printf("Error generating ID: %v", err)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+			return
+		}
+
+		name := backend.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-backend-%d", request.Name, i+1)
+		}
+
+		config := models.ProcessServiceConfig(string(models.LoadBalancerType), map[string]interface{}{"servers": servers})
+		if err := models.ValidateServiceConfig(string(models.LoadBalancerType), config); err != nil {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Backend %d: invalid %s config: %v", i+1, models.LoadBalancerType, err))
+			return
+		}
+		children = append(children, childService{id: id, name: name, config: config, weight: weight})
+	}
+
+	if totalWeight == 0 {
+		ResponseWithError(c, http.StatusBadRequest, "The backend weights add up to 0, so the split would send no traffic")
+		return
+	}
+
+	parentID, err := generateID()
+	if err != nil {
+		log.Printf("Error generating ID: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to generate ID")
+		return
+	}
+
+	weightedServices := make([]interface{}, 0, len(children))
+	for _, child := range children {
+		weightedServices = append(weightedServices, map[string]interface{}{
+			"name":   fmt.Sprintf("%s@file", child.id),
+			"weight": child.weight,
+		})
+	}
+	parentConfig := models.ProcessServiceConfig(string(models.WeightedType), map[string]interface{}{"services": weightedServices})
+	if err := models.ValidateServiceConfig(string(models.WeightedType), parentConfig); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s config: %v", models.WeightedType, err))
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// If something goes wrong, rollback
+	var txErr error
+	defer func() {
+		if txErr != nil {
+			tx.Rollback()
+			log.Printf("Transaction rolled back due to error: %v", txErr)
+		}
+	}()
+
+	insert := func(id, name string, typ models.ServiceType, config map[string]interface{}) error {
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to encode config for %s: %w", name, err)
+		}
+		_, err = tx.Exec(
+			"INSERT INTO services (id, name, type, config) VALUES (?, ?, ?, ?)",
+			id, name, string(typ), string(configJSON),
+		)
+		return err
+	}
+
+	backends := make([]gin.H, 0, len(children))
+	for _, child := range children {
+		if txErr = insert(child.id, child.name, models.LoadBalancerType, child.config); txErr != nil {
+			log.Printf("Error inserting backend service: %v", txErr)
+			ResponseWithError(c, http.StatusInternalServerError, "Failed to save service")
+			return
+		}
+		backends = append(backends, gin.H{"id": child.id, "name": child.name, "weight": child.weight})
+	}
+
+	if txErr = insert(parentID, request.Name, models.WeightedType, parentConfig); txErr != nil {
+		log.Printf("Error inserting weighted service: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to save service")
+		return
+	}
+
+	// Commit the transaction
+	if txErr = tx.Commit(); txErr != nil {
+		log.Printf("Error committing transaction: %v", txErr)
+		ResponseWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	log.Printf("Created weighted split %s (%s) across %d backends", request.Name, parentID, len(children))
+	c.JSON(http.StatusCreated, gin.H{
+		"id":       parentID,
+		"name":     request.Name,
+		"type":     string(models.WeightedType),
+		"config":   parentConfig,
+		"backends": backends,
+	})
+}
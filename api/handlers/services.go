@@ -3,12 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/database"
 	"github.com/hhftechnology/middleware-manager/models"
 )
 
@@ -22,9 +25,30 @@ func NewServiceHandler(db *sql.DB) *ServiceHandler {
 	return &ServiceHandler{DB: db}
 }
 
-// GetServices returns all service configurations
+// GetServices returns service configurations, optionally filtered by ?type=
+// and by a case-insensitive name search with ?q=
 func (h *ServiceHandler) GetServices(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, type, config FROM services")
+	query := "SELECT id, name, type, config, managed FROM services"
+	var conditions []string
+	var args []interface{}
+
+	if typ := c.Query("type"); typ != "" {
+		if !models.IsValidServiceType(typ) {
+			ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid service type: %s", typ))
+			return
+		}
+		conditions = append(conditions, "type = ?")
+		args = append(args, typ)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		conditions = append(conditions, "instr(LOWER(name), LOWER(?)) > 0")
+		args = append(args, search)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := h.DB.Query(query, args...)
 	if err != nil {
 		log.Printf("Error fetching services: %v", err)
 		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch services")
@@ -35,7 +59,8 @@ func (h *ServiceHandler) GetServices(c *gin.Context) {
 	services := []map[string]interface{}{}
 	for rows.Next() {
 		var id, name, typ, configStr string
-		if err := rows.Scan(&id, &name, &typ, &configStr); err != nil {
+		var managed bool
+		if err := rows.Scan(&id, &name, &typ, &configStr, &managed); err != nil {
 			log.Printf("Error scanning service row: %v", err)
 			continue
 		}
@@ -47,10 +72,11 @@ func (h *ServiceHandler) GetServices(c *gin.Context) {
 		}
 
 		services = append(services, map[string]interface{}{
-			"id":     id,
-			"name":   name,
-			"type":   typ,
-			"config": config,
+			"id":      id,
+			"name":    name,
+			"type":    typ,
+			"config":  config,
+			"managed": managed,
 		})
 	}
 
@@ -92,6 +118,13 @@ func (h *ServiceHandler) CreateService(c *gin.Context) {
 
 	// Process the service configuration based on the type
 	service.Config = models.ProcessServiceConfig(service.Type, service.Config)
+	if err := models.ValidateServiceConfig(service.Type, service.Config); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s config: %v", service.Type, err))
+		return
+	}
+	if !h.checkServiceReferences(c, service.Type, service.Config) {
+		return
+	}
 
 	// Convert config to JSON string
 	configJSON, err := json.Marshal(service.Config)
@@ -161,9 +194,8 @@ func (h *ServiceHandler) GetService(c *gin.Context) {
 		return
 	}
 
-	var name, typ, configStr string
-	err := h.DB.QueryRow("SELECT name, type, config FROM services WHERE id = ?", id).Scan(&name, &typ, &configStr)
-	if err == sql.ErrNoRows {
+	service, err := (&database.DB{DB: h.DB}).GetService(id)
+	if errors.Is(err, database.ErrNotFound) {
 		ResponseWithError(c, http.StatusNotFound, "Service not found")
 		return
 	} else if err != nil {
@@ -172,21 +204,12 @@ func (h *ServiceHandler) GetService(c *gin.Context) {
 		return
 	}
 
-	var config map[string]interface{}
-	if err := json.Unmarshal([]byte(configStr), &config); err != nil {
-		log.Printf("Error parsing service config: %v", err)
-		config = map[string]interface{}{}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"id":     id,
-		"name":   name,
-		"type":   typ,
-		"config": config,
-	})
+	c.JSON(http.StatusOK, service)
 }
 
-// UpdateService updates a service configuration
+// UpdateService updates a service configuration.
+// Editing a service marks it as unmanaged so the service watcher stops
+// overwriting it, unless the request explicitly sets "managed": true.
 func (h *ServiceHandler) UpdateService(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -195,9 +218,10 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 	}
 
 	var service struct {
-		Name   string                 `json:"name" binding:"required"`
-		Type   string                 `json:"type" binding:"required"`
-		Config map[string]interface{} `json:"config" binding:"required"`
+		Name    string                 `json:"name" binding:"required"`
+		Type    string                 `json:"type" binding:"required"`
+		Config  map[string]interface{} `json:"config" binding:"required"`
+		Managed *bool                  `json:"managed"`
 	}
 
 	if err := c.ShouldBindJSON(&service); err != nil {
@@ -211,6 +235,11 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 		return
 	}
 
+	managed := false
+	if service.Managed != nil {
+		managed = *service.Managed
+	}
+
 	// Check if service exists
 	var exists int
 	err := h.DB.QueryRow("SELECT 1 FROM services WHERE id = ?", id).Scan(&exists)
@@ -225,6 +254,13 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 
 	// Process the service configuration based on the type
 	service.Config = models.ProcessServiceConfig(service.Type, service.Config)
+	if err := models.ValidateServiceConfig(service.Type, service.Config); err != nil {
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s config: %v", service.Type, err))
+		return
+	}
+	if !h.checkServiceReferences(c, service.Type, service.Config) {
+		return
+	}
 
 	// Convert config to JSON string
 	configJSON, err := json.Marshal(service.Config)
@@ -255,8 +291,8 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 		id, service.Name, service.Type)
 	
 	result, txErr := tx.Exec(
-		"UPDATE services SET name = ?, type = ?, config = ?, updated_at = ? WHERE id = ?",
-		service.Name, service.Type, string(configJSON), time.Now(), id,
+		"UPDATE services SET name = ?, type = ?, config = ?, managed = ?, updated_at = ? WHERE id = ?",
+		service.Name, service.Type, string(configJSON), managed, time.Now(), id,
 	)
 	
 	if txErr != nil {
@@ -293,10 +329,11 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 
 	// Return the updated service
 	c.JSON(http.StatusOK, gin.H{
-		"id":     id,
-		"name":   service.Name,
-		"type":   service.Type,
-		"config": service.Config,
+		"id":      id,
+		"name":    service.Name,
+		"type":    service.Type,
+		"config":  service.Config,
+		"managed": managed,
 	})
 }
 
@@ -391,9 +428,9 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 	}
 
 	// Verify resource exists
-	var exists int
 	var status string
-	err := h.DB.QueryRow("SELECT 1, status FROM resources WHERE id = ?", resourceID).Scan(&exists, &status)
+	var tcpEnabled bool
+	err := h.DB.QueryRow("SELECT status, COALESCE(tcp_enabled, 0) FROM resources WHERE id = ? AND deleted_at IS NULL", resourceID).Scan(&status, &tcpEnabled)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Resource not found")
 		return
@@ -410,7 +447,8 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 	}
 
 	// Verify service exists
-	err = h.DB.QueryRow("SELECT 1 FROM services WHERE id = ?", input.ServiceID).Scan(&exists)
+	var serviceType, serviceConfig string
+	err = h.DB.QueryRow("SELECT type, config FROM services WHERE id = ?", input.ServiceID).Scan(&serviceType, &serviceConfig)
 	if err == sql.ErrNoRows {
 		ResponseWithError(c, http.StatusNotFound, "Service not found")
 		return
@@ -420,6 +458,11 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 		return
 	}
 
+	if problem := serviceProtocolMismatch(input.ServiceID, serviceType, serviceConfig, tcpEnabled); problem != "" {
+		ResponseWithError(c, http.StatusBadRequest, problem)
+		return
+	}
+
 	// Insert or update the resource service relationship using a transaction
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -437,25 +480,14 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 		}
 	}()
 	
-	// First delete any existing relationship
-	log.Printf("Removing existing service relationship: resource=%s", resourceID)
-	_, txErr = tx.Exec(
-		"DELETE FROM resource_services WHERE resource_id = ?",
-		resourceID,
-	)
-	if txErr != nil {
-		log.Printf("Error removing existing relationship: %v", txErr)
-		ResponseWithError(c, http.StatusInternalServerError, "Database error")
-		return
-	}
-	
-	// Then insert the new relationship
-	log.Printf("Creating new service relationship: resource=%s, service=%s",
+	// Upsert the relationship. resource_id is unique, so concurrent assigns
+	// to the same resource can't leave more than one row; the last one wins.
+	log.Printf("Setting service relationship: resource=%s, service=%s",
 		resourceID, input.ServiceID)
-	result, txErr := tx.Exec(
-		"INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)",
-		resourceID, input.ServiceID,
-	)
+	result, txErr := tx.Exec(`
+		INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)
+		ON CONFLICT(resource_id) DO UPDATE SET service_id = excluded.service_id, created_at = CURRENT_TIMESTAMP
+	`, resourceID, input.ServiceID)
 	
 	if txErr != nil {
 		log.Printf("Error assigning service: %v", txErr)
@@ -465,7 +497,7 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 	
 	rowsAffected, err := result.RowsAffected()
 	if err == nil {
-		log.Printf("Insert affected %d rows", rowsAffected)
+		log.Printf("Upsert affected %d rows", rowsAffected)
 	}
 	
 	// Commit the transaction
@@ -483,6 +515,27 @@ func (h *ServiceHandler) AssignServiceToResource(c *gin.Context) {
 	})
 }
 
+// serviceProtocolMismatch describes why a service can't be used by a
+// resource's routers, or returns an empty string. Resources with tcp_enabled
+// get a TCP router that uses the custom service, so it must be a TCP service;
+// their HTTP router keeps the upstream service. Other resources only have an
+// HTTP router.
+func serviceProtocolMismatch(serviceID, serviceType, configStr string, tcpEnabled bool) string {
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+		// Unreadable configs are reported by the generator, not here
+		return ""
+	}
+	protocol := models.ServiceProtocol(serviceType, config)
+	switch {
+	case tcpEnabled && protocol != "tcp":
+		return fmt.Sprintf("Service %s is an %s service, but the resource has TCP routing enabled; assign a TCP service (servers with an address)", serviceID, strings.ToUpper(protocol))
+	case !tcpEnabled && protocol != "http":
+		return fmt.Sprintf("Service %s is a %s service, but the resource only has an HTTP router; assign an HTTP service (servers with a url) or enable TCP routing", serviceID, strings.ToUpper(protocol))
+	}
+	return ""
+}
+
 // RemoveServiceFromResource removes a service from a resource
 func (h *ServiceHandler) RemoveServiceFromResource(c *gin.Context) {
 	resourceID := c.Param("id")
@@ -555,9 +608,10 @@ func (h *ServiceHandler) GetResourceService(c *gin.Context) {
 		return
 	}
 
-	var serviceID string
-	err := h.DB.QueryRow("SELECT service_id FROM resource_services WHERE resource_id = ?", resourceID).Scan(&serviceID)
-	if err == sql.ErrNoRows {
+	// Both a missing assignment and an assignment to a deleted service are
+	// reported as not found
+	service, err := (&database.DB{DB: h.DB}).GetResourceService(resourceID)
+	if errors.Is(err, database.ErrNotFound) {
 		ResponseWithError(c, http.StatusNotFound, "No service assigned to this resource")
 		return
 	} else if err != nil {
@@ -566,31 +620,8 @@ func (h *ServiceHandler) GetResourceService(c *gin.Context) {
 		return
 	}
 
-	// Get service details
-	var name, typ, configStr string
-	err = h.DB.QueryRow("SELECT name, type, config FROM services WHERE id = ?", serviceID).Scan(&name, &typ, &configStr)
-	if err == sql.ErrNoRows {
-		ResponseWithError(c, http.StatusNotFound, "Service not found")
-		return
-	} else if err != nil {
-		log.Printf("Error fetching service: %v", err)
-		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch service")
-		return
-	}
-
-	var config map[string]interface{}
-	if err := json.Unmarshal([]byte(configStr), &config); err != nil {
-		log.Printf("Error parsing service config: %v", err)
-		config = map[string]interface{}{}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"resource_id": resourceID,
-		"service": gin.H{
-			"id":     serviceID,
-			"name":   name,
-			"type":   typ,
-			"config": config,
-		},
+		"service":     service,
 	})
 }
\ No newline at end of file
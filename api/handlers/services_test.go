@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newServiceRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	db := newTestDB(t)
+	if _, err := db.Exec(`INSERT INTO services (id, name, type, config) VALUES ('web', 'web', 'loadBalancer', '{"servers":[{"url":"http://web:80"}]}')`); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO resource_services (resource_id, service_id) VALUES ('dangling', 'gone')`); err != nil {
+		t.Fatalf("insert assignment: %v", err)
+	}
+	h := NewServiceHandler(db)
+	router := gin.New()
+	router.GET("/api/services/:id", h.GetService)
+	router.GET("/api/resources/:id/service", h.GetResourceService)
+	router.POST("/api/services/weighted-split", h.CreateWeightedSplit)
+	return router
+}
+
+func TestGetServiceNotFound(t *testing.T) {
+	router := newServiceRouter(t)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/services/web", http.StatusOK},
+		{"/api/services/missing", http.StatusNotFound},
+		{"/api/resources/unassigned/service", http.StatusNotFound},
+		{"/api/resources/dangling/service", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := doRequest(t, router, http.MethodGet, tt.path, "")
+		if w.Code != tt.status {
+			t.Errorf("GET %s: got %d, want %d: %s", tt.path, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
+
+func TestCreateWeightedSplitValidation(t *testing.T) {
+	router := newServiceRouter(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"weights add up to 0", `{"name": "canary", "backends": [{"servers": ["http://a:80"], "weight": 0}, {"servers": ["http://b:80"], "weight": 0}]}`, http.StatusBadRequest},
+		{"server without url", `{"name": "canary", "backends": [{"servers": [{"weight": 1}]}, {"servers": ["http://b:80"]}]}`, http.StatusBadRequest},
+		{"valid split", `{"name": "canary", "backends": [{"servers": ["http://a:80"], "weight": 9}, {"servers": ["http://b:80"], "weight": 0}]}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(t, router, http.MethodPost, "/api/services/weighted-split", tt.body)
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashHandler lists and restores deleted resources and middlewares. Deleting
+// only marks them, and they stay in the trash until restored or purged by the
+// cleanup once TRASH_RETENTION_DAYS have passed.
+type TrashHandler struct {
+	DB *sql.DB
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(db *sql.DB) *TrashHandler {
+	return &TrashHandler{DB: db}
+}
+
+// GetTrash lists the deleted resources and middlewares, most recent first
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	resources := []gin.H{}
+	rows, err := h.DB.Query(`
+		SELECT id, host, status, deleted_at FROM resources
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id
+	`)
+	if err != nil {
+		log.Printf("Error fetching deleted resources: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, host, status string
+		var deletedAt time.Time
+		if err := rows.Scan(&id, &host, &status, &deletedAt); err != nil {
+			log.Printf("Error scanning deleted resource: %v", err)
+			continue
+		}
+		resources = append(resources, gin.H{
+			"id":         id,
+			"host":       host,
+			"status":     status,
+			"deleted_at": deletedAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating deleted resources: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	middlewares := []gin.H{}
+	mwRows, err := h.DB.Query(`
+		SELECT id, name, type, deleted_at FROM middlewares
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id
+	`)
+	if err != nil {
+		log.Printf("Error fetching deleted middlewares: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+	defer mwRows.Close()
+	for mwRows.Next() {
+		var id, name, typ string
+		var deletedAt time.Time
+		if err := mwRows.Scan(&id, &name, &typ, &deletedAt); err != nil {
+			log.Printf("Error scanning deleted middleware: %v", err)
+			continue
+		}
+		middlewares = append(middlewares, gin.H{
+			"id":         id,
+			"name":       name,
+			"type":       typ,
+			"deleted_at": deletedAt,
+		})
+	}
+	if err := mwRows.Err(); err != nil {
+		log.Printf("Error iterating deleted middlewares: %v", err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resources":   resources,
+		"middlewares": middlewares,
+	})
+}
+
+// RestoreFromTrash restores a deleted resource or middleware with its
+// assignments. Resources and middlewares have separate IDs, so ?type=resource
+// or ?type=middleware is required when both trashes hold the ID. A restored
+// resource stays disabled until the data source reports it again.
+func (h *TrashHandler) RestoreFromTrash(c *gin.Context) {
+	id := c.Param("id")
+
+	var tables []string
+	switch kind := c.Query("type"); kind {
+	case "":
+		tables = []string{"resources", "middlewares"}
+	case "resource":
+		tables = []string{"resources"}
+	case "middleware":
+		tables = []string{"middlewares"}
+	default:
+		ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("Unknown type %q, expected resource or middleware", kind))
+		return
+	}
+
+	var found []string
+	for _, table := range tables {
+		var exists int
+		err := h.DB.QueryRow("SELECT 1 FROM "+table+" WHERE id = ? AND deleted_at IS NOT NULL", id).Scan(&exists)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			log.Printf("Error checking trash for %s: %v", id, err)
+			ResponseWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		found = append(found, table)
+	}
+	if len(found) == 0 {
+		ResponseWithError(c, http.StatusNotFound, "Not found in the trash")
+		return
+	}
+	if len(found) > 1 {
+		ResponseWithError(c, http.StatusConflict, "Both a resource and a middleware with this ID are in the trash; set type to resource or middleware")
+		return
+	}
+
+	table := found[0]
+	result, err := h.DB.Exec(
+		"UPDATE "+table+" SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL",
+		time.Now(), id,
+	)
+	if err != nil {
+		log.Printf("Error restoring %s from the trash: %v", id, err)
+		ResponseWithError(c, http.StatusInternalServerError, "Failed to restore from the trash")
+		return
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		// Purged or restored since the check
+		ResponseWithError(c, http.StatusNotFound, "Not found in the trash")
+		return
+	}
+
+	kind := "resource"
+	if table == "middlewares" {
+		kind = "middleware"
+	}
+	log.Printf("Restored %s %s from the trash", kind, id)
+	c.JSON(http.StatusOK, gin.H{"id": id, "type": kind, "message": fmt.Sprintf("Restored %s from the trash", kind)})
+}
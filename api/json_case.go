@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhftechnology/middleware-manager/api/handlers"
+)
+
+// JSONCaseCamel is the ?case= value asking for camelCase field names
+const JSONCaseCamel = "camel"
+
+// Keys whose values are user or Traefik data, passed through untouched
+var opaqueResponseKeys = map[string]bool{
+	"config":    true, // Middleware and service configs use Traefik's own field names
+	"fields":    true, // Keyed by config field path
+	"headers":   true,
+	"responses": true, // Raw upstream data source payloads
+	"from":      true, // Config values before and after a coercion
+	"to":        true,
+}
+
+// Only API field names are converted: lowercase words joined by underscores.
+// IDs, header names and other data keys don't match this.
+var snakeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)+$`)
+
+// bufferedWriter holds back a handler's response body so it can be rewritten
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// jsonCase returns a handler that rewrites the snake_case field names of JSON
+// responses as camelCase when the request asks for it with ?case=camel. It is
+// opt-in per request because the bundled UI reads snake_case fields. Config
+// maps are left as they are.
+func jsonCase() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("case") != JSONCaseCamel {
+			c.Next()
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		if strings.HasPrefix(writer.Header().Get("Content-Type"), "application/json") && writer.Status() != http.StatusNoContent && !c.GetBool(handlers.RawJSONKey) {
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var data interface{}
+			if err := decoder.Decode(&data); err == nil {
+				if converted, err := json.Marshal(camelKeys(data)); err == nil {
+					body = converted
+				}
+			}
+		}
+		if writer.Header().Get("Content-Length") != "" {
+			writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		writer.ResponseWriter.Write(body)
+	}
+}
+
+// camelKeys converts the snake_case keys of JSON objects in data, recursively
+func camelKeys(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if !opaqueResponseKeys[key] {
+				value = camelKeys(value)
+			}
+			converted[snakeToCamel(key)] = value
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = camelKeys(item)
+		}
+		return v
+	}
+	return data
+}
+
+// snakeToCamel converts an API field name such as router_priority to routerPriority
+func snakeToCamel(key string) string {
+	if !snakeKeyPattern.MatchString(key) {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedResponse is a stored response of a cached list endpoint
+type cachedResponse struct {
+	status      int
+	contentType string
+	body        []byte
+	storedAt    time.Time
+}
+
+// listCache keeps the responses of list endpoints in memory for a short time,
+// so dashboards polling them don't query the database on every request. Any
+// mutating API request clears it.
+type listCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	entries    map[string]cachedResponse
+	generation uint64 // Bumped on every invalidation
+}
+
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{ttl: ttl, entries: make(map[string]cachedResponse)}
+}
+
+// bodyRecorder copies what a handler writes so it can be cached
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// serve returns a handler that answers from the cache while the stored
+// response is fresh and otherwise stores what the next handler writes.
+// Responses are keyed by path and query.
+func (lc *listCache) serve() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if lc.ttl <= 0 {
+			c.Next()
+			return
+		}
+		key := c.Request.URL.RequestURI()
+		cacheControl := fmt.Sprintf("private, max-age=%d", int(lc.ttl.Seconds()))
+
+		lc.mu.Lock()
+		entry, ok := lc.entries[key]
+		generation := lc.generation
+		lc.mu.Unlock()
+
+		if ok && time.Since(entry.storedAt) < lc.ttl {
+			c.Header("Cache-Control", cacheControl)
+			c.Header("Age", fmt.Sprintf("%d", int(time.Since(entry.storedAt).Seconds())))
+			c.Header("X-Cache", "HIT")
+			c.Data(entry.status, entry.contentType, entry.body)
+			c.Abort()
+			return
+		}
+
+		c.Header("Cache-Control", cacheControl)
+		c.Header("Age", "0")
+		c.Header("X-Cache", "MISS")
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() != http.StatusOK {
+			return
+		}
+		lc.mu.Lock()
+		defer lc.mu.Unlock()
+		// A mutation that finished while this request ran may not be reflected in it
+		if lc.generation != generation {
+			return
+		}
+		lc.entries[key] = cachedResponse{
+			status:      recorder.Status(),
+			contentType: recorder.Header().Get("Content-Type"),
+			body:        recorder.body.Bytes(),
+			storedAt:    time.Now(),
+		}
+	}
+}
+
+// invalidateOnWrite clears the cache after every request that may change state
+func (lc *listCache) invalidateOnWrite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		lc.invalidate()
+	}
+}
+
+// invalidate drops every cached response
+func (lc *listCache) invalidate() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.generation++
+	if len(lc.entries) > 0 {
+		lc.entries = make(map[string]cachedResponse)
+	}
+}
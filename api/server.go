@@ -28,22 +28,39 @@ type Server struct {
 	dataSourceHandler *handlers.DataSourceHandler
 	serviceHandler    *handlers.ServiceHandler
 	pluginHandler     *handlers.PluginHandler // New handler
+	checkHandler      *handlers.CheckHandler
+	generateHandler   *handlers.GenerateHandler
+	debugHandler      *handlers.DebugHandler
+	policyGroupHandler *handlers.PolicyGroupHandler
+	importHandler     *handlers.ImportHandler
+	trashHandler      *handlers.TrashHandler
+	reloadHandler     *handlers.ReloadHandler
+	listCache         *listCache
+	auth              APIAuth
+	authExemptHealth  bool
 	configManager     *services.ConfigManager
+	configGenerator   *services.ConfigGenerator
+	resourceWatcher   *services.ResourceWatcher
 	traefikStaticConfigPath string                 // New
 	pluginsJSONURL          string                 // New
 }
 
 // ServerConfig contains configuration options for the server
 type ServerConfig struct {
-	Port       string
-	UIPath     string
-	Debug      bool
-	AllowCORS  bool
-	CORSOrigin string
+	Port         string
+	UIPath       string
+	Debug        bool
+	AllowCORS    bool
+	CORSOrigin   string
+	ListCacheTTL time.Duration // How long list endpoint responses are served from memory; 0 disables
+	AccessLog    bool          // Log every API request, not only failed ones
+	Auth         APIAuth       // Credentials required by /api routes; none if empty
+	// Leave /health open when auth is enabled, for container health checks
+	AuthExemptHealth bool
 }
 
 // NewServer creates a new API server
-func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigManager, traefikStaticConfigPath string, pluginsJSONURL string) *Server {
+func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigManager, configGenerator *services.ConfigGenerator, resourceWatcher *services.ResourceWatcher, reloader *services.Reloader, traefikStaticConfigPath string, pluginsJSONURL string) *Server {
 	// Set gin mode based on debug flag
 	if !config.Debug {
 		gin.SetMode(gin.ReleaseMode)
@@ -53,7 +70,9 @@ func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigMa
 	
 	// Use recovery and logger middleware
 	router.Use(gin.Recovery())
-	if config.Debug {
+	if config.AccessLog {
+		router.Use(accessLogger())
+	} else if config.Debug {
 		router.Use(gin.Logger())
 	} else {
 		// In production, use a custom minimal logger
@@ -81,13 +100,20 @@ func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigMa
 	}
 
 	// Create request handlers
-	middlewareHandler := handlers.NewMiddlewareHandler(db)
-	resourceHandler := handlers.NewResourceHandler(db)
+	// Initialize PluginHandler, passing the path to traefik.yml and the plugins.json URL
+	pluginHandler := handlers.NewPluginHandler(db, traefikStaticConfigPath, pluginsJSONURL)
+	middlewareHandler := handlers.NewMiddlewareHandler(db, pluginHandler)
+	resourceHandler := handlers.NewResourceHandler(db, configGenerator, resourceWatcher)
 	configHandler := handlers.NewConfigHandler(db)
 	dataSourceHandler := handlers.NewDataSourceHandler(configManager)
 	serviceHandler := handlers.NewServiceHandler(db)
-	// Initialize PluginHandler, passing the path to traefik.yml and the plugins.json URL
-	pluginHandler := handlers.NewPluginHandler(db, traefikStaticConfigPath, pluginsJSONURL)
+	checkHandler := handlers.NewCheckHandler(configGenerator)
+	generateHandler := handlers.NewGenerateHandler(configGenerator)
+	debugHandler := handlers.NewDebugHandler(db)
+	policyGroupHandler := handlers.NewPolicyGroupHandler(db)
+	importHandler := handlers.NewImportHandler(db)
+	trashHandler := handlers.NewTrashHandler(db)
+	reloadHandler := handlers.NewReloadHandler(reloader)
 
 	// Setup server with all handlers
 	server := &Server{
@@ -99,7 +125,19 @@ func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigMa
 		dataSourceHandler: dataSourceHandler,
 		serviceHandler:    serviceHandler,
 		pluginHandler:     pluginHandler, // Add to server struct
+		checkHandler:      checkHandler,
+		generateHandler:   generateHandler,
+		debugHandler:      debugHandler,
+		policyGroupHandler: policyGroupHandler,
+		importHandler:     importHandler,
+		trashHandler:      trashHandler,
+		reloadHandler:     reloadHandler,
+		listCache:         newListCache(config.ListCacheTTL),
+		auth:              config.Auth,
+		authExemptHealth:  config.AuthExemptHealth,
 		configManager:     configManager,
+		configGenerator:   configGenerator,
+		resourceWatcher:   resourceWatcher,
 		traefikStaticConfigPath: traefikStaticConfigPath, // Store the path
 		pluginsJSONURL:          pluginsJSONURL,          // Store the URL
 		srv: &http.Server{
@@ -112,6 +150,12 @@ func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigMa
 		},
 	}
 
+	// A reload runs after its POST has returned, so the cache that request
+	// cleared may have been refilled with data the reload then changed
+	if reloader != nil {
+		reloader.OnFinish(server.listCache.invalidate)
+	}
+
 	// Configure routes
 	server.setupRoutes(config.UIPath)
 
@@ -120,49 +164,89 @@ func NewServer(db *sql.DB, config ServerConfig, configManager *services.ConfigMa
 
 // setupRoutes configures all the routes for the API server
 func (s *Server) setupRoutes(uiPath string) {
+	var authHandlers []gin.HandlerFunc
+	if s.auth.Enabled() {
+		log.Printf("API authentication enabled (%s)", s.auth)
+		authHandlers = append(authHandlers, requireAuth(s.auth))
+	}
+
 	// Health check endpoint
-	s.router.GET("/health", func(c *gin.Context) {
+	healthHandlers := []gin.HandlerFunc{}
+	if !s.authExemptHealth {
+		healthHandlers = append(healthHandlers, authHandlers...)
+	}
+	s.router.GET("/health", append(healthHandlers, func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	})...)
 	
 	// API routes
 	api := s.router.Group("/api")
+	api.Use(authHandlers...)
+	api.Use(s.listCache.invalidateOnWrite(), jsonCase())
 	{
 		// Middleware routes
 		middlewares := api.Group("/middlewares")
 		{
-			middlewares.GET("", s.middlewareHandler.GetMiddlewares)
+			middlewares.GET("", s.listCache.serve(), s.middlewareHandler.GetMiddlewares)
 			middlewares.POST("", s.middlewareHandler.CreateMiddleware)
+			middlewares.POST("/import-url", s.middlewareHandler.ImportMiddlewaresFromURL)
+			middlewares.GET("/unused-defaults", s.middlewareHandler.GetUnusedDefaultMiddlewares)
 			middlewares.GET("/:id", s.middlewareHandler.GetMiddleware)
+			middlewares.GET("/:id/resources", s.middlewareHandler.GetMiddlewareResources)
 			middlewares.PUT("/:id", s.middlewareHandler.UpdateMiddleware)
 			middlewares.DELETE("/:id", s.middlewareHandler.DeleteMiddleware)
+			middlewares.POST("/:id/replace-with/:newId", s.middlewareHandler.ReplaceMiddleware)
+			middlewares.POST("/:id/rename", s.middlewareHandler.RenameMiddleware)
+		}
+
+		// Policy group routes
+		policyGroups := api.Group("/policy-groups")
+		{
+			policyGroups.GET("", s.policyGroupHandler.GetPolicyGroups)
+			policyGroups.POST("", s.policyGroupHandler.CreatePolicyGroup)
+			policyGroups.GET("/:id", s.policyGroupHandler.GetPolicyGroup)
+			policyGroups.PUT("/:id", s.policyGroupHandler.UpdatePolicyGroup)
+			policyGroups.DELETE("/:id", s.policyGroupHandler.DeletePolicyGroup)
+			policyGroups.POST("/:id/apply", s.policyGroupHandler.ApplyPolicyGroup)
+			policyGroups.DELETE("/:id/resources/:resourceId", s.policyGroupHandler.DetachPolicyGroup)
 		}
 
 		// Service routes
 		services := api.Group("/services")
 		{
-			services.GET("", s.serviceHandler.GetServices)
+			services.GET("", s.listCache.serve(), s.serviceHandler.GetServices)
 			services.POST("", s.serviceHandler.CreateService)
+			services.POST("/weighted-split", s.serviceHandler.CreateWeightedSplit)
 			services.GET("/:id", s.serviceHandler.GetService)
 			services.PUT("/:id", s.serviceHandler.UpdateService)
 			services.DELETE("/:id", s.serviceHandler.DeleteService)
+			services.POST("/:id/assign-bulk", s.serviceHandler.AssignServiceToResources)
 		}
 
 		// Resource routes
 		resources := api.Group("/resources")
 		{
-			resources.GET("", s.resourceHandler.GetResources)
+			resources.GET("", s.listCache.serve(), s.resourceHandler.GetResources)
+			resources.GET("/assignments.csv", s.resourceHandler.ExportAssignmentsCSV)
+			resources.POST("/assignments.csv", s.resourceHandler.ImportAssignmentsCSV)
 			resources.GET("/:id", s.resourceHandler.GetResource)
+			resources.POST("", s.resourceHandler.CreateResource)
 			resources.DELETE("/:id", s.resourceHandler.DeleteResource)
+			resources.POST("/:id/refresh", s.resourceHandler.RefreshResource)
+			resources.POST("/:id/clone", s.resourceHandler.CloneResource)
+			resources.POST("/:id/probe", s.resourceHandler.ProbeResource)
+			resources.POST("/:id/reset", s.resourceHandler.ResetResource)
 			
 			// Middleware assignments
 			resources.POST("/:id/middlewares", s.resourceHandler.AssignMiddleware)
 			resources.POST("/:id/middlewares/bulk", s.resourceHandler.AssignMultipleMiddlewares)
+			resources.POST("/:id/middlewares/dry-run", s.resourceHandler.PreviewMiddlewareAssignment)
 			resources.DELETE("/:id/middlewares/:middlewareId", s.resourceHandler.RemoveMiddleware)
 			
 			// Service assignments
 			resources.GET("/:id/service", s.serviceHandler.GetResourceService)
 			resources.POST("/:id/service", s.serviceHandler.AssignServiceToResource)
+			resources.POST("/:id/service/dry-run", s.resourceHandler.PreviewServiceAssignment)
 			resources.DELETE("/:id/service", s.serviceHandler.RemoveServiceFromResource)
 			
 			// Router configuration routes
@@ -171,6 +255,8 @@ func (s *Server) setupRoutes(uiPath string) {
 			resources.PUT("/:id/config/tcp", s.configHandler.UpdateTCPConfig)
 			resources.PUT("/:id/config/headers", s.configHandler.UpdateHeadersConfig)
 			resources.PUT("/:id/config/priority", s.configHandler.UpdateRouterPriority)
+			resources.PUT("/:id/config/auth", s.configHandler.UpdateAuthConfig)
+			resources.PUT("/:id/error-pages", s.configHandler.UpdateErrorPagesConfig)
 		}
 
 		// Data source routes
@@ -178,11 +264,50 @@ func (s *Server) setupRoutes(uiPath string) {
 		{
 			datasource.GET("", s.dataSourceHandler.GetDataSources)
 			datasource.GET("/active", s.dataSourceHandler.GetActiveDataSource)
+			datasource.GET("/raw", s.dataSourceHandler.GetRawDataSourceResponse)
 			datasource.PUT("/active", s.dataSourceHandler.SetActiveDataSource)
+			datasource.POST("/activate/:name", s.dataSourceHandler.ActivateDataSource)
 			datasource.PUT("/:name", s.dataSourceHandler.UpdateDataSource)
 			datasource.POST("/:name/test", s.dataSourceHandler.TestDataSourceConnection)
 		}
 
+		// Consistency check route
+		api.GET("/check", s.checkHandler.CheckConsistency)
+
+		// Manual configuration generation
+		api.POST("/generate", s.generateHandler.GenerateConfig)
+
+		// Out-of-band refresh from the data source
+		api.POST("/reload", s.reloadHandler.Reload)
+		api.GET("/reload/status", s.reloadHandler.GetReloadStatus)
+
+		// Last-known-good configuration
+		config := api.Group("/config")
+		{
+			config.GET("/preview", s.generateHandler.PreviewConfig)
+			config.GET("/traefik-dynamic", s.generateHandler.GetTraefikDynamicConfig)
+			config.GET("/last-good", s.generateHandler.GetLastGoodConfig)
+			config.POST("/confirm-good", s.generateHandler.ConfirmGoodConfig)
+			config.POST("/restore-last-good", s.generateHandler.RestoreLastGoodConfig)
+		}
+
+		// Configuration export for backup and migration
+		api.GET("/export", s.generateHandler.ExportConfig)
+		api.POST("/import", s.importHandler.ImportConfig)
+
+		// Deleted resources and middlewares
+		trash := api.Group("/trash")
+		{
+			trash.GET("", s.trashHandler.GetTrash)
+			trash.POST("/:id/restore", s.trashHandler.RestoreFromTrash)
+		}
+
+		// Dynamic configuration for Traefik's HTTP provider
+		api.GET("/traefik/http-provider", s.generateHandler.ServeHTTPProvider)
+
+		// Debugging routes
+		api.GET("/debug/normalize", s.debugHandler.NormalizeID)
+
 		// Plugin Hub Routes
 		pluginsGroup := api.Group("/plugins")
 				{
@@ -281,6 +406,29 @@ func (s *Server) Stop() {
 	}
 }
 
+// accessLogger returns a Gin middleware that logs every request with its
+// method, path, status, latency, response size and client IP
+func accessLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Health checks would drown out everything else
+		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/ping" {
+			return
+		}
+		log.Printf("[ACCESS] %s %s | %d | %v | %d bytes | %s | %q",
+			c.Request.Method,
+			c.Request.URL.RequestURI(),
+			c.Writer.Status(),
+			time.Since(start),
+			c.Writer.Size(),
+			c.ClientIP(),
+			c.Request.UserAgent(),
+		)
+	}
+}
+
 // minimalLogger returns a Gin middleware for minimal request logging
 func minimalLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -11,15 +11,19 @@ import (
 	"strings"
 
 	"github.com/hhftechnology/middleware-manager/database"
+	"github.com/hhftechnology/middleware-manager/models"
 	"gopkg.in/yaml.v3"
 )
 
 // DefaultMiddleware represents a default middleware template
 type DefaultMiddleware struct {
-	ID     string                 `yaml:"id"`
-	Name   string                 `yaml:"name"`
-	Type   string                 `yaml:"type"`
-	Config map[string]interface{} `yaml:"config"`
+	ID          string                 `yaml:"id"`
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	Config      map[string]interface{} `yaml:"config"`
+	DisplayName string                 `yaml:"display_name"`
+	Icon        string                 `yaml:"icon"`
+	Category    string                 `yaml:"category"`
 }
 
 // DefaultTemplates represents the structure of the templates.yaml file
@@ -93,7 +97,17 @@ func LoadDefaultTemplates(db *database.DB) error {
 		var exists int
 		err := db.QueryRow("SELECT 1 FROM middlewares WHERE id = ?", middleware.ID).Scan(&exists)
 		if err == nil {
-			// Middleware exists, skip
+			// Middleware exists; mark it as a template one, in case it was
+			// added before templates were tracked
+			if _, err := db.Exec("UPDATE middlewares SET from_template = 1 WHERE id = ? AND COALESCE(from_template, 0) = 0", middleware.ID); err != nil {
+				log.Printf("Failed to mark middleware %s as a template: %v", middleware.ID, err)
+			}
+			// Deleted template middlewares come back while they're in the templates
+			if result, err := db.Exec("UPDATE middlewares SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", middleware.ID); err != nil {
+				log.Printf("Failed to restore template middleware %s: %v", middleware.ID, err)
+			} else if restored, _ := result.RowsAffected(); restored > 0 {
+				log.Printf("Restored template middleware %s from the trash", middleware.ID)
+			}
 			continue
 		}
 
@@ -106,8 +120,9 @@ func LoadDefaultTemplates(db *database.DB) error {
 
 		// Insert into database
 		_, err = db.Exec(
-			"INSERT INTO middlewares (id, name, type, config) VALUES (?, ?, ?, ?)",
+			"INSERT INTO middlewares (id, name, type, config, display_name, icon, category, from_template) VALUES (?, ?, ?, ?, ?, ?, ?, 1)",
 			middleware.ID, middleware.Name, middleware.Type, string(configJSON),
+			middleware.DisplayName, middleware.Icon, middleware.Category,
 		)
 
 		if err != nil {
@@ -309,6 +324,9 @@ func processChainingMiddleware(config *map[string]interface{}) {
 					// Assume it's from our file provider
 					middlewares[i] = fmt.Sprintf("%s@file", middlewareStr)
 				}
+			} else if ref, ok := models.ChainMemberReference(middleware); ok {
+				// Members with an explicit provider keep it
+				middlewares[i] = ref
 			}
 		}
 		(*config)["middlewares"] = middlewares
@@ -371,6 +389,13 @@ func processPathMiddleware(config *map[string]interface{}, middlewareType string
 
 	// Process other path manipulation configuration values
 	*config = preserveTraefikValues(*config).(map[string]interface{})
+
+	// Traefik expects the redirectScheme port as a string
+	if middlewareType == "redirectScheme" {
+		if port, ok := (*config)["port"].(int); ok {
+			(*config)["port"] = strconv.Itoa(port)
+		}
+	}
 }
 
 // processAuthMiddleware handles authentication middleware special processing
@@ -368,19 +368,17 @@ func SaveTemplateServicesFile(templatesDir string) error {
 		return nil
 	}
 
-	// Create default templates
+	// Create default templates. Keep this catalog in sync with config/templates_services.yaml.
 	templates := DefaultServiceTemplates{
 		Services: []DefaultService{
-			// LoadBalancer services
+			// HTTP LoadBalancer services
 			{
 				ID:   "simple-http",
 				Name: "Simple HTTP LoadBalancer",
 				Type: "loadBalancer",
 				Config: map[string]interface{}{
-					"servers": []map[string]interface{}{
-						{
-							"url": "http://localhost:8080",
-						},
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://localhost:8080"},
 					},
 				},
 			},
@@ -389,13 +387,20 @@ func SaveTemplateServicesFile(templatesDir string) error {
 				Name: "Multi-Server HTTP LoadBalancer",
 				Type: "loadBalancer",
 				Config: map[string]interface{}{
-					"servers": []map[string]interface{}{
-						{
-							"url": "http://server1:8080",
-						},
-						{
-							"url": "http://server2:8080",
-						},
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://server1:8080"},
+						map[string]interface{}{"url": "http://server2:8080"},
+					},
+				},
+			},
+			{
+				ID:   "weighted-servers",
+				Name: "Weighted HTTP Servers",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://primary:8080", "weight": 3},
+						map[string]interface{}{"url": "http://secondary:8080", "weight": 1},
 					},
 				},
 			},
@@ -404,83 +409,218 @@ func SaveTemplateServicesFile(templatesDir string) error {
 				Name: "HTTP Service with Health Check",
 				Type: "loadBalancer",
 				Config: map[string]interface{}{
-					"servers": []map[string]interface{}{
-						{
-							"url": "http://backend:8080",
-						},
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://backend:8080"},
 					},
 					"healthCheck": map[string]interface{}{
 						"path":     "/health",
 						"interval": "10s",
 						"timeout":  "3s",
+						"port":     8080,
+						"scheme":   "http",
+					},
+				},
+			},
+			{
+				ID:   "sticky-session",
+				Name: "HTTP Service with Sticky Sessions",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://backend1:8080"},
+						map[string]interface{}{"url": "http://backend2:8080"},
+					},
+					"sticky": map[string]interface{}{
+						"cookie": map[string]interface{}{
+							"name":     "sticky_session",
+							"secure":   true,
+							"httpOnly": true,
+						},
+					},
+				},
+			},
+			{
+				ID:   "preserved-path",
+				Name: "HTTP Service with Path Preservation",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://backend:8080/base", "preservePath": true},
+					},
+				},
+			},
+			{
+				ID:   "passhost-disabled",
+				Name: "HTTP Service with PassHost Disabled",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://backend:8080"},
+					},
+					"passHostHeader": false,
+				},
+			},
+			{
+				ID:   "response-forwarding",
+				Name: "Service with Response Forwarding",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"url": "http://backend:8080"},
+					},
+					"responseForwarding": map[string]interface{}{
+						"flushInterval": "1s",
 					},
 				},
 			},
 
-			// TCP service
+			// TCP services
 			{
 				ID:   "tcp-service",
 				Name: "TCP Service",
 				Type: "loadBalancer",
 				Config: map[string]interface{}{
-					"servers": []map[string]interface{}{
-						{
-							"address": "backend:9000",
-						},
+					"servers": []interface{}{
+						map[string]interface{}{"address": "backend:9000"},
+					},
+				},
+			},
+			{
+				ID:   "tcp-tls-backend",
+				Name: "TCP with TLS to Backend",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"address": "backend:8443", "tls": true},
 					},
 				},
 			},
+			{
+				ID:   "tcp-proxy-protocol",
+				Name: "TCP with Proxy Protocol",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"address": "backend:8080"},
+					},
+					"proxyProtocol": map[string]interface{}{
+						"version": 1,
+					},
+				},
+			},
+			{
+				ID:   "tcp-termination-delay",
+				Name: "TCP with Termination Delay",
+				Type: "loadBalancer",
+				Config: map[string]interface{}{
+					"servers": []interface{}{
+						map[string]interface{}{"address": "backend:8080"},
+					},
+					"terminationDelay": 200,
+				},
+			},
 
-			// UDP service
+			// UDP services
 			{
 				ID:   "udp-service",
 				Name: "UDP Service",
 				Type: "loadBalancer",
 				Config: map[string]interface{}{
-					"servers": []map[string]interface{}{
-						{
-							"address": "backend:53",
-						},
+					"servers": []interface{}{
+						map[string]interface{}{"address": "backend:53"},
 					},
 				},
 			},
 
-			// Weighted service
+			// Weighted services
 			{
 				ID:   "weighted-service",
 				Name: "Weighted Service",
 				Type: "weighted",
 				Config: map[string]interface{}{
-					"services": []map[string]interface{}{
-						{
-							"name":   "service1@file",
-							"weight": 3,
-						},
-						{
-							"name":   "service2@file",
-							"weight": 1,
-						},
+					"services": []interface{}{
+						map[string]interface{}{"name": "service1@file", "weight": 3},
+						map[string]interface{}{"name": "service2@file", "weight": 1},
+					},
+				},
+			},
+			{
+				ID:   "canary-release",
+				Name: "Canary Release (90/10)",
+				Type: "weighted",
+				Config: map[string]interface{}{
+					"services": []interface{}{
+						map[string]interface{}{"name": "stable@file", "weight": 9},
+						map[string]interface{}{"name": "canary@file", "weight": 1},
+					},
+				},
+			},
+			{
+				ID:   "weighted-with-health",
+				Name: "Weighted Service with Health Check",
+				Type: "weighted",
+				Config: map[string]interface{}{
+					"healthCheck": map[string]interface{}{},
+					"services": []interface{}{
+						map[string]interface{}{"name": "service1@file", "weight": 3},
+						map[string]interface{}{"name": "service2@file", "weight": 1},
+					},
+				},
+			},
+			{
+				ID:   "weighted-with-sticky",
+				Name: "Weighted Service with Sticky Sessions",
+				Type: "weighted",
+				Config: map[string]interface{}{
+					"sticky": map[string]interface{}{
+						"cookie": map[string]interface{}{"name": "lvl1"},
+					},
+					"services": []interface{}{
+						map[string]interface{}{"name": "service1@file", "weight": 1},
+						map[string]interface{}{"name": "service2@file", "weight": 1},
 					},
 				},
 			},
 
-			// Mirroring service
+			// Mirroring services
 			{
 				ID:   "traffic-mirror",
 				Name: "Traffic Mirroring Service",
 				Type: "mirroring",
 				Config: map[string]interface{}{
 					"service": "main-service@file",
-					"mirrors": []map[string]interface{}{
-						{
-							"name":    "test-service@file",
-							"percent": 10,
-						},
+					"mirrors": []interface{}{
+						map[string]interface{}{"name": "test-service@file", "percent": 10},
+					},
+				},
+			},
+			{
+				ID:   "full-mirror",
+				Name: "Full Traffic Mirror",
+				Type: "mirroring",
+				Config: map[string]interface{}{
+					"service":     "production@file",
+					"mirrorBody":  true,
+					"maxBodySize": 10240,
+					"mirrors": []interface{}{
+						map[string]interface{}{"name": "staging@file", "percent": 100},
+					},
+				},
+			},
+			{
+				ID:   "mirror-with-health",
+				Name: "Mirroring Service with Health Check",
+				Type: "mirroring",
+				Config: map[string]interface{}{
+					"healthCheck": map[string]interface{}{},
+					"service":     "main-service@file",
+					"mirrors": []interface{}{
+						map[string]interface{}{"name": "test-service@file", "percent": 10},
 					},
 				},
 			},
 
-			// Failover service
+			// Failover services
 			{
 				ID:   "failover-service",
 				Name: "Failover Service",
@@ -490,6 +630,16 @@ func SaveTemplateServicesFile(templatesDir string) error {
 					"fallback": "backup-service@file",
 				},
 			},
+			{
+				ID:   "failover-with-health",
+				Name: "Failover Service with Health Check",
+				Type: "failover",
+				Config: map[string]interface{}{
+					"healthCheck": map[string]interface{}{},
+					"service":     "main-service@file",
+					"fallback":    "backup-service@file",
+				},
+			},
 		},
 	}
 
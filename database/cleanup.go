@@ -17,8 +17,13 @@ type CleanupOptions struct {
     MaxDeleteBatch   int  // Maximum number of items to delete in one batch
     ReapDisabled     bool // If true, physically delete disabled resources
     RecoverCorrupted bool // If true, attempt to recover corrupted resources
+    TrashRetention   time.Duration // How long deleted items stay in the trash, 0 keeps them
 }
 
+// DefaultTrashRetention is how long deleted resources and middlewares are
+// kept in the trash before they are purged
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
 // DefaultCleanupOptions returns the default cleanup options
 func DefaultCleanupOptions() CleanupOptions {
     return CleanupOptions{
@@ -27,6 +32,7 @@ func DefaultCleanupOptions() CleanupOptions {
         MaxDeleteBatch:   100,
         ReapDisabled:     false,
         RecoverCorrupted: true,
+        TrashRetention:   DefaultTrashRetention,
     }
 }
 
@@ -162,7 +168,8 @@ func (db *DB) CleanupDuplicateResources(opts CleanupOptions) error {
     }
     
     // Get all resources
-    rows, err := db.Query("SELECT id, host, service_id, status FROM resources")
+    // Manually-created resources are never treated as duplicates
+    rows, err := db.Query("SELECT id, host, service_id, status FROM resources WHERE COALESCE(origin, '') != 'manual' AND deleted_at IS NULL")
     if err != nil {
         return fmt.Errorf("failed to query resources: %w", err)
     }
@@ -355,9 +362,11 @@ func (db *DB) CleanupDuplicateResources(opts CleanupOptions) error {
                     return fmt.Errorf("failed to delete resource_middlewares for %s: %w", id, err)
                 }
                 
-                // Then delete any service relationships
-                if _, err := tx.Exec("DELETE FROM resource_services WHERE resource_id = ?", id); err != nil {
-                    return fmt.Errorf("failed to delete resource_services for %s: %w", id, err)
+                // Then delete any service relationships and policy group memberships
+                for _, table := range []string{"resource_services", "policy_group_resources", "policy_group_resource_middlewares"} {
+                    if _, err := tx.Exec("DELETE FROM "+table+" WHERE resource_id = ?", id); err != nil {
+                        return fmt.Errorf("failed to delete %s for %s: %w", table, id, err)
+                    }
                 }
                 
                 // Finally delete the resource
@@ -401,5 +410,110 @@ func (db *DB) PerformFullCleanup(opts CleanupOptions) error {
         return fmt.Errorf("resource cleanup failed: %w", err)
     }
     
+    // Finally empty the trash of expired items
+    if err := db.PurgeTrash(opts); err != nil {
+        return fmt.Errorf("trash purge failed: %w", err)
+    }
+    
     return nil
+}
+
+// PurgeTrash permanently deletes resources and middlewares that have been in
+// the trash for longer than opts.TrashRetention, with their assignments
+func (db *DB) PurgeTrash(opts CleanupOptions) error {
+    if opts.TrashRetention <= 0 {
+        return nil
+    }
+    cutoff := fmt.Sprintf("-%d seconds", int64(opts.TrashRetention/time.Second))
+    
+    expired := func(table string) ([]string, error) {
+        rows, err := db.Query(
+            "SELECT id FROM "+table+" WHERE deleted_at IS NOT NULL AND deleted_at <= datetime('now', ?)",
+            cutoff,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to query deleted %s: %w", table, err)
+        }
+        defer rows.Close()
+        
+        var ids []string
+        for rows.Next() {
+            var id string
+            if err := rows.Scan(&id); err != nil {
+                return nil, fmt.Errorf("failed to scan deleted %s: %w", table, err)
+            }
+            ids = append(ids, id)
+        }
+        return ids, rows.Err()
+    }
+    
+    resourceIDs, err := expired("resources")
+    if err != nil {
+        return err
+    }
+    middlewareIDs, err := expired("middlewares")
+    if err != nil {
+        return err
+    }
+    
+    if len(resourceIDs) == 0 && len(middlewareIDs) == 0 {
+        if opts.LogLevel >= 2 {
+            log.Println("No expired items in the trash.")
+        }
+        return nil
+    }
+    
+    if opts.DryRun {
+        log.Printf("DRY RUN: Would purge %d resources and %d middlewares from the trash",
+                  len(resourceIDs), len(middlewareIDs))
+        return nil
+    }
+    
+    return db.WithTransaction(func(tx *sql.Tx) error {
+        for _, id := range resourceIDs {
+            if opts.LogLevel >= 2 {
+                log.Printf("Purging resource %s from the trash", id)
+            }
+            for _, table := range []string{"resource_middlewares", "resource_services", "policy_group_resources", "policy_group_resource_middlewares", "resource_status_transitions"} {
+                if _, err := tx.Exec("DELETE FROM "+table+" WHERE resource_id = ?", id); err != nil {
+                    return fmt.Errorf("failed to delete %s for %s: %w", table, id, err)
+                }
+            }
+            if _, err := tx.Exec("DELETE FROM resources WHERE id = ? AND deleted_at IS NOT NULL", id); err != nil {
+                return fmt.Errorf("failed to purge resource %s: %w", id, err)
+            }
+        }
+        
+        for _, id := range middlewareIDs {
+            if opts.LogLevel >= 2 {
+                log.Printf("Purging middleware %s from the trash", id)
+            }
+            for _, table := range []string{"resource_middlewares", "policy_group_middlewares", "policy_group_resource_middlewares"} {
+                if _, err := tx.Exec("DELETE FROM "+table+" WHERE middleware_id = ?", id); err != nil {
+                    return fmt.Errorf("failed to delete %s for %s: %w", table, id, err)
+                }
+            }
+            if _, err := tx.Exec("DELETE FROM middlewares WHERE id = ? AND deleted_at IS NOT NULL", id); err != nil {
+                return fmt.Errorf("failed to purge middleware %s: %w", id, err)
+            }
+        }
+        
+        if opts.LogLevel >= 1 {
+            log.Printf("Purged %d resources and %d middlewares from the trash",
+                      len(resourceIDs), len(middlewareIDs))
+        }
+        return nil
+    })
+}
+
+// StartTrashPurge runs PurgeTrash every interval for the life of the process
+func (db *DB) StartTrashPurge(opts CleanupOptions, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    
+    for range ticker.C {
+        if err := db.PurgeTrash(opts); err != nil {
+            log.Printf("Warning: Trash purge failed: %v", err)
+        }
+    }
 }
\ No newline at end of file
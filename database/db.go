@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,6 +20,10 @@ type DB struct {
 	*sql.DB
 }
 
+// ErrNotFound is wrapped by errors returned when a requested record doesn't exist;
+// check for it with errors.Is
+var ErrNotFound = errors.New("not found")
+
 // TraefikConfig represents the structure of the Traefik configuration
 type TraefikConfig struct {
 	HTTP struct {
@@ -300,6 +305,339 @@ func runPostMigrationUpdates(db *sql.DB) error {
 		
 		log.Println("Successfully added all routing configuration columns")
 	}
+
+	// Check for origin column on resources
+	var hasOriginColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('resources') 
+		WHERE name = 'origin'
+	`).Scan(&hasOriginColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if origin column exists: %w", err)
+	}
+
+	if !hasOriginColumn {
+		log.Println("Adding origin column to resources table")
+
+		if _, err := db.Exec("ALTER TABLE resources ADD COLUMN origin TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add origin column: %w", err)
+		}
+
+		log.Println("Successfully added origin column")
+	}
+
+	// Check for skip_auth column on resources
+	var hasSkipAuthColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('resources') 
+		WHERE name = 'skip_auth'
+	`).Scan(&hasSkipAuthColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if skip_auth column exists: %w", err)
+	}
+
+	if !hasSkipAuthColumn {
+		log.Println("Adding skip_auth column to resources table")
+
+		if _, err := db.Exec("ALTER TABLE resources ADD COLUMN skip_auth INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add skip_auth column: %w", err)
+		}
+
+		log.Println("Successfully added skip_auth column")
+	}
+
+	// Check for missing_since column on resources
+	var hasMissingSinceColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('resources') 
+		WHERE name = 'missing_since'
+	`).Scan(&hasMissingSinceColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if missing_since column exists: %w", err)
+	}
+
+	if !hasMissingSinceColumn {
+		log.Println("Adding missing_since column to resources table")
+
+		if _, err := db.Exec("ALTER TABLE resources ADD COLUMN missing_since TIMESTAMP"); err != nil {
+			return fmt.Errorf("failed to add missing_since column: %w", err)
+		}
+
+		log.Println("Successfully added missing_since column")
+	}
+
+	// Check for tls_auto_domains column on resources
+	var hasTLSAutoDomainsColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('resources') 
+		WHERE name = 'tls_auto_domains'
+	`).Scan(&hasTLSAutoDomainsColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if tls_auto_domains column exists: %w", err)
+	}
+
+	if !hasTLSAutoDomainsColumn {
+		log.Println("Adding tls_auto_domains column to resources table")
+
+		if _, err := db.Exec("ALTER TABLE resources ADD COLUMN tls_auto_domains INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add tls_auto_domains column: %w", err)
+		}
+
+		log.Println("Successfully added tls_auto_domains column")
+	}
+
+	// Check for error_pages column on resources
+	var hasErrorPagesColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('resources') 
+		WHERE name = 'error_pages'
+	`).Scan(&hasErrorPagesColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if error_pages column exists: %w", err)
+	}
+
+	if !hasErrorPagesColumn {
+		log.Println("Adding error_pages column to resources table")
+
+		if _, err := db.Exec("ALTER TABLE resources ADD COLUMN error_pages TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add error_pages column: %w", err)
+		}
+
+		log.Println("Successfully added error_pages column")
+	}
+
+	// Check for unsafe column on middlewares
+	var hasUnsafeColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('middlewares') 
+		WHERE name = 'unsafe'
+	`).Scan(&hasUnsafeColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if unsafe column exists: %w", err)
+	}
+
+	if !hasUnsafeColumn {
+		log.Println("Adding unsafe column to middlewares table")
+
+		if _, err := db.Exec("ALTER TABLE middlewares ADD COLUMN unsafe INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add unsafe column: %w", err)
+		}
+
+		log.Println("Successfully added unsafe column")
+	}
+
+	// Check for the display metadata columns on middlewares
+	for _, column := range []string{"display_name", "icon", "category"} {
+		var hasColumn bool
+		err = db.QueryRow(`
+			SELECT COUNT(*) > 0 
+			FROM pragma_table_info('middlewares') 
+			WHERE name = ?
+		`, column).Scan(&hasColumn)
+
+		if err != nil {
+			return fmt.Errorf("failed to check if %s column exists: %w", column, err)
+		}
+
+		if !hasColumn {
+			log.Printf("Adding %s column to middlewares table", column)
+
+			if _, err := db.Exec(fmt.Sprintf("ALTER TABLE middlewares ADD COLUMN %s TEXT DEFAULT ''", column)); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", column, err)
+			}
+
+			log.Printf("Successfully added %s column", column)
+		}
+	}
+
+	// Check for from_template column on middlewares. Existing template
+	// middlewares are marked when the templates are loaded.
+	var hasFromTemplateColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('middlewares') 
+		WHERE name = 'from_template'
+	`).Scan(&hasFromTemplateColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if from_template column exists: %w", err)
+	}
+
+	if !hasFromTemplateColumn {
+		log.Println("Adding from_template column to middlewares table")
+
+		if _, err := db.Exec("ALTER TABLE middlewares ADD COLUMN from_template INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add from_template column: %w", err)
+		}
+
+		log.Println("Successfully added from_template column")
+	}
+
+	// A resource has at most one custom service. The primary key allows one
+	// row per (resource, service) pair, so enforce it with a unique index on
+	// resource_id, dropping all but the latest assignment of any resource that
+	// concurrent writes left with several.
+	var hasResourceServiceIndex bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM sqlite_master
+		WHERE type='index' AND name='idx_resource_services_resource'
+	`).Scan(&hasResourceServiceIndex)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if resource_services index exists: %w", err)
+	}
+
+	if !hasResourceServiceIndex {
+		log.Println("Adding unique index on resource_services.resource_id")
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		result, err := tx.Exec(`
+			DELETE FROM resource_services
+			WHERE rowid NOT IN (SELECT MAX(rowid) FROM resource_services GROUP BY resource_id)
+		`)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to remove duplicate service assignments: %w", err)
+		}
+		if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+			log.Printf("Removed %d duplicate service assignments", removed)
+		}
+
+		if _, err := tx.Exec("CREATE UNIQUE INDEX idx_resource_services_resource ON resource_services(resource_id)"); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create resource_services index: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		log.Println("Successfully added unique index on resource_services.resource_id")
+	}
+
+	// Deleted resources and middlewares are kept in the trash until purged
+	for _, table := range []string{"resources", "middlewares"} {
+		var hasDeletedAtColumn bool
+		err = db.QueryRow(`
+			SELECT COUNT(*) > 0
+			FROM pragma_table_info(?)
+			WHERE name = 'deleted_at'
+		`, table).Scan(&hasDeletedAtColumn)
+
+		if err != nil {
+			return fmt.Errorf("failed to check if deleted_at column exists on %s: %w", table, err)
+		}
+
+		if !hasDeletedAtColumn {
+			log.Printf("Adding deleted_at column to %s table", table)
+
+			if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN deleted_at TIMESTAMP"); err != nil {
+				return fmt.Errorf("failed to add deleted_at column to %s: %w", table, err)
+			}
+
+			log.Printf("Successfully added deleted_at column to %s", table)
+		}
+	}
+
+	// Check for managed column on services
+	var hasManagedColumn bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('services') 
+		WHERE name = 'managed'
+	`).Scan(&hasManagedColumn)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if managed column exists: %w", err)
+	}
+
+	// If the column doesn't exist, add it and mark watcher-discovered services
+	// (those carrying a provider suffix) as managed
+	if !hasManagedColumn {
+		log.Println("Adding managed column to services table")
+
+		if _, err := db.Exec("ALTER TABLE services ADD COLUMN managed INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add managed column: %w", err)
+		}
+
+		if _, err := db.Exec("UPDATE services SET managed = 1 WHERE id LIKE '%@%'"); err != nil {
+			return fmt.Errorf("failed to backfill managed column: %w", err)
+		}
+
+		log.Println("Successfully added managed column")
+	}
+
+	// Policy groups record the middleware assignments they add, so detaching
+	// a group only removes those. Assignments of existing members that match
+	// their groups' middlewares are taken to have been added by the groups.
+	var hasGroupAssignmentsTable bool
+	err = db.QueryRow(`
+		SELECT COUNT(*) > 0
+		FROM sqlite_master
+		WHERE type='table' AND name='policy_group_resource_middlewares'
+	`).Scan(&hasGroupAssignmentsTable)
+
+	if err != nil {
+		return fmt.Errorf("failed to check if policy_group_resource_middlewares table exists: %w", err)
+	}
+
+	if !hasGroupAssignmentsTable {
+		log.Println("Adding policy_group_resource_middlewares table")
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+			CREATE TABLE policy_group_resource_middlewares (
+				group_id TEXT NOT NULL,
+				resource_id TEXT NOT NULL,
+				middleware_id TEXT NOT NULL,
+				PRIMARY KEY (group_id, resource_id, middleware_id),
+				FOREIGN KEY (group_id) REFERENCES policy_groups(id) ON DELETE CASCADE,
+				FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE,
+				FOREIGN KEY (middleware_id) REFERENCES middlewares(id) ON DELETE CASCADE
+			)
+		`); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create policy_group_resource_middlewares table: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO policy_group_resource_middlewares (group_id, resource_id, middleware_id)
+			SELECT pgr.group_id, pgr.resource_id, pgm.middleware_id
+			FROM policy_group_resources pgr
+			JOIN policy_group_middlewares pgm ON pgm.group_id = pgr.group_id
+			JOIN resource_middlewares rm ON rm.resource_id = pgr.resource_id AND rm.middleware_id = pgm.middleware_id
+		`); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to backfill policy_group_resource_middlewares: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		log.Println("Successfully added policy_group_resource_middlewares table")
+	}
 	
 	return nil
 }
@@ -342,7 +680,7 @@ func findServiceMigrationsFile() string {
 
 // GetMiddlewares fetches all middleware definitions
 func (db *DB) GetMiddlewares() ([]map[string]interface{}, error) {
-	rows, err := db.Query("SELECT id, name, type, config FROM middlewares")
+	rows, err := db.Query("SELECT id, name, type, config FROM middlewares WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -394,7 +732,8 @@ func (db *DB) GetResources() ([]map[string]interface{}, error) {
 		       GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares
 		FROM resources r
 		LEFT JOIN resource_middlewares rm ON r.id = rm.resource_id
-		LEFT JOIN middlewares m ON rm.middleware_id = m.id
+		LEFT JOIN middlewares m ON rm.middleware_id = m.id AND m.deleted_at IS NULL
+		WHERE r.deleted_at IS NULL
 		GROUP BY r.id
 	`)
 	if err != nil {
@@ -467,15 +806,15 @@ func (db *DB) GetResource(id string) (map[string]interface{}, error) {
 		       GROUP_CONCAT(m.id || ':' || m.name || ':' || rm.priority, ',') as middlewares
 		FROM resources r
 		LEFT JOIN resource_middlewares rm ON r.id = rm.resource_id
-		LEFT JOIN middlewares m ON rm.middleware_id = m.id
-		WHERE r.id = ?
+		LEFT JOIN middlewares m ON rm.middleware_id = m.id AND m.deleted_at IS NULL
+		WHERE r.id = ? AND r.deleted_at IS NULL
 		GROUP BY r.id
 	`, id).Scan(&host, &serviceID, &orgID, &siteID, &status, 
 		    &entrypoints, &tlsDomains, &tcpEnabled, &tcpEntrypoints, &tcpSNIRule, 
 		    &customHeaders, &routerPriority, &sourceType, &middlewares)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("resource not found: %s", id)
+		return nil, fmt.Errorf("resource %w: %s", ErrNotFound, id)
 	} else if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -517,11 +856,11 @@ func (db *DB) GetMiddleware(id string) (map[string]interface{}, error) {
 	var name, typ, configStr string
 
 	err := db.QueryRow(
-		"SELECT name, type, config FROM middlewares WHERE id = ?", id,
+		"SELECT name, type, config FROM middlewares WHERE id = ? AND deleted_at IS NULL", id,
 	).Scan(&name, &typ, &configStr)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("middleware not found: %s", id)
+		return nil, fmt.Errorf("middleware %w: %s", ErrNotFound, id)
 	} else if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -593,12 +932,15 @@ func (db *DB) GetServices() ([]map[string]interface{}, error) {
 // GetService fetches a specific service by ID
 func (db *DB) GetService(id string) (map[string]interface{}, error) {
 	var name, typ, configStr string
+	var managed bool
 
 	err := db.QueryRow(
-		"SELECT name, type, config FROM services WHERE id = ?", id,
-	).Scan(&name, &typ, &configStr)
+		"SELECT name, type, config, managed FROM services WHERE id = ?", id,
+	).Scan(&name, &typ, &configStr, &managed)
 
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, id)
+	} else if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
@@ -606,18 +948,20 @@ func (db *DB) GetService(id string) (map[string]interface{}, error) {
 	if err := json.Unmarshal([]byte(configStr), &configMap); err != nil {
 		// If we can't parse the JSON, just return the string
 		return map[string]interface{}{
-			"id":     id,
-			"name":   name,
-			"type":   typ,
-			"config": configStr,
+			"id":      id,
+			"name":    name,
+			"type":    typ,
+			"config":  configStr,
+			"managed": managed,
 		}, nil
 	}
 
 	return map[string]interface{}{
-		"id":     id,
-		"name":   name,
-		"type":   typ,
-		"config": configMap,
+		"id":      id,
+		"name":    name,
+		"type":    typ,
+		"config":  configMap,
+		"managed": managed,
 	}, nil
 }
 
@@ -628,7 +972,9 @@ func (db *DB) GetResourceService(resourceID string) (map[string]interface{}, err
 		"SELECT service_id FROM resource_services WHERE resource_id = ?", resourceID,
 	).Scan(&serviceID)
 
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("service for resource %w: %s", ErrNotFound, resourceID)
+	} else if err != nil {
 		return nil, fmt.Errorf("service relationship query failed: %w", err)
 	}
 
@@ -638,17 +984,11 @@ func (db *DB) GetResourceService(resourceID string) (map[string]interface{}, err
 // AddResourceService associates a service with a resource
 func (db *DB) AddResourceService(resourceID, serviceID string) error {
 	return db.WithTransaction(func(tx *sql.Tx) error {
-		// First, clear any existing service for this resource
-		_, err := tx.Exec("DELETE FROM resource_services WHERE resource_id = ?", resourceID)
-		if err != nil {
-			return fmt.Errorf("failed to clear existing service: %w", err)
-		}
-
-		// Then add the new service
-		_, err = tx.Exec(
-			"INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)",
-			resourceID, serviceID,
-		)
+		// Replace any existing service for this resource
+		_, err := tx.Exec(`
+			INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)
+			ON CONFLICT(resource_id) DO UPDATE SET service_id = excluded.service_id, created_at = CURRENT_TIMESTAMP
+		`, resourceID, serviceID)
 		if err != nil {
 			return fmt.Errorf("failed to add service: %w", err)
 		}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// StateBundle is a point-in-time snapshot of the manager's state
+type StateBundle struct {
+	ExportedAt       time.Time                `json:"exported_at"`
+	Middlewares      []map[string]interface{} `json:"middlewares"`
+	Resources        []map[string]interface{} `json:"resources"`
+	Services         []map[string]interface{} `json:"services"`
+	ResourceServices []map[string]interface{} `json:"resource_services"`
+}
+
+// ExportState collects middlewares, resources, services and resource-service
+// assignments into a single bundle
+func (db *DB) ExportState() (*StateBundle, error) {
+	bundle := &StateBundle{
+		ExportedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if bundle.Middlewares, err = db.GetMiddlewares(); err != nil {
+		return nil, fmt.Errorf("failed to export middlewares: %w", err)
+	}
+	if bundle.Resources, err = db.GetResources(); err != nil {
+		return nil, fmt.Errorf("failed to export resources: %w", err)
+	}
+	if bundle.Services, err = db.GetServices(); err != nil {
+		return nil, fmt.Errorf("failed to export services: %w", err)
+	}
+
+	rows, err := db.Query("SELECT resource_id, service_id FROM resource_services")
+	if err != nil {
+		return nil, fmt.Errorf("failed to export resource services: %w", err)
+	}
+	defer rows.Close()
+
+	bundle.ResourceServices = []map[string]interface{}{}
+	for rows.Next() {
+		var resourceID, serviceID string
+		if err := rows.Scan(&resourceID, &serviceID); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		bundle.ResourceServices = append(bundle.ResourceServices, map[string]interface{}{
+			"resource_id": resourceID,
+			"service_id":  serviceID,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return bundle, nil
+}
@@ -4,6 +4,16 @@ CREATE TABLE IF NOT EXISTS middlewares (
     name TEXT NOT NULL,
     type TEXT NOT NULL,
     config TEXT NOT NULL,
+    -- When set, the stricter field and chain validators are skipped for this middleware
+    unsafe INTEGER DEFAULT 0,
+    -- Optional display metadata for the UI catalog
+    display_name TEXT DEFAULT '',
+    icon TEXT DEFAULT '',
+    category TEXT DEFAULT '',
+    -- Set for middlewares created from the default templates
+    from_template INTEGER DEFAULT 0,
+    -- When the middleware was moved to the trash; NULL for live middlewares
+    deleted_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -23,6 +33,7 @@ CREATE TABLE IF NOT EXISTS resources (
     
     -- TLS certificate configuration
     tls_domains TEXT DEFAULT '',
+    tls_auto_domains INTEGER DEFAULT 0,
     
     -- TCP SNI routing configuration
     tcp_enabled INTEGER DEFAULT 0,
@@ -32,12 +43,27 @@ CREATE TABLE IF NOT EXISTS resources (
     -- Custom headers configuration
     custom_headers TEXT DEFAULT '',
     
+    -- Custom error pages configuration (JSON with status, service and query)
+    error_pages TEXT DEFAULT '',
+    
     -- Router priority configuration
     router_priority INTEGER DEFAULT 100,
     
     -- Source type for tracking data origin
     source_type TEXT DEFAULT '',
     
+    -- 'manual' for resources created through the API (not managed by the watcher)
+    origin TEXT DEFAULT '',
+    
+    -- When set, the Pangolin auth middleware (badger) isn't added to the router
+    skip_auth INTEGER DEFAULT 0,
+    
+    -- When the resource was first missing from the data source, cleared once it's back or disabled
+    missing_since TIMESTAMP,
+    
+    -- When the resource was moved to the trash; NULL for live resources
+    deleted_at TIMESTAMP,
+    
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -53,6 +79,59 @@ CREATE TABLE IF NOT EXISTS resource_middlewares (
     FOREIGN KEY (middleware_id) REFERENCES middlewares(id) ON DELETE CASCADE
 );
 
+-- Resource_status_transitions records each time the resource watcher changes a
+-- resource's status, so resources that keep flapping can be detected
+CREATE TABLE IF NOT EXISTS resource_status_transitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    resource_id TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_status_transitions_resource
+    ON resource_status_transitions (resource_id, changed_at);
+
+-- Last_good_config keeps the most recent generated config that Traefik was
+-- confirmed to have loaded, so it can be restored after a bad change
+CREATE TABLE IF NOT EXISTS last_good_config (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    config TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    confirmed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Policy_groups are named sets of middleware assignments that can be applied
+-- to many resources at once
+CREATE TABLE IF NOT EXISTS policy_groups (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Policy_group_middlewares stores the middlewares of each policy group
+CREATE TABLE IF NOT EXISTS policy_group_middlewares (
+    group_id TEXT NOT NULL,
+    middleware_id TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    PRIMARY KEY (group_id, middleware_id),
+    FOREIGN KEY (group_id) REFERENCES policy_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (middleware_id) REFERENCES middlewares(id) ON DELETE CASCADE
+);
+
+-- Policy_group_resources records the resources a policy group was applied to
+CREATE TABLE IF NOT EXISTS policy_group_resources (
+    group_id TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, resource_id),
+    FOREIGN KEY (group_id) REFERENCES policy_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
+);
+
 -- Insert default middlewares
 INSERT OR IGNORE INTO middlewares (id, name, type, config) VALUES 
 ('authelia', 'Authelia', 'forwardAuth', '{"address":"http://authelia:9091/api/authz/forward-auth","trustForwardHeader":true,"authResponseHeaders":["Remote-User","Remote-Groups","Remote-Name","Remote-Email"]}'),
@@ -65,6 +144,7 @@ CREATE TABLE IF NOT EXISTS services (
     name TEXT NOT NULL,
     type TEXT NOT NULL,
     config TEXT NOT NULL,
+    managed INTEGER DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -116,7 +116,7 @@ func (db *DB) UpdateInTransaction(table string, id string, updates map[string]in
     }
     
     if rowsAffected == 0 {
-      return fmt.Errorf("no rows affected, record with ID %s not found", id)
+      return fmt.Errorf("no rows affected, record with ID %s %w", id, ErrNotFound)
     }
     
     return nil
@@ -15,6 +15,7 @@ import (
 	"github.com/hhftechnology/middleware-manager/api"
 	"github.com/hhftechnology/middleware-manager/config"
 	"github.com/hhftechnology/middleware-manager/database"
+	"github.com/hhftechnology/middleware-manager/models"
 	"github.com/hhftechnology/middleware-manager/services"
 )
 
@@ -51,6 +52,47 @@ type Configuration struct {
 	ActiveDataSource        string
 	TraefikStaticConfigPath string
 	PluginsJSONURL          string
+	ExportStatePath         string
+	ExportInterval          time.Duration
+	OTLPMetricsEndpoint     string
+	OTLPHeaders             map[string]string
+	OTelServiceName         string
+	OTLPMetricsInterval     time.Duration
+	OTLPTracesEndpoint      string
+	OTLPTracesInterval      time.Duration
+	StagingConfDir          string
+	TraefikValidateCmd      string
+	TLSEntrypoints          []string
+	EntrypointAddress       string
+	RedirectPermanent       bool
+	MaintenanceService      string
+	GenerationPauseSchedule string
+	YAMLAnchors             bool
+	FlapWindow              time.Duration
+	FlapThreshold           int
+	ServiceProviderStrategy string
+	DefaultService          string
+	VerifyBackends          string
+	VerifyBackendsInterval  time.Duration
+	PangolinMaxPages        int
+	DuplicateHostPolicy     string
+	SplitRouters            bool
+	ListCacheTTL            time.Duration
+	AccessLog               bool
+	MissingServicePolicy    string
+	AutoTLSDomains          bool
+	DisableGracePeriod      string
+	DefaultMiddlewares      string
+	WebhookURL              string
+	WebhookSecret           string
+	APIAuthUser             string
+	APIAuthPass             string
+	APIAuthToken            string
+	APIAuthExemptHealth     bool
+	YAMLIndent              string
+	YAMLSequenceStyle       string
+	StrictFields            bool
+	TrashRetention          time.Duration
 }
 
 // DiscoverTraefikAPI attempts to discover the Traefik API by trying common URLs
@@ -128,12 +170,20 @@ func main() {
     log.Println("Performing full database cleanup...")
     cleanupOpts := database.DefaultCleanupOptions()
     cleanupOpts.LogLevel = 2 // More verbose logging during startup
+    cleanupOpts.TrashRetention = cfg.TrashRetention
     
     if err := db.PerformFullCleanup(cleanupOpts); err != nil {
         log.Printf("Warning: Database cleanup encountered issues: %v", err)
     } else {
         log.Println("Database cleanup completed successfully")
     }
+    
+    // Keep purging the trash while running
+    if cfg.TrashRetention > 0 {
+        purgeOpts := cleanupOpts
+        purgeOpts.LogLevel = 1
+        go db.StartTrashPurge(purgeOpts, time.Hour)
+    }
 
     configManager, err := services.NewConfigManager(filepath.Join(configDir, "config.json"))
     if err != nil {
@@ -142,26 +192,123 @@ func main() {
 
     configManager.EnsureDefaultDataSources(cfg.PangolinAPIURL, cfg.TraefikAPIURL)
 
+    // Set up tracing before the watchers start so their first runs are traced
+    var traceExporter *services.OTLPTraceExporter
+    if cfg.OTLPTracesEndpoint != "" {
+        traceExporter = services.NewOTLPTraceExporter(cfg.OTLPTracesEndpoint, cfg.OTLPHeaders, cfg.OTelServiceName)
+        services.SetTraceExporter(traceExporter)
+        go traceExporter.Start(cfg.OTLPTracesInterval)
+    }
+
     stopChan := make(chan struct{})
 
+    services.SetFlapDetection(cfg.FlapWindow, cfg.FlapThreshold)
+    services.SetPangolinMaxPages(cfg.PangolinMaxPages)
     resourceWatcher, err := services.NewResourceWatcher(db, configManager)
     if err != nil {
         log.Fatalf("Failed to create resource watcher: %v", err)
     }
+    resourceWatcher.SetDefaultService(cfg.DefaultService)
+    disableGrace, err := services.ParseDisableGracePeriod(cfg.DisableGracePeriod)
+    if err != nil {
+        log.Fatalf("Invalid DISABLE_GRACE_PERIOD: %v", err)
+    }
+    resourceWatcher.SetDisableGracePeriod(disableGrace)
+    defaultMiddlewares, err := services.ParseDefaultMiddlewares(cfg.DefaultMiddlewares)
+    if err != nil {
+        log.Fatalf("Invalid DEFAULT_MIDDLEWARES: %v", err)
+    }
+    resourceWatcher.SetDefaultMiddlewares(defaultMiddlewares)
     go resourceWatcher.Start(cfg.CheckInterval)
 
     configGenerator := services.NewConfigGenerator(db, cfg.TraefikConfDir, configManager)
+    configGenerator.SetTLSEntrypoints(cfg.TLSEntrypoints)
+    configGenerator.SetEntrypointAddress(cfg.EntrypointAddress)
+    configGenerator.SetMaintenanceService(cfg.MaintenanceService)
+    configGenerator.SetDefaultService(cfg.DefaultService)
+    configGenerator.SetYAMLAnchors(cfg.YAMLAnchors)
+    yamlIndent, err := services.ParseYAMLIndent(cfg.YAMLIndent)
+    if err != nil {
+        log.Fatalf("Invalid YAML_INDENT: %v", err)
+    }
+    yamlSequenceStyle, err := services.ParseYAMLSequenceStyle(cfg.YAMLSequenceStyle)
+    if err != nil {
+        log.Fatalf("Invalid YAML_SEQUENCE_STYLE: %v", err)
+    }
+    configGenerator.SetYAMLFormat(yamlIndent, yamlSequenceStyle)
+    if cfg.GenerationPauseSchedule != "" {
+        schedule, err := services.ParsePauseSchedule(cfg.GenerationPauseSchedule)
+        if err != nil {
+            log.Fatalf("Invalid GENERATION_PAUSE_SCHEDULE: %v", err)
+        }
+        configGenerator.SetPauseSchedule(schedule)
+    }
+    providerStrategy, err := services.ParseServiceProviderStrategy(cfg.ServiceProviderStrategy)
+    if err != nil {
+        log.Fatalf("Invalid SERVICE_PROVIDER_STRATEGY: %v", err)
+    }
+    configGenerator.SetServiceProviderStrategy(providerStrategy)
+    verifyMode, err := services.ParseBackendVerifyMode(cfg.VerifyBackends)
+    if err != nil {
+        log.Fatalf("Invalid VERIFY_BACKENDS: %v", err)
+    }
+    configGenerator.SetBackendVerification(verifyMode, cfg.VerifyBackendsInterval)
+    duplicateHostPolicy, err := services.ParseDuplicateHostPolicy(cfg.DuplicateHostPolicy)
+    if err != nil {
+        log.Fatalf("Invalid DUPLICATE_HOST_POLICY: %v", err)
+    }
+    configGenerator.SetDuplicateHostPolicy(duplicateHostPolicy)
+    configGenerator.SetSplitRoutersPerEntrypoint(cfg.SplitRouters)
+    configGenerator.SetAutoTLSDomains(cfg.AutoTLSDomains)
+    missingServicePolicy, err := services.ParseMissingServicePolicy(cfg.MissingServicePolicy)
+    if err != nil {
+        log.Fatalf("Invalid MISSING_SERVICE_POLICY: %v", err)
+    }
+    configGenerator.SetMissingServicePolicy(missingServicePolicy)
+    webhookURL, err := services.ParseWebhookURL(cfg.WebhookURL)
+    if err != nil {
+        log.Fatalf("Invalid WEBHOOK_URL: %v", err)
+    }
+    configGenerator.SetWebhook(webhookURL, cfg.WebhookSecret)
+    if webhookURL == "" && cfg.WebhookSecret != "" {
+        log.Printf("Warning: WEBHOOK_SECRET is ignored because WEBHOOK_URL is not set")
+    }
+    models.SetRedirectSchemePermanentDefault(cfg.RedirectPermanent)
+    models.SetStrictMiddlewareFields(cfg.StrictFields)
+    if cfg.StagingConfDir != "" {
+        configGenerator.SetStaging(cfg.StagingConfDir, cfg.TraefikValidateCmd)
+    } else if cfg.TraefikValidateCmd != "" {
+        log.Printf("Warning: TRAEFIK_VALIDATE_CMD is ignored because STAGING_CONF_DIR is not set")
+    }
     go configGenerator.Start(cfg.GenerateInterval)
 
+    apiAuth, err := api.ParseAPIAuth(cfg.APIAuthUser, cfg.APIAuthPass, cfg.APIAuthToken)
+    if err != nil {
+        log.Fatalf("Invalid API auth settings: %v", err)
+    }
     serverConfig := api.ServerConfig{
-        Port:       cfg.Port,
-        UIPath:     cfg.UIPath,
-        Debug:      cfg.Debug,
-        AllowCORS:  cfg.AllowCORS,
-        CORSOrigin: cfg.CORSOrigin,
+        Port:             cfg.Port,
+        UIPath:           cfg.UIPath,
+        Debug:            cfg.Debug,
+        AllowCORS:        cfg.AllowCORS,
+        CORSOrigin:       cfg.CORSOrigin,
+        ListCacheTTL:     cfg.ListCacheTTL,
+        AccessLog:        cfg.AccessLog,
+        Auth:             apiAuth,
+        AuthExemptHealth: cfg.APIAuthExemptHealth,
     }
 
-    server := api.NewServer(db.DB, serverConfig, configManager, cfg.TraefikStaticConfigPath, cfg.PluginsJSONURL)
+    serviceWatcher, err := services.NewServiceWatcher(db, configManager)
+    if err != nil {
+        log.Printf("Warning: Failed to create service watcher: %v", err)
+        serviceWatcher = nil
+    } else {
+        go serviceWatcher.Start(cfg.ServiceInterval)
+    }
+
+    reloader := services.NewReloader(resourceWatcher, serviceWatcher, configGenerator)
+
+    server := api.NewServer(db.DB, serverConfig, configManager, configGenerator, resourceWatcher, reloader, cfg.TraefikStaticConfigPath, cfg.PluginsJSONURL)
     go func() {
         if err := server.Start(); err != nil {
             log.Printf("Server error: %v", err)
@@ -172,12 +319,16 @@ func main() {
     signalChan := make(chan os.Signal, 1)
     signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-    serviceWatcher, err := services.NewServiceWatcher(db, configManager)
-    if err != nil {
-        log.Printf("Warning: Failed to create service watcher: %v", err)
-        serviceWatcher = nil
-    } else {
-        go serviceWatcher.Start(cfg.ServiceInterval)
+    var stateExporter *services.StateExporter
+    if cfg.ExportStatePath != "" {
+        stateExporter = services.NewStateExporter(db, cfg.ExportStatePath)
+        go stateExporter.Start(cfg.ExportInterval)
+    }
+
+    var metricsExporter *services.OTLPMetricsExporter
+    if cfg.OTLPMetricsEndpoint != "" {
+        metricsExporter = services.NewOTLPMetricsExporter(cfg.OTLPMetricsEndpoint, cfg.OTLPHeaders, cfg.OTelServiceName)
+        go metricsExporter.Start(cfg.OTLPMetricsInterval)
     }
 
     select {
@@ -192,11 +343,37 @@ func main() {
     if serviceWatcher != nil {
         serviceWatcher.Stop()
     }
+    if stateExporter != nil {
+        stateExporter.Stop()
+    }
+    if metricsExporter != nil {
+        metricsExporter.Stop()
+    }
+    if traceExporter != nil {
+        traceExporter.Stop()
+    }
     configGenerator.Stop()
     server.Stop()
     log.Println("Middleware Manager stopped")
 }
 
+// Minimum intervals enforced on the environment settings so a misconfiguration
+// can't make the watchers hammer the data source or the generator thrash Traefik
+const (
+	minCheckInterval    = 5 * time.Second
+	minGenerateInterval = 2 * time.Second
+	minServiceInterval  = 5 * time.Second
+)
+
+// clampInterval raises an interval to its minimum, logging a warning when it does
+func clampInterval(name string, interval, minimum time.Duration) time.Duration {
+	if interval < minimum {
+		log.Printf("Warning: %s of %v is below the minimum, using %v", name, interval, minimum)
+		return minimum
+	}
+	return interval
+}
+
 func loadConfiguration(debug bool) Configuration {
 	checkInterval := 30 * time.Second
 	if intervalStr := getEnv("CHECK_INTERVAL_SECONDS", "30"); intervalStr != "" {
@@ -204,6 +381,7 @@ func loadConfiguration(debug bool) Configuration {
 			checkInterval = time.Duration(interval) * time.Second
 		}
 	}
+	checkInterval = clampInterval("CHECK_INTERVAL_SECONDS", checkInterval, minCheckInterval)
 
 	generateInterval := 10 * time.Second
 	if intervalStr := getEnv("GENERATE_INTERVAL_SECONDS", "10"); intervalStr != "" {
@@ -211,6 +389,7 @@ func loadConfiguration(debug bool) Configuration {
 			generateInterval = time.Duration(interval) * time.Second
 		}
 	}
+	generateInterval = clampInterval("GENERATE_INTERVAL_SECONDS", generateInterval, minGenerateInterval)
 
 	parsedServiceInterval := 30 * time.Second
 	if intervalStr := getEnv("SERVICE_INTERVAL_SECONDS", "30"); intervalStr != "" {
@@ -218,6 +397,81 @@ func loadConfiguration(debug bool) Configuration {
 			parsedServiceInterval = time.Duration(interval) * time.Second
 		}
 	}
+	parsedServiceInterval = clampInterval("SERVICE_INTERVAL_SECONDS", parsedServiceInterval, minServiceInterval)
+
+	exportInterval := 300 * time.Second
+	if intervalStr := getEnv("EXPORT_INTERVAL", "300"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			exportInterval = time.Duration(interval) * time.Second
+		}
+	}
+
+	// Follows the standard OpenTelemetry exporter environment variables
+	otlpMetricsEndpoint := getOTLPEndpoint("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "/v1/metrics")
+	otlpTracesEndpoint := getOTLPEndpoint("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "/v1/traces")
+
+	otlpTracesInterval := 5 * time.Second
+	if intervalStr := getEnv("OTEL_BSP_SCHEDULE_DELAY", "5000"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			otlpTracesInterval = time.Duration(interval) * time.Millisecond
+		}
+	}
+
+	otlpMetricsInterval := 60 * time.Second
+	if intervalStr := getEnv("OTEL_METRIC_EXPORT_INTERVAL", "60000"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			otlpMetricsInterval = time.Duration(interval) * time.Millisecond
+		}
+	}
+
+	flapWindow := time.Hour
+	if windowStr := getEnv("RESOURCE_FLAP_WINDOW_SECONDS", "3600"); windowStr != "" {
+		if window, err := strconv.Atoi(windowStr); err == nil && window > 0 {
+			flapWindow = time.Duration(window) * time.Second
+		}
+	}
+
+	flapThreshold := 4
+	if thresholdStr := getEnv("RESOURCE_FLAP_THRESHOLD", "4"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold > 0 {
+			flapThreshold = threshold
+		}
+	}
+
+	pangolinMaxPages := 50
+	if pagesStr := getEnv("PANGOLIN_MAX_PAGES", "50"); pagesStr != "" {
+		if pages, err := strconv.Atoi(pagesStr); err == nil && pages > 0 {
+			pangolinMaxPages = pages
+		}
+	}
+
+	trashRetention := database.DefaultTrashRetention
+	if daysStr := getEnv("TRASH_RETENTION_DAYS", "30"); daysStr != "" {
+		if days, err := strconv.Atoi(daysStr); err == nil && days >= 0 {
+			trashRetention = time.Duration(days) * 24 * time.Hour
+		}
+	}
+
+	var listCacheTTL time.Duration
+	if ttlStr := getEnv("LIST_CACHE_SECONDS", "0"); ttlStr != "" {
+		if ttl, err := strconv.Atoi(ttlStr); err == nil && ttl > 0 {
+			listCacheTTL = time.Duration(ttl) * time.Second
+		}
+	}
+
+	verifyBackendsInterval := 60 * time.Second
+	if intervalStr := getEnv("VERIFY_BACKENDS_INTERVAL_SECONDS", "60"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			verifyBackendsInterval = time.Duration(interval) * time.Second
+		}
+	}
+
+	var tlsEntrypoints []string
+	for _, ep := range strings.Split(getEnv("TLS_ENTRYPOINTS", "websecure"), ",") {
+		if ep = strings.TrimSpace(ep); ep != "" {
+			tlsEntrypoints = append(tlsEntrypoints, ep)
+		}
+	}
 
 	allowCORS := false
 	if corsStr := getEnv("ALLOW_CORS", "false"); corsStr != "" {
@@ -245,7 +499,60 @@ func loadConfiguration(debug bool) Configuration {
 		CORSOrigin:              getEnv("CORS_ORIGIN", ""),
 		TraefikStaticConfigPath: getEnv("TRAEFIK_STATIC_CONFIG_PATH", "/etc/traefik/traefik.yml"),
 		PluginsJSONURL:          getEnv("PLUGINS_JSON_URL", "https://raw.githubusercontent.com/hhftechnology/middleware-manager/traefik-int/plugin/plugins.json"),
+		ExportStatePath:         getEnv("EXPORT_STATE_PATH", ""),
+		ExportInterval:          exportInterval,
+		OTLPMetricsEndpoint:     otlpMetricsEndpoint,
+		OTLPHeaders:             services.ParseOTLPHeaders(getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		OTelServiceName:         getEnv("OTEL_SERVICE_NAME", "middleware-manager"),
+		OTLPMetricsInterval:     otlpMetricsInterval,
+		OTLPTracesEndpoint:      otlpTracesEndpoint,
+		OTLPTracesInterval:      otlpTracesInterval,
+		StagingConfDir:          getEnv("STAGING_CONF_DIR", ""),
+		TraefikValidateCmd:      getEnv("TRAEFIK_VALIDATE_CMD", ""),
+		TLSEntrypoints:          tlsEntrypoints,
+		EntrypointAddress:       getEnv("TRAEFIK_ENTRYPOINT_ADDRESS", ""),
+		MaintenanceService:      getEnv("MAINTENANCE_SERVICE", ""),
+		GenerationPauseSchedule: getEnv("GENERATION_PAUSE_SCHEDULE", ""),
+		YAMLAnchors:             strings.ToLower(getEnv("YAML_ANCHORS", "false")) == "true",
+		FlapWindow:              flapWindow,
+		FlapThreshold:           flapThreshold,
+		ServiceProviderStrategy: getEnv("SERVICE_PROVIDER_STRATEGY", "auto"),
+		DefaultService:          getEnv("DEFAULT_SERVICE", ""),
+		VerifyBackends:          strings.ToLower(getEnv("VERIFY_BACKENDS", "off")),
+		VerifyBackendsInterval:  verifyBackendsInterval,
+		PangolinMaxPages:        pangolinMaxPages,
+		DuplicateHostPolicy:     strings.ToLower(getEnv("DUPLICATE_HOST_POLICY", "warn")),
+		SplitRouters:            strings.ToLower(getEnv("SPLIT_ROUTERS_PER_ENTRYPOINT", "false")) == "true",
+		ListCacheTTL:            listCacheTTL,
+		AccessLog:               strings.ToLower(getEnv("ACCESS_LOG", "false")) == "true",
+		MissingServicePolicy:    strings.ToLower(getEnv("MISSING_SERVICE_POLICY", "fallback")),
+		AutoTLSDomains:          strings.ToLower(getEnv("TLS_AUTO_DOMAINS", "false")) == "true",
+		DisableGracePeriod:      getEnv("DISABLE_GRACE_PERIOD", ""),
+		DefaultMiddlewares:      getEnv("DEFAULT_MIDDLEWARES", ""),
+		WebhookURL:              getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:           getEnv("WEBHOOK_SECRET", ""),
+		APIAuthUser:             getEnv("API_AUTH_USER", ""),
+		APIAuthPass:             getEnv("API_AUTH_PASS", ""),
+		APIAuthToken:            getEnv("API_AUTH_TOKEN", ""),
+		APIAuthExemptHealth:     strings.ToLower(getEnv("API_AUTH_EXEMPT_HEALTH", "true")) != "false",
+		YAMLIndent:              getEnv("YAML_INDENT", ""),
+		YAMLSequenceStyle:       strings.ToLower(getEnv("YAML_SEQUENCE_STYLE", "block")),
+		StrictFields:            strings.ToLower(getEnv("STRICT_FIELDS", "false")) == "true",
+		TrashRetention:          trashRetention,
+		RedirectPermanent:       strings.ToLower(getEnv("REDIRECT_SCHEME_PERMANENT_DEFAULT", "true")) != "false",
+	}
+}
+
+// getOTLPEndpoint returns the signal-specific OTLP endpoint if set, otherwise the
+// shared OTEL_EXPORTER_OTLP_ENDPOINT with the signal's path appended
+func getOTLPEndpoint(signalKey, path string) string {
+	if endpoint := getEnv(signalKey, ""); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + path
 	}
+	return ""
 }
 
 func getEnv(key, fallback string) string {
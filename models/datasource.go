@@ -1,6 +1,7 @@
 package models
 
 import (
+    "net/http"
     "strings"
 )
 
@@ -12,6 +13,10 @@ type DataSourceType string
 const (
     PangolinAPI DataSourceType = "pangolin"
     TraefikAPI  DataSourceType = "traefik"
+    // DockerAPI reads Traefik labels from containers via the Docker Engine API
+    DockerAPI   DataSourceType = "docker"
+    // TraefikFile reads Traefik dynamic config files (file provider) from disk
+    TraefikFile DataSourceType = "file"
 )
 
 // DataSourceConfig represents configuration for a data source
@@ -22,6 +27,31 @@ type DataSourceConfig struct {
         Username string `json:"username"`
         Password string `json:"password"`
     } `json:"basic_auth,omitempty"`
+    // Headers are extra HTTP headers sent with every request (e.g. bearer tokens for an auth proxy)
+    Headers map[string]string   `json:"headers,omitempty"`
+    TLS     DataSourceTLSConfig `json:"tls,omitempty"`
+}
+
+// DataSourceTLSConfig holds client TLS settings for HTTPS data sources.
+// File paths point to PEM-encoded files readable by the manager.
+type DataSourceTLSConfig struct {
+    CAFile             string `json:"ca_file,omitempty"`
+    CertFile           string `json:"cert_file,omitempty"`
+    KeyFile            string `json:"key_file,omitempty"`
+    InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// MaskedSecret is displayed in place of secret values
+const MaskedSecret = "••••••••"
+
+// ApplyToRequest adds the configured basic auth and custom headers to a request
+func (dc DataSourceConfig) ApplyToRequest(req *http.Request) {
+    if dc.BasicAuth.Username != "" {
+        req.SetBasicAuth(dc.BasicAuth.Username, dc.BasicAuth.Password)
+    }
+    for name, value := range dc.Headers {
+        req.Header.Set(name, value)
+    }
 }
 
 // SystemConfig represents the overall system configuration
@@ -61,10 +91,20 @@ type ResourceCollection struct {
 }
 
 // FormatBasicAuth formats the basic auth field to mask the password
+// and masks custom header values, which usually carry credentials
 func (dc *DataSourceConfig) FormatBasicAuth() {
     // If the password is not empty, mask it for display
     if dc.BasicAuth.Password != "" {
-        dc.BasicAuth.Password = "••••••••" // Mask the password
+        dc.BasicAuth.Password = MaskedSecret // Mask the password
+    }
+    
+    // Copy the headers so the stored config isn't modified
+    if len(dc.Headers) > 0 {
+        masked := make(map[string]string, len(dc.Headers))
+        for name := range dc.Headers {
+            masked[name] = MaskedSecret
+        }
+        dc.Headers = masked
     }
 }
 
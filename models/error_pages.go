@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultErrorPagesQuery is the page requested from the error service when a
+// resource's error pages don't set a query
+const DefaultErrorPagesQuery = "/{status}.html"
+
+// ErrorPages configures a resource's custom error pages. Responses with a
+// status in one of the Status ranges are replaced by the page Service returns
+// for Query, in which {status} stands for the original status code.
+type ErrorPages struct {
+	Status  []string `json:"status"`
+	Service string   `json:"service"`
+	Query   string   `json:"query"`
+}
+
+// Validate checks the status ranges and service
+func (p ErrorPages) Validate() error {
+	if len(p.Status) == 0 {
+		return fmt.Errorf("status must list at least one status code or range")
+	}
+	for _, status := range p.Status {
+		if err := validateStatusRange(status); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(p.Service) == "" {
+		return fmt.Errorf("service is required")
+	}
+	if p.Query != "" && !strings.HasPrefix(p.Query, "/") {
+		return fmt.Errorf("query must start with /, got %q", p.Query)
+	}
+	return nil
+}
+
+// MiddlewareConfig returns the errors middleware config for the pages
+func (p ErrorPages) MiddlewareConfig() map[string]interface{} {
+	status := make([]interface{}, len(p.Status))
+	for i, s := range p.Status {
+		status[i] = s
+	}
+	query := p.Query
+	if query == "" {
+		query = DefaultErrorPagesQuery
+	}
+	return map[string]interface{}{
+		"status":  status,
+		"service": p.Service,
+		"query":   query,
+	}
+}
+
+// validateStatusRange checks a status code such as 404 or a range such as
+// 500-599, as accepted by Traefik's errors middleware
+func validateStatusRange(value string) error {
+	parts := strings.SplitN(value, "-", 2)
+	codes := make([]int, len(parts))
+	for i, part := range parts {
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("status %q must be a status code from 100 to 599 or a range such as 500-599", value)
+		}
+		codes[i] = code
+	}
+	if len(codes) == 2 && codes[0] > codes[1] {
+		return fmt.Errorf("status range %q starts after it ends", value)
+	}
+	return nil
+}
@@ -8,12 +8,16 @@ import (
 
 // Middleware represents a Traefik middleware configuration
 type Middleware struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Type      string    `json:"type"`
-	Config    string    `json:"config"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Config      string    `json:"config"`
+	Unsafe      bool      `json:"unsafe"` // Opts out of the stricter field and chain validators
+	DisplayName string    `json:"display_name"`
+	Icon        string    `json:"icon"`     // Icon URL or path for the UI
+	Category    string    `json:"category"` // Used by the UI to group middlewares
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // ConfigMap returns the middleware config as a map
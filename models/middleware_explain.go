@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConfigCoercion is a config value that processing changes before the config
+// is generated, such as the string "true" becoming a boolean or a numeric
+// redirectScheme port becoming a string. Fields added by processing have
+// from_type "missing".
+type ConfigCoercion struct {
+	Path     string      `json:"path"`
+	From     interface{} `json:"from"`
+	To       interface{} `json:"to"`
+	FromType string      `json:"from_type"`
+	ToType   string      `json:"to_type"`
+}
+
+// ExplainMiddlewareConfig runs a copy of a stored middleware config through
+// ProcessMiddlewareConfig and lists every value that came out different,
+// ordered by path. The config itself is left untouched and secret references
+// are not resolved.
+func ExplainMiddlewareConfig(middlewareType string, config map[string]interface{}) ([]ConfigCoercion, error) {
+	// The processors change the config in place, so work on a copy
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var processed map[string]interface{}
+	if err := json.Unmarshal(encoded, &processed); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	processed = ProcessMiddlewareConfig(middlewareType, processed)
+
+	coercions := []ConfigCoercion{}
+	diffConfigValues("", config, processed, &coercions)
+	return coercions, nil
+}
+
+// diffConfigValues records where after differs from before, descending into
+// maps and into lists whose length is unchanged
+func diffConfigValues(path string, before, after interface{}, coercions *[]ConfigCoercion) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			keys := make(map[string]bool, len(b)+len(a))
+			for key := range b {
+				keys[key] = true
+			}
+			for key := range a {
+				keys[key] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for key := range keys {
+				sorted = append(sorted, key)
+			}
+			sort.Strings(sorted)
+
+			for _, key := range sorted {
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				beforeValue, inBefore := b[key]
+				afterValue, inAfter := a[key]
+				switch {
+				case !inBefore:
+					*coercions = append(*coercions, ConfigCoercion{Path: childPath, To: afterValue, FromType: "missing", ToType: valueTypeName(afterValue)})
+				case !inAfter:
+					*coercions = append(*coercions, ConfigCoercion{Path: childPath, From: beforeValue, FromType: valueTypeName(beforeValue), ToType: "missing"})
+				default:
+					diffConfigValues(childPath, beforeValue, afterValue, coercions)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok && len(a) == len(b) {
+			for i := range b {
+				diffConfigValues(fmt.Sprintf("%s[%d]", path, i), b[i], a[i], coercions)
+			}
+			return
+		}
+	}
+
+	beforeType, afterType := valueTypeName(before), valueTypeName(after)
+	if beforeType != afterType || !reflect.DeepEqual(before, after) {
+		*coercions = append(*coercions, ConfigCoercion{Path: path, From: before, To: after, FromType: beforeType, ToType: afterType})
+	}
+}
+
+// valueTypeName names the type of a decoded config value. Numbers decoded
+// from JSON are "number"; processing turns whole ones into "integer".
+func valueTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64:
+		return "integer"
+	case float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// middlewareLints are soft checks for configs that are valid but likely a
+// mistake, keyed by middleware type. Each returns a warning per finding.
+var middlewareLints = map[string]func(config map[string]interface{}) []string{
+	"ipWhiteList":    lintIPAllowList,
+	"ipAllowList":    lintIPAllowList,
+	"rateLimit":      lintRateLimit,
+	"headers":        lintHeaders,
+	"forwardAuth":    lintForwardAuth,
+	"redirectRegex":  lintRedirect,
+	"redirectScheme": lintRedirect,
+}
+
+// LintMiddlewareConfig returns warnings about a middleware config that passes
+// validation but probably doesn't do what was intended. Warnings never block
+// saving the middleware.
+func LintMiddlewareConfig(middlewareType string, config map[string]interface{}) []string {
+	var warnings []string
+	if lint, ok := middlewareLints[middlewareType]; ok {
+		warnings = lint(config)
+	}
+	// With STRICT_FIELDS these are validation errors instead
+	if !strictMiddlewareFields {
+		for _, key := range unknownTopLevelFields(middlewareType, config) {
+			warnings = append(warnings, fmt.Sprintf("%s is not a known %s field; Traefik may ignore it or reject the middleware", key, middlewareType))
+		}
+	}
+	return warnings
+}
+
+// lintIPAllowList flags source ranges that match every address
+func lintIPAllowList(config map[string]interface{}) []string {
+	var warnings []string
+	for _, source := range stringItems(config["sourceRange"]) {
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			continue
+		}
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			warnings = append(warnings, fmt.Sprintf("sourceRange: %s matches every %s address, so this middleware lets all clients through", source, ipFamily(network.IP)))
+		}
+	}
+	return warnings
+}
+
+// ipFamily names the address family of an IP for warnings
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// lintRateLimit flags averages that disable the limit and bursts below the average
+func lintRateLimit(config map[string]interface{}) []string {
+	var warnings []string
+	average, hasAverage := numberValue(config["average"])
+	burst, hasBurst := numberValue(config["burst"])
+
+	if hasAverage && average == 0 {
+		warnings = append(warnings, "average: 0 disables rate limiting")
+	}
+	if hasAverage && hasBurst && burst > 0 && burst < average {
+		warnings = append(warnings, fmt.Sprintf("burst (%v) is lower than average (%v); requests beyond %v at once are rejected even while the average rate is respected", burst, average, burst))
+	}
+	return warnings
+}
+
+// lintHeaders flags CORS settings that browsers refuse to combine
+func lintHeaders(config map[string]interface{}) []string {
+	credentials, _ := config["accessControlAllowCredentials"].(bool)
+	if !credentials {
+		return nil
+	}
+	for _, origin := range stringItems(config["accessControlAllowOriginList"]) {
+		if origin == "*" {
+			return []string{"accessControlAllowOriginList: browsers reject credentialed requests when the allowed origin is *, list the origins instead"}
+		}
+	}
+	return nil
+}
+
+// lintForwardAuth flags disabled certificate verification of the auth server
+func lintForwardAuth(config map[string]interface{}) []string {
+	if skip, ok := lookupConfigPath(config, "tls.insecureSkipVerify"); ok && skip == true {
+		return []string{"tls.insecureSkipVerify: the auth server's certificate is not verified"}
+	}
+	return nil
+}
+
+// Fields sometimes set on redirect middlewares expecting to pick the status code
+var redirectStatusFields = []string{"statusCode", "status", "code"}
+
+// lintRedirect flags attempts to choose a redirect's status code. Traefik
+// derives it from permanent and the request method: 302 (307 for methods other
+// than GET) when temporary, 301 (308) when permanent.
+func lintRedirect(config map[string]interface{}) []string {
+	var warnings []string
+	for _, field := range redirectStatusFields {
+		if _, ok := config[field]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s: has no effect, the status is 302/307 or, with permanent: true, 301/308 (307 and 308 keep the request method)", field))
+		}
+	}
+	// "true" and "false" strings are turned into booleans on generation
+	if permanent, ok := config["permanent"]; ok {
+		s, isString := permanent.(string)
+		if _, isBool := permanent.(bool); !isBool && !(isString && (s == "true" || s == "false")) {
+			warnings = append(warnings, fmt.Sprintf("permanent: %v is not a boolean, use true or false", permanent))
+		}
+	}
+	return warnings
+}
+
+// numberValue reads a JSON number, or a string holding one
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,443 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType describes how a middleware config field should be interpreted
+type FieldType string
+
+const (
+	FieldString         FieldType = "string"
+	FieldBoolean        FieldType = "boolean"
+	FieldInteger        FieldType = "integer"
+	FieldNumber         FieldType = "number"
+	FieldDuration       FieldType = "duration"
+	FieldURL            FieldType = "url"
+	FieldRegex          FieldType = "regex"
+	FieldSecret         FieldType = "secret"
+	FieldStringList     FieldType = "string_list"
+	FieldCIDRList       FieldType = "cidr_list"
+	FieldRegexList      FieldType = "regex_list"
+	FieldSecretList     FieldType = "secret_list"
+	FieldMiddlewareList FieldType = "middleware_list"
+	FieldHeaderMap      FieldType = "header_map"
+	FieldList           FieldType = "list"
+	FieldMap            FieldType = "map"
+)
+
+// Known fields for each middleware type, keyed by dotted path
+var middlewareSchemas = map[string]map[string]FieldType{
+	"basicAuth": {
+		"users":        FieldSecretList,
+		"usersFile":    FieldString,
+		"realm":        FieldString,
+		"removeHeader": FieldBoolean,
+		"headerField":  FieldString,
+	},
+	"digestAuth": {
+		"users":        FieldSecretList,
+		"usersFile":    FieldString,
+		"realm":        FieldString,
+		"removeHeader": FieldBoolean,
+		"headerField":  FieldString,
+	},
+	"forwardAuth": {
+		"address":                  FieldURL,
+		"trustForwardHeader":       FieldBoolean,
+		"authResponseHeaders":      FieldStringList,
+		"authResponseHeadersRegex": FieldRegex,
+		"authRequestHeaders":       FieldStringList,
+		"addAuthCookiesToResponse": FieldStringList,
+		"headerField":              FieldString,
+		"forwardBody":              FieldBoolean,
+		"maxBodySize":              FieldInteger,
+		"preserveLocationHeader":   FieldBoolean,
+		"preserveRequestMethod":    FieldBoolean,
+		"tls.ca":                   FieldString,
+		"tls.caOptional":           FieldBoolean,
+		"tls.cert":                 FieldString,
+		"tls.key":                  FieldString,
+		"tls.insecureSkipVerify":   FieldBoolean,
+	},
+	"ipWhiteList": {
+		"sourceRange":            FieldCIDRList,
+		"ipStrategy.depth":       FieldInteger,
+		"ipStrategy.excludedIPs": FieldCIDRList,
+	},
+	"ipAllowList": {
+		"sourceRange":            FieldCIDRList,
+		"ipStrategy.depth":       FieldInteger,
+		"ipStrategy.excludedIPs": FieldCIDRList,
+		"ipStrategy.ipv6Subnet":  FieldInteger,
+		"rejectStatusCode":       FieldInteger,
+	},
+	"rateLimit": {
+		"average":                                FieldInteger,
+		"burst":                                  FieldInteger,
+		"period":                                 FieldDuration,
+		"sourceCriterion.ipStrategy.depth":       FieldInteger,
+		"sourceCriterion.ipStrategy.excludedIPs": FieldCIDRList,
+		"sourceCriterion.ipStrategy.ipv6Subnet":  FieldInteger,
+		"sourceCriterion.requestHeaderName":      FieldString,
+		"sourceCriterion.requestHost":            FieldBoolean,
+	},
+	"inFlightReq": {
+		"amount":                                 FieldInteger,
+		"sourceCriterion.ipStrategy.depth":       FieldInteger,
+		"sourceCriterion.ipStrategy.excludedIPs": FieldCIDRList,
+		"sourceCriterion.ipStrategy.ipv6Subnet":  FieldInteger,
+		"sourceCriterion.requestHeaderName":      FieldString,
+		"sourceCriterion.requestHost":            FieldBoolean,
+	},
+	"headers": {
+		"customRequestHeaders":              FieldHeaderMap,
+		"customResponseHeaders":             FieldHeaderMap,
+		"accessControlAllowCredentials":     FieldBoolean,
+		"accessControlAllowHeaders":         FieldStringList,
+		"accessControlAllowMethods":         FieldStringList,
+		"accessControlAllowOriginList":      FieldStringList,
+		"accessControlAllowOriginListRegex": FieldRegexList,
+		"accessControlExposeHeaders":        FieldStringList,
+		"accessControlMaxAge":               FieldInteger,
+		"addVaryHeader":                     FieldBoolean,
+		"allowedHosts":                      FieldStringList,
+		"hostsProxyHeaders":                 FieldStringList,
+		"sslRedirect":                       FieldBoolean,
+		"sslTemporaryRedirect":              FieldBoolean,
+		"sslHost":                           FieldString,
+		"sslForceHost":                      FieldBoolean,
+		"sslProxyHeaders":                   FieldHeaderMap,
+		"stsSeconds":                        FieldInteger,
+		"stsIncludeSubdomains":              FieldBoolean,
+		"stsPreload":                        FieldBoolean,
+		"forceSTSHeader":                    FieldBoolean,
+		"frameDeny":                         FieldBoolean,
+		"customFrameOptionsValue":           FieldString,
+		"contentTypeNosniff":                FieldBoolean,
+		"browserXssFilter":                  FieldBoolean,
+		"customBrowserXSSValue":             FieldString,
+		"contentSecurityPolicy":             FieldString,
+		"contentSecurityPolicyReportOnly":   FieldString,
+		"publicKey":                         FieldString,
+		"referrerPolicy":                    FieldString,
+		"featurePolicy":                     FieldString,
+		"permissionsPolicy":                 FieldString,
+		"isDevelopment":                     FieldBoolean,
+	},
+	"stripPrefix": {
+		"prefixes":   FieldStringList,
+		"forceSlash": FieldBoolean,
+	},
+	"stripPrefixRegex": {
+		"regex": FieldRegexList,
+	},
+	"addPrefix": {
+		"prefix": FieldString,
+	},
+	"redirectRegex": {
+		"regex":       FieldRegex,
+		"replacement": FieldString,
+		"permanent":   FieldBoolean,
+	},
+	"redirectScheme": {
+		"scheme":    FieldString,
+		"port":      FieldString,
+		"permanent": FieldBoolean,
+	},
+	"replacePath": {
+		"path": FieldString,
+	},
+	"replacePathRegex": {
+		"regex":       FieldRegex,
+		"replacement": FieldString,
+	},
+	"chain": {
+		"middlewares": FieldMiddlewareList,
+	},
+	"buffering": {
+		"maxRequestBodyBytes":  FieldInteger,
+		"memRequestBodyBytes":  FieldInteger,
+		"maxResponseBodyBytes": FieldInteger,
+		"memResponseBodyBytes": FieldInteger,
+		"retryExpression":      FieldString,
+	},
+	"circuitBreaker": {
+		"expression":       FieldString,
+		"checkPeriod":      FieldDuration,
+		"fallbackDuration": FieldDuration,
+		"recoveryDuration": FieldDuration,
+		"responseCode":     FieldInteger,
+	},
+	"compress": {
+		"excludedContentTypes": FieldStringList,
+		"includedContentTypes": FieldStringList,
+		"minResponseBodyBytes": FieldInteger,
+		"defaultEncoding":      FieldString,
+		"encodings":            FieldStringList,
+	},
+	"contentType": {
+		"autoDetect": FieldBoolean,
+	},
+	"errors": {
+		"status":         FieldStringList,
+		"statusRewrites": FieldMap,
+		"service":        FieldString,
+		"query":          FieldString,
+	},
+	"grpcWeb": {
+		"allowOrigins": FieldStringList,
+	},
+	"passTLSClientCert": {
+		"pem":                             FieldBoolean,
+		"info.notAfter":                   FieldBoolean,
+		"info.notBefore":                  FieldBoolean,
+		"info.sans":                       FieldBoolean,
+		"info.serialNumber":               FieldBoolean,
+		"info.subject.country":            FieldBoolean,
+		"info.subject.province":           FieldBoolean,
+		"info.subject.locality":           FieldBoolean,
+		"info.subject.organization":       FieldBoolean,
+		"info.subject.organizationalUnit": FieldBoolean,
+		"info.subject.commonName":         FieldBoolean,
+		"info.subject.serialNumber":       FieldBoolean,
+		"info.subject.domainComponent":    FieldBoolean,
+		"info.issuer.country":             FieldBoolean,
+		"info.issuer.province":            FieldBoolean,
+		"info.issuer.locality":            FieldBoolean,
+		"info.issuer.organization":        FieldBoolean,
+		"info.issuer.commonName":          FieldBoolean,
+		"info.issuer.serialNumber":        FieldBoolean,
+		"info.issuer.domainComponent":     FieldBoolean,
+	},
+	"retry": {
+		"attempts":        FieldInteger,
+		"initialInterval": FieldDuration,
+	},
+}
+
+// Middleware types whose schema lists every top-level field Traefik accepts.
+// Only these have unknown top-level fields reported or, with STRICT_FIELDS,
+// rejected; the others (forwardAuth and rateLimit gain options between
+// Traefik releases) only have misspellings of known fields caught.
+var completeMiddlewareSchemas = map[string]bool{
+	"addPrefix":         true,
+	"basicAuth":         true,
+	"buffering":         true,
+	"chain":             true,
+	"circuitBreaker":    true,
+	"compress":          true,
+	"contentType":       true,
+	"digestAuth":        true,
+	"errors":            true,
+	"grpcWeb":           true,
+	"headers":           true,
+	"inFlightReq":       true,
+	"ipAllowList":       true,
+	"ipWhiteList":       true,
+	"passTLSClientCert": true,
+	"redirectRegex":     true,
+	"redirectScheme":    true,
+	"replacePath":       true,
+	"replacePathRegex":  true,
+	"retry":             true,
+	"stripPrefix":       true,
+	"stripPrefixRegex":  true,
+}
+
+// Field names that usually hold credentials in plugin and other free-form configs
+var secretFieldPattern = regexp.MustCompile(`(?i)(secret|password|passwd|token|apikey|api_key|privatekey|lapikey)`)
+
+// GetMiddlewareSchema returns the known fields for a middleware type, or nil if
+// the type has no fixed schema (e.g. plugins)
+func GetMiddlewareSchema(middlewareType string) map[string]FieldType {
+	schema, ok := middlewareSchemas[middlewareType]
+	if !ok {
+		return nil
+	}
+
+	// Return a copy to prevent map mutation
+	result := make(map[string]FieldType, len(schema))
+	for path, fieldType := range schema {
+		result[path] = fieldType
+	}
+	return result
+}
+
+// DescribeMiddlewareConfig returns a field type descriptor for a middleware config.
+// It includes every known field for the type plus any other field present in the
+// config, whose type is inferred from its value.
+func DescribeMiddlewareConfig(middlewareType string, config map[string]interface{}) map[string]FieldType {
+	descriptor := GetMiddlewareSchema(middlewareType)
+	if descriptor == nil {
+		descriptor = make(map[string]FieldType)
+	}
+
+	inferFieldTypes("", config, descriptor)
+	return descriptor
+}
+
+// inferFieldTypes walks a config map and records a type for every field that
+// isn't already described, using dotted paths for nested maps. Maps described
+// as a whole (such as header maps) are not descended into.
+func inferFieldTypes(prefix string, config map[string]interface{}, descriptor map[string]FieldType) {
+	for key, value := range config {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if _, known := descriptor[path]; known {
+			continue
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			inferFieldTypes(path, v, descriptor)
+		case []interface{}:
+			if secretFieldPattern.MatchString(key) {
+				descriptor[path] = FieldSecretList
+			} else {
+				descriptor[path] = FieldList
+			}
+		case bool:
+			descriptor[path] = FieldBoolean
+		case float64:
+			if v == float64(int64(v)) {
+				descriptor[path] = FieldInteger
+			} else {
+				descriptor[path] = FieldNumber
+			}
+		case string:
+			if secretFieldPattern.MatchString(key) {
+				descriptor[path] = FieldSecret
+			} else {
+				descriptor[path] = FieldString
+			}
+		}
+	}
+}
+
+// ValidateMiddlewareFields checks the values of known duration, CIDR and regex
+// fields in a middleware config and returns a description of each invalid value
+func ValidateMiddlewareFields(middlewareType string, config map[string]interface{}) []string {
+	problems, _ := validateMiddlewareFields(middlewareType, config)
+	return problems
+}
+
+// validateMiddlewareFields is ValidateMiddlewareFields, also reporting whether
+// any of the problems is a regex that doesn't compile
+func validateMiddlewareFields(middlewareType string, config map[string]interface{}) (problems []string, invalidRegex bool) {
+
+	schema := GetMiddlewareSchema(middlewareType)
+	paths := make([]string, 0, len(schema))
+	for path := range schema {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		value, ok := lookupConfigPath(config, path)
+		if !ok {
+			continue
+		}
+
+		switch schema[path] {
+		case FieldDuration:
+			if err := validateDuration(value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			}
+		case FieldRegex:
+			if s, ok := value.(string); ok && !IsSecretRef(s) {
+				if _, err := regexp.Compile(s); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid regex %q: %v", path, s, err))
+					invalidRegex = true
+				}
+			}
+		case FieldRegexList:
+			for _, s := range stringItems(value) {
+				if _, err := regexp.Compile(s); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid regex %q: %v", path, s, err))
+					invalidRegex = true
+				}
+			}
+		case FieldCIDRList:
+			for _, s := range stringItems(value) {
+				if net.ParseIP(s) != nil {
+					continue
+				}
+				if _, _, err := net.ParseCIDR(s); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid IP or CIDR %q", path, s))
+				}
+			}
+		}
+	}
+
+	return problems, invalidRegex
+}
+
+// lookupConfigPath returns the value at a dotted path in a config map
+func lookupConfigPath(config map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = config
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// validateDuration accepts Go duration strings and plain numbers of seconds,
+// matching what Traefik accepts
+func validateDuration(value interface{}) error {
+	switch v := value.(type) {
+	case float64:
+		return nil
+	case string:
+		if IsSecretRef(v) {
+			return nil
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return nil
+		}
+		if _, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+}
+
+// stringItems returns the non-reference strings of a string or list value
+func stringItems(value interface{}) []string {
+	var items []string
+	switch v := value.(type) {
+	case string:
+		items = append(items, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+	}
+
+	result := items[:0]
+	for _, s := range items {
+		if !IsSecretRef(s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
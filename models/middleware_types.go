@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"log"
+	"strconv"
 	"strings"
 )
 
@@ -16,7 +19,7 @@ var middlewareProcessors = map[string]MiddlewareProcessor{
 	"forwardAuth":     &AuthProcessor{},
 	"digestAuth":      &AuthProcessor{},
 	"redirectRegex":   &PathProcessor{},
-	"redirectScheme":  &PathProcessor{},
+	"redirectScheme":  &RedirectSchemeProcessor{},
 	"replacePath":     &PathProcessor{},
 	"replacePathRegex": &PathProcessor{},
 	"stripPrefix":     &PathProcessor{},
@@ -191,6 +194,97 @@ func (p *PathProcessor) Process(config map[string]interface{}) map[string]interf
 	return preserveTraefikValues(config).(map[string]interface{})
 }
 
+// RedirectSchemeProcessor handles redirectScheme middleware specific processing
+type RedirectSchemeProcessor struct{}
+
+// redirectSchemePermanentDefault is the permanent value used when a redirectScheme
+// middleware doesn't set one. Temporary (302) redirects to https break HSTS preload.
+var redirectSchemePermanentDefault = true
+
+// SetRedirectSchemePermanentDefault sets the permanent value used when a
+// redirectScheme middleware omits it
+func SetRedirectSchemePermanentDefault(permanent bool) {
+	redirectSchemePermanentDefault = permanent
+}
+
+// Process implements special handling for redirectScheme middleware
+func (p *RedirectSchemeProcessor) Process(config map[string]interface{}) map[string]interface{} {
+	if err := ValidateRedirectSchemeConfig(config); err != nil {
+		log.Printf("Warning: invalid redirectScheme config: %v", err)
+	}
+
+	config = preserveTraefikValues(config).(map[string]interface{})
+
+	// Traefik expects the port as a string; a numeric port is rejected
+	if port, ok := config["port"]; ok {
+		if portStr, ok := redirectSchemePort(port); ok {
+			config["port"] = portStr
+		}
+	}
+
+	// An explicit permanent (including false) is always kept
+	if _, ok := config["permanent"]; !ok {
+		config["permanent"] = redirectSchemePermanentDefault
+	}
+
+	return config
+}
+
+// redirectSchemePort returns a port value as a string
+func redirectSchemePort(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return strconv.Itoa(int(v)), true
+		}
+	}
+	return "", false
+}
+
+// ValidateRedirectSchemeConfig checks that scheme is http or https and that
+// port, if set, is a valid port number
+func ValidateRedirectSchemeConfig(config map[string]interface{}) error {
+	scheme, _ := config["scheme"].(string)
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("scheme must be \"http\" or \"https\", got %q", scheme)
+	}
+
+	port, ok := config["port"]
+	if !ok || port == "" {
+		return nil
+	}
+	portStr, ok := redirectSchemePort(port)
+	if !ok {
+		return fmt.Errorf("port must be a port number, got %v", port)
+	}
+	if n, err := strconv.Atoi(portStr); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %q", portStr)
+	}
+	return nil
+}
+
+// ValidateMiddlewareConfig runs type-specific validation on a middleware
+// config. Every problem found is reported, in a *ConfigValidationError.
+func ValidateMiddlewareConfig(middlewareType string, config map[string]interface{}) error {
+	var problems []string
+	if middlewareType == "redirectScheme" {
+		if err := ValidateRedirectSchemeConfig(config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	problems = append(problems, schemaProblems(middlewareType, config)...)
+	fieldProblems, invalidRegex := validateMiddlewareFields(middlewareType, config)
+	problems = append(problems, fieldProblems...)
+	if len(problems) > 0 {
+		return &ConfigValidationError{Problems: problems, InvalidRegex: invalidRegex}
+	}
+	return nil
+}
+
 // ChainProcessor handles chain middleware specific processing
 type ChainProcessor struct{}
 
@@ -199,12 +293,10 @@ func (p *ChainProcessor) Process(config map[string]interface{}) map[string]inter
 	// Process middlewares array
 	if middlewares, ok := config["middlewares"].([]interface{}); ok {
 		for i, middleware := range middlewares {
-			if middlewareStr, ok := middleware.(string); ok {
-				// If this is not already a fully qualified middleware reference
-				if !strings.Contains(middlewareStr, "@") {
-					// Assume it's from our file provider
-					middlewares[i] = middlewareStr
-				}
+			// Qualified names (e.g. "auth@docker") and bare names are kept as-is;
+			// members stored with an explicit provider are rendered as name@provider
+			if ref, ok := ChainMemberReference(middleware); ok {
+				middlewares[i] = ref
 			}
 		}
 	}
@@ -213,6 +305,27 @@ func (p *ChainProcessor) Process(config map[string]interface{}) map[string]inter
 	return preserveTraefikValues(config).(map[string]interface{})
 }
 
+// ChainMemberReference returns the Traefik reference for a chain member, which
+// is either a middleware name (optionally qualified, e.g. "auth@docker") or an
+// object such as {"name": "auth", "provider": "kubernetescrd"}
+func ChainMemberReference(member interface{}) (string, bool) {
+	switch m := member.(type) {
+	case string:
+		return m, true
+	case map[string]interface{}:
+		name, _ := m["name"].(string)
+		if name == "" {
+			return "", false
+		}
+		provider, _ := m["provider"].(string)
+		if provider == "" || strings.Contains(name, "@") {
+			return name, true
+		}
+		return fmt.Sprintf("%s@%s", name, provider), true
+	}
+	return "", false
+}
+
 // PluginProcessor handles plugin middleware specific processing
 type PluginProcessor struct{}
 
@@ -0,0 +1,340 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ConfigValidationError lists every problem found in a middleware config
+type ConfigValidationError struct {
+	Problems     []string
+	InvalidRegex bool // One of the problems is a regex that doesn't compile
+}
+
+func (e *ConfigValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// Fields a middleware type can't work without, keyed by type and dotted path.
+// Strings and lists must also be non-empty.
+var requiredMiddlewareFields = map[string][]string{
+	"addPrefix":        {"prefix"},
+	"chain":            {"middlewares"},
+	"circuitBreaker":   {"expression"},
+	"errors":           {"status", "service"},
+	"forwardAuth":      {"address"},
+	"inFlightReq":      {"amount"},
+	"ipAllowList":      {"sourceRange"},
+	"ipWhiteList":      {"sourceRange"},
+	"redirectRegex":    {"regex", "replacement"},
+	"replacePath":      {"path"},
+	"replacePathRegex": {"regex", "replacement"},
+	"stripPrefix":      {"prefixes"},
+	"stripPrefixRegex": {"regex"},
+}
+
+// Integer fields that must be at least 1. Other integer fields must not be negative.
+var positiveMiddlewareFields = map[string]bool{
+	"inFlightReq.amount": true,
+	"rateLimit.average":  true,
+	"retry.attempts":     true,
+}
+
+// Integer fields holding an HTTP status code
+var statusCodeMiddlewareFields = map[string]bool{
+	"circuitBreaker.responseCode":  true,
+	"ipAllowList.rejectStatusCode": true,
+}
+
+// strictMiddlewareFields makes unknown top-level config fields a validation
+// error. Otherwise they are only reported as warnings when saving.
+var strictMiddlewareFields = false
+
+// SetStrictMiddlewareFields sets whether unknown top-level fields in a
+// middleware config are rejected
+func SetStrictMiddlewareFields(strict bool) {
+	strictMiddlewareFields = strict
+}
+
+// schemaProblems checks a config against its type's schema: required fields,
+// value types and ranges, and unknown fields that look like a misspelling of a
+// known one. Other unknown fields are allowed, as the schema doesn't list
+// every field Traefik supports.
+func schemaProblems(middlewareType string, config map[string]interface{}) []string {
+	schema := GetMiddlewareSchema(middlewareType)
+	if schema == nil {
+		return nil
+	}
+
+	var problems []string
+	for _, path := range requiredMiddlewareFields[middlewareType] {
+		value, ok := lookupConfigPath(config, path)
+		if !ok || value == nil || value == "" {
+			problems = append(problems, fmt.Sprintf("%s: is required", path))
+		} else if list, isList := value.([]interface{}); isList && len(list) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be empty", path))
+		}
+	}
+	walkSchemaFields(middlewareType, "", config, schema, &problems)
+	if strictMiddlewareFields {
+		for _, key := range unknownTopLevelFields(middlewareType, config) {
+			problems = append(problems, fmt.Sprintf("%s: unknown field for %s", key, middlewareType))
+		}
+	}
+	return problems
+}
+
+// unknownTopLevelFields returns the sorted top-level keys of a config that
+// aren't known fields of its type. Keys close to a known field are left out,
+// as they are already rejected as misspellings. Only types with a complete
+// schema have unknown fields; plugins and types whose schema may miss newer
+// Traefik options have none.
+func unknownTopLevelFields(middlewareType string, config map[string]interface{}) []string {
+	schema := GetMiddlewareSchema(middlewareType)
+	if schema == nil || !completeMiddlewareSchemas[middlewareType] {
+		return nil
+	}
+
+	var unknown []string
+	for key := range config {
+		if _, known := schema[key]; known || hasSchemaChildren(schema, key) {
+			continue
+		}
+		if closestSchemaField(schema, "", key) != "" {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// walkSchemaFields checks each field of a config map against the schema,
+// descending into maps that hold nested known fields
+func walkSchemaFields(middlewareType, prefix string, config map[string]interface{}, schema map[string]FieldType, problems *[]string) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		value := config[key]
+
+		if fieldType, known := schema[path]; known {
+			if problem := fieldValueProblem(middlewareType+"."+path, fieldType, value); problem != "" {
+				*problems = append(*problems, fmt.Sprintf("%s: %s", path, problem))
+			}
+			continue
+		}
+		if hasSchemaChildren(schema, path) {
+			if nested, ok := value.(map[string]interface{}); ok {
+				walkSchemaFields(middlewareType, path, nested, schema, problems)
+			} else {
+				*problems = append(*problems, fmt.Sprintf("%s: must be an object", path))
+			}
+			continue
+		}
+		if suggestion := closestSchemaField(schema, prefix, key); suggestion != "" {
+			*problems = append(*problems, fmt.Sprintf("%s: unknown field, did you mean %s?", path, suggestion))
+		}
+	}
+}
+
+// fieldValueProblem describes what is wrong with a value for its field type,
+// or returns an empty string. Secret references stand in for any value.
+// Durations, regexes and CIDRs are checked by ValidateMiddlewareFields.
+func fieldValueProblem(qualifiedPath string, fieldType FieldType, value interface{}) string {
+	if s, ok := value.(string); ok && IsSecretRef(s) {
+		return ""
+	}
+
+	switch fieldType {
+	case FieldString, FieldRegex, FieldSecret:
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("must be a string, got %s", describeValue(value))
+		}
+	case FieldURL:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Sprintf("must be a URL string, got %s", describeValue(value))
+		}
+		if parsed, err := url.Parse(s); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Sprintf("must be an absolute URL such as http://auth:9091/verify, got %q", s)
+		}
+	case FieldBoolean:
+		// "true" and "false" strings are turned into booleans on generation
+		if s, ok := value.(string); ok && (s == "true" || s == "false") {
+			return ""
+		}
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("must be true or false, got %s", describeValue(value))
+		}
+	case FieldInteger:
+		n, ok := integerValue(value)
+		if !ok {
+			return fmt.Sprintf("must be an integer, got %s", describeValue(value))
+		}
+		switch {
+		case statusCodeMiddlewareFields[qualifiedPath] && (n < 100 || n > 599):
+			return fmt.Sprintf("must be an HTTP status code between 100 and 599, got %d", n)
+		case positiveMiddlewareFields[qualifiedPath] && n < 1:
+			return fmt.Sprintf("must be a positive integer, got %d", n)
+		case n < 0:
+			return fmt.Sprintf("must not be negative, got %d", n)
+		}
+	case FieldNumber:
+		switch value.(type) {
+		case float64, int, int64:
+		default:
+			return fmt.Sprintf("must be a number, got %s", describeValue(value))
+		}
+	case FieldStringList, FieldCIDRList, FieldRegexList, FieldSecretList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Sprintf("must be a list of strings, got %s", describeValue(value))
+		}
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return fmt.Sprintf("must be a list of strings, got an item %s", describeValue(item))
+			}
+		}
+	case FieldMiddlewareList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Sprintf("must be a list of middleware names, got %s", describeValue(value))
+		}
+		for _, item := range list {
+			if _, ok := ChainMemberReference(item); !ok {
+				return fmt.Sprintf("must be a list of middleware names, got an item %s", describeValue(item))
+			}
+		}
+	case FieldHeaderMap:
+		headers, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("must be a map of header names to values, got %s", describeValue(value))
+		}
+		for name, item := range headers {
+			if _, ok := item.(string); !ok {
+				return fmt.Sprintf("value of header %s must be a string, got %s", name, describeValue(item))
+			}
+		}
+	case FieldList:
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Sprintf("must be a list, got %s", describeValue(value))
+		}
+	case FieldMap:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Sprintf("must be a map, got %s", describeValue(value))
+		}
+	}
+	return ""
+}
+
+// integerValue reads a JSON number that holds a whole number
+func integerValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, false
+		}
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// describeValue names a value and its JSON type for validation messages
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case bool:
+		return fmt.Sprintf("the boolean %v", v)
+	case float64, int, int64:
+		return fmt.Sprintf("the number %v", v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// hasSchemaChildren reports whether the schema has fields nested under path
+func hasSchemaChildren(schema map[string]FieldType, path string) bool {
+	for known := range schema {
+		if strings.HasPrefix(known, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// closestSchemaField returns the known field under prefix that key is most
+// likely a misspelling of: the same name in different case, or one a couple
+// of edits away. It returns an empty string if none is close.
+func closestSchemaField(schema map[string]FieldType, prefix, key string) string {
+	if prefix != "" {
+		prefix += "."
+	}
+	siblings := make(map[string]bool)
+	for known := range schema {
+		if !strings.HasPrefix(known, prefix) {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(known, prefix), ".", 2)[0]
+		siblings[name] = true
+	}
+
+	maxDistance := 1
+	if len(key) >= 8 {
+		maxDistance = 2
+	}
+	best, bestDistance := "", maxDistance+1
+	for name := range siblings {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if distance < bestDistance || (distance == bestDistance && name < best) {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -19,6 +19,9 @@ type Resource struct {
 	// TLS certificate configuration
 	TLSDomains     string    `json:"tls_domains"`
 	
+	// TLSAutoDomains fills the certificate domains from the resource's hosts
+	TLSAutoDomains bool      `json:"tls_auto_domains"`
+	
 	// TCP SNI routing configuration
 	TCPEnabled     bool      `json:"tcp_enabled"`
 	TCPEntrypoints string    `json:"tcp_entrypoints"`
@@ -27,16 +30,29 @@ type Resource struct {
 	// Custom headers configuration
 	CustomHeaders  string    `json:"custom_headers"`
 	
+	// Custom error pages configuration, an ErrorPages as JSON
+	ErrorPages     string    `json:"error_pages"`
+	
 	// Router priority configuration
 	RouterPriority int       `json:"router_priority"`
 	
 	// Source type for tracking data origin
 	SourceType     string    `json:"source_type"`
 	
+	// Origin is ResourceOriginManual for resources created through the API
+	Origin         string    `json:"origin"`
+	
+	// SkipAuth excludes the resource from the Pangolin auth middleware (badger)
+	SkipAuth       bool      `json:"skip_auth"`
+	
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// ResourceOriginManual marks resources that were created through the API rather
+// than discovered from the data source. The resource watcher never disables them.
+const ResourceOriginManual = "manual"
+
 // PangolinResource represents the format of a resource from Pangolin API
 type PangolinResource struct {
 	ID     string `json:"id"`
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// SecretRefPrefix marks a config string whose value is read from an environment
+// variable at generation time, e.g. "secretRef://CROWDSEC_LAPI_KEY"
+const SecretRefPrefix = "secretRef://"
+
+// IsSecretRef reports whether a config value is a secret reference
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, SecretRefPrefix)
+}
+
+// ResolveSecretRefs returns a copy of the value with every secret reference
+// replaced by its environment variable. Missing variables resolve to an empty
+// string and are logged by name only.
+func ResolveSecretRefs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved[key] = ResolveSecretRefs(item)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved[i] = ResolveSecretRefs(item)
+		}
+		return resolved
+	case string:
+		if !IsSecretRef(v) {
+			return v
+		}
+		envVar := strings.TrimPrefix(v, SecretRefPrefix)
+		secret, ok := os.LookupEnv(envVar)
+		if !ok {
+			log.Printf("Warning: environment variable %s referenced by %s is not set", envVar, v)
+		}
+		return secret
+	default:
+		return value
+	}
+}
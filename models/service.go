@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +14,7 @@ type Service struct {
 	Name      string    `json:"name"`
 	Type      string    `json:"type"`
 	Config    string    `json:"config"`
+	Managed   bool      `json:"managed"` // True when the service watcher owns this service
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -162,10 +166,57 @@ func (p *DefaultServiceProcessor) Process(config map[string]interface{}) map[str
 	return preserveTraefikValues(config).(map[string]interface{})
 }
 
+// LoadBalancerServiceProcessor also normalizes the healthCheck block
+type LoadBalancerServiceProcessor struct{}
+
+// Process turns bare numbers in healthCheck interval and timeout into seconds
+// ("10" becomes "10s") and a numeric string port into an integer. Values that
+// can't be normalized are left for ValidateServiceConfig to reject.
+func (p *LoadBalancerServiceProcessor) Process(config map[string]interface{}) map[string]interface{} {
+	config = preserveTraefikValues(config).(map[string]interface{})
+	healthCheck, ok := config["healthCheck"].(map[string]interface{})
+	if !ok {
+		return config
+	}
+
+	for _, key := range []string{"interval", "timeout"} {
+		switch v := healthCheck[key].(type) {
+		case float64:
+			if v >= 0 && v == float64(int64(v)) {
+				healthCheck[key] = fmt.Sprintf("%ds", int64(v))
+			}
+		case int:
+			if v >= 0 {
+				healthCheck[key] = fmt.Sprintf("%ds", v)
+			}
+		case string:
+			trimmed := strings.TrimSpace(v)
+			if n, err := strconv.Atoi(trimmed); err == nil && n >= 0 {
+				healthCheck[key] = fmt.Sprintf("%ds", n)
+			} else {
+				healthCheck[key] = trimmed
+			}
+		}
+	}
+
+	switch v := healthCheck["port"].(type) {
+	case float64:
+		if v == float64(int(v)) {
+			healthCheck["port"] = int(v)
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			healthCheck["port"] = n
+		}
+	}
+	return config
+}
+
 // GetServiceProcessor returns the appropriate processor for a service type
 func GetServiceProcessor(serviceType string) ServiceProcessor {
-	// For now, we use the default processor for all service types
-	// In the future, we could have specialized processors for different service types
+	if ServiceType(serviceType) == LoadBalancerType {
+		return &LoadBalancerServiceProcessor{}
+	}
 	return &DefaultServiceProcessor{}
 }
 
@@ -173,4 +224,133 @@ func GetServiceProcessor(serviceType string) ServiceProcessor {
 func ProcessServiceConfig(serviceType string, config map[string]interface{}) map[string]interface{} {
 	processor := GetServiceProcessor(serviceType)
 	return processor.Process(config)
-}
\ No newline at end of file
+}
+
+// ServiceProtocol returns the router type a service can be used by: "tcp" for
+// loadBalancers whose servers have an address, "http" otherwise
+func ServiceProtocol(serviceType string, config map[string]interface{}) string {
+	if serviceType == string(LoadBalancerType) {
+		if servers, ok := config["servers"].([]interface{}); ok {
+			for _, s := range servers {
+				if serverMap, ok := s.(map[string]interface{}); ok {
+					if _, hasAddress := serverMap["address"]; hasAddress {
+						// Could be TCP or UDP. Default to TCP.
+						return "tcp"
+					}
+					if _, hasURL := serverMap["url"]; hasURL {
+						return "http"
+					}
+				}
+			}
+		}
+	}
+	// For weighted, mirroring, failover, they reference other services.
+	// Assume HTTP if not explicitly a loadbalancer with address.
+	return "http"
+}
+
+// ValidateServiceConfig checks that a service config has the fields its type requires
+func ValidateServiceConfig(serviceType string, config map[string]interface{}) error {
+	switch ServiceType(serviceType) {
+	case LoadBalancerType:
+		servers, ok := config["servers"].([]interface{})
+		if !ok || len(servers) == 0 {
+			return fmt.Errorf("loadBalancer service requires a non-empty servers list")
+		}
+		for i, server := range servers {
+			s, ok := server.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("server %d must be an object", i+1)
+			}
+			url, _ := s["url"].(string)
+			address, _ := s["address"].(string)
+			if url == "" && address == "" {
+				return fmt.Errorf("server %d requires a url or address", i+1)
+			}
+		}
+		if healthCheck, present := config["healthCheck"]; present {
+			if err := validateHealthCheck(healthCheck); err != nil {
+				return fmt.Errorf("healthCheck: %w", err)
+			}
+		}
+	case WeightedType:
+		services, ok := config["services"].([]interface{})
+		if !ok || len(services) == 0 {
+			return fmt.Errorf("weighted service requires a non-empty services list")
+		}
+		for i, service := range services {
+			s, ok := service.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("weighted service entry %d must be an object", i+1)
+			}
+			if name, _ := s["name"].(string); name == "" {
+				return fmt.Errorf("weighted service entry %d requires a name", i+1)
+			}
+		}
+	case MirroringType:
+		if service, _ := config["service"].(string); service == "" {
+			return fmt.Errorf("mirroring service requires a service")
+		}
+		if mirrors, present := config["mirrors"]; present {
+			list, ok := mirrors.([]interface{})
+			if !ok {
+				return fmt.Errorf("mirrors must be a list")
+			}
+			for i, mirror := range list {
+				m, ok := mirror.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("mirror %d must be an object", i+1)
+				}
+				if name, _ := m["name"].(string); name == "" {
+					return fmt.Errorf("mirror %d requires a name", i+1)
+				}
+			}
+		}
+	case FailoverType:
+		if service, _ := config["service"].(string); service == "" {
+			return fmt.Errorf("failover service requires a service")
+		}
+		if fallback, _ := config["fallback"].(string); fallback == "" {
+			return fmt.Errorf("failover service requires a fallback")
+		}
+	}
+	return nil
+}
+
+// validateHealthCheck checks a loadBalancer healthCheck block as normalized by
+// LoadBalancerServiceProcessor
+func validateHealthCheck(value interface{}) error {
+	healthCheck, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("must be an object")
+	}
+
+	for _, key := range []string{"interval", "timeout"} {
+		raw, present := healthCheck[key]
+		if !present {
+			continue
+		}
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a duration such as 10s, got %v", key, raw)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%s must be a positive duration such as 10s, got %q", key, s)
+		}
+	}
+
+	if raw, present := healthCheck["path"]; present {
+		if path, ok := raw.(string); !ok || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path must start with /, got %v", raw)
+		}
+	}
+
+	if raw, present := healthCheck["port"]; present {
+		port, ok := raw.(int)
+		if !ok || port < 1 || port > 65535 {
+			return fmt.Errorf("port must be an integer between 1 and 65535, got %v", raw)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BackendVerifyMode controls what the generator does with unreachable backends
+type BackendVerifyMode string
+
+const (
+	// BackendVerifyOff doesn't check backends
+	BackendVerifyOff BackendVerifyMode = "off"
+	// BackendVerifyLog logs routers whose service has unreachable backends
+	BackendVerifyLog BackendVerifyMode = "log"
+	// BackendVerifySkip also leaves out routers whose backends are all unreachable
+	BackendVerifySkip BackendVerifyMode = "skip"
+)
+
+// Backend checks are TCP connects, bounded per backend and per generation run.
+// A run's checks are dialed by a few workers at once, so with the generation
+// lock held a run waits at most a couple of dial timeouts.
+const (
+	backendDialTimeout     = 2 * time.Second
+	maxBackendChecksPerRun = 20
+	backendCheckWorkers    = 10
+)
+
+// ParseBackendVerifyMode parses a VERIFY_BACKENDS value; empty and "false" mean off
+func ParseBackendVerifyMode(value string) (BackendVerifyMode, error) {
+	switch value {
+	case "", "false", string(BackendVerifyOff):
+		return BackendVerifyOff, nil
+	case "true", string(BackendVerifyLog):
+		return BackendVerifyLog, nil
+	case string(BackendVerifySkip):
+		return BackendVerifySkip, nil
+	}
+	return "", fmt.Errorf("unknown backend verification mode %q, expected off, log or skip", value)
+}
+
+type backendResult struct {
+	err       error
+	checkedAt time.Time
+}
+
+// backendChecker checks that loadBalancer server URLs accept connections.
+// Results are cached for interval so each backend is dialed at most once per
+// interval, however often the config is generated.
+type backendChecker struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	results     map[string]backendResult
+	budget      int
+	unreachable map[string]bool // Backends found unreachable in the current run
+	changed     map[string]bool // Backends whose reachability changed when prefetched in the current run
+}
+
+func newBackendChecker(interval time.Duration) *backendChecker {
+	return &backendChecker{interval: interval, results: make(map[string]backendResult)}
+}
+
+// startRun resets the number of new checks allowed in this generation run
+func (b *backendChecker) startRun() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.budget = maxBackendChecksPerRun
+	b.unreachable = make(map[string]bool)
+	b.changed = make(map[string]bool)
+}
+
+// prefetch dials the servers that are due for a check concurrently, within
+// the run's budget, so check finds their results cached
+func (b *backendChecker) prefetch(serverURLs []string) {
+	b.mu.Lock()
+	var due []string
+	seen := make(map[string]bool)
+	for _, serverURL := range serverURLs {
+		if seen[serverURL] || b.budget <= 0 {
+			continue
+		}
+		seen[serverURL] = true
+		if result, cached := b.results[serverURL]; cached && time.Since(result.checkedAt) < b.interval {
+			continue
+		}
+		due = append(due, serverURL)
+		b.budget--
+	}
+	b.mu.Unlock()
+
+	results := make([]backendResult, len(due))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < backendCheckWorkers && w < len(due); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = backendResult{err: dialBackend(due[i]), checkedAt: time.Now()}
+			}
+		}()
+	}
+	for i := range due {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, serverURL := range due {
+		previous, cached := b.results[serverURL]
+		if cached {
+			b.changed[serverURL] = (previous.err == nil) != (results[i].err == nil)
+		} else {
+			b.changed[serverURL] = results[i].err != nil
+		}
+		b.results[serverURL] = results[i]
+	}
+}
+
+// endRun returns how many distinct backends were unreachable during the run
+func (b *backendChecker) endRun() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.unreachable)
+}
+
+// check returns the error connecting to the server, if any. known is false
+// when the check was skipped because the run's budget is used up; changed is
+// true when the server became unreachable or recovered since the previous
+// check (a first check counts as a change only when it fails).
+func (b *backendChecker) check(serverURL string) (known, changed bool, err error) {
+	b.mu.Lock()
+	result, cached := b.results[serverURL]
+	if !cached || time.Since(result.checkedAt) >= b.interval {
+		if b.budget <= 0 {
+			b.mu.Unlock()
+			return false, false, nil
+		}
+		b.budget--
+		b.mu.Unlock()
+
+		previous := result
+		result = backendResult{err: dialBackend(serverURL), checkedAt: time.Now()}
+		if cached {
+			changed = (previous.err == nil) != (result.err == nil)
+		} else {
+			changed = result.err != nil
+		}
+
+		b.mu.Lock()
+		b.results[serverURL] = result
+	} else {
+		changed = b.changed[serverURL]
+		delete(b.changed, serverURL)
+	}
+	if result.err != nil {
+		b.unreachable[serverURL] = true
+	}
+	b.mu.Unlock()
+	return true, changed, result.err
+}
+
+// dialBackend opens and closes a TCP connection to the server's host and port
+func dialBackend(serverURL string) error {
+	parsed, err := url.Parse(serverURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid server url %q", serverURL)
+	}
+	address := parsed.Host
+	if parsed.Port() == "" {
+		port := "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(parsed.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", address, backendDialTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// loadBalancerServerURLs returns the server URLs of an emitted loadBalancer service entry
+func loadBalancerServerURLs(serviceEntry interface{}) []string {
+	entry, ok := serviceEntry.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	lb, ok := entry["loadBalancer"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	servers, _ := lb["servers"].([]interface{})
+	var urls []string
+	for _, server := range servers {
+		if s, ok := server.(map[string]interface{}); ok {
+			if u, ok := s["url"].(string); ok && u != "" {
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// prefetchRouterBackends starts the checks of the loadBalancer backends of the
+// custom services that resources' routers use, before the routers are built
+func (cg *ConfigGenerator) prefetchRouterBackends(resourceDataMap map[string]resourceRouterData, skip map[string]bool, config *TraefikConfig) {
+	var urls []string
+	for id, data := range resourceDataMap {
+		data = httpRouterData(data, config)
+		if skip[id] || !data.CustomServiceID.Valid || data.CustomServiceID.String == "" {
+			continue
+		}
+		urls = append(urls, loadBalancerServerURLs(config.HTTP.Services[normalizeServiceID(data.CustomServiceID.String)])...)
+	}
+	sort.Strings(urls)
+	cg.backendChecker.prefetch(urls)
+}
+
+// verifyRouterBackends checks the loadBalancer backends of the custom service
+// a router uses. It returns false when every backend is known to be
+// unreachable, and whether any backend's reachability changed, so callers
+// only log on changes rather than every generation.
+func (cg *ConfigGenerator) verifyRouterBackends(routerID, serviceID string, config *TraefikConfig) (ok, changed bool) {
+	urls := loadBalancerServerURLs(config.HTTP.Services[serviceID])
+	if len(urls) == 0 {
+		return true, false
+	}
+
+	unreachable := 0
+	for _, serverURL := range urls {
+		known, backendChanged, err := cg.backendChecker.check(serverURL)
+		if !known {
+			// Out of budget for this run; assume it's fine until it's checked
+			continue
+		}
+		changed = changed || backendChanged
+		if err != nil {
+			unreachable++
+			if backendChanged {
+				log.Printf("Warning: router %s: backend %s of service %s is unreachable: %v", routerID, serverURL, serviceID, err)
+			}
+		} else if backendChanged {
+			log.Printf("Router %s: backend %s of service %s is reachable again", routerID, serverURL, serviceID)
+		}
+	}
+	return unreachable < len(urls), changed
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBackendPrefetchReportsChangesOnce(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	reachable := "http://" + listener.Addr().String()
+	unreachable := "http://127.0.0.1:1"
+
+	b := newBackendChecker(time.Minute)
+	b.startRun()
+	b.prefetch([]string{unreachable, reachable, unreachable})
+	if b.budget != maxBackendChecksPerRun-2 {
+		t.Errorf("budget = %d after prefetching two backends, want %d", b.budget, maxBackendChecksPerRun-2)
+	}
+
+	known, changed, err := b.check(unreachable)
+	if !known || !changed || err == nil {
+		t.Errorf("first check of the unreachable backend = %v, %v, %v; want known, changed and an error", known, changed, err)
+	}
+	if _, changed, _ := b.check(unreachable); changed {
+		t.Errorf("second check of the unreachable backend reported a change again")
+	}
+	if known, changed, err := b.check(reachable); !known || changed || err != nil {
+		t.Errorf("check of the reachable backend = %v, %v, %v; want known, unchanged, no error", known, changed, err)
+	}
+	if count := b.endRun(); count != 1 {
+		t.Errorf("endRun = %d unreachable backends, want 1", count)
+	}
+}
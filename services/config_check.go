@@ -0,0 +1,250 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// Consistency issue severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ConsistencyIssue describes a single problem found by the consistency check
+type ConsistencyIssue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ConsistencyReport is the result of a full consistency check
+type ConsistencyReport struct {
+	Healthy   bool               `json:"healthy"`
+	CheckedAt time.Time          `json:"checked_at"`
+	Errors    []ConsistencyIssue `json:"errors"`
+	Warnings  []ConsistencyIssue `json:"warnings"`
+}
+
+func (r *ConsistencyReport) add(check, severity, message string) {
+	issue := ConsistencyIssue{Check: check, Severity: severity, Message: message}
+	if severity == SeverityError {
+		r.Errors = append(r.Errors, issue)
+	} else {
+		r.Warnings = append(r.Warnings, issue)
+	}
+}
+
+// CheckConsistency runs every consistency validation across the database and
+// the configuration generated from it. Building the config touches generator
+// state, so it holds generateMutex like a generation run.
+func (cg *ConfigGenerator) CheckConsistency() (*ConsistencyReport, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	report := &ConsistencyReport{
+		CheckedAt: time.Now(),
+		Errors:    []ConsistencyIssue{},
+		Warnings:  []ConsistencyIssue{},
+	}
+
+	checks := []func(*ConsistencyReport) error{
+		cg.checkMiddlewareConfigs,
+		cg.checkProcessedMiddlewareConfigs,
+		cg.checkResourceServices,
+		cg.checkDisabledAssignments,
+		cg.checkRouterIDCollisions,
+		cg.checkDuplicateHosts,
+		cg.checkGeneratedServiceReferences,
+	}
+	for _, check := range checks {
+		if err := check(report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Healthy = len(report.Errors) == 0
+	return report, nil
+}
+
+// checkMiddlewareConfigs validates field values and chain references in stored middlewares
+func (cg *ConfigGenerator) checkMiddlewareConfigs(report *ConsistencyReport) error {
+	rows, err := cg.db.Query("SELECT id, type, config, COALESCE(unsafe, 0) FROM middlewares WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to fetch middlewares: %w", err)
+	}
+	defer rows.Close()
+
+	type chain struct {
+		id      string
+		members []interface{}
+	}
+	middlewareIDs := make(map[string]bool)
+	var chains []chain
+
+	for rows.Next() {
+		var id, typ, configStr string
+		var unsafe bool
+		if err := rows.Scan(&id, &typ, &configStr, &unsafe); err != nil {
+			return fmt.Errorf("failed to scan middleware: %w", err)
+		}
+		middlewareIDs[id] = true
+
+		// Unsafe middlewares opted out of field and chain validation; say so instead
+		if unsafe {
+			report.add("middleware_unsafe", SeverityWarning, fmt.Sprintf("middleware %s is marked unsafe, its field and chain validation is skipped", id))
+			continue
+		}
+
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+			report.add("middleware_config", SeverityError, fmt.Sprintf("middleware %s has invalid config JSON: %v", id, err))
+			continue
+		}
+
+		for _, problem := range models.ValidateMiddlewareFields(typ, config) {
+			report.add("middleware_config", SeverityError, fmt.Sprintf("middleware %s: %s", id, problem))
+		}
+
+		if typ == "chain" {
+			members, _ := config["middlewares"].([]interface{})
+			chains = append(chains, chain{id: id, members: members})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read middlewares: %w", err)
+	}
+
+	// Chains may reference other providers; only @file (or unqualified) names are ours
+	for _, ch := range chains {
+		for _, member := range ch.members {
+			ref, ok := models.ChainMemberReference(member)
+			if !ok {
+				report.add("chain_reference", SeverityError, fmt.Sprintf("chain middleware %s has an invalid member %v", ch.id, member))
+				continue
+			}
+			name := ref
+			if idx := strings.Index(ref, "@"); idx > 0 {
+				if ref[idx+1:] != "file" {
+					continue
+				}
+				name = ref[:idx]
+			}
+			if !middlewareIDs[name] {
+				report.add("chain_reference", SeverityError, fmt.Sprintf("chain middleware %s references missing middleware %s", ch.id, ref))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkResourceServices finds resources assigned to custom services that no longer exist
+func (cg *ConfigGenerator) checkResourceServices(report *ConsistencyReport) error {
+	rows, err := cg.db.Query(`
+		SELECT rs.resource_id, rs.service_id
+		FROM resource_services rs
+		LEFT JOIN services s ON s.id = rs.service_id
+		WHERE s.id IS NULL
+		ORDER BY rs.resource_id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch resource services: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resourceID, serviceID string
+		if err := rows.Scan(&resourceID, &serviceID); err != nil {
+			return fmt.Errorf("failed to scan resource service: %w", err)
+		}
+		report.add("missing_service", SeverityError, fmt.Sprintf("resource %s references missing service %s", resourceID, serviceID))
+	}
+	return rows.Err()
+}
+
+// checkDisabledAssignments finds middlewares still assigned to disabled resources
+func (cg *ConfigGenerator) checkDisabledAssignments(report *ConsistencyReport) error {
+	rows, err := cg.db.Query(`
+		SELECT rm.resource_id, rm.middleware_id
+		FROM resource_middlewares rm
+		JOIN resources r ON r.id = rm.resource_id
+		WHERE r.status = 'disabled' AND r.deleted_at IS NULL
+		ORDER BY rm.resource_id, rm.middleware_id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch resource middlewares: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resourceID, middlewareID string
+		if err := rows.Scan(&resourceID, &middlewareID); err != nil {
+			return fmt.Errorf("failed to scan resource middleware: %w", err)
+		}
+		report.add("disabled_resource", SeverityWarning, fmt.Sprintf("middleware %s is assigned to disabled resource %s", middlewareID, resourceID))
+	}
+	return rows.Err()
+}
+
+// checkRouterIDCollisions finds active resources that generate the same router ID,
+// in which case only one of them ends up in the generated config
+func (cg *ConfigGenerator) checkRouterIDCollisions(report *ConsistencyReport) error {
+	rows, err := cg.db.Query("SELECT id, tcp_enabled FROM resources WHERE status = 'active' AND deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to fetch resources: %w", err)
+	}
+	defer rows.Close()
+
+	routers := make(map[string][]string)
+	for rows.Next() {
+		var id string
+		var tcpEnabled bool
+		if err := rows.Scan(&id, &tcpEnabled); err != nil {
+			return fmt.Errorf("failed to scan resource: %w", err)
+		}
+		// Must match the IDs used by buildHTTPRouter and processTCPRouters
+		httpRouterID := fmt.Sprintf("%s-auth", extractBaseName(id))
+		routers[httpRouterID] = append(routers[httpRouterID], id)
+		if tcpEnabled {
+			tcpRouterID := fmt.Sprintf("%s-tcp", extractBaseName(id))
+			routers[tcpRouterID] = append(routers[tcpRouterID], id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read resources: %w", err)
+	}
+
+	routerIDs := make([]string, 0, len(routers))
+	for routerID := range routers {
+		routerIDs = append(routerIDs, routerID)
+	}
+	sort.Strings(routerIDs)
+
+	for _, routerID := range routerIDs {
+		if resources := routers[routerID]; len(resources) > 1 {
+			report.add("router_collision", SeverityError, fmt.Sprintf("resources %s all generate router %s",
+				strings.Join(resources, ", "), routerID))
+		}
+	}
+	return nil
+}
+
+// checkGeneratedServiceReferences builds the config and checks that every @file
+// service a router uses is emitted for the right protocol
+func (cg *ConfigGenerator) checkGeneratedServiceReferences(report *ConsistencyReport) error {
+	config, err := cg.buildConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range findDanglingServiceReferences(config) {
+		report.add("service_reference", SeverityError, problem)
+	}
+	return nil
+}
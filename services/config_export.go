@@ -0,0 +1,257 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportVersion is the format version of exported configurations. Importers
+// should refuse documents with a version they don't know.
+const ExportVersion = 1
+
+// ConfigExport is a snapshot of every middleware, service and router in
+// Traefik's dynamic configuration layout. Unlike the generated file,
+// middlewares have their stored configs, with secret references kept as
+// references, and the names of middlewares and services are recorded
+// alongside so they can be restored. Resources, with their settings and
+// assignments, are recorded in the metadata, as routers only show the
+// active ones.
+type ConfigExport struct {
+	Version       int            `yaml:"version"`
+	ExportedAt    time.Time      `yaml:"exported_at"`
+	Metadata      ExportMetadata `yaml:"metadata"`
+	TraefikConfig `yaml:",inline"`
+}
+
+// ExportMetadata holds what the Traefik layout has no place for, keyed by ID
+type ExportMetadata struct {
+	Middlewares map[string]ExportedMiddleware `yaml:"middlewares,omitempty"`
+	Services    map[string]ExportedService    `yaml:"services,omitempty"`
+	Resources   map[string]ExportedResource   `yaml:"resources,omitempty"`
+}
+
+// ExportedMiddleware is the Middleware Manager side of an exported middleware
+type ExportedMiddleware struct {
+	Name        string `yaml:"name"`
+	DisplayName string `yaml:"display_name,omitempty"`
+	Icon        string `yaml:"icon,omitempty"`
+	Category    string `yaml:"category,omitempty"`
+	Unsafe      bool   `yaml:"unsafe,omitempty"`
+}
+
+// ExportedService is the Middleware Manager side of an exported service
+type ExportedService struct {
+	Name string `yaml:"name"`
+}
+
+// ExportedResource is a resource's settings and assignments, as stored
+type ExportedResource struct {
+	Host           string               `yaml:"host"`
+	ServiceID      string               `yaml:"service_id"`
+	OrgID          string               `yaml:"org_id,omitempty"`
+	SiteID         string               `yaml:"site_id,omitempty"`
+	Status         string               `yaml:"status"`
+	Origin         string               `yaml:"origin,omitempty"`
+	SourceType     string               `yaml:"source_type,omitempty"`
+	Entrypoints    string               `yaml:"entrypoints,omitempty"`
+	TLSDomains     string               `yaml:"tls_domains,omitempty"`
+	TLSAutoDomains bool                 `yaml:"tls_auto_domains,omitempty"`
+	TCPEnabled     bool                 `yaml:"tcp_enabled,omitempty"`
+	TCPEntrypoints string               `yaml:"tcp_entrypoints,omitempty"`
+	TCPSNIRule     string               `yaml:"tcp_sni_rule,omitempty"`
+	CustomHeaders  string               `yaml:"custom_headers,omitempty"`
+	ErrorPages     string               `yaml:"error_pages,omitempty"`
+	RouterPriority int                  `yaml:"router_priority"`
+	SkipAuth       bool                 `yaml:"skip_auth,omitempty"`
+	CustomService  string               `yaml:"custom_service,omitempty"`
+	Middlewares    []ExportedAssignment `yaml:"middlewares,omitempty"`
+}
+
+// ExportedAssignment is a middleware assigned to an exported resource
+type ExportedAssignment struct {
+	ID       string `yaml:"id"`
+	Priority int    `yaml:"priority"`
+}
+
+// BuildExport builds a YAML snapshot of the configuration for backup or
+// migration. Routers are built the way the next generation run would build
+// them; the stored resources and their assignments are in the metadata.
+func (cg *ConfigGenerator) BuildExport() ([]byte, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	config, err := cg.assembleConfig(middlewaresRaw)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := cg.exportMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	export := ConfigExport{
+		Version:       ExportVersion,
+		ExportedAt:    time.Now().UTC(),
+		Metadata:      metadata,
+		TraefikConfig: *config,
+	}
+	// Stored configs are decoded from JSON; write whole numbers as integers
+	// rather than in the float notation YAML would use for 5000000
+	for _, section := range []map[string]interface{}{config.HTTP.Middlewares, config.HTTP.Services, config.TCP.Services, config.UDP.Services} {
+		for id, entry := range section {
+			section[id] = wholeNumbersAsInts(entry)
+		}
+	}
+
+	// Plain marshalling keeps values' types; generation's string quoting would
+	// turn long numbers into strings the import then rejects
+	data, err := yaml.Marshal(export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export: %w", err)
+	}
+	return data, nil
+}
+
+// exportMetadata reads the names and display metadata of middlewares and services
+func (cg *ConfigGenerator) exportMetadata() (ExportMetadata, error) {
+	metadata := ExportMetadata{
+		Middlewares: make(map[string]ExportedMiddleware),
+		Services:    make(map[string]ExportedService),
+	}
+
+	rows, err := cg.db.Query(`
+		SELECT id, name, COALESCE(display_name, ''), COALESCE(icon, ''),
+		       COALESCE(category, ''), COALESCE(unsafe, 0)
+		FROM middlewares
+		WHERE deleted_at IS NULL
+	`)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to fetch middlewares: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var mw ExportedMiddleware
+		if err := rows.Scan(&id, &mw.Name, &mw.DisplayName, &mw.Icon, &mw.Category, &mw.Unsafe); err != nil {
+			rows.Close()
+			return metadata, fmt.Errorf("failed to scan middleware: %w", err)
+		}
+		metadata.Middlewares[id] = mw
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return metadata, fmt.Errorf("failed to read middlewares: %w", err)
+	}
+
+	rows, err = cg.db.Query("SELECT id, name FROM services")
+	if err != nil {
+		return metadata, fmt.Errorf("failed to fetch services: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var service ExportedService
+		if err := rows.Scan(&id, &service.Name); err != nil {
+			rows.Close()
+			return metadata, fmt.Errorf("failed to scan service: %w", err)
+		}
+		metadata.Services[id] = service
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return metadata, fmt.Errorf("failed to read services: %w", err)
+	}
+
+	if metadata.Resources, err = cg.exportResources(); err != nil {
+		return metadata, err
+	}
+	return metadata, nil
+}
+
+// exportResources reads every resource that isn't in the trash, active or
+// disabled, with its custom service and middleware assignments
+func (cg *ConfigGenerator) exportResources() (map[string]ExportedResource, error) {
+	resources := make(map[string]ExportedResource)
+
+	rows, err := cg.db.Query(`
+		SELECT r.id, r.host, r.service_id, r.org_id, r.site_id, r.status,
+		       COALESCE(r.origin, ''), COALESCE(r.source_type, ''), COALESCE(r.entrypoints, ''),
+		       COALESCE(r.tls_domains, ''), COALESCE(r.tls_auto_domains, 0), COALESCE(r.tcp_enabled, 0),
+		       COALESCE(r.tcp_entrypoints, ''), COALESCE(r.tcp_sni_rule, ''), COALESCE(r.custom_headers, ''),
+		       COALESCE(r.error_pages, ''), COALESCE(r.router_priority, 100), COALESCE(r.skip_auth, 0),
+		       COALESCE(rs.service_id, '')
+		FROM resources r
+		LEFT JOIN resource_services rs ON r.id = rs.resource_id
+		WHERE r.deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch resources: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var r ExportedResource
+		if err := rows.Scan(&id, &r.Host, &r.ServiceID, &r.OrgID, &r.SiteID, &r.Status,
+			&r.Origin, &r.SourceType, &r.Entrypoints,
+			&r.TLSDomains, &r.TLSAutoDomains, &r.TCPEnabled,
+			&r.TCPEntrypoints, &r.TCPSNIRule, &r.CustomHeaders,
+			&r.ErrorPages, &r.RouterPriority, &r.SkipAuth,
+			&r.CustomService); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		resources[id] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read resources: %w", err)
+	}
+
+	rows, err = cg.db.Query(`
+		SELECT rm.resource_id, rm.middleware_id, rm.priority
+		FROM resource_middlewares rm
+		JOIN middlewares m ON rm.middleware_id = m.id AND m.deleted_at IS NULL
+		ORDER BY rm.resource_id, rm.priority DESC, rm.middleware_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch resource middlewares: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var resourceID string
+		var assignment ExportedAssignment
+		if err := rows.Scan(&resourceID, &assignment.ID, &assignment.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan resource middleware: %w", err)
+		}
+		r, ok := resources[resourceID]
+		if !ok {
+			continue
+		}
+		r.Middlewares = append(r.Middlewares, assignment)
+		resources[resourceID] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read resource middlewares: %w", err)
+	}
+	return resources, nil
+}
+
+// wholeNumbersAsInts replaces float64 values that hold whole numbers with
+// int64, recursively
+func wholeNumbersAsInts(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = wholeNumbersAsInts(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = wholeNumbersAsInts(item)
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	}
+	return value
+}
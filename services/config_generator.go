@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -16,18 +20,49 @@ import (
 
 	"github.com/hhftechnology/middleware-manager/database"
 	"github.com/hhftechnology/middleware-manager/models" // Correct import for your models
+	"github.com/hhftechnology/middleware-manager/util"
 	"gopkg.in/yaml.v3"
 )
 
 // ConfigGenerator generates Traefik configuration files
 type ConfigGenerator struct {
-	db            *database.DB
-	confDir       string
-	configManager *ConfigManager // To access active data source
-	stopChan      chan struct{}
-	isRunning     bool
-	mutex         sync.Mutex
-	lastConfig    []byte
+	db                        *database.DB
+	confDir                   string
+	configManager             *ConfigManager // To access active data source
+	stopChan                  chan struct{}
+	isRunning                 bool
+	mutex                     sync.Mutex
+	generateMutex             sync.Mutex // Serializes generation runs from the loop and the API
+	lastConfig                []byte
+	lastUnresolvedConfig      []byte                  // lastConfig with secret references unresolved, as stored for last-known-good
+	stagingDir                string                  // If set, config is written here and validated before promotion
+	validateCmd               string                  // Shell command run against the staged config
+	tlsEntrypoints            []string                // Entrypoints that terminate TLS; routers using none of them get no tls block
+	entrypointAddress         string                  // Host, optionally with port, where Traefik's entrypoints listen
+	maintenanceService        string                  // If set, disabled resources get a router pointing at this service
+	defaultService            string                  // Service the resource watcher gives resources without one; referenced as-is
+	pauseSchedule             *PauseSchedule          // Recurring windows during which generation is skipped
+	paused                    bool                    // Whether the last run was skipped by the pause schedule
+	yamlAnchors               bool                    // Emit repeated router blocks as YAML anchors and aliases
+	yamlIndent                int                     // Spaces per indentation level of the generated YAML
+	yamlSequenceStyle         YAMLSequenceStyle       // Whether lists of plain values are written inline
+	serviceProviderStrategy   ServiceProviderStrategy // How upstream service provider suffixes are chosen
+	serviceNames              serviceNameCache        // Traefik service names used by the lookup strategy
+	backendVerifyMode         BackendVerifyMode       // Whether routers' loadBalancer backends are checked
+	backendChecker            *backendChecker         // Cached backend reachability, set when verification is on
+	pendingConfig             *pendingConfig          // Last written config, until Traefik is seen to load it
+	heldHash                  string                  // Hash of the config replaced by a last-known-good restore
+	rejectedHash              string                  // Hash of the last config the validation command rejected
+	duplicateHostPolicy       DuplicateHostPolicy     // What to do with active resources that share a host
+	duplicateHostLogged       map[string]bool         // Shared host messages logged by the last generation run
+	splitRoutersPerEntrypoint bool                    // Generate one router per entrypoint of a resource
+	missingServicePolicy      MissingServicePolicy    // What to do with routers whose custom service isn't generated
+	missingServiceLogged      map[string]bool         // Missing custom service assignments logged by the last generation run
+	uncheckedMiddlewareLogged map[string]bool         // Unchecked middleware type warnings logged by the last generation run
+	autoTLSDomains            bool                    // Fill every resource's certificate domains from its host
+	webhook                   *configWebhook          // Notified each time a new config is written
+	generating                bool                    // Set while generateConfig builds the config it writes
+	routerPreview             *routerPreview          // Set while a preview builds the config with a proposed change
 	// lastConfigHash string // This was commented out in your original struct, uncomment if needed
 }
 
@@ -49,19 +84,201 @@ type TraefikConfig struct {
 	} `yaml:"udp,omitempty"`
 }
 
+// newTraefikConfig returns a TraefikConfig with all maps initialized
+func newTraefikConfig() *TraefikConfig {
+	config := &TraefikConfig{}
+	config.HTTP.Middlewares = make(map[string]interface{})
+	config.HTTP.Routers = make(map[string]interface{})
+	config.HTTP.Services = make(map[string]interface{})
+	config.TCP.Routers = make(map[string]interface{})
+	config.TCP.Services = make(map[string]interface{})
+	config.UDP.Services = make(map[string]interface{})
+	return config
+}
+
 // NewConfigGenerator creates a new config generator
 func NewConfigGenerator(db *database.DB, confDir string, configManager *ConfigManager) *ConfigGenerator {
 	return &ConfigGenerator{
-		db:            db,
-		confDir:       confDir,
-		configManager: configManager,
-		stopChan:      make(chan struct{}),
-		isRunning:     false,
-		lastConfig:    nil,
+		db:                      db,
+		confDir:                 confDir,
+		configManager:           configManager,
+		stopChan:                make(chan struct{}),
+		isRunning:               false,
+		lastConfig:              nil,
+		tlsEntrypoints:          []string{"websecure"},
+		serviceProviderStrategy: ServiceProviderAuto,
+		backendVerifyMode:       BackendVerifyOff,
+		duplicateHostPolicy:     DuplicateHostWarn,
+		missingServicePolicy:    MissingServiceFallback,
 		// lastConfigHash: "", // ensure this matches your struct
 	}
 }
 
+// Timeout for the staged config validation command
+const validateCmdTimeout = 60 * time.Second
+
+// SetStaging makes the generator write into stagingDir first and only copy the
+// config to the live conf dir once validateCmd (if any) succeeds
+func (cg *ConfigGenerator) SetStaging(stagingDir, validateCmd string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.stagingDir = stagingDir
+	cg.validateCmd = validateCmd
+}
+
+// SetTLSEntrypoints sets the entrypoints that terminate TLS
+func (cg *ConfigGenerator) SetTLSEntrypoints(entrypoints []string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.tlsEntrypoints = entrypoints
+}
+
+// SetEntrypointAddress sets the host, optionally with a port, that Traefik's
+// entrypoints listen on. Without it the host of the Traefik data source is used.
+func (cg *ConfigGenerator) SetEntrypointAddress(address string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.entrypointAddress = strings.TrimSpace(address)
+}
+
+// SetMaintenanceService makes disabled resources keep a router that sends
+// traffic to service (e.g. maintenance@file) instead of being dropped
+func (cg *ConfigGenerator) SetMaintenanceService(service string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.maintenanceService = service
+}
+
+// SetDefaultService tells the generator which service DEFAULT_SERVICE
+// names. Like the maintenance service it's a complete Traefik reference
+// (e.g. placeholder@file), so routers use it without a provider suffix.
+func (cg *ConfigGenerator) SetDefaultService(service string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.defaultService = service
+}
+
+// SetPauseSchedule pauses generation during the schedule's windows; the
+// configuration is regenerated once a window ends
+func (cg *ConfigGenerator) SetPauseSchedule(schedule *PauseSchedule) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.pauseSchedule = schedule
+}
+
+// SetYAMLAnchors makes the generator emit repeated router tls and middlewares
+// blocks as YAML anchors and aliases, which shrinks large configs
+func (cg *ConfigGenerator) SetYAMLAnchors(enabled bool) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.yamlAnchors = enabled
+}
+
+// SetServiceProviderStrategy sets how routers pick the provider suffix of a
+// resource's upstream service
+func (cg *ConfigGenerator) SetServiceProviderStrategy(strategy ServiceProviderStrategy) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.serviceProviderStrategy = strategy
+}
+
+// SetBackendVerification makes the generator check that the loadBalancer
+// backends of routers' custom services accept connections, rechecking each
+// backend at most once per interval. In skip mode, routers whose backends are
+// all unreachable are left out.
+func (cg *ConfigGenerator) SetBackendVerification(mode BackendVerifyMode, interval time.Duration) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.backendVerifyMode = mode
+	if mode != BackendVerifyOff {
+		cg.backendChecker = newBackendChecker(interval)
+	}
+}
+
+// SetDuplicateHostPolicy sets what the generator does when more than one
+// active resource uses the same host
+func (cg *ConfigGenerator) SetDuplicateHostPolicy(policy DuplicateHostPolicy) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.duplicateHostPolicy = policy
+}
+
+// SetSplitRoutersPerEntrypoint makes the generator emit one router per
+// entrypoint of a resource instead of one router listening on all of them
+func (cg *ConfigGenerator) SetSplitRoutersPerEntrypoint(enabled bool) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.splitRoutersPerEntrypoint = enabled
+}
+
+// SetAutoTLSDomains makes every resource's certificate domains cover its host,
+// as if each had tls_auto_domains set
+func (cg *ConfigGenerator) SetAutoTLSDomains(enabled bool) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.autoTLSDomains = enabled
+}
+
+// SetMissingServicePolicy sets what the generator does with resources whose
+// assigned custom service isn't among the generated HTTP services
+func (cg *ConfigGenerator) SetMissingServicePolicy(policy MissingServicePolicy) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.missingServicePolicy = policy
+}
+
+// GenerationSummary describes the outcome of one configuration generation run
+type GenerationSummary struct {
+	Middlewares  int       `json:"middlewares"`
+	HTTPServices int       `json:"http_services"`
+	TCPServices  int       `json:"tcp_services"`
+	UDPServices  int       `json:"udp_services"`
+	HTTPRouters  int       `json:"http_routers"`
+	TCPRouters   int       `json:"tcp_routers"`
+	Changed      bool      `json:"changed"`
+	Hash         string    `json:"hash"` // SHA-256 of the generated YAML
+	Paused       bool      `json:"paused"`
+	Held         bool      `json:"held"` // Not written because a last-known-good restore is holding it back
+	GeneratedAt  time.Time `json:"generated_at"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// Generate runs a configuration generation now, as the periodic loop would,
+// and returns a summary of what was emitted. Pause windows are respected.
+func (cg *ConfigGenerator) Generate(ctx context.Context) (*GenerationSummary, error) {
+	return cg.generateUnlessPaused(ctx)
+}
+
+// generateUnlessPaused runs generateConfig unless a pause window is active.
+// Changes made during a window are picked up by the first run after it ends.
+func (cg *ConfigGenerator) generateUnlessPaused(ctx context.Context) (*GenerationSummary, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	if cg.pauseSchedule != nil && cg.pauseSchedule.Active(time.Now()) {
+		if !cg.paused {
+			log.Printf("Entering scheduled generation pause (%s), changes will be applied when it ends", cg.pauseSchedule)
+			cg.paused = true
+		}
+		return &GenerationSummary{Paused: true, GeneratedAt: time.Now()}, nil
+	}
+	if cg.paused {
+		log.Println("Scheduled generation pause ended, regenerating configuration")
+		cg.paused = false
+	}
+	return cg.generateConfig(ctx)
+}
+
+// usesTLSEntrypoint reports whether any of the router's entrypoints terminates TLS
+func (cg *ConfigGenerator) usesTLSEntrypoint(entrypoints []string) bool {
+	for _, ep := range entrypoints {
+		if stringSliceContains(cg.tlsEntrypoints, strings.TrimSpace(ep)) {
+			return true
+		}
+	}
+	return false
+}
+
 // Start begins generating configuration files
 func (cg *ConfigGenerator) Start(interval time.Duration) {
 	cg.mutex.Lock()
@@ -78,20 +295,42 @@ func (cg *ConfigGenerator) Start(interval time.Duration) {
 		log.Printf("Failed to create conf directory: %v", err)
 		return
 	}
+	if cg.stagingDir != "" {
+		if err := os.MkdirAll(cg.stagingDir, 0755); err != nil {
+			log.Printf("Failed to create staging conf directory: %v", err)
+			return
+		}
+		log.Printf("Staging generated config in %s before promoting to %s", cg.stagingDir, cg.confDir)
+	}
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	if err := cg.generateConfig(); err != nil {
+	// Each generation starts its own trace. Stopping the generator cancels
+	// ctx, so a run waiting to retry a write gives up instead of stalling
+	// shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-cg.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if _, err := cg.generateUnlessPaused(ctx); err != nil {
 		log.Printf("Initial config generation failed: %v", err)
 	}
+	cg.confirmPendingConfig(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := cg.generateConfig(); err != nil {
+			if _, err := cg.generateUnlessPaused(ctx); err != nil {
 				log.Printf("Config generation failed: %v", err)
 			}
+			cg.confirmPendingConfig(ctx)
 		case <-cg.stopChan:
 			log.Println("Config generator stopped")
 			return
@@ -120,58 +359,313 @@ func (cg *ConfigGenerator) Stop() {
 }
 
 // generateConfig generates Traefik configuration files
-func (cg *ConfigGenerator) generateConfig() error {
+func (cg *ConfigGenerator) generateConfig(ctx context.Context) (summary *GenerationSummary, err error) {
 	log.Println("Generating Traefik configuration...")
 
-	config := TraefikConfig{}
-	config.HTTP.Middlewares = make(map[string]interface{})
-	config.HTTP.Routers = make(map[string]interface{})
-	config.HTTP.Services = make(map[string]interface{})
-	config.TCP.Routers = make(map[string]interface{})
-	config.TCP.Services = make(map[string]interface{})
-	config.UDP.Services = make(map[string]interface{})
+	ctx, span := StartSpan(ctx, "generateConfig")
+	start := time.Now()
+	defer func() {
+		MetricConfigGenerations.Inc()
+		MetricConfigGenerationDuration.Set(float64(time.Since(start).Milliseconds()))
+		if err != nil {
+			MetricConfigGenerationErrors.Inc()
+		}
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// The config is built with secret references unresolved, which is the
+	// copy kept as last-known-good, and encoded again with them resolved for
+	// Traefik
+	cg.generating = true
+	config, unresolvedYAML, err := cg.buildConfigYAML(middlewaresForDisplay)
+	cg.generating = false
+	if err != nil {
+		return nil, err
+	}
+	yamlData, err := cg.encodeConfigYAML(withResolvedSecrets(config))
+	if err != nil {
+		return nil, err
+	}
 
+	hash := configHash(yamlData)
 
-	if err := cg.processMiddlewares(&config); err != nil {
-		return fmt.Errorf("failed to process middlewares: %w", err)
+	// After a restore, the config that was replaced stays held back until
+	// something changes
+	held := false
+	if cg.heldHash != "" {
+		if hash == cg.heldHash {
+			held = true
+		} else {
+			log.Println("Configuration changed since last-known-good restore, resuming writes")
+			cg.heldHash = ""
+		}
 	}
-	if err := cg.processServices(&config); err != nil {
-		return fmt.Errorf("failed to process services: %w", err)
+
+	// A config the validation command rejected isn't staged and validated
+	// again until something changes
+	rejected := cg.rejectedHash != "" && hash == cg.rejectedHash
+
+	changed := !held && !rejected && cg.hasConfigurationChanged(yamlData)
+	span.SetAttribute("config.changed", strconv.FormatBool(changed))
+	if changed {
+		configFile := filepath.Join(cg.confDir, "resource-overrides.yml")
+		cg.loadPreviousConfigIDs(configFile)
+		if err := cg.writeConfigToFile(ctx, yamlData); err != nil {
+			return nil, fmt.Errorf("failed to write config to file: %w", err)
+		}
+		cg.lastConfig = yamlData
+		cg.lastUnresolvedConfig = unresolvedYAML
+		cg.rejectedHash = ""
+		log.Printf("Generated new Traefik configuration at %s", configFile)
+		MetricConfigWrites.Inc()
+		cg.recordPendingConfig(unresolvedYAML, hash, config)
+		cg.notifyConfigChanged(config, configFile, hash)
+
+		// Only report when the config changed to avoid repeating the same warnings every cycle
+		for _, problem := range findDanglingServiceReferences(config) {
+			log.Printf("Warning: %s", problem)
+		}
+	} else if held {
+		log.Println("Configuration matches the one replaced by the last-known-good restore, not writing")
+	} else if rejected {
+		log.Println("Configuration matches the one the validation command rejected, not writing")
+	} else {
+		log.Println("Configuration unchanged, skipping file write")
 	}
-	if err := cg.processResourcesWithServices(&config); err != nil {
-		return fmt.Errorf("failed to process HTTP resources with services: %w", err)
+
+	return &GenerationSummary{
+		Middlewares:  len(config.HTTP.Middlewares),
+		HTTPServices: len(config.HTTP.Services),
+		TCPServices:  len(config.TCP.Services),
+		UDPServices:  len(config.UDP.Services),
+		HTTPRouters:  len(config.HTTP.Routers),
+		TCPRouters:   len(config.TCP.Routers),
+		Changed:      changed,
+		Hash:         hash,
+		Held:         held,
+		GeneratedAt:  time.Now(),
+		DurationMs:   time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// BuildConfigYAML builds the Traefik configuration the next generation run
+// would write and returns it as YAML, without writing anything. Secret
+// references are left unresolved, so the result is safe to show.
+func (cg *ConfigGenerator) BuildConfigYAML() ([]byte, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	_, yamlData, err := cg.buildConfigYAML(middlewaresForDisplay)
+	return yamlData, err
+}
+
+// BuildDynamicConfig builds the Traefik configuration the next generation run
+// would write, structured like Traefik's dynamic configuration (http, tcp and
+// udp sections). Empty sections are left out. Secret references are left
+// unresolved; BuildHTTPProviderConfig serves Traefik the resolved values.
+func (cg *ConfigGenerator) BuildDynamicConfig() (map[string]interface{}, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	return cg.buildDynamicConfig(middlewaresForDisplay)
+}
+
+// buildDynamicConfig builds the configuration in Traefik's dynamic
+// configuration layout. Callers must hold generateMutex.
+func (cg *ConfigGenerator) buildDynamicConfig(mode middlewareMode) (map[string]interface{}, error) {
+	config, err := cg.assembleConfig(mode)
+	if err != nil {
+		return nil, err
 	}
-	if err := cg.processTCPRouters(&config); err != nil {
-		return fmt.Errorf("failed to process TCP resources: %w", err)
+
+	section := func(parts map[string]map[string]interface{}) map[string]interface{} {
+		result := make(map[string]interface{})
+		for name, part := range parts {
+			if len(part) > 0 {
+				result[name] = part
+			}
+		}
+		return result
 	}
 
-	processedConfig := preserveTraefikValues(config)
+	dynamic := make(map[string]interface{})
+	sections := map[string]map[string]interface{}{
+		"http": section(map[string]map[string]interface{}{
+			"middlewares": config.HTTP.Middlewares,
+			"routers":     config.HTTP.Routers,
+			"services":    config.HTTP.Services,
+		}),
+		"tcp": section(map[string]map[string]interface{}{
+			"routers":  config.TCP.Routers,
+			"services": config.TCP.Services,
+		}),
+		"udp": section(map[string]map[string]interface{}{
+			"services": config.UDP.Services,
+		}),
+	}
+	for name, content := range sections {
+		if len(content) > 0 {
+			dynamic[name] = content
+		}
+	}
+	return dynamic, nil
+}
+
+// ActiveDataSourceType returns the type of the data source the configuration
+// is generated for
+func (cg *ConfigGenerator) ActiveDataSourceType() models.DataSourceType {
+	return cg.activeDataSourceType()
+}
+
+// buildConfigYAML builds the configuration and marshals it the way it is
+// written to disk. The YAML is validated so callers never see a config
+// Traefik couldn't parse. Callers must hold generateMutex.
+func (cg *ConfigGenerator) buildConfigYAML(mode middlewareMode) (*TraefikConfig, []byte, error) {
+	config, err := cg.assembleConfig(mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	yamlData, err := cg.encodeConfigYAML(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, yamlData, nil
+}
+
+// encodeConfigYAML marshals a configuration the way it is written to disk
+// and validates the result
+func (cg *ConfigGenerator) encodeConfigYAML(config *TraefikConfig) ([]byte, error) {
+	processedConfig := preserveTraefikValues(*config)
 
 	yamlNode := &yaml.Node{}
 	err := yamlNode.Encode(processedConfig)
 	if err != nil {
-		return fmt.Errorf("failed to encode config to YAML node: %w", err)
+		return nil, fmt.Errorf("failed to encode config to YAML node: %w", err)
 	}
 	preserveStringsInYamlNode(yamlNode)
-	yamlData, err := yaml.Marshal(yamlNode)
+	applySequenceStyle(yamlNode, cg.yamlSequenceStyle)
+	if cg.yamlAnchors {
+		anchorSharedBlocks(yamlNode)
+	}
+	yamlData, err := cg.marshalYAML(yamlNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML node: %w", err)
+	}
+
+	// Never hand Traefik a config it can't parse
+	warnings, err := validateGeneratedConfig(yamlData)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML node: %w", err)
+		return nil, fmt.Errorf("generated config rejected, not writing: %w", err)
+	}
+	cg.logUncheckedMiddlewares(warnings)
+	return yamlData, nil
+}
+
+// withResolvedSecrets returns a copy of config whose middlewares have their
+// secret references resolved. The other sections are shared with config.
+func withResolvedSecrets(config *TraefikConfig) *TraefikConfig {
+	resolved := *config
+	resolved.HTTP.Middlewares = models.ResolveSecretRefs(config.HTTP.Middlewares).(map[string]interface{})
+	return &resolved
+}
+
+// middlewareMode decides how stored middleware configs are added to a config
+type middlewareMode int
+
+const (
+	// middlewaresForTraefik processes the configs and resolves secret
+	// references, for output Traefik loads
+	middlewaresForTraefik middlewareMode = iota
+	// middlewaresForDisplay processes the configs but leaves secret references
+	// as they are, for output shown through the API
+	middlewaresForDisplay
+	// middlewaresRaw keeps the stored configs, as exports need
+	middlewaresRaw
+)
+
+// buildConfig assembles the full Traefik configuration from the database
+func (cg *ConfigGenerator) buildConfig() (*TraefikConfig, error) {
+	return cg.assembleConfig(middlewaresForTraefik)
+}
+
+// assembleConfig runs every processing step into a new configuration, adding
+// middlewares as mode says
+func (cg *ConfigGenerator) assembleConfig(mode middlewareMode) (*TraefikConfig, error) {
+	config := newTraefikConfig()
+
+	if err := cg.processMiddlewares(config, mode); err != nil {
+		return nil, fmt.Errorf("failed to process middlewares: %w", err)
+	}
+	if err := cg.processServices(config); err != nil {
+		return nil, fmt.Errorf("failed to process services: %w", err)
 	}
+	if err := cg.processResourcesWithServices(config); err != nil {
+		return nil, fmt.Errorf("failed to process HTTP resources with services: %w", err)
+	}
+	if err := cg.processTCPRouters(config); err != nil {
+		return nil, fmt.Errorf("failed to process TCP resources: %w", err)
+	}
+
+	return config, nil
+}
 
-	if cg.hasConfigurationChanged(yamlData) {
-		if err := cg.writeConfigToFile(yamlData); err != nil {
-			return fmt.Errorf("failed to write config to file: %w", err)
+// findDanglingServiceReferences checks that every router pointing at a @file
+// service has a matching service emitted for the same protocol
+func findDanglingServiceReferences(config *TraefikConfig) []string {
+	var problems []string
+
+	check := func(protocol string, routers, services map[string]interface{}, others map[string]map[string]interface{}) {
+		for routerID, r := range routers {
+			router, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ref, _ := router["service"].(string)
+			if !strings.HasSuffix(ref, "@file") {
+				continue
+			}
+			serviceID := strings.TrimSuffix(ref, "@file")
+			if _, exists := services[serviceID]; exists {
+				continue
+			}
+
+			// Point out services that exist but were emitted for another protocol
+			found := ""
+			for otherProtocol, otherServices := range others {
+				if _, exists := otherServices[serviceID]; exists {
+					found = otherProtocol
+					break
+				}
+			}
+			if found != "" {
+				problems = append(problems, fmt.Sprintf("%s router %s references service %s, which is defined as a %s service",
+					protocol, routerID, ref, found))
+			} else {
+				problems = append(problems, fmt.Sprintf("%s router %s references service %s, which is not defined",
+					protocol, routerID, ref))
+			}
 		}
-		log.Printf("Generated new Traefik configuration at %s", filepath.Join(cg.confDir, "resource-overrides.yml"))
-	} else {
-		log.Println("Configuration unchanged, skipping file write")
 	}
 
-	return nil
+	check("HTTP", config.HTTP.Routers, config.HTTP.Services, map[string]map[string]interface{}{
+		"TCP": config.TCP.Services,
+		"UDP": config.UDP.Services,
+	})
+	check("TCP", config.TCP.Routers, config.TCP.Services, map[string]map[string]interface{}{
+		"HTTP": config.HTTP.Services,
+		"UDP":  config.UDP.Services,
+	})
+
+	sort.Strings(problems)
+	return problems
 }
 
-func (cg *ConfigGenerator) processMiddlewares(config *TraefikConfig) error {
-	rows, err := cg.db.Query("SELECT id, name, type, config FROM middlewares")
+// processMiddlewares adds every middleware to the config. Secret references
+// are only resolved for middlewaresForTraefik, and middlewaresRaw adds the
+// stored config as it is, not processed for Traefik.
+func (cg *ConfigGenerator) processMiddlewares(config *TraefikConfig, mode middlewareMode) error {
+	rows, err := cg.db.Query("SELECT id, name, type, config FROM middlewares WHERE deleted_at IS NULL")
 	if err != nil {
 		return fmt.Errorf("failed to fetch middlewares: %w", err)
 	}
@@ -189,8 +683,14 @@ func (cg *ConfigGenerator) processMiddlewares(config *TraefikConfig) error {
 			continue
 		}
 		
-		// Use the centralized processing logic from models package
-		middlewareConfig = models.ProcessMiddlewareConfig(typ, middlewareConfig)
+		if mode != middlewaresRaw {
+			// Use the centralized processing logic from models package
+			middlewareConfig = models.ProcessMiddlewareConfig(typ, middlewareConfig)
+		}
+		if mode == middlewaresForTraefik {
+			// Resolve secret references last so the values only ever live in the generated file
+			middlewareConfig = models.ResolveSecretRefs(middlewareConfig).(map[string]interface{})
+		}
 
 		config.HTTP.Middlewares[id] = map[string]interface{}{
 			typ: middlewareConfig,
@@ -221,7 +721,7 @@ func (cg *ConfigGenerator) processServices(config *TraefikConfig) error {
 		// Use the centralized processing logic from models package
 		serviceConfig = models.ProcessServiceConfig(typ, serviceConfig)
 
-		protocol := determineServiceProtocol(typ, serviceConfig)
+		protocol := models.ServiceProtocol(typ, serviceConfig)
 		serviceEntry := map[string]interface{}{typ: serviceConfig}
 
 		switch protocol {
@@ -248,48 +748,76 @@ func extractBaseName(id string) string {
     return id
 }
 
-// processResourcesWithServices processes resources with their assigned services
-func (cg *ConfigGenerator) processResourcesWithServices(config *TraefikConfig) error {
+// resourceRouterData holds the data needed to build the HTTP router for a resource
+type resourceRouterData struct {
+    Info            models.Resource
+    Middlewares     []MiddlewareWithPriority
+    CustomServiceID sql.NullString
+}
+
+// httpRouterData returns the data for a resource's HTTP router. The custom
+// service of a TCP-enabled resource is used by its TCP router, so unless it is
+// also an HTTP service the HTTP router keeps routing to the upstream service.
+func httpRouterData(data resourceRouterData, config *TraefikConfig) resourceRouterData {
+    if !data.Info.TCPEnabled || !data.CustomServiceID.Valid || data.CustomServiceID.String == "" {
+        return data
+    }
+    if _, isHTTP := config.HTTP.Services[normalizeServiceID(data.CustomServiceID.String)]; !isHTTP {
+        data.CustomServiceID = sql.NullString{}
+    }
+    return data
+}
+
+// activeDataSourceType returns the type of the active data source, defaulting to Pangolin
+func (cg *ConfigGenerator) activeDataSourceType() models.DataSourceType {
     activeDSConfig, err := cg.configManager.GetActiveDataSourceConfig()
     if err != nil {
         log.Printf("Warning: Could not get active data source config in ConfigGenerator: %v. Defaulting to Pangolin logic.", err)
-        activeDSConfig.Type = models.PangolinAPI
+        return models.PangolinAPI
     }
+    return activeDSConfig.Type
+}
 
+// loadResourceRouterData fetches active resources with their middlewares and custom service.
+// If resourceID is non-empty, only that resource is loaded.
+func (cg *ConfigGenerator) loadResourceRouterData(resourceID string) (map[string]resourceRouterData, error) {
     query := `
-        SELECT r.id, r.host, r.service_id, r.entrypoints, r.tls_domains,
-               r.custom_headers, r.router_priority, r.source_type, 
+        SELECT r.id, r.host, r.service_id, r.entrypoints, r.tls_domains, COALESCE(r.tls_auto_domains, 0),
+               COALESCE(r.tcp_enabled, 0), r.custom_headers, COALESCE(r.error_pages, ''), r.router_priority, r.source_type, COALESCE(r.skip_auth, 0),
                rm.middleware_id, rm.priority,
                rs.service_id as custom_service_id
         FROM resources r
         LEFT JOIN resource_middlewares rm ON r.id = rm.resource_id
+            AND rm.middleware_id IN (SELECT id FROM middlewares WHERE deleted_at IS NULL)
         LEFT JOIN resource_services rs ON r.id = rs.resource_id
-        WHERE r.status = 'active'
-        ORDER BY r.id, rm.priority DESC
+        WHERE r.status = 'active' AND r.deleted_at IS NULL
     `
-    rows, err := cg.db.Query(query)
+    var args []interface{}
+    if resourceID != "" {
+        query += " AND r.id = ?"
+        args = append(args, resourceID)
+    }
+    query += " ORDER BY r.id, rm.priority DESC"
+
+    rows, err := cg.db.Query(query, args...)
     if err != nil {
-        return fmt.Errorf("failed to fetch resources for HTTP routers: %w", err)
+        return nil, fmt.Errorf("failed to fetch resources for HTTP routers: %w", err)
     }
     defer rows.Close()
 
-    type resourceProcessedData struct {
-        Info            models.Resource
-        Middlewares     []MiddlewareWithPriority
-        CustomServiceID sql.NullString
-    }
-    resourceDataMap := make(map[string]resourceProcessedData)
+    resourceDataMap := make(map[string]resourceRouterData)
 
     for rows.Next() {
-        var rID_db, host_db, serviceID_db, entrypoints_db, tlsDomains_db, customHeadersStr_db, sourceType_db string
+        var rID_db, host_db, serviceID_db, entrypoints_db, tlsDomains_db, customHeadersStr_db, errorPages_db, sourceType_db string
         var routerPriority_db sql.NullInt64
+        var skipAuth_db, tlsAutoDomains_db, tcpEnabled_db bool
         var middlewareID_db sql.NullString
         var middlewarePriority_db sql.NullInt64
         var customServiceID_db sql.NullString
 
         err := rows.Scan(
-            &rID_db, &host_db, &serviceID_db, &entrypoints_db, &tlsDomains_db,
-            &customHeadersStr_db, &routerPriority_db, &sourceType_db,
+            &rID_db, &host_db, &serviceID_db, &entrypoints_db, &tlsDomains_db, &tlsAutoDomains_db,
+            &tcpEnabled_db, &customHeadersStr_db, &errorPages_db, &routerPriority_db, &sourceType_db, &skipAuth_db,
             &middlewareID_db, &middlewarePriority_db, &customServiceID_db,
         )
         if err != nil {
@@ -304,9 +832,13 @@ func (cg *ConfigGenerator) processResourcesWithServices(config *TraefikConfig) e
                 Host:          host_db,
                 ServiceID:     serviceID_db,
                 Entrypoints:   entrypoints_db,
-                TLSDomains:    tlsDomains_db,
-                CustomHeaders: customHeadersStr_db,
-                SourceType:    sourceType_db,
+                TLSDomains:     tlsDomains_db,
+                TLSAutoDomains: tlsAutoDomains_db,
+                TCPEnabled:     tcpEnabled_db,
+                CustomHeaders:  customHeadersStr_db,
+                ErrorPages:     errorPages_db,
+                SourceType:     sourceType_db,
+                SkipAuth:       skipAuth_db,
             }
             if routerPriority_db.Valid {
                 data.Info.RouterPriority = int(routerPriority_db.Int64)
@@ -329,145 +861,547 @@ func (cg *ConfigGenerator) processResourcesWithServices(config *TraefikConfig) e
         resourceDataMap[rID_db] = data
     }
     if err = rows.Err(); err != nil {
-        return fmt.Errorf("error iterating resource rows for HTTP: %w", err)
+        return nil, fmt.Errorf("error iterating resource rows for HTTP: %w", err)
     }
-    
-    for _, mapValueDataEntry := range resourceDataMap {
-        info := mapValueDataEntry.Info
-        assignedMiddlewares := mapValueDataEntry.Middlewares
-        
-        sort.SliceStable(assignedMiddlewares, func(i, j int) bool {
-            return assignedMiddlewares[i].Priority > assignedMiddlewares[j].Priority
-        })
-
-        routerEntryPoints := strings.Split(strings.TrimSpace(info.Entrypoints), ",")
-        if len(routerEntryPoints) == 0 || (len(routerEntryPoints) == 1 && routerEntryPoints[0] == "") {
-            routerEntryPoints = []string{"websecure"}
-        }
-
-        var customHeadersMiddlewareID string
-        if info.CustomHeaders != "" && info.CustomHeaders != "{}" && info.CustomHeaders != "null" {
-            var headersMap map[string]string 
-            if err := json.Unmarshal([]byte(info.CustomHeaders), &headersMap); err == nil && len(headersMap) > 0 {
-                middlewareName := fmt.Sprintf("%s-customheaders", info.ID) 
-                customRequestHeadersMap := make(map[string]string)
-                for k,v := range headersMap {
-                    customRequestHeadersMap[k] = v
-                }
-                config.HTTP.Middlewares[middlewareName] = map[string]interface{}{
-                    "headers": map[string]interface{}{"customRequestHeaders": customRequestHeadersMap},
-                }
-                customHeadersMiddlewareID = fmt.Sprintf("%s@file", middlewareName)
-            } else if err != nil {
-                log.Printf("Failed to parse custom headers for resource %s: %v. Headers: %s", info.ID, err, info.CustomHeaders)
-            }
-        }
+    return resourceDataMap, nil
+}
+
+// processResourcesWithServices processes resources with their assigned services
+func (cg *ConfigGenerator) processResourcesWithServices(config *TraefikConfig) error {
+    dsType := cg.activeDataSourceType()
 
-        var finalMiddlewares []string
-        if customHeadersMiddlewareID != "" {
-            finalMiddlewares = append(finalMiddlewares, customHeadersMiddlewareID)
+    resourceDataMap, err := cg.loadResourceRouterData("")
+    if err != nil {
+        return err
+    }
+    preview := cg.routerPreview
+    if preview != nil && preview.overlay != nil {
+        if data, ok := resourceDataMap[preview.resourceID]; ok {
+            resourceDataMap[preview.resourceID] = preview.overlay(data)
+        }
+    }
+    
+    skipHosts := cg.applyDuplicateHostPolicy(resourceDataMap)
+    
+    // Backend checks belong to generation runs; previews and checks would
+    // otherwise start and end checker runs in the middle of one
+    verify := cg.generating && cg.backendVerifyMode != BackendVerifyOff && cg.backendChecker != nil
+    if verify {
+        cg.backendChecker.startRun()
+        cg.prefetchRouterBackends(resourceDataMap, skipHosts, config)
+    }
+    
+    missingLogged := make(map[string]bool)
+    for id, data := range resourceDataMap {
+        if skipHosts[id] {
+            continue
         }
-        for _, mw := range assignedMiddlewares {
-            // Use extractBaseName here too for middleware IDs if needed
-            middlewareID := extractBaseName(mw.ID)
-            finalMiddlewares = append(finalMiddlewares, fmt.Sprintf("%s@file", middlewareID))
+        data = httpRouterData(data, config)
+        data, ok := cg.applyMissingServicePolicy(data, config, missingLogged)
+        if !ok {
+            continue
         }
+        routerID, routerConfig := cg.buildHTTPRouter(data, dsType, config)
         
-        // Only add the badger middleware when using Pangolin data source
-        if activeDSConfig.Type == models.PangolinAPI {
-            isBadgerPresent := false
-            for _, m := range finalMiddlewares {
-                if m == "badger@http" {
-                    isBadgerPresent = true
-                    break
+        // Only custom services are ours to inspect; upstream ones live in other providers
+        if verify && data.CustomServiceID.Valid && data.CustomServiceID.String != "" {
+            serviceID := normalizeServiceID(data.CustomServiceID.String)
+            ok, changed := cg.verifyRouterBackends(routerID, serviceID, config)
+            if !ok && cg.backendVerifyMode == BackendVerifySkip {
+                if changed {
+                    log.Printf("Skipping router %s: all backends of service %s are unreachable", routerID, serviceID)
                 }
+                continue
             }
-            if !isBadgerPresent {
-                finalMiddlewares = append(finalMiddlewares, "badger@http")
+        }
+        routers := map[string]map[string]interface{}{routerID: routerConfig}
+        if cg.splitRoutersPerEntrypoint {
+            routers = cg.splitRouterByEntrypoint(routerID, routerConfig, data.Info, config)
+        }
+        for splitID, splitConfig := range routers {
+            config.HTTP.Routers[splitID] = splitConfig
+            if preview != nil && data.Info.ID == preview.resourceID {
+                preview.routers[splitID] = splitConfig
             }
         }
-        
-// Find the section where serviceReference is set
-var serviceReference string
-if mapValueDataEntry.CustomServiceID.Valid && mapValueDataEntry.CustomServiceID.String != "" {
-    // Extract base name without any suffixes
-    baseName := normalizeServiceID(mapValueDataEntry.CustomServiceID.String)
-    // Always add the file provider for custom services
-    serviceReference = fmt.Sprintf("%s@file", baseName)
-} else {
-    // For Docker environments when using Traefik API, prefer docker provider
-    providerSuffix := "docker"
+    }
     
-    // If not using Traefik API as data source, use http provider
-    if activeDSConfig.Type != models.TraefikAPI {
-        providerSuffix = "http"
+    if cg.generating {
+        cg.missingServiceLogged = missingLogged
     }
     
-    // Extract base name without any suffixes
-    baseName := normalizeServiceID(info.ServiceID)
-    // Add the appropriate provider suffix
-    serviceReference = fmt.Sprintf("%s@%s", baseName, providerSuffix)
+    if verify {
+        MetricUnreachableBackends.Set(float64(cg.backendChecker.endRun()))
+    }
+
+    if cg.maintenanceService != "" {
+        return cg.processMaintenanceRouters(config)
+    }
+    return nil
 }
-        
-        log.Printf("Resource %s (HTTP): Router service set to %s. (SourceType: %s, ActiveDS: %s, CustomSvc: %s)",
-            info.ID,
-            serviceReference,
-            info.SourceType,
-            activeDSConfig.Type,
-            mapValueDataEntry.CustomServiceID.String)
 
-        // Make sure we don't have duplicated suffixes in router ID
-        routerIDBase := extractBaseName(info.ID)
-        routerIDForTraefik := fmt.Sprintf("%s-auth", routerIDBase) 
-        
+// processMaintenanceRouters adds a router for each disabled resource that sends
+// its traffic to the maintenance service, so clients get a maintenance page
+// rather than a connection error
+func (cg *ConfigGenerator) processMaintenanceRouters(config *TraefikConfig) error {
+    rows, err := cg.db.Query(`
+        SELECT id, host, entrypoints, tls_domains, COALESCE(tls_auto_domains, 0), router_priority
+        FROM resources
+        WHERE status = 'disabled' AND deleted_at IS NULL
+    `)
+    if err != nil {
+        return fmt.Errorf("failed to fetch disabled resources: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var info models.Resource
+        var routerPriority sql.NullInt64
+        if err := rows.Scan(&info.ID, &info.Host, &info.Entrypoints, &info.TLSDomains, &info.TLSAutoDomains, &routerPriority); err != nil {
+            log.Printf("Failed to scan disabled resource: %v", err)
+            continue
+        }
+        if info.Host == "" {
+            continue
+        }
+
+        // An active resource generating the same router always wins
+        routerID := fmt.Sprintf("%s-auth", extractBaseName(info.ID))
+        if _, exists := config.HTTP.Routers[routerID]; exists {
+            continue
+        }
+
+        priority := 100
+        if routerPriority.Valid {
+            priority = int(routerPriority.Int64)
+        }
+
+        entryPoints := routerEntryPoints(info)
         routerConfig := map[string]interface{}{
             "rule":        fmt.Sprintf("Host(`%s`)", info.Host),
-            "service":     serviceReference,
-            "entryPoints": routerEntryPoints,
-            "priority":    info.RouterPriority, 
+            "service":     cg.maintenanceService,
+            "entryPoints": entryPoints,
+            "priority":    priority,
         }
-        if len(finalMiddlewares) > 0 {
-            routerConfig["middlewares"] = finalMiddlewares
+        if tlsConfig := cg.routerTLSConfig(info, entryPoints); tlsConfig != nil {
+            routerConfig["tls"] = tlsConfig
         }
+        config.HTTP.Routers[routerID] = routerConfig
+    }
+    return rows.Err()
+}
 
-        tlsConfig := map[string]interface{}{"certResolver": "letsencrypt"}
-        if info.TLSDomains != "" {
-            sans := strings.Split(strings.TrimSpace(info.TLSDomains), ",")
-            var cleanSans []string
-            for _, s := range sans {
-                if trimmed := strings.TrimSpace(s); trimmed != "" {
-                    cleanSans = append(cleanSans, trimmed)
-                }
+// routerEntryPoints returns the resource's entrypoints, defaulting to websecure
+func routerEntryPoints(info models.Resource) []string {
+    entryPoints := strings.Split(strings.TrimSpace(info.Entrypoints), ",")
+    if len(entryPoints) == 0 || (len(entryPoints) == 1 && entryPoints[0] == "") {
+        entryPoints = []string{"websecure"}
+    }
+    return entryPoints
+}
+
+// RouterScheme returns the URL scheme clients reach a resource's HTTP router
+// with: https when one of its entrypoints terminates TLS, http otherwise
+func (cg *ConfigGenerator) RouterScheme(entrypoints string) string {
+    if cg.usesTLSEntrypoint(routerEntryPoints(models.Resource{Entrypoints: entrypoints})) {
+        return "https"
+    }
+    return "http"
+}
+
+// EntrypointAddress returns the host:port Traefik serves routers of the given
+// scheme on: the configured entrypoint address, or else the host of the Traefik
+// data source. A missing port defaults to 80 or 443. It returns "" if neither is set.
+func (cg *ConfigGenerator) EntrypointAddress(scheme string) string {
+    cg.mutex.Lock()
+    address := cg.entrypointAddress
+    cg.mutex.Unlock()
+
+    if address == "" {
+        dsConfig, found := cg.traefikDataSource()
+        if !found {
+            return ""
+        }
+        parsed, err := url.Parse(dsConfig.URL)
+        if err != nil || parsed.Hostname() == "" {
+            return ""
+        }
+        address = parsed.Hostname()
+    }
+    if _, _, err := net.SplitHostPort(address); err != nil {
+        port := "80"
+        if scheme == "https" {
+            port = "443"
+        }
+        address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+    }
+    return util.NormalizeServerAddress(address)
+}
+
+// routerTLSConfig returns the tls block for a resource's router, or nil when
+// none of its entrypoints terminates TLS
+func (cg *ConfigGenerator) routerTLSConfig(info models.Resource, entryPoints []string) map[string]interface{} {
+    // HTTP-only routers shouldn't carry TLS config
+    if !cg.usesTLSEntrypoint(entryPoints) {
+        return nil
+    }
+
+    tlsConfig := map[string]interface{}{"certResolver": "letsencrypt"}
+    var cleanSans []string
+    if info.TLSDomains != "" {
+        sans := strings.Split(strings.TrimSpace(info.TLSDomains), ",")
+        for _, s := range sans {
+            if trimmed := strings.TrimSpace(s); trimmed != "" {
+                cleanSans = append(cleanSans, trimmed)
             }
-            if len(cleanSans) > 0 {
-                tlsConfig["domains"] = []map[string]interface{}{{"main": info.Host, "sans": cleanSans}}
+        }
+    }
+    if (cg.autoTLSDomains || info.TLSAutoDomains) && info.Host != "" {
+        tlsConfig["domains"] = []map[string]interface{}{autoTLSDomain(info.Host, cleanSans)}
+    } else if len(cleanSans) > 0 {
+        tlsConfig["domains"] = []map[string]interface{}{{"main": info.Host, "sans": cleanSans}}
+    }
+    return tlsConfig
+}
+
+// autoTLSDomain builds a certificate domain for a resource's host that also
+// covers its manual SANs, without repeating the host or any SAN
+func autoTLSDomain(host string, sans []string) map[string]interface{} {
+    domain := map[string]interface{}{"main": host}
+    seen := map[string]bool{strings.ToLower(host): true}
+    var uniqueSans []string
+    for _, san := range sans {
+        if key := strings.ToLower(san); !seen[key] {
+            seen[key] = true
+            uniqueSans = append(uniqueSans, san)
+        }
+    }
+    if len(uniqueSans) > 0 {
+        domain["sans"] = uniqueSans
+    }
+    return domain
+}
+
+// buildHTTPRouter builds the router block for a single resource. Any per-resource
+// middlewares it needs (such as custom headers and error pages) are added to config.
+func (cg *ConfigGenerator) buildHTTPRouter(data resourceRouterData, dsType models.DataSourceType, config *TraefikConfig) (string, map[string]interface{}) {
+    info := data.Info
+    assignedMiddlewares := make([]MiddlewareWithPriority, len(data.Middlewares))
+    copy(assignedMiddlewares, data.Middlewares)
+    
+    sort.SliceStable(assignedMiddlewares, func(i, j int) bool {
+        return assignedMiddlewares[i].Priority > assignedMiddlewares[j].Priority
+    })
+
+    entryPoints := routerEntryPoints(info)
+
+    var customHeadersMiddlewareID string
+    if info.CustomHeaders != "" && info.CustomHeaders != "{}" && info.CustomHeaders != "null" {
+        var headersMap map[string]string 
+        if err := json.Unmarshal([]byte(info.CustomHeaders), &headersMap); err == nil && len(headersMap) > 0 {
+            middlewareName := fmt.Sprintf("%s-customheaders", info.ID) 
+            // Empty values remove the header; they go through the same
+            // preservation logic as middleware headers so they're emitted as ""
+            customRequestHeadersMap := make(map[string]interface{})
+            for k,v := range headersMap {
+                customRequestHeadersMap[k] = v
             }
+            config.HTTP.Middlewares[middlewareName] = map[string]interface{}{
+                "headers": map[string]interface{}{"customRequestHeaders": customRequestHeadersMap},
+            }
+            customHeadersMiddlewareID = fmt.Sprintf("%s@file", middlewareName)
+        } else if err != nil {
+            log.Printf("Failed to parse custom headers for resource %s: %v. Headers: %s", info.ID, err, info.CustomHeaders)
+        }
+    }
+
+    var errorPagesMiddlewareID string
+    if info.ErrorPages != "" {
+        var pages models.ErrorPages
+        if err := json.Unmarshal([]byte(info.ErrorPages), &pages); err != nil {
+            log.Printf("Failed to parse error pages for resource %s: %v. Error pages: %s", info.ID, err, info.ErrorPages)
+        } else if len(pages.Status) > 0 {
+            middlewareName := fmt.Sprintf("%s-errorpages", info.ID)
+            config.HTTP.Middlewares[middlewareName] = map[string]interface{}{
+                "errors": pages.MiddlewareConfig(),
+            }
+            errorPagesMiddlewareID = fmt.Sprintf("%s@file", middlewareName)
         }
+    }
+
+    var finalMiddlewares []string
+    // Error pages go first so they also replace errors from the other middlewares
+    if errorPagesMiddlewareID != "" {
+        finalMiddlewares = append(finalMiddlewares, errorPagesMiddlewareID)
+    }
+    if customHeadersMiddlewareID != "" {
+        finalMiddlewares = append(finalMiddlewares, customHeadersMiddlewareID)
+    }
+    for _, mw := range assignedMiddlewares {
+        // Use extractBaseName here too for middleware IDs if needed
+        middlewareID := extractBaseName(mw.ID)
+        finalMiddlewares = append(finalMiddlewares, fmt.Sprintf("%s@file", middlewareID))
+    }
+    
+    // Only add the badger middleware when using Pangolin data source, and
+    // never to resources that opted out of auth
+    if dsType == models.PangolinAPI && !info.SkipAuth {
+        isBadgerPresent := false
+        for _, m := range finalMiddlewares {
+            if m == "badger@http" {
+                isBadgerPresent = true
+                break
+            }
+        }
+        if !isBadgerPresent {
+            finalMiddlewares = append(finalMiddlewares, "badger@http")
+        }
+    }
+    
+    var serviceReference string
+    if data.CustomServiceID.Valid && data.CustomServiceID.String != "" {
+        // Extract base name without any suffixes
+        baseName := normalizeServiceID(data.CustomServiceID.String)
+        // Always add the file provider for custom services
+        serviceReference = fmt.Sprintf("%s@file", baseName)
+    } else if cg.defaultService != "" && info.ServiceID == cg.defaultService {
+        // The default service is referenced as configured, like the maintenance service
+        serviceReference = cg.defaultService
+    } else {
+        // For Docker environments when using Traefik API, prefer docker provider
+        providerSuffix := "docker"
+        
+        switch dsType {
+        case models.TraefikAPI, models.DockerAPI:
+        case models.TraefikFile:
+            // Services read from dynamic config files live in the file provider
+            providerSuffix = "file"
+        default:
+            // Otherwise (Pangolin) use http provider
+            providerSuffix = "http"
+        }
+        
+        // Add the provider suffix chosen by the configured strategy
+        serviceReference = cg.upstreamServiceReference(info.ServiceID, "http", providerSuffix)
+    }
+    
+    log.Printf("Resource %s (HTTP): Router service set to %s. (SourceType: %s, ActiveDS: %s, CustomSvc: %s)",
+        info.ID,
+        serviceReference,
+        info.SourceType,
+        dsType,
+        data.CustomServiceID.String)
+
+    // Make sure we don't have duplicated suffixes in router ID
+    routerIDBase := extractBaseName(info.ID)
+    routerIDForTraefik := fmt.Sprintf("%s-auth", routerIDBase) 
+    
+    routerConfig := map[string]interface{}{
+        "rule":        fmt.Sprintf("Host(`%s`)", info.Host),
+        "service":     serviceReference,
+        "entryPoints": entryPoints,
+        "priority":    info.RouterPriority, 
+    }
+    if len(finalMiddlewares) > 0 {
+        routerConfig["middlewares"] = finalMiddlewares
+    }
+
+    if tlsConfig := cg.routerTLSConfig(info, entryPoints); tlsConfig != nil {
         routerConfig["tls"] = tlsConfig
-        config.HTTP.Routers[routerIDForTraefik] = routerConfig
     }
-    return nil
+    return routerIDForTraefik, routerConfig
 }
 
-// Add to the imports if needed:
-// import "encoding/json"
+// EffectiveMiddleware is one entry of the middleware chain a resource's router is generated with
+type EffectiveMiddleware struct {
+    Reference string `json:"reference"`          // As written in the router, e.g. id@file
+    ID        string `json:"id,omitempty"`       // Middleware ID for assigned middlewares
+    Name      string `json:"name,omitempty"`     // Middleware name for assigned middlewares
+    Priority  int    `json:"priority,omitempty"` // Assignment priority for assigned middlewares
+    Source    string `json:"source"`             // assigned, custom_headers, error_pages or pangolin_auth
+}
 
-// Helper to fetch service names from Traefik API
-func (cg *ConfigGenerator) fetchTraefikServiceNames() map[string]string {
+// EffectiveMiddlewares returns the middlewares of a resource's HTTP router in the
+// order they're generated, including the ones injected for the resource. Disabled
+// resources don't get a router, so their chain is empty.
+func (cg *ConfigGenerator) EffectiveMiddlewares(resourceID string) ([]EffectiveMiddleware, error) {
+    resourceDataMap, err := cg.loadResourceRouterData(resourceID)
+    if err != nil {
+        return nil, err
+    }
+    chain := []EffectiveMiddleware{}
+    data, ok := resourceDataMap[resourceID]
+    if !ok {
+        return chain, nil
+    }
+
+    _, router := cg.buildHTTPRouter(data, cg.activeDataSourceType(), newTraefikConfig())
+    references, _ := router["middlewares"].([]string)
+
+    assigned := make(map[string]MiddlewareWithPriority)
+    for _, mw := range data.Middlewares {
+        assigned[fmt.Sprintf("%s@file", extractBaseName(mw.ID))] = mw
+    }
+
+    for _, ref := range references {
+        entry := EffectiveMiddleware{Reference: ref}
+        switch {
+        case ref == "badger@http":
+            entry.Source = "pangolin_auth"
+        case ref == fmt.Sprintf("%s-customheaders@file", data.Info.ID):
+            entry.Source = "custom_headers"
+        case ref == fmt.Sprintf("%s-errorpages@file", data.Info.ID):
+            entry.Source = "error_pages"
+        default:
+            entry.Source = "assigned"
+            if mw, ok := assigned[ref]; ok {
+                entry.ID = mw.ID
+                entry.Priority = mw.Priority
+                if err := cg.db.QueryRow("SELECT name FROM middlewares WHERE id = ?", mw.ID).Scan(&entry.Name); err != nil && err != sql.ErrNoRows {
+                    return nil, fmt.Errorf("failed to fetch middleware %s: %w", mw.ID, err)
+                }
+            }
+        }
+        chain = append(chain, entry)
+    }
+    return chain, nil
+}
+
+// routerPreview changes one resource's router data before its routers are
+// built and collects the HTTP routers generated for it
+type routerPreview struct {
+    resourceID string
+    overlay    func(data resourceRouterData) resourceRouterData
+    routers    map[string]interface{}
+}
+
+// previewResourceRouters builds the configuration the next generation run
+// would write, with overlay applied to the resource's router data, and returns
+// the resource's HTTP routers by router ID. The duplicate host, missing service
+// and split router policies apply, so the result may hold no router or several.
+// Callers must hold generateMutex.
+func (cg *ConfigGenerator) previewResourceRouters(resourceID string, overlay func(data resourceRouterData) resourceRouterData) (map[string]interface{}, *TraefikConfig, error) {
+    preview := &routerPreview{resourceID: resourceID, overlay: overlay, routers: make(map[string]interface{})}
+    cg.routerPreview = preview
+    defer func() { cg.routerPreview = nil }()
+
+    config, err := cg.assembleConfig(middlewaresForDisplay)
+    if err != nil {
+        return nil, nil, err
+    }
+    return preview.routers, config, nil
+}
+
+// activeResourceExists reports whether the resource is active, so it has HTTP routers to preview
+func (cg *ConfigGenerator) activeResourceExists(resourceID string) error {
+    var exists int
+    err := cg.db.QueryRow("SELECT 1 FROM resources WHERE id = ? AND status = 'active' AND deleted_at IS NULL", resourceID).Scan(&exists)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("active resource not found: %s", resourceID)
+    }
+    return err
+}
+
+// PreviewMiddlewareAssignment returns the HTTP routers generated for a resource,
+// by router ID, before and after overlaying the proposed middleware assignments.
+// Nothing is persisted. A proposed middleware that is already assigned has its
+// priority replaced.
+func (cg *ConfigGenerator) PreviewMiddlewareAssignment(resourceID string, proposed []MiddlewareWithPriority) (map[string]interface{}, map[string]interface{}, error) {
+    cg.generateMutex.Lock()
+    defer cg.generateMutex.Unlock()
+
+    if err := cg.activeResourceExists(resourceID); err != nil {
+        return nil, nil, err
+    }
+    before, _, err := cg.previewResourceRouters(resourceID, nil)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    after, _, err := cg.previewResourceRouters(resourceID, func(data resourceRouterData) resourceRouterData {
+        overlay := data
+        overlay.Middlewares = make([]MiddlewareWithPriority, len(data.Middlewares))
+        copy(overlay.Middlewares, data.Middlewares)
+        for _, p := range proposed {
+            replaced := false
+            for i := range overlay.Middlewares {
+                if overlay.Middlewares[i].ID == p.ID {
+                    overlay.Middlewares[i].Priority = p.Priority
+                    replaced = true
+                    break
+                }
+            }
+            if !replaced {
+                overlay.Middlewares = append(overlay.Middlewares, p)
+            }
+        }
+        return overlay
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    return before, after, nil
+}
+
+// PreviewServiceAssignment returns the HTTP routers generated for a resource,
+// by router ID, before and after assigning it the given custom service, and
+// whether that service is generated as an HTTP service (a router can't use it
+// otherwise). Nothing is persisted.
+func (cg *ConfigGenerator) PreviewServiceAssignment(resourceID, serviceID string) (map[string]interface{}, map[string]interface{}, bool, error) {
+    cg.generateMutex.Lock()
+    defer cg.generateMutex.Unlock()
+
+    if err := cg.activeResourceExists(resourceID); err != nil {
+        return nil, nil, false, err
+    }
+    before, _, err := cg.previewResourceRouters(resourceID, nil)
+    if err != nil {
+        return nil, nil, false, err
+    }
+
+    after, config, err := cg.previewResourceRouters(resourceID, func(data resourceRouterData) resourceRouterData {
+        data.CustomServiceID = sql.NullString{String: serviceID, Valid: true}
+        return data
+    })
+    if err != nil {
+        return nil, nil, false, err
+    }
+    _, isHTTP := config.HTTP.Services[normalizeServiceID(serviceID)]
+    return before, after, isHTTP, nil
+}
+
+// traefikDataSource returns the active data source if it is a Traefik API,
+// otherwise any configured Traefik API data source
+func (cg *ConfigGenerator) traefikDataSource() (models.DataSourceConfig, bool) {
+    dsConfig, err := cg.configManager.GetActiveDataSourceConfig()
+    if err == nil && dsConfig.Type == models.TraefikAPI {
+        return dsConfig, true
+    }
+    for _, candidate := range cg.configManager.GetDataSources() {
+        if candidate.Type == models.TraefikAPI {
+            return candidate, true
+        }
+    }
+    return models.DataSourceConfig{}, false
+}
+
+// fetchTraefikServiceNames fetches the names of Traefik's http or tcp services,
+// mapped from base name to the full name with provider. The active data source
+// is used if it is Traefik, otherwise the configured Traefik data source.
+func (cg *ConfigGenerator) fetchTraefikServiceNames(protocol string) map[string]string {
     serviceMap := make(map[string]string)
-    client := &http.Client{Timeout: 5 * time.Second}
-    
     // Get Traefik API URL from data source config
-    dsConfig, err := cg.configManager.GetActiveDataSourceConfig()
-    if err != nil {
-        log.Printf("Warning: Failed to get active data source config: %v", err)
+    dsConfig, found := cg.traefikDataSource()
+    if !found {
+        log.Printf("Warning: No Traefik data source configured to look up service names")
         return serviceMap
     }
+    client := NewDataSourceHTTPClient(dsConfig, 5*time.Second)
     
-    apiURL := dsConfig.URL
+    apiURL := strings.TrimSuffix(dsConfig.URL, "/")
     
-    // Fetch HTTP services
-    resp, err := client.Get(apiURL + "/api/http/services")
+    // Fetch services
+    resp, err := client.Get(fmt.Sprintf("%s/api/%s/services", apiURL, protocol))
     if err != nil {
         log.Printf("Warning: Failed to fetch services from Traefik API: %v", err)
         return serviceMap
@@ -510,7 +1444,7 @@ func (cg *ConfigGenerator) processTCPRouters(config *TraefikConfig) error {
                rs.service_id as custom_service_id
         FROM resources r
         LEFT JOIN resource_services rs ON r.id = rs.resource_id
-        WHERE r.status = 'active' AND r.tcp_enabled = 1
+        WHERE r.status = 'active' AND r.tcp_enabled = 1 AND r.deleted_at IS NULL
     `
     rows, err := cg.db.Query(query)
     if err != nil {
@@ -542,6 +1476,13 @@ func (cg *ConfigGenerator) processTCPRouters(config *TraefikConfig) error {
             rule = fmt.Sprintf("HostSNI(`%s`)", host)
         }
 
+		// Custom HTTP services are for the resource's HTTP router
+		if customServiceID.Valid {
+			if _, isHTTP := config.HTTP.Services[normalizeServiceID(customServiceID.String)]; isHTTP {
+				customServiceID = sql.NullString{}
+			}
+		}
+
 		var tcpServiceReference string
 		if customServiceID.Valid && customServiceID.String != "" {
 			// Extract base name without any suffixes
@@ -557,12 +1498,14 @@ func (cg *ConfigGenerator) processTCPRouters(config *TraefikConfig) error {
 				if models.DataSourceType(sourceType) == models.TraefikAPI {
 					providerSuffix = "docker"
 				}
+			} else if activeDSConfig.Type == models.DockerAPI {
+				providerSuffix = "docker"
+			} else if activeDSConfig.Type == models.TraefikFile {
+				providerSuffix = "file"
 			}
 			
-			// Extract base name without any suffixes
-			baseName := normalizeServiceID(serviceID)
-			// Add the appropriate provider suffix
-			tcpServiceReference = fmt.Sprintf("%s@%s", baseName, providerSuffix)
+			// Add the provider suffix chosen by the configured strategy
+			tcpServiceReference = cg.upstreamServiceReference(serviceID, "tcp", providerSuffix)
 		}
         log.Printf("Resource %s (TCP): Router service set to %s. (SourceType: %s, ActiveDS: %s, CustomSvc: %s)", 
             id, tcpServiceReference, sourceType, activeDSConfig.Type, customServiceID.String)
@@ -587,22 +1530,123 @@ func (cg *ConfigGenerator) processTCPRouters(config *TraefikConfig) error {
 // These should be mostly the same as previously provided, ensure `models.ProcessMiddlewareConfig`
 // and `models.ProcessServiceConfig` are used where appropriate for type-specific logic.
 
+// hasConfigurationChanged reports whether newConfig differs from the last
+// config written. lastConfig is only updated once a write succeeds, so a
+// config that failed to write is tried again by the next run.
 func (cg *ConfigGenerator) hasConfigurationChanged(newConfig []byte) bool {
-	if cg.lastConfig == nil || len(cg.lastConfig) != len(newConfig) || string(cg.lastConfig) != string(newConfig) {
-		cg.lastConfig = make([]byte, len(newConfig))
-		copy(cg.lastConfig, newConfig)
-		return true
-	}
-	return false
+	return cg.lastConfig == nil || string(cg.lastConfig) != string(newConfig)
 }
 
-func (cg *ConfigGenerator) writeConfigToFile(yamlData []byte) error {
+// writeConfigToFile writes the config, staging and validating it first when
+// a staging dir is set. A config the validation command rejects has its hash
+// remembered in rejectedHash.
+func (cg *ConfigGenerator) writeConfigToFile(ctx context.Context, yamlData []byte) (err error) {
+	_, span := StartSpan(ctx, "writeConfigToFile")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	hash := configHash(yamlData)
+	yamlData = withGeneratedHeader(yamlData, time.Now())
+
+	if cg.stagingDir != "" {
+		stagedFile := filepath.Join(cg.stagingDir, "resource-overrides.yml")
+		if err := writeFileWithRetry(ctx, stagedFile, yamlData); err != nil {
+			return fmt.Errorf("failed to write staged config: %w", err)
+		}
+		if err := cg.validateStagedConfig(ctx, stagedFile); err != nil {
+			// Shutting down can kill the command; that's no verdict on the config
+			if ctx.Err() == nil {
+				cg.rejectedHash = hash
+			}
+			return err
+		}
+		log.Printf("Staged config passed validation, promoting to %s", cg.confDir)
+	}
+
 	configFile := filepath.Join(cg.confDir, "resource-overrides.yml")
-	tempFile := configFile + ".tmp"
-	if err := os.WriteFile(tempFile, yamlData, 0644); err != nil {
+	span.SetAttribute("file.path", configFile)
+	return writeFileWithRetry(ctx, configFile, yamlData)
+}
+
+// validateStagedConfig runs the validation command, if any, against the staged
+// config. The command runs through sh with STAGED_CONFIG_FILE and STAGING_CONF_DIR set.
+func (cg *ConfigGenerator) validateStagedConfig(ctx context.Context, stagedFile string) error {
+	if cg.validateCmd == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validateCmdTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", cg.validateCmd)
+	cmd.Env = append(os.Environ(),
+		"STAGED_CONFIG_FILE="+stagedFile,
+		"STAGING_CONF_DIR="+cg.stagingDir,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("staged config failed validation, not promoting: %w: %s", err, out)
+		}
+		return fmt.Errorf("staged config failed validation, not promoting: %w", err)
+	}
+	return nil
+}
+
+// generatedFileMarker starts the header comment of every file the generator writes
+const generatedFileMarker = "# Generated by middleware-manager"
+
+// withGeneratedHeader prepends a comment marking the file as generated, so it
+// isn't mistaken for a hand-written file in the same conf dir. It is only added
+// when writing so the timestamp doesn't make every run look like a change.
+func withGeneratedHeader(yamlData []byte, generatedAt time.Time) []byte {
+	header := fmt.Sprintf(generatedFileMarker+" at %s; do not edit\n"+
+		"# Manual changes are overwritten the next time the configuration is generated\n",
+		generatedAt.UTC().Format(time.RFC3339))
+	return append([]byte(header), yamlData...)
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write temp config file: %w", err)
 	}
-	return os.Rename(tempFile, configFile)
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to move temp config file into place: %w", err)
+	}
+	return nil
+}
+
+// Config file writes are attempted this many times before the run fails.
+// Failures are usually transient (e.g. a busy network mount), and giving up
+// at once would leave the old config live for a whole interval.
+const (
+	configWriteAttempts   = 3
+	configWriteRetryDelay = 500 * time.Millisecond
+)
+
+// writeFileWithRetry writes path with writeFileAtomic, retrying failures.
+// It stops waiting to retry once ctx is done.
+func writeFileWithRetry(ctx context.Context, path string, data []byte) error {
+	var err error
+	for attempt := 1; attempt <= configWriteAttempts; attempt++ {
+		if err = writeFileAtomic(path, data); err == nil {
+			return nil
+		}
+		MetricConfigWriteErrors.Inc()
+		if attempt < configWriteAttempts {
+			log.Printf("Failed to write %s (attempt %d of %d), retrying: %v", path, attempt, configWriteAttempts, err)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%v (not retrying: %w)", err, ctx.Err())
+			case <-time.After(configWriteRetryDelay):
+			}
+		}
+	}
+	return err
 }
 
 // MiddlewareWithPriority represents a middleware with its priority value
@@ -620,30 +1664,6 @@ func stringSliceContains(slice []string, str string) bool {
 	return false
 }
 
-func determineServiceProtocol(serviceType string, config map[string]interface{}) string {
-	if serviceType == string(models.LoadBalancerType) {
-		if servers, ok := config["servers"].([]interface{}); ok {
-			for _, s := range servers {
-				if serverMap, ok := s.(map[string]interface{}); ok {
-					if _, hasAddress := serverMap["address"]; hasAddress {
-						// Could be TCP or UDP. Default to TCP.
-						// UDP services might need more specific markers or be handled by a separate UDP services map in TraefikConfig
-						return "tcp" 
-					}
-					if _, hasURL := serverMap["url"]; hasURL {
-						return "http"
-					}
-				}
-			}
-		}
-	}
-	// For weighted, mirroring, failover, they reference other services.
-	// The protocol is typically determined by the nature of those referenced services.
-	// For simplicity here, assume HTTP if not explicitly a loadbalancer with address.
-	return "http"
-}
-
-
 func preserveStringsInYamlNode(node *yaml.Node) {
 	if node == nil { return }
 	switch node.Kind {
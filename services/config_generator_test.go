@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hhftechnology/middleware-manager/database"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMain(m *testing.M) {
+	// InitDB looks for database/migrations.sql relative to the working directory
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+const testSecret = "s3cr3t-value"
+
+// newSecretConfigGenerator returns a generator over a database holding a
+// headers middleware whose header value is a secret reference
+func newSecretConfigGenerator(t *testing.T) *ConfigGenerator {
+	t.Helper()
+	t.Setenv("MM_TEST_SECRET", testSecret)
+
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`INSERT INTO middlewares (id, name, type, config) VALUES (?, ?, ?, ?)`,
+		"token-header", "Token header", "headers",
+		`{"customRequestHeaders": {"X-Token": "secretRef://MM_TEST_SECRET"}}`); err != nil {
+		t.Fatalf("insert middleware: %v", err)
+	}
+
+	configManager, err := NewConfigManager(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+	return NewConfigGenerator(db, dir, configManager)
+}
+
+func TestBuildConfigYAMLKeepsSecretRefs(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+
+	yamlData, err := cg.BuildConfigYAML()
+	if err != nil {
+		t.Fatalf("BuildConfigYAML: %v", err)
+	}
+	if strings.Contains(string(yamlData), testSecret) {
+		t.Errorf("preview contains the resolved secret:\n%s", yamlData)
+	}
+	if !strings.Contains(string(yamlData), "secretRef://MM_TEST_SECRET") {
+		t.Errorf("preview lost the secret reference:\n%s", yamlData)
+	}
+}
+
+func TestBuildDynamicConfigKeepsSecretRefs(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+
+	dynamic, err := cg.BuildDynamicConfig()
+	if err != nil {
+		t.Fatalf("BuildDynamicConfig: %v", err)
+	}
+	data, err := json.Marshal(dynamic)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), testSecret) {
+		t.Errorf("dynamic config contains the resolved secret: %s", data)
+	}
+	if !strings.Contains(string(data), "secretRef://MM_TEST_SECRET") {
+		t.Errorf("dynamic config lost the secret reference: %s", data)
+	}
+
+	// Traefik itself still gets the value
+	provider, err := cg.BuildHTTPProviderConfig()
+	if err != nil {
+		t.Fatalf("BuildHTTPProviderConfig: %v", err)
+	}
+	if !strings.Contains(string(provider), testSecret) {
+		t.Errorf("HTTP provider config is missing the resolved secret: %s", provider)
+	}
+}
+
+func TestTCPCustomServiceOnlyUsedByTCPRouter(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+	// keep would reference the service from the HTTP router as well
+	cg.SetMissingServicePolicy(MissingServiceKeep)
+
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, tcp_enabled) VALUES (?, ?, ?, ?, ?, 1)`,
+		"db", "db.example.com", "db-upstream", "org", "site"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+	if _, err := cg.db.Exec(`INSERT INTO services (id, name, type, config) VALUES (?, ?, ?, ?)`,
+		"db-tcp", "DB TCP", "loadBalancer", `{"servers": [{"address": "10.0.0.5:5432"}]}`); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	if _, err := cg.db.Exec(`INSERT INTO resource_services (resource_id, service_id) VALUES (?, ?)`, "db", "db-tcp"); err != nil {
+		t.Fatalf("insert resource service: %v", err)
+	}
+
+	config, err := cg.buildConfig()
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	tcpRouter, _ := config.TCP.Routers["db-tcp"].(map[string]interface{})
+	if tcpRouter["service"] != "db-tcp@file" {
+		t.Errorf("TCP router service = %v, want db-tcp@file", tcpRouter["service"])
+	}
+	httpRouter, _ := config.HTTP.Routers["db-auth"].(map[string]interface{})
+	if httpRouter["service"] == "db-tcp@file" {
+		t.Errorf("HTTP router references the TCP service db-tcp@file")
+	}
+	if problems := findDanglingServiceReferences(config); len(problems) > 0 {
+		t.Errorf("dangling service references: %v", problems)
+	}
+}
+
+func TestBuildExportIncludesResourceAssignments(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, status, router_priority) VALUES (?, ?, ?, ?, ?, 'disabled', 150)`,
+		"app", "app.example.com", "app-upstream", "org", "site"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+	if _, err := cg.db.Exec(`INSERT INTO resource_middlewares (resource_id, middleware_id, priority) VALUES (?, ?, ?)`,
+		"app", "token-header", 200); err != nil {
+		t.Fatalf("insert resource middleware: %v", err)
+	}
+
+	data, err := cg.BuildExport()
+	if err != nil {
+		t.Fatalf("BuildExport: %v", err)
+	}
+	var export ConfigExport
+	if err := yaml.Unmarshal(data, &export); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	resource, ok := export.Metadata.Resources["app"]
+	if !ok {
+		t.Fatalf("disabled resource missing from export:\n%s", data)
+	}
+	if resource.Status != "disabled" || resource.RouterPriority != 150 {
+		t.Errorf("resource = %+v, want status disabled and priority 150", resource)
+	}
+	if len(resource.Middlewares) != 1 || resource.Middlewares[0] != (ExportedAssignment{ID: "token-header", Priority: 200}) {
+		t.Errorf("middlewares = %+v, want token-header at priority 200", resource.Middlewares)
+	}
+}
+
+func TestDefaultServiceReferencedAsConfigured(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+	cg.SetMissingServicePolicy(MissingServiceKeep)
+	cg.SetDefaultService("placeholder@file")
+
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id) VALUES (?, ?, ?, ?, ?)`,
+		"app", "app.example.com", "placeholder@file", "org", "site"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+
+	config, err := cg.buildConfig()
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	router, _ := config.HTTP.Routers["app-auth"].(map[string]interface{})
+	if router["service"] != "placeholder@file" {
+		t.Errorf("router service = %v, want placeholder@file", router["service"])
+	}
+}
+
+func TestRejectedConfigNotRevalidated(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	cg.SetStaging(filepath.Join(dir, "staging"), "echo run >> "+runs+"; exit 1")
+	if err := os.MkdirAll(filepath.Join(dir, "staging"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		cg.Generate(context.Background())
+	}
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatalf("validation command never ran: %v", err)
+	}
+	if n := strings.Count(string(data), "run"); n != 1 {
+		t.Errorf("validation command ran %d times for the same config, want 1", n)
+	}
+}
+
+func TestLastGoodConfigKeepsSecretRefs(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+
+	if _, err := cg.Generate(context.Background()); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	written, err := os.ReadFile(filepath.Join(cg.confDir, "resource-overrides.yml"))
+	if err != nil {
+		t.Fatalf("read written config: %v", err)
+	}
+	if !strings.Contains(string(written), testSecret) {
+		t.Fatalf("written config is missing the resolved secret:\n%s", written)
+	}
+
+	if _, err := cg.ConfirmCurrentConfig(); err != nil {
+		t.Fatalf("ConfirmCurrentConfig: %v", err)
+	}
+	var stored string
+	if err := cg.db.QueryRow("SELECT config FROM last_good_config WHERE id = 1").Scan(&stored); err != nil {
+		t.Fatalf("read last-good row: %v", err)
+	}
+	if strings.Contains(stored, testSecret) || !strings.Contains(stored, "secretRef://MM_TEST_SECRET") {
+		t.Errorf("stored last-known-good config doesn't keep the secret reference:\n%s", stored)
+	}
+	lastGood, err := cg.LastGoodConfig(true)
+	if err != nil {
+		t.Fatalf("LastGoodConfig: %v", err)
+	}
+	if strings.Contains(lastGood.Config, testSecret) {
+		t.Errorf("last-known-good response contains the resolved secret:\n%s", lastGood.Config)
+	}
+
+	// Restoring writes the resolved value again
+	if err := os.Remove(filepath.Join(cg.confDir, "resource-overrides.yml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cg.RestoreLastGood(context.Background()); err != nil {
+		t.Fatalf("RestoreLastGood: %v", err)
+	}
+	restored, err := os.ReadFile(filepath.Join(cg.confDir, "resource-overrides.yml"))
+	if err != nil {
+		t.Fatalf("read restored config: %v", err)
+	}
+	if !strings.Contains(string(restored), testSecret) || strings.Contains(string(restored), "secretRef://") {
+		t.Errorf("restored config doesn't have the secret resolved:\n%s", restored)
+	}
+}
+
+func TestPreviewMiddlewareAssignmentAppliesPolicies(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+	cg.SetSplitRoutersPerEntrypoint(true)
+
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id, entrypoints) VALUES (?, ?, ?, ?, ?, ?)`,
+		"app", "app.example.com", "app-upstream", "org", "site", "web,websecure"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+	proposed := []MiddlewareWithPriority{{ID: "token-header", Priority: 100}}
+
+	before, after, err := cg.PreviewMiddlewareAssignment("app", proposed)
+	if err != nil {
+		t.Fatalf("PreviewMiddlewareAssignment: %v", err)
+	}
+	for _, id := range []string{"app-auth-web", "app-auth-websecure"} {
+		if _, ok := before[id]; !ok {
+			t.Errorf("before is missing split router %s: %v", id, before)
+		}
+		router, _ := after[id].(map[string]interface{})
+		if middlewares, _ := router["middlewares"].([]string); !stringSliceContains(middlewares, "token-header@file") {
+			t.Errorf("after router %s middlewares = %v, want token-header@file", id, router["middlewares"])
+		}
+	}
+
+	// Under the reject policy a shared host leaves the resource without routers
+	cg.SetDuplicateHostPolicy(DuplicateHostReject)
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id) VALUES (?, ?, ?, ?, ?)`,
+		"app-copy", "app.example.com", "app-upstream", "org", "site"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+	before, after, err = cg.PreviewMiddlewareAssignment("app", proposed)
+	if err != nil {
+		t.Fatalf("PreviewMiddlewareAssignment: %v", err)
+	}
+	if len(before) != 0 || len(after) != 0 {
+		t.Errorf("rejected duplicate host previewed routers: before %v, after %v", before, after)
+	}
+}
+
+func TestPreviewServiceAssignmentAppliesMissingServicePolicy(t *testing.T) {
+	cg := newSecretConfigGenerator(t)
+	cg.SetMissingServicePolicy(MissingServiceSkip)
+
+	if _, err := cg.db.Exec(`INSERT INTO resources (id, host, service_id, org_id, site_id) VALUES (?, ?, ?, ?, ?)`,
+		"db", "db.example.com", "db-upstream", "org", "site"); err != nil {
+		t.Fatalf("insert resource: %v", err)
+	}
+	if _, err := cg.db.Exec(`INSERT INTO services (id, name, type, config) VALUES (?, ?, ?, ?)`,
+		"db-tcp", "DB TCP", "loadBalancer", `{"servers": [{"address": "10.0.0.5:5432"}]}`); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+
+	before, after, isHTTP, err := cg.PreviewServiceAssignment("db", "db-tcp")
+	if err != nil {
+		t.Fatalf("PreviewServiceAssignment: %v", err)
+	}
+	if isHTTP {
+		t.Errorf("db-tcp reported as an HTTP service")
+	}
+	if _, ok := before["db-auth"]; !ok {
+		t.Errorf("before is missing router db-auth: %v", before)
+	}
+	if len(after) != 0 {
+		t.Errorf("skip policy still previews routers for a TCP service: %v", after)
+	}
+}
@@ -21,6 +21,7 @@ type ConfigManager struct {
     configPath string
     config     models.SystemConfig
     mu         sync.RWMutex
+    listeners  []chan struct{} // Signalled when the active data source changes
 }
 
 // NewConfigManager creates a new config manager
@@ -203,7 +204,32 @@ func (cm *ConfigManager) SetActiveDataSource(name string) error {
     // Log the change
     log.Printf("Changed active data source from %s to %s", oldSource, name)
     
-    return cm.saveConfig()
+    if err := cm.saveConfig(); err != nil {
+        return err
+    }
+    
+    // Let watchers swap their fetchers now rather than on their next tick
+    for _, listener := range cm.listeners {
+        select {
+        case listener <- struct{}{}:
+        default:
+            // A change is already pending for this listener
+        }
+    }
+    
+    return nil
+}
+
+// ActiveSourceChanges returns a channel that receives a value whenever the
+// active data source changes. Changes that arrive while one is still pending
+// are coalesced.
+func (cm *ConfigManager) ActiveSourceChanges() <-chan struct{} {
+    cm.mu.Lock()
+    defer cm.mu.Unlock()
+    
+    listener := make(chan struct{}, 1)
+    cm.listeners = append(cm.listeners, listener)
+    return listener
 }
 
 // GetDataSources returns all configured data sources
@@ -233,6 +259,18 @@ func (cm *ConfigManager) UpdateDataSource(name string, config models.DataSourceC
         newConfig.URL = strings.TrimSuffix(newConfig.URL, "/")
     }
     
+    // Keep stored header values for headers sent back masked
+    if existing, ok := cm.config.DataSources[name]; ok && len(newConfig.Headers) > 0 {
+        headers := make(map[string]string, len(newConfig.Headers))
+        for headerName, value := range newConfig.Headers {
+            if value == models.MaskedSecret {
+                value = existing.Headers[headerName]
+            }
+            headers[headerName] = value
+        }
+        newConfig.Headers = headers
+    }
+    
     // Test the connection before saving
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
@@ -255,9 +293,7 @@ func (cm *ConfigManager) UpdateDataSource(name string, config models.DataSourceC
 
 // testDataSourceConnection tests the connection to a data source
 func (cm *ConfigManager) testDataSourceConnection(ctx context.Context, config models.DataSourceConfig) error {
-    client := &http.Client{
-        Timeout: 5 * time.Second,
-    }
+    client := NewDataSourceHTTPClient(config, 5*time.Second)
     
     var url string
     switch config.Type {
@@ -265,6 +301,10 @@ func (cm *ConfigManager) testDataSourceConnection(ctx context.Context, config mo
         url = config.URL + "/status"
     case models.TraefikAPI:
         url = config.URL + "/api/version"
+    case models.DockerAPI:
+        return PingDocker(ctx, config)
+    case models.TraefikFile:
+        return CheckFileSource(config)
     default:
         return fmt.Errorf("unsupported data source type: %s", config.Type)
     }
@@ -274,10 +314,8 @@ func (cm *ConfigManager) testDataSourceConnection(ctx context.Context, config mo
         return fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if config.BasicAuth.Username != "" {
-        req.SetBasicAuth(config.BasicAuth.Username, config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    config.ApplyToRequest(req)
     
     resp, err := client.Do(req)
     if err != nil {
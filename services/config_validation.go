@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The types below mirror the parts of Traefik's dynamic configuration
+// (pkg/config/dynamic) that we generate. Traefik's own package can't be
+// imported as a library because its module relies on replace directives, so
+// generated YAML is decoded into these instead. Only the top-level sections
+// are decoded strictly: fields and middleware kinds newer than these types,
+// such as those of plugins, are left for Traefik to check. Sub-trees that come
+// straight from user-supplied middleware or service configs are kept as raw
+// nodes; Traefik validates their contents itself.
+
+// dynamicSections lists the top-level sections of a dynamic configuration
+type dynamicSections struct {
+	HTTP yaml.Node `yaml:"http"`
+	TCP  yaml.Node `yaml:"tcp"`
+	UDP  yaml.Node `yaml:"udp"`
+	TLS  yaml.Node `yaml:"tls"`
+}
+
+type dynamicConfig struct {
+	HTTP *dynamicHTTPConfig `yaml:"http"`
+	TCP  *dynamicTCPConfig  `yaml:"tcp"`
+	UDP  *dynamicUDPConfig  `yaml:"udp"`
+}
+
+type dynamicHTTPConfig struct {
+	Routers           map[string]*dynamicHTTPRouter   `yaml:"routers"`
+	Middlewares       map[string]map[string]yaml.Node `yaml:"middlewares"`
+	Services          map[string]*dynamicService      `yaml:"services"`
+	ServersTransports map[string]yaml.Node            `yaml:"serversTransports"`
+}
+
+type dynamicHTTPRouter struct {
+	EntryPoints   []string          `yaml:"entryPoints"`
+	Middlewares   []string          `yaml:"middlewares"`
+	Service       string            `yaml:"service"`
+	Rule          string            `yaml:"rule"`
+	RuleSyntax    string            `yaml:"ruleSyntax"`
+	Priority      int               `yaml:"priority"`
+	TLS           *dynamicRouterTLS `yaml:"tls"`
+	Observability yaml.Node         `yaml:"observability"`
+}
+
+type dynamicTCPConfig struct {
+	Routers           map[string]*dynamicTCPRouter    `yaml:"routers"`
+	Middlewares       map[string]map[string]yaml.Node `yaml:"middlewares"`
+	Services          map[string]*dynamicService      `yaml:"services"`
+	ServersTransports map[string]yaml.Node            `yaml:"serversTransports"`
+}
+
+type dynamicTCPRouter struct {
+	EntryPoints []string          `yaml:"entryPoints"`
+	Middlewares []string          `yaml:"middlewares"`
+	Service     string            `yaml:"service"`
+	Rule        string            `yaml:"rule"`
+	RuleSyntax  string            `yaml:"ruleSyntax"`
+	Priority    int               `yaml:"priority"`
+	TLS         *dynamicRouterTLS `yaml:"tls"`
+}
+
+type dynamicUDPConfig struct {
+	Routers  map[string]yaml.Node       `yaml:"routers"`
+	Services map[string]*dynamicService `yaml:"services"`
+}
+
+type dynamicRouterTLS struct {
+	Passthrough  bool            `yaml:"passthrough"`
+	Options      string          `yaml:"options"`
+	CertResolver string          `yaml:"certResolver"`
+	Domains      []dynamicDomain `yaml:"domains"`
+}
+
+type dynamicDomain struct {
+	Main string   `yaml:"main"`
+	SANs []string `yaml:"sans"`
+}
+
+// dynamicService accepts any of Traefik's service kinds; exactly one must be set
+type dynamicService struct {
+	LoadBalancer yaml.Node `yaml:"loadBalancer"`
+	Weighted     yaml.Node `yaml:"weighted"`
+	Mirroring    yaml.Node `yaml:"mirroring"`
+	Failover     yaml.Node `yaml:"failover"`
+}
+
+// Middleware kinds known to Traefik's dynamic.Middleware and dynamic.TCPMiddleware
+var (
+	dynamicHTTPMiddlewareKinds = map[string]bool{
+		"addPrefix": true, "stripPrefix": true, "stripPrefixRegex": true,
+		"replacePath": true, "replacePathRegex": true, "chain": true,
+		"ipWhiteList": true, "ipAllowList": true, "headers": true, "errors": true,
+		"rateLimit": true, "redirectRegex": true, "redirectScheme": true,
+		"basicAuth": true, "digestAuth": true, "forwardAuth": true,
+		"inFlightReq": true, "buffering": true, "circuitBreaker": true,
+		"compress": true, "passTLSClientCert": true, "retry": true,
+		"contentType": true, "grpcWeb": true, "plugin": true,
+	}
+	dynamicTCPMiddlewareKinds = map[string]bool{
+		"inFlightConn": true, "ipWhiteList": true, "ipAllowList": true,
+	}
+)
+
+// validateGeneratedConfig checks that yamlData decodes cleanly into Traefik's
+// dynamic configuration structure and that routers, middlewares and services
+// are well formed. It also returns warnings about middlewares whose type
+// isn't checked.
+func validateGeneratedConfig(yamlData []byte) ([]string, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	decoder.KnownFields(true)
+	var sections dynamicSections
+	if err := decoder.Decode(&sections); err != nil {
+		return nil, fmt.Errorf("config does not match Traefik's dynamic configuration: %w", err)
+	}
+
+	var config dynamicConfig
+	if err := yaml.Unmarshal(yamlData, &config); err != nil {
+		return nil, fmt.Errorf("config does not match Traefik's dynamic configuration: %w", err)
+	}
+
+	var problems, warnings []string
+	if config.HTTP != nil {
+		for name, router := range config.HTTP.Routers {
+			if router == nil || router.Rule == "" {
+				problems = append(problems, fmt.Sprintf("http router %s has no rule", name))
+			}
+			if router != nil && router.Service == "" {
+				problems = append(problems, fmt.Sprintf("http router %s has no service", name))
+			}
+		}
+		middlewareProblems, unchecked := validateDynamicMiddlewares("http", config.HTTP.Middlewares, dynamicHTTPMiddlewareKinds)
+		problems = append(problems, middlewareProblems...)
+		warnings = append(warnings, unchecked...)
+		problems = append(problems, validateDynamicServices("http", config.HTTP.Services)...)
+	}
+	if config.TCP != nil {
+		for name, router := range config.TCP.Routers {
+			if router == nil || router.Rule == "" {
+				problems = append(problems, fmt.Sprintf("tcp router %s has no rule", name))
+			}
+			if router != nil && router.Service == "" {
+				problems = append(problems, fmt.Sprintf("tcp router %s has no service", name))
+			}
+		}
+		middlewareProblems, unchecked := validateDynamicMiddlewares("tcp", config.TCP.Middlewares, dynamicTCPMiddlewareKinds)
+		problems = append(problems, middlewareProblems...)
+		warnings = append(warnings, unchecked...)
+		problems = append(problems, validateDynamicServices("tcp", config.TCP.Services)...)
+	}
+	if config.UDP != nil {
+		problems = append(problems, validateDynamicServices("udp", config.UDP.Services)...)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid Traefik configuration: %s", strings.Join(problems, "; "))
+	}
+	sort.Strings(warnings)
+	return warnings, nil
+}
+
+// logUncheckedMiddlewares logs the warnings validateGeneratedConfig returned
+// when they first appear, not on every run. Like shared hosts, only generation
+// runs log them. Callers must hold generateMutex.
+func (cg *ConfigGenerator) logUncheckedMiddlewares(warnings []string) {
+	if !cg.generating {
+		return
+	}
+	logged := make(map[string]bool, len(warnings))
+	for _, warning := range warnings {
+		logged[warning] = true
+		if !cg.uncheckedMiddlewareLogged[warning] {
+			log.Print(warning)
+		}
+	}
+	cg.uncheckedMiddlewareLogged = logged
+}
+
+// validateDynamicMiddlewares checks each middleware has exactly one kind with a
+// mapping body. Kinds we don't know are returned as warnings rather than
+// rejected, as Traefik may support them.
+func validateDynamicMiddlewares(protocol string, middlewares map[string]map[string]yaml.Node, kinds map[string]bool) (problems, unchecked []string) {
+	for name, middleware := range middlewares {
+		if len(middleware) != 1 {
+			problems = append(problems, fmt.Sprintf("%s middleware %s must define exactly one middleware type, found %d", protocol, name, len(middleware)))
+			continue
+		}
+		for kind, body := range middleware {
+			if !kinds[kind] {
+				unchecked = append(unchecked, fmt.Sprintf("Warning: %s middleware %s has type %s, which isn't checked before writing", protocol, name, kind))
+			}
+			if body.Kind != yaml.MappingNode {
+				problems = append(problems, fmt.Sprintf("%s middleware %s: %s must be a mapping", protocol, name, kind))
+			}
+		}
+	}
+	return problems, unchecked
+}
+
+// validateDynamicServices checks each service sets exactly one service kind
+func validateDynamicServices(protocol string, services map[string]*dynamicService) []string {
+	var problems []string
+	for name, service := range services {
+		count := 0
+		if service != nil {
+			for _, kind := range []yaml.Kind{service.LoadBalancer.Kind, service.Weighted.Kind, service.Mirroring.Kind, service.Failover.Kind} {
+				if kind != 0 {
+					count++
+				}
+			}
+		}
+		if count != 1 {
+			problems = append(problems, fmt.Sprintf("%s service %s must define exactly one service type, found %d", protocol, name, count))
+		}
+	}
+	return problems
+}
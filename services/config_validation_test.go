@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateGeneratedConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "valid config",
+			yaml: `
+http:
+  routers:
+    app-router:
+      rule: Host(` + "`app.example.com`" + `)
+      service: app@file
+  middlewares:
+    auth:
+      basicAuth:
+        users: ["admin:hash"]
+  services:
+    app:
+      loadBalancer:
+        servers:
+          - url: http://app:8080
+`,
+		},
+		{
+			name: "unknown middleware kind",
+			yaml: `
+http:
+  middlewares:
+    geoblock:
+      geoBlock:
+        allowedCountries: ["DE"]
+`,
+		},
+		{
+			name: "unknown router field",
+			yaml: `
+http:
+  routers:
+    app-router:
+      rule: Host(` + "`app.example.com`" + `)
+      service: app@file
+      newTraefikOption: true
+`,
+		},
+		{
+			name:    "unknown top-level section",
+			yaml:    "htp:\n  routers: {}\n",
+			wantErr: "field htp not found",
+		},
+		{
+			name: "wrong value type",
+			yaml: `
+http:
+  routers:
+    app-router:
+      rule: Host(` + "`app.example.com`" + `)
+      service: app@file
+      priority: high
+`,
+			wantErr: "cannot unmarshal",
+		},
+		{
+			name: "middleware with two kinds",
+			yaml: `
+http:
+  middlewares:
+    both:
+      addPrefix:
+        prefix: /api
+      stripPrefix:
+        prefixes: ["/api"]
+`,
+			wantErr: "exactly one middleware type",
+		},
+		{
+			name: "router without a rule",
+			yaml: `
+http:
+  routers:
+    app-router:
+      service: app@file
+`,
+			wantErr: "has no rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateGeneratedConfig([]byte(tt.yaml))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUncheckedMiddlewaresLoggedOnce(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	yamlData := []byte(`
+http:
+  middlewares:
+    custom:
+      someNewKind:
+        enabled: true
+`)
+	cg := &ConfigGenerator{}
+	for _, generating := range []bool{false, true, true} {
+		warnings, err := validateGeneratedConfig(yamlData)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cg.generating = generating
+		cg.logUncheckedMiddlewares(warnings)
+	}
+	if count := strings.Count(logs.String(), "custom has type someNewKind"); count != 1 {
+		t.Errorf("warning logged %d times, want once by the first generation run:\n%s", count, logs.String())
+	}
+}
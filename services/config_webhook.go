@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Timeout for delivering a config change notification
+const webhookTimeout = 5 * time.Second
+
+// Header carrying the HMAC-SHA256 of the request body when a secret is set
+const WebhookSignatureHeader = "X-Middleware-Manager-Signature"
+
+// configWebhook posts a notification each time a new config is written
+type configWebhook struct {
+	url      string
+	secret   string
+	client   *http.Client
+	previous *configIDs // IDs in the last written config, nil until known
+}
+
+// configIDs are the router and middleware names of a config
+type configIDs struct {
+	HTTPRouters map[string]bool
+	TCPRouters  map[string]bool
+	Middlewares map[string]bool
+}
+
+// IDDiff lists the names added to and removed from a config section
+type IDDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// ConfigChangeEvent is the JSON payload posted to the webhook
+type ConfigChangeEvent struct {
+	Event       string    `json:"event"`
+	File        string    `json:"file"`
+	Hash        string    `json:"hash"` // SHA-256 of the generated YAML
+	GeneratedAt time.Time `json:"generated_at"`
+	Routers     IDDiff    `json:"routers"`
+	TCPRouters  IDDiff    `json:"tcp_routers"`
+	Middlewares IDDiff    `json:"middlewares"`
+}
+
+// ParseWebhookURL checks a WEBHOOK_URL value. Empty means no webhook.
+func ParseWebhookURL(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", value)
+	}
+	return value, nil
+}
+
+// SetWebhook makes the generator POST a ConfigChangeEvent to webhookURL each
+// time it writes a new config. If secret is set, the body is signed with
+// HMAC-SHA256 in the X-Middleware-Manager-Signature header as sha256=<hex>.
+func (cg *ConfigGenerator) SetWebhook(webhookURL, secret string) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	if webhookURL == "" {
+		cg.webhook = nil
+		return
+	}
+	cg.webhook = &configWebhook{
+		url:    webhookURL,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// loadPreviousConfigIDs reads the IDs of the config file about to be
+// replaced, so the first notification after a start has a meaningful diff.
+// It must run before the new config is written.
+func (cg *ConfigGenerator) loadPreviousConfigIDs(configFile string) {
+	if cg.webhook == nil || cg.webhook.previous != nil {
+		return
+	}
+	ids := &configIDs{}
+	data, err := ioutil.ReadFile(configFile)
+	if err == nil {
+		var previous TraefikConfig
+		if err := yaml.Unmarshal(data, &previous); err != nil {
+			log.Printf("Webhook: could not read previous config %s, reporting everything as added: %v", configFile, err)
+		} else {
+			ids = collectConfigIDs(&previous)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Webhook: could not read previous config %s, reporting everything as added: %v", configFile, err)
+	}
+	cg.webhook.previous = ids
+}
+
+// notifyConfigChanged sends the webhook for a config that was just written.
+// Delivery happens in the background and failures are only logged.
+func (cg *ConfigGenerator) notifyConfigChanged(config *TraefikConfig, configFile, hash string) {
+	webhook := cg.webhook
+	if webhook == nil {
+		return
+	}
+
+	current := collectConfigIDs(config)
+	previous := webhook.previous
+	if previous == nil {
+		previous = &configIDs{}
+	}
+	webhook.previous = current
+
+	event := ConfigChangeEvent{
+		Event:       "config_changed",
+		File:        configFile,
+		Hash:        hash,
+		GeneratedAt: time.Now().UTC(),
+		Routers:     diffIDs(previous.HTTPRouters, current.HTTPRouters),
+		TCPRouters:  diffIDs(previous.TCPRouters, current.TCPRouters),
+		Middlewares: diffIDs(previous.Middlewares, current.Middlewares),
+	}
+	go func() {
+		if err := webhook.send(event); err != nil {
+			log.Printf("Webhook notification to %s failed: %v", webhook.url, err)
+		}
+	}()
+}
+
+// send posts the event, signing it if a secret is set
+func (w *configWebhook) send(event ConfigChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "middleware-manager")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(payload)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// collectConfigIDs returns the router and middleware names of a config
+func collectConfigIDs(config *TraefikConfig) *configIDs {
+	keys := func(m map[string]interface{}) map[string]bool {
+		set := make(map[string]bool, len(m))
+		for key := range m {
+			set[key] = true
+		}
+		return set
+	}
+	return &configIDs{
+		HTTPRouters: keys(config.HTTP.Routers),
+		TCPRouters:  keys(config.TCP.Routers),
+		Middlewares: keys(config.HTTP.Middlewares),
+	}
+}
+
+// diffIDs lists the names only in current as added and those only in
+// previous as removed, both sorted
+func diffIDs(previous, current map[string]bool) IDDiff {
+	diff := IDDiff{Added: []string{}, Removed: []string{}}
+	for id := range current {
+		if !previous[id] {
+			diff.Added = append(diff.Added, id)
+		}
+	}
+	for id := range previous {
+		if !current[id] {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	return diff
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// DefaultMiddleware is a middleware assigned to every newly discovered resource
+type DefaultMiddleware struct {
+	MiddlewareID string
+	Priority     int
+}
+
+// ParseDefaultMiddlewares parses a DEFAULT_MIDDLEWARES value: comma-separated
+// middleware IDs, each optionally followed by :priority (100 if omitted), e.g.
+// "security-headers:200,rate-limit". Empty means none.
+func ParseDefaultMiddlewares(value string) ([]DefaultMiddleware, error) {
+	var defaults []DefaultMiddleware
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, priority := entry, 100
+		if i := strings.LastIndex(entry, ":"); i >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(entry[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority in %q, expected middleware-id:priority", entry)
+			}
+			id, priority = strings.TrimSpace(entry[:i]), n
+		}
+		if id == "" {
+			return nil, fmt.Errorf("missing middleware ID in %q", entry)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("middleware %s is listed more than once", id)
+		}
+		seen[id] = true
+		defaults = append(defaults, DefaultMiddleware{MiddlewareID: id, Priority: priority})
+	}
+	return defaults, nil
+}
+
+// SetDefaultMiddlewares makes the watcher assign middlewares to each resource
+// it creates. Existing resources are left alone.
+func (rw *ResourceWatcher) SetDefaultMiddlewares(defaults []DefaultMiddleware) {
+	rw.defaultMiddlewares = defaults
+}
+
+// assignDefaultMiddlewares assigns the default middlewares to a resource that
+// was just created, in the transaction that created it. Middlewares that don't
+// exist (yet) are skipped with a log line rather than failing the resource.
+func (rw *ResourceWatcher) assignDefaultMiddlewares(tx *sql.Tx, resourceID string) error {
+	for _, mw := range rw.defaultMiddlewares {
+		var exists int
+		err := tx.QueryRow("SELECT 1 FROM middlewares WHERE id = ? AND deleted_at IS NULL", mw.MiddlewareID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			log.Printf("Default middleware %s does not exist, not assigning it to resource %s", mw.MiddlewareID, resourceID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up default middleware %s: %w", mw.MiddlewareID, err)
+		}
+
+		if _, err := tx.Exec(
+			"INSERT OR IGNORE INTO resource_middlewares (resource_id, middleware_id, priority) VALUES (?, ?, ?)",
+			resourceID, mw.MiddlewareID, mw.Priority,
+		); err != nil {
+			return fmt.Errorf("failed to assign default middleware %s to resource %s: %w", mw.MiddlewareID, resourceID, err)
+		}
+		log.Printf("Assigned default middleware %s to new resource %s with priority %d", mw.MiddlewareID, resourceID, mw.Priority)
+	}
+	return nil
+}
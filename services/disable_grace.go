@@ -0,0 +1,145 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// DisableGracePeriod is how long a resource may be missing from the data
+// source before the watcher disables it: a number of consecutive fetches, or a
+// duration since it was first seen missing. The zero value disables a resource
+// on the first fetch it is missing from.
+type DisableGracePeriod struct {
+	Cycles   int
+	Duration time.Duration
+}
+
+// ParseDisableGracePeriod parses a DISABLE_GRACE_PERIOD value: a plain number
+// of fetches such as 3, or a Go duration such as 5m. Empty means no grace.
+func ParseDisableGracePeriod(value string) (DisableGracePeriod, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return DisableGracePeriod{}, nil
+	}
+	if cycles, err := strconv.Atoi(value); err == nil {
+		if cycles < 0 {
+			return DisableGracePeriod{}, fmt.Errorf("grace period %q must not be negative", value)
+		}
+		return DisableGracePeriod{Cycles: cycles}, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return DisableGracePeriod{}, fmt.Errorf("invalid grace period %q, expected a number of fetches or a duration", value)
+	}
+	if duration < 0 {
+		return DisableGracePeriod{}, fmt.Errorf("grace period %q must not be negative", value)
+	}
+	return DisableGracePeriod{Duration: duration}, nil
+}
+
+// String describes the grace period for logs
+func (g DisableGracePeriod) String() string {
+	switch {
+	case g.Duration > 0:
+		return g.Duration.String()
+	case g.Cycles > 1:
+		return fmt.Sprintf("%d fetches", g.Cycles)
+	}
+	return "none"
+}
+
+// SetDisableGracePeriod makes resources missing from the data source stay
+// active until they have been missing for the grace period
+func (rw *ResourceWatcher) SetDisableGracePeriod(grace DisableGracePeriod) {
+	rw.disableGrace = grace
+}
+
+// resourceMissing records that an active resource was absent from a fetch and
+// disables it once it has been missing for the grace period. The time it was
+// first missed is stored in missing_since; consecutive misses are counted in
+// memory, so a restart starts the count again.
+func (rw *ResourceWatcher) resourceMissing(resourceID string) {
+	now := time.Now()
+	if _, err := rw.db.Exec(
+		"UPDATE resources SET missing_since = ? WHERE id = ? AND missing_since IS NULL",
+		now, resourceID,
+	); err != nil {
+		log.Printf("Error recording missing resource %s: %v", resourceID, err)
+	}
+
+	var missingSince sql.NullTime
+	if err := rw.db.QueryRow("SELECT missing_since FROM resources WHERE id = ?", resourceID).Scan(&missingSince); err != nil {
+		log.Printf("Error reading missing_since of resource %s: %v", resourceID, err)
+	}
+	since := now
+	if missingSince.Valid {
+		since = missingSince.Time
+	}
+
+	rw.missingCycles[resourceID]++
+	cycles := rw.missingCycles[resourceID]
+
+	grace := rw.disableGrace
+	expired := cycles >= grace.Cycles
+	if grace.Duration > 0 {
+		expired = now.Sub(since) >= grace.Duration
+	}
+	if !expired {
+		log.Printf("Resource %s is missing from the data source (%d consecutive fetches, since %s), keeping it active for the %s grace period",
+			resourceID, cycles, since.Format(time.RFC3339), grace)
+		return
+	}
+
+	log.Printf("Resource %s no longer exists, marking as disabled", resourceID)
+	rw.disableResource(resourceID)
+	delete(rw.missingCycles, resourceID)
+}
+
+// clearMissing forgets that resources seen in the latest fetch were missing.
+// found is keyed by normalized resource ID.
+func (rw *ResourceWatcher) clearMissing(found map[string]bool) {
+	rows, err := rw.db.Query("SELECT id FROM resources WHERE missing_since IS NOT NULL")
+	if err != nil {
+		log.Printf("Error querying missing resources: %v", err)
+		return
+	}
+	stillMissing := make(map[string]bool)
+	var reappeared []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Error scanning missing resource ID: %v", err)
+			continue
+		}
+		if found[util.NormalizeID(id)] {
+			reappeared = append(reappeared, id)
+		} else {
+			stillMissing[id] = true
+		}
+	}
+	rows.Close()
+
+	for _, id := range reappeared {
+		if _, err := rw.db.Exec("UPDATE resources SET missing_since = NULL WHERE id = ?", id); err != nil {
+			log.Printf("Error clearing missing_since of resource %s: %v", id, err)
+			stillMissing[id] = true
+			continue
+		}
+		if rw.missingCycles[id] > 0 {
+			log.Printf("Resource %s is back in the data source after %d missed fetches", id, rw.missingCycles[id])
+		}
+	}
+
+	// Drop counts of resources that reappeared, were deleted or were disabled
+	for id := range rw.missingCycles {
+		if !stillMissing[id] {
+			delete(rw.missingCycles, id)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/models"
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// DefaultDockerURL is the Docker socket used when a docker data source has no URL
+const DefaultDockerURL = "unix:///var/run/docker.sock"
+
+// Host used in request URLs sent over a unix socket; the socket path is dialed instead
+const dockerSocketHost = "http://docker"
+
+// DockerFetcher discovers routers and services from the Traefik labels of
+// running containers, read from the Docker Engine API. It implements both
+// ResourceFetcher and ServiceFetcher.
+type DockerFetcher struct {
+	config     models.DataSourceConfig
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewDockerFetcher creates a new Docker label fetcher. The data source URL is
+// either a unix socket (unix:///var/run/docker.sock) or a TCP endpoint such as
+// a socket proxy (tcp://docker-proxy:2375 or http://docker-proxy:2375).
+func NewDockerFetcher(config models.DataSourceConfig) *DockerFetcher {
+	client, baseURL := newDockerHTTPClient(config, 10*time.Second)
+	return &DockerFetcher{
+		config:     config,
+		httpClient: client,
+		baseURL:    baseURL,
+	}
+}
+
+// newDockerHTTPClient returns a client for the Docker API and the base URL to
+// send requests to
+func newDockerHTTPClient(config models.DataSourceConfig, timeout time.Duration) (*http.Client, string) {
+	endpoint := config.URL
+	if endpoint == "" {
+		endpoint = DefaultDockerURL
+	}
+
+	if strings.HasPrefix(endpoint, "unix://") {
+		socketPath := strings.TrimPrefix(endpoint, "unix://")
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		return &http.Client{Timeout: timeout, Transport: transport}, dockerSocketHost
+	}
+
+	if strings.HasPrefix(endpoint, "tcp://") {
+		scheme := "http://"
+		if config.TLS.CertFile != "" || config.TLS.CAFile != "" {
+			scheme = "https://"
+		}
+		endpoint = scheme + strings.TrimPrefix(endpoint, "tcp://")
+	}
+	return NewDataSourceHTTPClient(config, timeout), strings.TrimSuffix(endpoint, "/")
+}
+
+// PingDocker checks that the Docker API of a docker data source responds
+func PingDocker(ctx context.Context, config models.DataSourceConfig) error {
+	client, baseURL := newDockerHTTPClient(config, 5*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/_ping", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	config.ApplyToRequest(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Docker API returned status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dockerContainer is the part of the Docker API's container list entry we use
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Labels          map[string]string `json:"Labels"`
+	Ports           []dockerPort      `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerPort struct {
+	PrivatePort int    `json:"PrivatePort"`
+	Type        string `json:"Type"`
+}
+
+// name returns the container name without the leading slash
+func (c dockerContainer) name() string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// dockerRouter is an HTTP router defined by container labels
+type dockerRouter struct {
+	name        string
+	rule        string
+	entrypoints string
+	service     string
+	priority    int
+	tls         bool
+	domains     []models.TraefikTLSDomain
+	container   string
+}
+
+// dockerService is an HTTP loadBalancer service defined by container labels.
+// Containers defining the same service add their servers to it, like Traefik does.
+type dockerService struct {
+	name           string
+	servers        []string
+	passHostHeader *bool
+}
+
+// listContainers returns the running containers
+func (f *DockerFetcher) listContainers(ctx context.Context) ([]dockerContainer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+"/containers/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	f.config.ApplyToRequest(req)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Docker API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var containers []dockerContainer
+	if err := json.Unmarshal(body, &containers); err != nil {
+		return nil, fmt.Errorf("failed to parse containers JSON: %w", err)
+	}
+	return containers, nil
+}
+
+// fetchLabelConfig reads the routers and services defined by container labels
+func (f *DockerFetcher) fetchLabelConfig(ctx context.Context) ([]dockerRouter, map[string]*dockerService, error) {
+	containers, err := f.listContainers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Sort so that duplicate definitions resolve the same way every run
+	sort.Slice(containers, func(i, j int) bool { return containers[i].name() < containers[j].name() })
+
+	var routers []dockerRouter
+	seenRouters := make(map[string]bool)
+	services := make(map[string]*dockerService)
+	for _, container := range containers {
+		containerRouters, containerServices := parseDockerLabels(container)
+		for _, router := range containerRouters {
+			if seenRouters[router.name] {
+				log.Printf("Router %s is defined by more than one container, keeping the first", router.name)
+				continue
+			}
+			seenRouters[router.name] = true
+			routers = append(routers, router)
+		}
+		for _, service := range containerServices {
+			if existing, ok := services[service.name]; ok {
+				existing.servers = append(existing.servers, service.servers...)
+				continue
+			}
+			services[service.name] = service
+		}
+	}
+	return routers, services, nil
+}
+
+// parseDockerLabels extracts the HTTP routers and services a container's
+// traefik.* labels define. Label options are matched case-insensitively, as
+// Traefik does. Containers with traefik.enable=false are ignored.
+func parseDockerLabels(container dockerContainer) ([]dockerRouter, []*dockerService) {
+	labels := make(map[string]string, len(container.Labels))
+	for key, value := range container.Labels {
+		labels[strings.ToLower(key)] = value
+	}
+	if enabled, ok := labels["traefik.enable"]; ok && !strings.EqualFold(enabled, "true") {
+		return nil, nil
+	}
+
+	routerOptions := dockerLabelOptions(container.Labels, "traefik.http.routers.")
+	serviceOptions := dockerLabelOptions(container.Labels, "traefik.http.services.")
+	if len(routerOptions) == 0 && len(serviceOptions) == 0 {
+		return nil, nil
+	}
+
+	address := dockerContainerAddress(container, labels["traefik.docker.network"])
+
+	// Without service labels Traefik creates a service named after the container
+	if len(serviceOptions) == 0 {
+		serviceOptions[container.name()] = map[string]string{}
+	}
+	var services []*dockerService
+	for name, options := range serviceOptions {
+		service := &dockerService{name: name}
+		port := options["loadbalancer.server.port"]
+		if port == "" {
+			port = dockerDefaultPort(container)
+		}
+		scheme := options["loadbalancer.server.scheme"]
+		if scheme == "" {
+			scheme = "http"
+		}
+		if address != "" && port != "" {
+			service.servers = append(service.servers, util.NormalizeServerURL(fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(address, port))))
+		} else {
+			log.Printf("Container %s: no address or port for service %s", container.name(), name)
+		}
+		if value, ok := options["loadbalancer.passhostheader"]; ok {
+			if passHostHeader, err := strconv.ParseBool(value); err == nil {
+				service.passHostHeader = &passHostHeader
+			}
+		}
+		services = append(services, service)
+	}
+
+	var routers []dockerRouter
+	for name, options := range routerOptions {
+		router := dockerRouter{
+			name:        name,
+			rule:        options["rule"],
+			entrypoints: options["entrypoints"],
+			service:     options["service"],
+			container:   container.name(),
+		}
+		if router.service == "" {
+			// Traefik only infers the service when the container defines exactly one
+			if len(services) != 1 {
+				log.Printf("Container %s: router %s has no service and the container defines %d, skipping", container.name(), name, len(services))
+				continue
+			}
+			router.service = services[0].name
+		}
+		if priority, err := strconv.Atoi(options["priority"]); err == nil {
+			router.priority = priority
+		}
+		router.tls, router.domains = dockerRouterTLS(options)
+		routers = append(routers, router)
+	}
+	return routers, services
+}
+
+// dockerLabelOptions groups labels under prefix by the name that follows it,
+// e.g. traefik.http.routers.web.rule becomes options["web"]["rule"]. Names keep
+// their case, options are lowercased.
+func dockerLabelOptions(labels map[string]string, prefix string) map[string]map[string]string {
+	options := make(map[string]map[string]string)
+	for key, value := range labels {
+		if !strings.HasPrefix(strings.ToLower(key), prefix) {
+			continue
+		}
+		parts := strings.SplitN(key[len(prefix):], ".", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			continue
+		}
+		if options[parts[0]] == nil {
+			options[parts[0]] = make(map[string]string)
+		}
+		options[parts[0]][strings.ToLower(parts[1])] = value
+	}
+	return options
+}
+
+// dockerRouterTLS reads a router's tls options, including tls.domains[n].main and .sans
+func dockerRouterTLS(options map[string]string) (bool, []models.TraefikTLSDomain) {
+	enabled := false
+	domains := make(map[int]*models.TraefikTLSDomain)
+	for option, value := range options {
+		if option != "tls" && !strings.HasPrefix(option, "tls.") {
+			continue
+		}
+		if option == "tls" {
+			enabled, _ = strconv.ParseBool(value)
+			continue
+		}
+		enabled = true
+
+		var index int
+		var field string
+		if _, err := fmt.Sscanf(option, "tls.domains[%d].%s", &index, &field); err != nil {
+			continue
+		}
+		if domains[index] == nil {
+			domains[index] = &models.TraefikTLSDomain{}
+		}
+		switch field {
+		case "main":
+			domains[index].Main = value
+		case "sans":
+			domains[index].Sans = strings.Split(value, ",")
+		}
+	}
+
+	indexes := make([]int, 0, len(domains))
+	for index := range domains {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	result := make([]models.TraefikTLSDomain, 0, len(indexes))
+	for _, index := range indexes {
+		result = append(result, *domains[index])
+	}
+	return enabled, result
+}
+
+// dockerContainerAddress returns the container's IP on the given network, or
+// on the first of its networks (by name) if none is given
+func dockerContainerAddress(container dockerContainer, network string) string {
+	networks := container.NetworkSettings.Networks
+	if network != "" {
+		if settings, ok := networks[network]; ok {
+			return settings.IPAddress
+		}
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := networks[name].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// dockerDefaultPort returns the lowest TCP port the container exposes
+func dockerDefaultPort(container dockerContainer) string {
+	lowest := 0
+	for _, port := range container.Ports {
+		if port.Type != "" && port.Type != "tcp" {
+			continue
+		}
+		if lowest == 0 || port.PrivatePort < lowest {
+			lowest = port.PrivatePort
+		}
+	}
+	if lowest == 0 {
+		return ""
+	}
+	return strconv.Itoa(lowest)
+}
+
+// FetchResources returns a resource for each labelled HTTP router with a host rule
+func (f *DockerFetcher) FetchResources(ctx context.Context) (*models.ResourceCollection, error) {
+	routers, _, err := f.fetchLabelConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := &models.ResourceCollection{
+		Resources: make([]models.Resource, 0, len(routers)),
+	}
+	for _, router := range routers {
+		// Skip routers without TLS only if configured to do so
+		if !router.tls && !shouldIncludeNonTLSRouters() {
+			continue
+		}
+
+		host := extractHostFromRule(router.rule)
+		if host == "" {
+			log.Printf("Could not extract host from rule of router %s on container %s: %s", router.name, router.container, router.rule)
+			continue
+		}
+
+		resources.Resources = append(resources.Resources, models.Resource{
+			ID:             router.name + "@docker",
+			Host:           host,
+			ServiceID:      router.service,
+			Status:         "active",
+			SourceType:     string(models.DockerAPI),
+			Entrypoints:    router.entrypoints,
+			TLSDomains:     models.JoinTLSDomains(router.domains),
+			RouterPriority: router.priority,
+		})
+	}
+
+	log.Printf("Fetched %d resources from Docker labels", len(resources.Resources))
+	return resources, nil
+}
+
+// FetchServices returns a loadBalancer service for each labelled HTTP service
+func (f *DockerFetcher) FetchServices(ctx context.Context) (*models.ServiceCollection, error) {
+	_, dockerServices, err := f.fetchLabelConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(dockerServices))
+	for name := range dockerServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services := &models.ServiceCollection{
+		Services: make([]models.Service, 0, len(names)),
+	}
+	for _, name := range names {
+		dockerService := dockerServices[name]
+		if len(dockerService.servers) == 0 {
+			continue
+		}
+
+		servers := make([]map[string]interface{}, 0, len(dockerService.servers))
+		for _, server := range dockerService.servers {
+			servers = append(servers, map[string]interface{}{"url": server})
+		}
+		config := map[string]interface{}{"servers": servers}
+		if dockerService.passHostHeader != nil {
+			config["passHostHeader"] = *dockerService.passHostHeader
+		}
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			log.Printf("Error marshaling service config: %v", err)
+			continue
+		}
+
+		services.Services = append(services.Services, models.Service{
+			ID:        name + "@docker",
+			Name:      name,
+			Type:      string(models.LoadBalancerType),
+			Config:    string(configJSON),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+	}
+
+	log.Printf("Fetched %d services from Docker labels", len(services.Services))
+	return services, nil
+}
+
+// FetchRaw returns the Docker API's container list unprocessed
+func (f *DockerFetcher) FetchRaw(ctx context.Context) (map[string]interface{}, error) {
+	payload, err := fetchRawJSON(ctx, f.httpClient, f.config, f.baseURL+"/containers/json")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"/containers/json": payload}, nil
+}
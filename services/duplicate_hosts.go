@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// DuplicateHostPolicy controls what the generator does with active resources
+// that share a host
+type DuplicateHostPolicy string
+
+const (
+	// DuplicateHostWarn generates every router and logs the shared hosts
+	DuplicateHostWarn DuplicateHostPolicy = "warn"
+	// DuplicateHostHighestPriority only generates the router of the resource
+	// with the highest router priority; ties go to the lowest resource ID
+	DuplicateHostHighestPriority DuplicateHostPolicy = "highest-priority-wins"
+	// DuplicateHostReject generates no router for any resource sharing a host
+	DuplicateHostReject DuplicateHostPolicy = "reject"
+)
+
+// ParseDuplicateHostPolicy parses a DUPLICATE_HOST_POLICY value; empty means warn
+func ParseDuplicateHostPolicy(value string) (DuplicateHostPolicy, error) {
+	switch DuplicateHostPolicy(value) {
+	case "", DuplicateHostWarn:
+		return DuplicateHostWarn, nil
+	case DuplicateHostHighestPriority:
+		return DuplicateHostHighestPriority, nil
+	case DuplicateHostReject:
+		return DuplicateHostReject, nil
+	}
+	return "", fmt.Errorf("unknown duplicate host policy %q, expected warn, highest-priority-wins or reject", value)
+}
+
+// duplicateHostGroup is a host shared by more than one active resource
+type duplicateHostGroup struct {
+	host      string
+	resources []string // Sorted resource IDs
+}
+
+// findDuplicateHosts groups resources by host, case-insensitively, and returns
+// the hosts used by more than one of them, sorted by host
+func findDuplicateHosts(hosts map[string]string) []duplicateHostGroup {
+	byHost := make(map[string][]string)
+	for id, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		byHost[host] = append(byHost[host], id)
+	}
+
+	var groups []duplicateHostGroup
+	for host, ids := range byHost {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		groups = append(groups, duplicateHostGroup{host: host, resources: ids})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].host < groups[j].host })
+	return groups
+}
+
+// applyDuplicateHostPolicy returns the IDs of resources whose routers should be
+// left out under the generator's duplicate host policy. Shared hosts are
+// logged when they first appear or their outcome changes, not on every run.
+// Only generation runs log; previews and checks leave duplicateHostLogged
+// alone. Callers must hold generateMutex.
+func (cg *ConfigGenerator) applyDuplicateHostPolicy(resourceDataMap map[string]resourceRouterData) map[string]bool {
+	hosts := make(map[string]string, len(resourceDataMap))
+	for id, data := range resourceDataMap {
+		hosts[id] = data.Info.Host
+	}
+	groups := findDuplicateHosts(hosts)
+	MetricDuplicateHosts.Set(float64(len(groups)))
+
+	skip := make(map[string]bool)
+	messages := make([]string, 0, len(groups))
+	for _, group := range groups {
+		switch cg.duplicateHostPolicy {
+		case DuplicateHostHighestPriority:
+			winner := group.resources[0]
+			for _, id := range group.resources[1:] {
+				if resourceDataMap[id].Info.RouterPriority > resourceDataMap[winner].Info.RouterPriority {
+					winner = id
+				}
+			}
+			for _, id := range group.resources {
+				if id != winner {
+					skip[id] = true
+				}
+			}
+			messages = append(messages, fmt.Sprintf("Host %s is used by resources %s; only generating the router of %s (highest priority)",
+				group.host, strings.Join(group.resources, ", "), winner))
+		case DuplicateHostReject:
+			for _, id := range group.resources {
+				skip[id] = true
+			}
+			messages = append(messages, fmt.Sprintf("Host %s is used by resources %s; generating none of their routers until only one remains",
+				group.host, strings.Join(group.resources, ", ")))
+		default:
+			messages = append(messages, fmt.Sprintf("Warning: host %s is used by resources %s; Traefik will route it by router priority and rule length",
+				group.host, strings.Join(group.resources, ", ")))
+		}
+	}
+
+	if !cg.generating {
+		return skip
+	}
+	logged := make(map[string]bool, len(messages))
+	for _, message := range messages {
+		logged[message] = true
+		if !cg.duplicateHostLogged[message] {
+			log.Print(message)
+		}
+	}
+	cg.duplicateHostLogged = logged
+	return skip
+}
+
+// checkDuplicateHosts finds active resources that share a host, which lets one
+// of them silently take over the other's traffic
+func (cg *ConfigGenerator) checkDuplicateHosts(report *ConsistencyReport) error {
+	rows, err := cg.db.Query("SELECT id, host FROM resources WHERE status = 'active' AND deleted_at IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to fetch resources: %w", err)
+	}
+	defer rows.Close()
+
+	hosts := make(map[string]string)
+	for rows.Next() {
+		var id, host string
+		if err := rows.Scan(&id, &host); err != nil {
+			return fmt.Errorf("failed to scan resource: %w", err)
+		}
+		hosts[id] = host
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read resources: %w", err)
+	}
+
+	for _, group := range findDuplicateHosts(hosts) {
+		report.add("duplicate_host", SeverityWarning, fmt.Sprintf("host %s is used by active resources %s (policy: %s)",
+			group.host, strings.Join(group.resources, ", "), cg.duplicateHostPolicy))
+	}
+	return nil
+}
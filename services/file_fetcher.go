@@ -0,0 +1,356 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/models"
+	"github.com/hhftechnology/middleware-manager/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of the dynamic config files FileFetcher reads. JSON is parsed as YAML.
+var fileSourceExtensions = []string{".yml", ".yaml", ".json"}
+
+// FileFetcher reads routers and services from Traefik dynamic configuration
+// files, the kind Traefik's file provider loads, for setups without API
+// access. The data source URL is the path of a file or of a directory whose
+// files are merged like Traefik does. It implements both ResourceFetcher and
+// ServiceFetcher.
+type FileFetcher struct {
+	config models.DataSourceConfig
+	path   string
+}
+
+// NewFileFetcher creates a new file fetcher; the URL may be a plain path or a file:// URL
+func NewFileFetcher(config models.DataSourceConfig) *FileFetcher {
+	return &FileFetcher{
+		config: config,
+		path:   fileSourcePath(config),
+	}
+}
+
+// fileSourcePath returns the path a file data source points at
+func fileSourcePath(config models.DataSourceConfig) string {
+	return strings.TrimPrefix(config.URL, "file://")
+}
+
+// CheckFileSource checks that the path of a file data source can be read
+func CheckFileSource(config models.DataSourceConfig) error {
+	path := fileSourcePath(config)
+	if path == "" {
+		return fmt.Errorf("no file or directory configured")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return nil
+}
+
+// fileDynamicConfig is the part of a dynamic config file FileFetcher maps.
+// Files are decoded generically and converted through JSON so the Traefik
+// API models, which use the same field names, can be reused.
+type fileDynamicConfig struct {
+	HTTP struct {
+		Routers  map[string]models.TraefikRouter  `json:"routers"`
+		Services map[string]models.TraefikService `json:"services"`
+	} `json:"http"`
+	TCP struct {
+		Services map[string]map[string]interface{} `json:"services"`
+	} `json:"tcp"`
+	UDP struct {
+		Services map[string]map[string]interface{} `json:"services"`
+	} `json:"udp"`
+}
+
+// configFiles returns the files to read, sorted so merges are deterministic
+func (f *FileFetcher) configFiles() ([]string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{f.path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !stringSliceContains(fileSourceExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		files = append(files, filepath.Join(f.path, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readConfigFile parses one dynamic config file into a generic document.
+// Files written by this manager are skipped (ok is false) so that pointing
+// the data source at Traefik's conf dir doesn't feed our own routers back in.
+func readConfigFile(path string) (document map[string]interface{}, ok bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.HasPrefix(data, []byte(generatedFileMarker)) {
+		return nil, false, nil
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return document, true, nil
+}
+
+// readDocuments reads every config file, keyed by path. Files that can't be
+// parsed are logged and skipped, unless the source is a single file.
+func (f *FileFetcher) readDocuments() (map[string]map[string]interface{}, []string, error) {
+	files, err := f.configFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list config files: %w", err)
+	}
+
+	documents := make(map[string]map[string]interface{}, len(files))
+	var read []string
+	for _, path := range files {
+		document, ok, err := readConfigFile(path)
+		if err != nil {
+			if len(files) == 1 {
+				return nil, nil, err
+			}
+			log.Printf("Warning: skipping dynamic config file: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		documents[path] = document
+		read = append(read, path)
+	}
+	return documents, read, nil
+}
+
+// fetchDynamicConfig merges the routers and services of all config files.
+// Names defined in more than one file keep their first definition.
+func (f *FileFetcher) fetchDynamicConfig() (*fileDynamicConfig, error) {
+	documents, paths, err := f.readDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &fileDynamicConfig{}
+	merged.HTTP.Routers = make(map[string]models.TraefikRouter)
+	merged.HTTP.Services = make(map[string]models.TraefikService)
+	merged.TCP.Services = make(map[string]map[string]interface{})
+	merged.UDP.Services = make(map[string]map[string]interface{})
+	for _, path := range paths {
+		// yaml.v3 decodes mappings with string keys, so the document converts to JSON as is
+		encoded, err := json.Marshal(documents[path])
+		if err != nil {
+			log.Printf("Warning: skipping dynamic config file %s: %v", path, err)
+			continue
+		}
+		var config fileDynamicConfig
+		if err := json.Unmarshal(encoded, &config); err != nil {
+			log.Printf("Warning: skipping dynamic config file %s: %v", path, err)
+			continue
+		}
+
+		for name, router := range config.HTTP.Routers {
+			if _, exists := merged.HTTP.Routers[name]; exists {
+				log.Printf("Router %s is defined in more than one file, keeping the first", name)
+				continue
+			}
+			merged.HTTP.Routers[name] = router
+		}
+		for name, service := range config.HTTP.Services {
+			if _, exists := merged.HTTP.Services[name]; exists {
+				log.Printf("Service %s is defined in more than one file, keeping the first", name)
+				continue
+			}
+			merged.HTTP.Services[name] = service
+		}
+		mergeStreamServices("TCP", merged.TCP.Services, config.TCP.Services)
+		mergeStreamServices("UDP", merged.UDP.Services, config.UDP.Services)
+	}
+	return merged, nil
+}
+
+// mergeStreamServices adds the TCP or UDP services of one file to the merged
+// ones, keeping the first definition of a name like HTTP services do
+func mergeStreamServices(protocol string, merged, services map[string]map[string]interface{}) {
+	for name, service := range services {
+		if _, exists := merged[name]; exists {
+			log.Printf("%s service %s is defined in more than one file, keeping the first", protocol, name)
+			continue
+		}
+		merged[name] = service
+	}
+}
+
+// FetchResources returns a resource for each HTTP router with a host rule
+func (f *FileFetcher) FetchResources(ctx context.Context) (*models.ResourceCollection, error) {
+	config, err := f.fetchDynamicConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(config.HTTP.Routers))
+	for name := range config.HTTP.Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources := &models.ResourceCollection{
+		Resources: make([]models.Resource, 0, len(names)),
+	}
+	for _, name := range names {
+		router := config.HTTP.Routers[name]
+
+		// Skip routers without TLS only if configured to do so
+		if router.TLS.CertResolver == "" && !shouldIncludeNonTLSRouters() {
+			continue
+		}
+
+		host := extractHostFromRule(router.Rule)
+		if host == "" {
+			log.Printf("Could not extract host from rule of router %s: %s", name, router.Rule)
+			continue
+		}
+
+		resources.Resources = append(resources.Resources, models.Resource{
+			ID:             name + "@file",
+			Host:           host,
+			ServiceID:      router.Service,
+			Status:         "active",
+			SourceType:     string(models.TraefikFile),
+			Entrypoints:    joinEntrypoints(router.EntryPoints),
+			TLSDomains:     models.JoinTLSDomains(router.TLS.Domains),
+			RouterPriority: router.Priority,
+		})
+	}
+
+	log.Printf("Fetched %d resources from %s", len(resources.Resources), f.path)
+	return resources, nil
+}
+
+// FetchServices returns the HTTP services defined in the config files
+func (f *FileFetcher) FetchServices(ctx context.Context) (*models.ServiceCollection, error) {
+	config, err := f.fetchDynamicConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(config.HTTP.Services))
+	for name := range config.HTTP.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services := &models.ServiceCollection{
+		Services: make([]models.Service, 0, len(names)),
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		traefikService := config.HTTP.Services[name]
+		traefikService.Name = name + "@file"
+		traefikService.Provider = "file"
+		if service := processTraefikService(traefikService); service != nil {
+			services.Services = append(services.Services, *service)
+			seen[service.ID] = true
+		}
+	}
+
+	// Service IDs don't carry the protocol, so a TCP or UDP service can't share
+	// a name with a service of another protocol
+	streamServices := []struct {
+		protocol    string
+		definitions map[string]map[string]interface{}
+	}{
+		{"TCP", config.TCP.Services},
+		{"UDP", config.UDP.Services},
+	}
+	for _, stream := range streamServices {
+		protocol := stream.protocol
+		for _, service := range fileStreamServices(stream.definitions) {
+			if seen[service.ID] {
+				log.Printf("Skipping %s service %s, a service with the same name is already defined", protocol, service.ID)
+				continue
+			}
+			seen[service.ID] = true
+			services.Services = append(services.Services, service)
+		}
+	}
+	sort.Slice(services.Services, func(i, j int) bool {
+		return services.Services[i].ID < services.Services[j].ID
+	})
+
+	log.Printf("Fetched %d services from %s", len(services.Services), f.path)
+	return services, nil
+}
+
+// fileStreamServices converts TCP or UDP services from a config file into
+// services. Like the Traefik API fetcher, a loadBalancer's settings are the
+// service config; weighted services keep their weighted block.
+func fileStreamServices(definitions map[string]map[string]interface{}) []models.Service {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var services []models.Service
+	for _, name := range names {
+		definition := definitions[name]
+		serviceType := string(models.LoadBalancerType)
+		config, ok := definition["loadBalancer"].(map[string]interface{})
+		if ok {
+			util.NormalizeServersInConfig(config)
+		} else if config, ok = definition["weighted"].(map[string]interface{}); ok {
+			serviceType = string(models.WeightedType)
+		} else {
+			log.Printf("Skipping service %s: only loadBalancer and weighted TCP and UDP services are supported", name)
+			continue
+		}
+
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			log.Printf("Skipping service %s: %v", name, err)
+			continue
+		}
+		services = append(services, models.Service{
+			ID:        name + "@file",
+			Name:      name + "@file",
+			Type:      serviceType,
+			Config:    string(configJSON),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+	}
+	return services
+}
+
+// FetchRaw returns each config file's parsed contents, keyed by path
+func (f *FileFetcher) FetchRaw(ctx context.Context) (map[string]interface{}, error) {
+	documents, _, err := f.readDocuments()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(documents))
+	for path, document := range documents {
+		result[path] = document
+	}
+	return result, nil
+}
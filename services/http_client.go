@@ -0,0 +1,89 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// NewDataSourceHTTPClient creates an HTTP client for talking to a data source,
+// applying its TLS settings (custom CA, client certificate, insecureSkipVerify).
+// If the TLS settings can't be loaded, the error is logged and every request
+// made with the client fails with it, rather than going out without the
+// configured CA or client certificate.
+func NewDataSourceHTTPClient(config models.DataSourceConfig, timeout time.Duration) *http.Client {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	tlsConfig, err := buildDataSourceTLSConfig(config)
+	if err != nil {
+		log.Printf("Error: Failed to load TLS settings for data source %s: %v. Requests to it will fail until they're fixed.", config.URL, err)
+		client.Transport = tlsErrorTransport{err: err}
+		return client
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+
+	return client
+}
+
+// tlsErrorTransport fails every request with the error that kept the data
+// source's TLS settings from loading
+type tlsErrorTransport struct {
+	err error
+}
+
+func (t tlsErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, fmt.Errorf("data source TLS settings failed to load: %w", t.err)
+}
+
+// buildDataSourceTLSConfig returns the tls.Config for a data source, or nil if
+// no TLS settings are configured
+func buildDataSourceTLSConfig(config models.DataSourceConfig) (*tls.Config, error) {
+	settings := config.TLS
+	if settings.CAFile == "" && settings.CertFile == "" && settings.KeyFile == "" && !settings.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+
+	if settings.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in CA file %s", settings.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		if settings.CertFile == "" || settings.KeyFile == "" {
+			return nil, fmt.Errorf("both cert_file and key_file are required for client certificates")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
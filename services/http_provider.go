@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// BuildHTTPProviderConfig builds the dynamic configuration as the JSON body
+// served to Traefik's HTTP provider. Objects loaded that way belong to the
+// http provider, so references to middlewares and services defined in the
+// config are rewritten from @file to @http. References to anything else, such
+// as upstream services of a file data source, are left alone.
+func (cg *ConfigGenerator) BuildHTTPProviderConfig() ([]byte, error) {
+	cg.generateMutex.Lock()
+	dynamic, err := cg.buildDynamicConfig(middlewaresForTraefik)
+	cg.generateMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip through JSON so the config can be walked generically
+	data, err := json.Marshal(dynamic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var config map[string]interface{}
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	defined := make(map[string]bool)
+	for _, protocol := range []string{"http", "tcp", "udp"} {
+		section, _ := config[protocol].(map[string]interface{})
+		for _, kind := range []string{"middlewares", "services"} {
+			objects, _ := section[kind].(map[string]interface{})
+			for name := range objects {
+				defined[name] = true
+			}
+		}
+	}
+
+	return json.Marshal(rewriteProviderReferences(config, defined))
+}
+
+// rewriteProviderReferences replaces name@file with name@http in every string
+// of value whose name is in defined, recursively
+func rewriteProviderReferences(value interface{}, defined map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = rewriteProviderReferences(item, defined)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rewriteProviderReferences(item, defined)
+		}
+	case string:
+		if name := strings.TrimSuffix(v, "@file"); name != v && defined[name] {
+			return name + "@http"
+		}
+	}
+	return value
+}
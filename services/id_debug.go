@@ -0,0 +1,32 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/hhftechnology/middleware-manager/util"
+)
+
+// IDNormalization shows how an upstream ID is normalized and the names derived from it
+type IDNormalization struct {
+	ID             string `json:"id"`
+	NormalizedID   string `json:"normalized_id"`   // util.NormalizeID, used to match resources across fetches
+	BaseName       string `json:"base_name"`       // ID without the provider suffix
+	ServiceName    string `json:"service_name"`    // Base name used in service references
+	ProviderSuffix string `json:"provider_suffix"` // e.g. "@docker", empty if none
+	HTTPRouterID   string `json:"http_router_id"`  // Router name generated for the resource
+	TCPRouterID    string `json:"tcp_router_id"`   // TCP router name generated for the resource
+}
+
+// NormalizeIDForDebug reports what the ID helpers derive from id
+func NormalizeIDForDebug(id string) IDNormalization {
+	baseName := extractBaseName(id)
+	return IDNormalization{
+		ID:             id,
+		NormalizedID:   util.NormalizeID(id),
+		BaseName:       baseName,
+		ServiceName:    normalizeServiceID(id),
+		ProviderSuffix: util.GetProviderSuffix(id),
+		HTTPRouterID:   fmt.Sprintf("%s-auth", baseName),
+		TCPRouterID:    fmt.Sprintf("%s-tcp", baseName),
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Timeout for the Traefik API calls that confirm a written config was loaded
+const lastGoodConfirmTimeout = 5 * time.Second
+
+// ErrNoLastGoodConfig is returned when no config has been confirmed yet
+var ErrNoLastGoodConfig = errors.New("no last-known-good configuration recorded")
+
+// pendingConfig is a written config that Traefik has not yet been seen to load
+type pendingConfig struct {
+	data      []byte   // The config with secret references unresolved
+	hash      string   // Hash of the config as written, with them resolved
+	routers   []string // HTTP routers Traefik should report once the config is loaded
+	writtenAt time.Time
+	warned    bool // Whether a failed confirmation has been logged for this config
+}
+
+// LastGoodConfig describes the stored last-known-good configuration
+type LastGoodConfig struct {
+	Hash        string    `json:"hash"`
+	ConfirmedAt time.Time `json:"confirmed_at"`
+	Size        int       `json:"size"`
+	Config      string    `json:"config,omitempty"`
+}
+
+// configHash returns the hex SHA-256 of a generated config
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordPendingConfig remembers a config that was just written so it can be
+// confirmed once Traefik reports having loaded it. data is the config with
+// secret references unresolved, so secrets never reach the database; hash is
+// that of the config as written. Callers must hold generateMutex.
+func (cg *ConfigGenerator) recordPendingConfig(data []byte, hash string, config *TraefikConfig) {
+	routers := make([]string, 0, len(config.HTTP.Routers))
+	for id := range config.HTTP.Routers {
+		routers = append(routers, id+"@file")
+	}
+	sort.Strings(routers)
+
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	cg.pendingConfig = &pendingConfig{
+		data:      copied,
+		hash:      hash,
+		routers:   routers,
+		writtenAt: time.Now(),
+	}
+}
+
+// confirmPendingConfig asks Traefik whether the last written config has been
+// loaded and, if so, stores it as the last-known-good config. Without a
+// Traefik data source, configs can only be confirmed with ConfirmCurrentConfig.
+func (cg *ConfigGenerator) confirmPendingConfig(ctx context.Context) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	pending := cg.pendingConfig
+	if pending == nil {
+		return
+	}
+	dsConfig, found := cg.traefikDataSource()
+	if !found {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lastGoodConfirmTimeout)
+	defer cancel()
+
+	loaded, err := traefikLoadedRouters(ctx, dsConfig.URL, NewDataSourceHTTPClient(dsConfig, lastGoodConfirmTimeout), pending.routers)
+	if err != nil {
+		if !pending.warned {
+			log.Printf("Warning: could not confirm generated config with Traefik: %v", err)
+			pending.warned = true
+		}
+		return
+	}
+	if !loaded {
+		return
+	}
+
+	if err := cg.saveLastGoodConfig(pending.data, pending.hash); err != nil {
+		log.Printf("Warning: failed to record last-known-good config: %v", err)
+		return
+	}
+	cg.pendingConfig = nil
+	log.Printf("Traefik loaded generated config %s, recorded as last-known-good", shortHash(pending.hash))
+}
+
+// traefikLoadedRouters reports whether Traefik has loaded every expected
+// router without errors. With no routers to look for, a reachable
+// /api/overview listing the file provider is taken as confirmation.
+func traefikLoadedRouters(ctx context.Context, baseURL string, client *http.Client, routers []string) (bool, error) {
+	apiURL := strings.TrimSuffix(baseURL, "/")
+
+	if len(routers) == 0 {
+		var overview struct {
+			Providers []string `json:"providers"`
+		}
+		if err := getTraefikJSON(ctx, client, apiURL+"/api/overview", &overview); err != nil {
+			return false, err
+		}
+		for _, provider := range overview.Providers {
+			if strings.EqualFold(provider, "file") {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	var rawData struct {
+		Routers map[string]struct {
+			Status string   `json:"status"`
+			Err    []string `json:"error"`
+		} `json:"routers"`
+	}
+	if err := getTraefikJSON(ctx, client, apiURL+"/api/rawdata", &rawData); err != nil {
+		return false, err
+	}
+	for _, name := range routers {
+		router, ok := rawData.Routers[name]
+		if !ok || len(router.Err) > 0 || (router.Status != "" && router.Status != "enabled") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getTraefikJSON fetches a Traefik API endpoint and decodes its JSON response
+func getTraefikJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// saveLastGoodConfig replaces the stored last-known-good config. data must
+// have its secret references unresolved.
+func (cg *ConfigGenerator) saveLastGoodConfig(data []byte, hash string) error {
+	_, err := cg.db.Exec(
+		"INSERT OR REPLACE INTO last_good_config (id, config, hash, confirmed_at) VALUES (1, ?, ?, ?)",
+		string(data), hash, time.Now(),
+	)
+	return err
+}
+
+// ConfirmCurrentConfig records the config currently written by the generator
+// as last-known-good, for setups where Traefik's API isn't reachable and the
+// confirmation comes from an external check instead
+func (cg *ConfigGenerator) ConfirmCurrentConfig() (*LastGoodConfig, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	if cg.lastConfig == nil || cg.lastUnresolvedConfig == nil {
+		return nil, fmt.Errorf("no configuration has been generated yet")
+	}
+	hash := configHash(cg.lastConfig)
+	if err := cg.saveLastGoodConfig(cg.lastUnresolvedConfig, hash); err != nil {
+		return nil, fmt.Errorf("failed to record last-known-good config: %w", err)
+	}
+	if cg.pendingConfig != nil && cg.pendingConfig.hash == hash {
+		cg.pendingConfig = nil
+	}
+	log.Printf("Config %s confirmed externally, recorded as last-known-good", shortHash(hash))
+	return &LastGoodConfig{Hash: hash, ConfirmedAt: time.Now(), Size: len(cg.lastUnresolvedConfig)}, nil
+}
+
+// LastGoodConfig returns the stored last-known-good config, or
+// ErrNoLastGoodConfig if none has been confirmed
+func (cg *ConfigGenerator) LastGoodConfig(includeConfig bool) (*LastGoodConfig, error) {
+	var config, hash string
+	var confirmedAt time.Time
+	err := cg.db.QueryRow(
+		"SELECT config, hash, confirmed_at FROM last_good_config WHERE id = 1",
+	).Scan(&config, &hash, &confirmedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoLastGoodConfig
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last-known-good config: %w", err)
+	}
+
+	lastGood := &LastGoodConfig{Hash: hash, ConfirmedAt: confirmedAt, Size: len(config)}
+	if includeConfig {
+		lastGood.Config = config
+	}
+	return lastGood, nil
+}
+
+// RestoreLastGood re-writes the last-known-good config, resolving its secret
+// references from the current environment. The config that was live before
+// the restore is held back: generation won't write it again until the
+// database changes and a different config is generated.
+func (cg *ConfigGenerator) RestoreLastGood(ctx context.Context) (*LastGoodConfig, error) {
+	cg.generateMutex.Lock()
+	defer cg.generateMutex.Unlock()
+
+	lastGood, err := cg.LastGoodConfig(true)
+	if err != nil {
+		return nil, err
+	}
+	unresolved := []byte(lastGood.Config)
+	data, err := cg.resolveSecretRefsInYAML(unresolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last-known-good config: %w", err)
+	}
+	if err := cg.writeConfigToFile(ctx, data); err != nil {
+		return nil, fmt.Errorf("failed to write last-known-good config: %w", err)
+	}
+	MetricConfigWrites.Inc()
+
+	if cg.lastConfig != nil {
+		if current := configHash(cg.lastConfig); current != lastGood.Hash {
+			cg.heldHash = current
+		}
+	}
+	cg.lastConfig = data
+	cg.lastUnresolvedConfig = unresolved
+	cg.pendingConfig = nil
+
+	log.Printf("Restored last-known-good config %s confirmed at %s", shortHash(lastGood.Hash), lastGood.ConfirmedAt.Format(time.RFC3339))
+	lastGood.Config = ""
+	return lastGood, nil
+}
+
+// resolveSecretRefsInYAML returns a stored config with its secret references
+// replaced by their environment variables, keeping the rest of the document
+// as it is. Resolved values are always written as quoted strings.
+func (cg *ConfigGenerator) resolveSecretRefsInYAML(data []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Kind == 0 {
+		return data, nil
+	}
+
+	var resolve func(node *yaml.Node)
+	resolve = func(node *yaml.Node) {
+		if node.Kind == yaml.ScalarNode && models.IsSecretRef(node.Value) {
+			node.Value = models.ResolveSecretRefs(node.Value).(string)
+			node.Tag = "!!str"
+			node.Style = yaml.DoubleQuotedStyle
+			return
+		}
+		for _, child := range node.Content {
+			resolve(child)
+		}
+	}
+	resolve(&document)
+	return cg.marshalYAML(&document)
+}
+
+// shortHash abbreviates a config hash for log messages
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// MetricKind distinguishes cumulative counters from point-in-time gauges
+type MetricKind int
+
+const (
+	MetricCounter MetricKind = iota
+	MetricGauge
+)
+
+// Metric is a single named value recorded by the generator or the watchers
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Kind        MetricKind
+	bits        uint64
+}
+
+// Add increases the metric by delta
+func (m *Metric) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&m.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&m.bits, old, updated) {
+			return
+		}
+	}
+}
+
+// Inc increases the metric by one
+func (m *Metric) Inc() {
+	m.Add(1)
+}
+
+// Set replaces the metric's value; used for gauges
+func (m *Metric) Set(value float64) {
+	atomic.StoreUint64(&m.bits, math.Float64bits(value))
+}
+
+// Value returns the current value of the metric
+func (m *Metric) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&m.bits))
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   []*Metric
+)
+
+// newMetric registers a metric so exporters can find it
+func newMetric(name, description, unit string, kind MetricKind) *Metric {
+	m := &Metric{Name: name, Description: description, Unit: unit, Kind: kind}
+	metricsMu.Lock()
+	metrics = append(metrics, m)
+	metricsMu.Unlock()
+	return m
+}
+
+// AllMetrics returns every registered metric
+func AllMetrics() []*Metric {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	result := make([]*Metric, len(metrics))
+	copy(result, metrics)
+	return result
+}
+
+// Generator and watcher metrics
+var (
+	MetricConfigGenerations         = newMetric("middleware_manager.config.generations", "Traefik configuration generation runs", "{run}", MetricCounter)
+	MetricConfigGenerationErrors    = newMetric("middleware_manager.config.generation_errors", "Failed Traefik configuration generation runs", "{run}", MetricCounter)
+	MetricConfigWrites              = newMetric("middleware_manager.config.writes", "Times the generated configuration changed and was written", "{write}", MetricCounter)
+	MetricConfigWriteErrors         = newMetric("middleware_manager.config.write_errors", "Failed attempts to write the generated configuration file", "{attempt}", MetricCounter)
+	MetricConfigGenerationDuration  = newMetric("middleware_manager.config.generation_duration", "Duration of the last configuration generation run", "ms", MetricGauge)
+	MetricResourceChecks            = newMetric("middleware_manager.resources.checks", "Resource watcher checks against the data source", "{check}", MetricCounter)
+	MetricResourceCheckErrors       = newMetric("middleware_manager.resources.check_errors", "Failed resource watcher checks", "{check}", MetricCounter)
+	MetricResourceStatusTransitions = newMetric("middleware_manager.resources.status_transitions", "Resource status changes made by the resource watcher", "{transition}", MetricCounter)
+	MetricResourcesFlapping         = newMetric("middleware_manager.resources.flapping", "Resources whose status changed at least the flap threshold number of times within the flap window", "{resource}", MetricGauge)
+	MetricUnreachableBackends       = newMetric("middleware_manager.backends.unreachable", "Custom service backends found unreachable in the last generation run", "{backend}", MetricGauge)
+	MetricDuplicateHosts            = newMetric("middleware_manager.resources.duplicate_hosts", "Hosts used by more than one active resource in the last generation run", "{host}", MetricGauge)
+	MetricServiceChecks             = newMetric("middleware_manager.services.checks", "Service watcher checks against the data source", "{check}", MetricCounter)
+	MetricServiceCheckErrors        = newMetric("middleware_manager.services.check_errors", "Failed service watcher checks", "{check}", MetricCounter)
+)
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhftechnology/middleware-manager/models"
+	"gopkg.in/yaml.v3"
+)
+
+// checkProcessedMiddlewareConfigs runs every stored middleware config through
+// the same processing and YAML encoding as generation and flags values Traefik
+// may reject: numbers written in scientific notation, floats in integer fields
+// and numbers or booleans that end up written as strings
+func (cg *ConfigGenerator) checkProcessedMiddlewareConfigs(report *ConsistencyReport) error {
+	rows, err := cg.db.Query("SELECT id, type, config FROM middlewares WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to fetch middlewares: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, typ, configStr string
+		if err := rows.Scan(&id, &typ, &configStr); err != nil {
+			return fmt.Errorf("failed to scan middleware: %w", err)
+		}
+		// Invalid JSON is already reported by checkMiddlewareConfigs
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+			continue
+		}
+
+		problems, err := processedConfigProblems(typ, config)
+		if err != nil {
+			report.add("middleware_processing", SeverityError, fmt.Sprintf("middleware %s could not be encoded: %v", id, err))
+			continue
+		}
+		for _, problem := range problems {
+			report.add("middleware_processing", SeverityWarning, fmt.Sprintf("middleware %s: %s", id, problem))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read middlewares: %w", err)
+	}
+	return nil
+}
+
+// processedConfigProblems processes a middleware config, encodes it the way
+// generateConfig does and describes each suspicious scalar in the result
+func processedConfigProblems(middlewareType string, config map[string]interface{}) ([]string, error) {
+	processed := preserveTraefikValues(models.ProcessMiddlewareConfig(middlewareType, config))
+
+	node := &yaml.Node{}
+	if err := node.Encode(processed); err != nil {
+		return nil, err
+	}
+	preserveStringsInYamlNode(node)
+
+	var problems []string
+	schema := models.GetMiddlewareSchema(middlewareType)
+	walkProcessedNode(node, "", schema, &problems)
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// walkProcessedNode visits the scalars of an encoded config, tracking their
+// dotted path so they can be compared with the middleware's schema
+func walkProcessedNode(node *yaml.Node, path string, schema map[string]models.FieldType, problems *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			walkProcessedNode(child, path, schema, problems)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := node.Content[i].Value
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			walkProcessedNode(node.Content[i+1], childPath, schema, problems)
+		}
+	case yaml.ScalarNode:
+		if problem := processedScalarProblem(node, schema[path]); problem != "" {
+			*problems = append(*problems, fmt.Sprintf("%s: %s", path, problem))
+		}
+	}
+}
+
+// processedScalarProblem describes what is wrong with an encoded scalar, or
+// returns an empty string if it looks fine for its field type
+func processedScalarProblem(node *yaml.Node, fieldType models.FieldType) string {
+	switch node.Tag {
+	case "!!float":
+		if strings.ContainsAny(node.Value, "eE") {
+			return fmt.Sprintf("number is written in scientific notation (%s)", node.Value)
+		}
+		if fieldType == models.FieldInteger {
+			return fmt.Sprintf("integer field is written as a float (%s)", node.Value)
+		}
+	case "!!str":
+		if models.IsSecretRef(node.Value) {
+			return ""
+		}
+		// Strings that were numbers before preserveStringsInYamlNode aren't quoted
+		converted := node.Style != yaml.DoubleQuotedStyle && node.Style != yaml.SingleQuotedStyle && isNumericString(node.Value)
+		if converted && strings.ContainsAny(node.Value, "eE") {
+			return fmt.Sprintf("number is written as a string in scientific notation (%q)", node.Value)
+		}
+		switch fieldType {
+		case models.FieldInteger, models.FieldNumber:
+			if _, err := strconv.ParseFloat(node.Value, 64); err == nil {
+				return fmt.Sprintf("number is written as a string (%q)", node.Value)
+			}
+		case models.FieldBoolean:
+			if node.Value == "true" || node.Value == "false" {
+				return fmt.Sprintf("boolean is written as a string (%q)", node.Value)
+			}
+		}
+	}
+	return ""
+}
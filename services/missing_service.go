@@ -0,0 +1,64 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// MissingServicePolicy controls what the generator does with a resource whose
+// assigned custom service isn't in the generated HTTP services
+type MissingServicePolicy string
+
+const (
+	// MissingServiceFallback routes the resource to its discovered upstream service
+	MissingServiceFallback MissingServicePolicy = "fallback"
+	// MissingServiceSkip leaves the resource's router out
+	MissingServiceSkip MissingServicePolicy = "skip"
+	// MissingServiceKeep still references the missing service, as older versions did
+	MissingServiceKeep MissingServicePolicy = "keep"
+)
+
+// ParseMissingServicePolicy parses a MISSING_SERVICE_POLICY value; empty means fallback
+func ParseMissingServicePolicy(value string) (MissingServicePolicy, error) {
+	switch MissingServicePolicy(value) {
+	case "", MissingServiceFallback:
+		return MissingServiceFallback, nil
+	case MissingServiceSkip:
+		return MissingServiceSkip, nil
+	case MissingServiceKeep:
+		return MissingServiceKeep, nil
+	}
+	return "", fmt.Errorf("unknown missing service policy %q, expected fallback, skip or keep", value)
+}
+
+// applyMissingServicePolicy checks that a resource's custom service was
+// generated as an HTTP service and, if not, applies the policy. It returns
+// the router data to use and whether the router should be generated at all.
+// Each missing assignment is logged once, by the generation run that first
+// finds it. Callers must hold generateMutex and have processed services.
+func (cg *ConfigGenerator) applyMissingServicePolicy(data resourceRouterData, config *TraefikConfig, logged map[string]bool) (resourceRouterData, bool) {
+	if cg.missingServicePolicy == MissingServiceKeep || !data.CustomServiceID.Valid || data.CustomServiceID.String == "" {
+		return data, true
+	}
+	serviceID := normalizeServiceID(data.CustomServiceID.String)
+	if _, exists := config.HTTP.Services[serviceID]; exists {
+		return data, true
+	}
+
+	key := data.Info.ID + "\x00" + serviceID
+	logged[key] = true
+	report := cg.generating && !cg.missingServiceLogged[key]
+
+	if cg.missingServicePolicy == MissingServiceSkip {
+		if report {
+			log.Printf("Warning: custom service %s of resource %s is not defined as an HTTP service, skipping its router", serviceID, data.Info.ID)
+		}
+		return data, false
+	}
+	if report {
+		log.Printf("Warning: custom service %s of resource %s is not defined as an HTTP service, routing to %s instead", serviceID, data.Info.ID, data.Info.ServiceID)
+	}
+	data.CustomServiceID = sql.NullString{}
+	return data, true
+}
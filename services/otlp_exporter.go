@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP aggregation temporality for cumulative sums
+const otlpCumulative = 2
+
+// OTLPMetricsExporter periodically pushes the registered metrics to an
+// OpenTelemetry collector using OTLP over HTTP with JSON encoding
+type OTLPMetricsExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	startTime   time.Time
+	stopChan    chan struct{}
+	isRunning   bool
+	mutex       sync.Mutex
+}
+
+// NewOTLPMetricsExporter creates an exporter that posts to endpoint, which must
+// be the full metrics URL (e.g. http://collector:4318/v1/metrics)
+func NewOTLPMetricsExporter(endpoint string, headers map[string]string, serviceName string) *OTLPMetricsExporter {
+	return &OTLPMetricsExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		startTime:   time.Now(),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// ParseOTLPHeaders parses the OTEL_EXPORTER_OTLP_HEADERS format (key1=value1,key2=value2)
+func ParseOTLPHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers
+}
+
+// Start begins exporting metrics at the given interval
+func (e *OTLPMetricsExporter) Start(interval time.Duration) {
+	e.mutex.Lock()
+	if e.isRunning {
+		e.mutex.Unlock()
+		return
+	}
+	e.isRunning = true
+	e.mutex.Unlock()
+
+	log.Printf("OTLP metrics exporter started, sending to %s every %v", e.endpoint, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Export(); err != nil {
+				log.Printf("OTLP metrics export failed: %v", err)
+			}
+		case <-e.stopChan:
+			// Flush the final values before stopping
+			if err := e.Export(); err != nil {
+				log.Printf("Final OTLP metrics export failed: %v", err)
+			}
+			log.Println("OTLP metrics exporter stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the exporter
+func (e *OTLPMetricsExporter) Stop() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.isRunning {
+		return
+	}
+	close(e.stopChan)
+	e.isRunning = false
+}
+
+// Export sends the current value of every registered metric
+func (e *OTLPMetricsExporter) Export() error {
+	payload, err := json.Marshal(e.buildPayload(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+
+	return postOTLP(e.client, e.endpoint, e.headers, payload)
+}
+
+// postOTLP sends a JSON-encoded OTLP export request to the collector
+func postOTLP(client *http.Client, endpoint string, headers map[string]string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// otlpResource describes this process in OTLP export requests
+func otlpResource(serviceName string) map[string]interface{} {
+	return map[string]interface{}{
+		"attributes": []map[string]interface{}{{
+			"key":   "service.name",
+			"value": map[string]interface{}{"stringValue": serviceName},
+		}},
+	}
+}
+
+// otlpScope identifies the instrumentation in OTLP export requests
+var otlpScope = map[string]interface{}{"name": "github.com/hhftechnology/middleware-manager"}
+
+// buildPayload builds an OTLP ExportMetricsServiceRequest in its JSON form
+func (e *OTLPMetricsExporter) buildPayload(now time.Time) map[string]interface{} {
+	startNanos := strconv.FormatInt(e.startTime.UnixNano(), 10)
+	nowNanos := strconv.FormatInt(now.UnixNano(), 10)
+
+	otlpMetrics := make([]map[string]interface{}, 0)
+	for _, m := range AllMetrics() {
+		dataPoints := []map[string]interface{}{{
+			"startTimeUnixNano": startNanos,
+			"timeUnixNano":      nowNanos,
+			"asDouble":          m.Value(),
+		}}
+
+		metric := map[string]interface{}{
+			"name":        m.Name,
+			"description": m.Description,
+			"unit":        m.Unit,
+		}
+		if m.Kind == MetricCounter {
+			metric["sum"] = map[string]interface{}{
+				"aggregationTemporality": otlpCumulative,
+				"isMonotonic":            true,
+				"dataPoints":             dataPoints,
+			}
+		} else {
+			metric["gauge"] = map[string]interface{}{
+				"dataPoints": dataPoints,
+			}
+		}
+		otlpMetrics = append(otlpMetrics, metric)
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource": otlpResource(e.serviceName),
+			"scopeMetrics": []map[string]interface{}{{
+				"scope":   otlpScope,
+				"metrics": otlpMetrics,
+			}},
+		}},
+	}
+}
@@ -2,9 +2,6 @@ package services
 
 import (
     "context"
-    "encoding/json"
-    "fmt"
-    "io/ioutil"
     "log"
     "net/http"
     "strings"
@@ -22,48 +19,17 @@ type PangolinFetcher struct {
 // NewPangolinFetcher creates a new Pangolin API fetcher
 func NewPangolinFetcher(config models.DataSourceConfig) *PangolinFetcher {
     return &PangolinFetcher{
-        config: config,
-        httpClient: &http.Client{
-            Timeout: 10 * time.Second,
-        },
+        config:     config,
+        httpClient: NewDataSourceHTTPClient(config, 10*time.Second),
     }
 }
 
 // FetchResources fetches resources from Pangolin API
 func (f *PangolinFetcher) FetchResources(ctx context.Context) (*models.ResourceCollection, error) {
-    // Create HTTP request
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.config.URL+"/traefik-config", nil)
+    // Fetch the Pangolin config, following pagination if present
+    config, err := fetchPangolinTraefikConfig(ctx, f.httpClient, f.config)
     if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
-    }
-    
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
-    
-    // Execute request
-    resp, err := f.httpClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("HTTP request failed: %w", err)
-    }
-    defer resp.Body.Close()
-    
-    // Check status code
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-    }
-    
-    // Process response
-    body, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read response: %w", err)
-    }
-    
-    // Parse the Pangolin config
-    var config models.PangolinTraefikConfig
-    if err := json.Unmarshal(body, &config); err != nil {
-        return nil, fmt.Errorf("failed to parse JSON: %w", err)
+        return nil, err
     }
     
     // Convert Pangolin config to our internal model
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// Default cap on the pages followed for one Pangolin traefik-config fetch
+const defaultPangolinMaxPages = 50
+
+// Largest single traefik-config page that is read
+const pangolinPageMaxSize = 10 * 1024 * 1024 // 10MB
+
+var pangolinMaxPages int32 = defaultPangolinMaxPages
+
+// SetPangolinMaxPages caps how many pages a paginated traefik-config fetch follows
+func SetPangolinMaxPages(pages int) {
+	if pages < 1 {
+		pages = 1
+	}
+	atomic.StoreInt32(&pangolinMaxPages, int32(pages))
+}
+
+// linkNextPattern matches the next relation of an RFC 8288 Link header
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>\s*;[^,]*\brel="?next"?`)
+
+// pangolinConfigPage is one traefik-config response, with the optional link to the next page
+type pangolinConfigPage struct {
+	models.PangolinTraefikConfig
+	Next string `json:"next"`
+}
+
+// fetchPangolinTraefikConfig fetches Pangolin's traefik-config, following
+// pagination when the response links to a next page (a Link header with
+// rel="next" or a top-level "next" field) and merging the pages. Only links
+// on the configured scheme and host are followed. Fetches that would need
+// more than the page cap fail rather than return a truncated set, which
+// would make the watchers disable resources that weren't read.
+func fetchPangolinTraefikConfig(ctx context.Context, client *http.Client, config models.DataSourceConfig) (*models.PangolinTraefikConfig, error) {
+	merged := &models.PangolinTraefikConfig{}
+	merged.HTTP.Routers = make(map[string]models.PangolinRouter)
+	merged.HTTP.Services = make(map[string]models.PangolinService)
+
+	maxPages := int(atomic.LoadInt32(&pangolinMaxPages))
+	visited := make(map[string]bool)
+	pageURL := config.URL + "/traefik-config"
+	for page := 1; pageURL != ""; page++ {
+		if page > maxPages {
+			return nil, fmt.Errorf("traefik-config has more than %d pages, not using a partial resource list", maxPages)
+		}
+		if visited[pageURL] {
+			return nil, fmt.Errorf("traefik-config pagination loops back to %s", pageURL)
+		}
+		visited[pageURL] = true
+
+		next, err := fetchPangolinConfigPage(ctx, client, config, pageURL, merged)
+		if err != nil {
+			if page > 1 {
+				return nil, fmt.Errorf("page %d: %w", page, err)
+			}
+			return nil, err
+		}
+		pageURL = next
+	}
+	return merged, nil
+}
+
+// fetchPangolinConfigPage fetches one page into merged and returns the
+// absolute URL of the next page, if any
+func fetchPangolinConfigPage(ctx context.Context, client *http.Client, config models.DataSourceConfig, pageURL string, merged *models.PangolinTraefikConfig) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add basic auth and custom headers if configured
+	config.ApplyToRequest(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, pangolinPageMaxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > pangolinPageMaxSize {
+		return "", fmt.Errorf("response is larger than %d bytes", pangolinPageMaxSize)
+	}
+
+	var page pangolinConfigPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	for id, router := range page.HTTP.Routers {
+		merged.HTTP.Routers[id] = router
+	}
+	for id, service := range page.HTTP.Services {
+		merged.HTTP.Services[id] = service
+	}
+
+	next := page.Next
+	if match := linkNextPattern.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+		next = match[1]
+	}
+	if next == "" {
+		return "", nil
+	}
+
+	// Next links may be relative to the page they came from
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link %q: %w", next, err)
+	}
+	resolved := base.ResolveReference(ref)
+
+	// The request carries the data source's credentials, so a link to another
+	// origin isn't followed
+	if resolved.Scheme != base.Scheme || !strings.EqualFold(resolved.Host, base.Host) {
+		return "", fmt.Errorf("next page link %q leaves %s://%s, not following it", next, base.Scheme, base.Host)
+	}
+	return resolved.String(), nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// pauseWindow is a recurring daily time window on a set of weekdays.
+// Times are minutes since midnight; a window whose end is not after its
+// start runs past midnight into the next day.
+type pauseWindow struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+// PauseSchedule is a set of recurring windows during which config generation is paused
+type PauseSchedule struct {
+	windows []pauseWindow
+	spec    string
+}
+
+// ParsePauseSchedule parses windows separated by ";", each of the form
+// "[DAYS ]HH:MM-HH:MM" where DAYS is a comma-separated list of days or day
+// ranges (e.g. "Mon-Fri", "Sat,Sun"). Without DAYS the window applies daily.
+// Example: "Mon-Fri 22:00-23:30; Sun 01:00-03:00". Times are in local time.
+func ParsePauseSchedule(spec string) (*PauseSchedule, error) {
+	schedule := &PauseSchedule{spec: spec}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		window, err := parsePauseWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pause window %q: %w", part, err)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	if len(schedule.windows) == 0 {
+		return nil, fmt.Errorf("no pause windows defined")
+	}
+	return schedule, nil
+}
+
+func parsePauseWindow(spec string) (pauseWindow, error) {
+	var window pauseWindow
+
+	fields := strings.Fields(spec)
+	var daysSpec, timeSpec string
+	switch len(fields) {
+	case 1:
+		daysSpec, timeSpec = "*", fields[0]
+	case 2:
+		daysSpec, timeSpec = fields[0], fields[1]
+	default:
+		return window, fmt.Errorf("expected \"[DAYS ]HH:MM-HH:MM\"")
+	}
+
+	if daysSpec == "*" {
+		for i := range window.days {
+			window.days[i] = true
+		}
+	} else {
+		for _, item := range strings.Split(daysSpec, ",") {
+			bounds := strings.SplitN(item, "-", 2)
+			first, ok := weekdayNames[strings.ToLower(bounds[0])]
+			if !ok {
+				return window, fmt.Errorf("unknown day %q", bounds[0])
+			}
+			last := first
+			if len(bounds) == 2 {
+				if last, ok = weekdayNames[strings.ToLower(bounds[1])]; !ok {
+					return window, fmt.Errorf("unknown day %q", bounds[1])
+				}
+			}
+			// Ranges may wrap around the week, e.g. Fri-Mon
+			for day := first; ; day = (day + 1) % 7 {
+				window.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+	}
+
+	times := strings.SplitN(timeSpec, "-", 2)
+	if len(times) != 2 {
+		return window, fmt.Errorf("expected a time range HH:MM-HH:MM")
+	}
+	var err error
+	if window.start, err = parseClockMinutes(times[0]); err != nil {
+		return window, err
+	}
+	if window.end, err = parseClockMinutes(times[1]); err != nil {
+		return window, err
+	}
+	if window.start == window.end {
+		return window, fmt.Errorf("window start and end are the same")
+	}
+	return window, nil
+}
+
+// parseClockMinutes parses HH:MM into minutes since midnight
+func parseClockMinutes(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Active reports whether t falls inside any pause window
+func (s *PauseSchedule) Active(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// Window runs past midnight: the evening part belongs to today,
+		// the early-morning part to a window that started yesterday
+		if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the schedule as it was configured
+func (s *PauseSchedule) String() string {
+	return s.spec
+}
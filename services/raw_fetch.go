@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// RawResourceFetcher is implemented by fetchers that can return what the
+// upstream API sent, before it is parsed into resources
+type RawResourceFetcher interface {
+	// FetchRaw returns the decoded response of each upstream endpoint, keyed by path
+	FetchRaw(ctx context.Context) (map[string]interface{}, error)
+}
+
+// Largest upstream response FetchRaw will read
+const rawFetchMaxSize = 10 * 1024 * 1024 // 10MB
+
+// FetchRaw returns Pangolin's traefik-config response unprocessed
+func (f *PangolinFetcher) FetchRaw(ctx context.Context) (map[string]interface{}, error) {
+	payload, err := fetchRawJSON(ctx, f.httpClient, f.config, f.config.URL+"/traefik-config")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"/traefik-config": payload}, nil
+}
+
+// FetchRaw returns Traefik's HTTP and TCP router lists unprocessed. Unlike
+// FetchResources it only tries the configured URL, not the fallbacks.
+func (f *TraefikFetcher) FetchRaw(ctx context.Context) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, path := range []string{"/api/http/routers", "/api/tcp/routers"} {
+		payload, err := fetchRawJSON(ctx, f.httpClient, f.config, f.config.URL+path)
+		if err != nil {
+			return nil, err
+		}
+		result[path] = payload
+	}
+	return result, nil
+}
+
+// fetchRawJSON requests rawURL with the data source's auth and headers and decodes the JSON body
+func fetchRawJSON(ctx context.Context, client *http.Client, config models.DataSourceConfig, rawURL string) (interface{}, error) {
+	// Errors name the URL without any credentials embedded in it
+	url := RedactURL(rawURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	config.ApplyToRequest(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, rawFetchMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	if len(body) > rawFetchMaxSize {
+		return nil, fmt.Errorf("response from %s is larger than %d bytes", url, rawFetchMaxSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from %s: %d", url, resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("response from %s is not valid JSON: %w", url, err)
+	}
+	return payload, nil
+}
+
+// Substrings of keys whose values are masked by RedactSecrets
+var secretKeyFragments = []string{
+	"password", "secret", "token", "apikey", "api_key", "authorization",
+	"credential", "privatekey", "private_key", "users",
+}
+
+// Keys masked only on an exact (case-insensitive) match, since they also
+// appear inside harmless names such as cookie names
+var secretKeys = map[string]bool{"cookie": true, "key": true}
+
+// RedactURL returns rawURL without any user:password@ part, which may hold a
+// password or token. Values that don't parse as a URL with user info are
+// returned as is.
+func RedactURL(rawURL string) string {
+	if !strings.Contains(rawURL, "@") {
+		return rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// RedactSecrets returns a copy of a decoded JSON value with the values of
+// credential-like keys (passwords, tokens, basicAuth users, ...) masked and
+// credentials removed from URLs
+func RedactSecrets(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSecretKey(key) {
+				redacted[key] = models.MaskedSecret
+				continue
+			}
+			redacted[key] = RedactSecrets(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = RedactSecrets(item)
+		}
+		return redacted
+	case string:
+		return RedactURL(v)
+	}
+	return value
+}
+
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	if secretKeys[lower] {
+		return true
+	}
+	for _, fragment := range secretKeyFragments {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
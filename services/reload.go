@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reloader runs an out-of-band resource check, service check and config
+// generation, so changes made in the data source show up without waiting
+// for the watchers' and the generator's next ticks
+type Reloader struct {
+	resourceWatcher *ResourceWatcher
+	serviceWatcher  *ServiceWatcher // nil if the service watcher couldn't be created
+	configGenerator *ConfigGenerator
+	mutex           sync.Mutex
+	status          ReloadStatus
+	onFinish        func() // Called after each reload, e.g. to drop cached API responses
+}
+
+// ReloadStatus describes the reload in progress or the last one that finished
+type ReloadStatus struct {
+	Running      bool               `json:"running"`
+	Pending      bool               `json:"pending"` // Requested while running; starts when the current one ends
+	LastStarted  *time.Time         `json:"last_started"`
+	LastFinished *time.Time         `json:"last_finished"`
+	DurationMs   int64              `json:"duration_ms"`
+	Error        string             `json:"error,omitempty"`
+	Generation   *GenerationSummary `json:"generation,omitempty"`
+}
+
+// NewReloader creates a reloader for the given watchers and generator.
+// serviceWatcher may be nil, in which case services aren't checked.
+func NewReloader(resourceWatcher *ResourceWatcher, serviceWatcher *ServiceWatcher, configGenerator *ConfigGenerator) *Reloader {
+	return &Reloader{
+		resourceWatcher: resourceWatcher,
+		serviceWatcher:  serviceWatcher,
+		configGenerator: configGenerator,
+	}
+}
+
+// OnFinish sets a function called each time a reload finishes, whether or
+// not it succeeded
+func (r *Reloader) OnFinish(fn func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onFinish = fn
+}
+
+// Trigger starts a reload in the background and returns at once. A reload
+// requested while one is running is queued, so changes made during the
+// current run are still picked up; further requests are merged into it.
+func (r *Reloader) Trigger() ReloadStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status.Running {
+		r.status.Pending = true
+		return r.status
+	}
+	r.begin()
+	go r.run()
+	return r.status
+}
+
+// Status returns the state of the current or last reload
+func (r *Reloader) Status() ReloadStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.status
+}
+
+// begin marks a reload as started. The mutex must be held.
+func (r *Reloader) begin() {
+	now := time.Now()
+	r.status.Running = true
+	r.status.Pending = false
+	r.status.LastStarted = &now
+}
+
+// run performs reloads until none is pending
+func (r *Reloader) run() {
+	for {
+		summary, err := r.reload(context.Background())
+
+		r.mutex.Lock()
+		if r.onFinish != nil {
+			r.onFinish()
+		}
+		finished := time.Now()
+		r.status.LastFinished = &finished
+		r.status.DurationMs = finished.Sub(*r.status.LastStarted).Milliseconds()
+		r.status.Generation = summary
+		r.status.Error = ""
+		if err != nil {
+			r.status.Error = err.Error()
+		}
+		if !r.status.Pending {
+			r.status.Running = false
+			r.mutex.Unlock()
+			return
+		}
+		r.begin()
+		r.mutex.Unlock()
+	}
+}
+
+// reload checks resources and services and regenerates the config. Every
+// step runs even if an earlier one fails; the failures are reported together.
+func (r *Reloader) reload(ctx context.Context) (*GenerationSummary, error) {
+	log.Println("Reloading resources, services and configuration")
+
+	var problems []string
+	if r.resourceWatcher != nil {
+		if err := r.resourceWatcher.CheckNow(ctx); err != nil {
+			problems = append(problems, fmt.Sprintf("resource check: %v", err))
+		}
+	}
+	if r.serviceWatcher != nil {
+		if err := r.serviceWatcher.CheckNow(); err != nil {
+			problems = append(problems, fmt.Sprintf("service check: %v", err))
+		}
+	}
+	summary, err := r.configGenerator.Generate(ctx)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("config generation: %v", err))
+	}
+
+	if len(problems) > 0 {
+		err := fmt.Errorf("%s", strings.Join(problems, "; "))
+		log.Printf("Reload finished with errors: %v", err)
+		return summary, err
+	}
+	log.Println("Reload finished")
+	return summary, nil
+}
@@ -20,6 +20,10 @@ func NewResourceFetcher(config models.DataSourceConfig) (ResourceFetcher, error)
         return NewPangolinFetcher(config), nil
     case models.TraefikAPI:
         return NewTraefikFetcher(config), nil
+    case models.DockerAPI:
+        return NewDockerFetcher(config), nil
+    case models.TraefikFile:
+        return NewFileFetcher(config), nil
     default:
         return nil, fmt.Errorf("unknown data source type: %s", config.Type)
     }
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/database"
+)
+
+// Flap detection defaults: a resource changing status this many times within
+// the window is reported as flapping
+const (
+	defaultFlapWindow    = time.Hour
+	defaultFlapThreshold = 4
+)
+
+var (
+	flapMu        sync.RWMutex
+	flapWindow    = defaultFlapWindow
+	flapThreshold = defaultFlapThreshold
+)
+
+// SetFlapDetection sets the window status transitions are counted over and the
+// number of transitions within it at which a resource is considered flapping
+func SetFlapDetection(window time.Duration, threshold int) {
+	flapMu.Lock()
+	defer flapMu.Unlock()
+	if window > 0 {
+		flapWindow = window
+	}
+	if threshold > 0 {
+		flapThreshold = threshold
+	}
+}
+
+// FlapWindow returns the window over which a resource's flap_count is counted
+func FlapWindow() time.Duration {
+	flapMu.RLock()
+	defer flapMu.RUnlock()
+	return flapWindow
+}
+
+func getFlapThreshold() int {
+	flapMu.RLock()
+	defer flapMu.RUnlock()
+	return flapThreshold
+}
+
+// dbExecer is satisfied by both *sql.DB and *sql.Tx
+type dbExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordStatusTransition stores a resource status change for flap detection
+func recordStatusTransition(db dbExecer, resourceID, fromStatus, toStatus string) error {
+	if fromStatus == toStatus {
+		return nil
+	}
+	_, err := db.Exec(
+		"INSERT INTO resource_status_transitions (resource_id, from_status, to_status, changed_at) VALUES (?, ?, ?, ?)",
+		resourceID, fromStatus, toStatus, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record status transition for %s: %w", resourceID, err)
+	}
+	MetricResourceStatusTransitions.Inc()
+	return nil
+}
+
+// reportFlappingResources prunes transitions that fell out of the window, then
+// logs a warning for every resource at or above the flap threshold and updates
+// the flapping resources gauge
+func reportFlappingResources(db *database.DB) error {
+	since := time.Now().Add(-FlapWindow())
+	threshold := getFlapThreshold()
+
+	if _, err := db.Exec("DELETE FROM resource_status_transitions WHERE changed_at < ?", since); err != nil {
+		return fmt.Errorf("failed to prune status transitions: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT resource_id, COUNT(*) FROM resource_status_transitions
+		WHERE changed_at >= ?
+		GROUP BY resource_id
+		HAVING COUNT(*) >= ?
+	`, since, threshold)
+	if err != nil {
+		return fmt.Errorf("failed to query status transitions: %w", err)
+	}
+	defer rows.Close()
+
+	flapping := 0
+	for rows.Next() {
+		var resourceID string
+		var count int
+		if err := rows.Scan(&resourceID, &count); err != nil {
+			log.Printf("Error scanning status transition count: %v", err)
+			continue
+		}
+		flapping++
+		log.Printf("Warning: resource %s is flapping, %d status changes in the last %v", resourceID, count, FlapWindow())
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read status transitions: %w", err)
+	}
+
+	MetricResourcesFlapping.Set(float64(flapping))
+	return nil
+}
@@ -3,13 +3,13 @@ package services
 import (
     "context"
     "database/sql"
-    "encoding/json"
+    "errors"
     "fmt"
-    "io"
-    "io/ioutil"
     "log"
     "net/http"
+    "strconv"
     "strings"
+    "sync"
     "time"
 
     "github.com/hhftechnology/middleware-manager/database"
@@ -22,9 +22,19 @@ type ResourceWatcher struct {
     db              *database.DB
     fetcher         ResourceFetcher
     configManager   *ConfigManager
+    sourceChanges   <-chan struct{} // Active data source switches
     stopChan        chan struct{}
+    doneChan        chan struct{} // Closed when the running loop returns
     isRunning       bool
+    mutex           sync.Mutex // Guards isRunning, stopChan and doneChan
+    checkMutex      sync.Mutex // Serializes checks from the loop and CheckNow
     httpClient      *http.Client
+    defaultService  string // Service used for resources the data source sends without one
+    defaulted       map[string]bool // Resources currently given the default service
+    defaultedMutex  sync.Mutex // Guards defaulted, which RefreshResource also updates
+    disableGrace    DisableGracePeriod // How long a resource may be missing before it's disabled
+    missingCycles   map[string]int // Consecutive fetches each active resource has been missing from
+    defaultMiddlewares []DefaultMiddleware // Assigned to each resource when it's first created
 }
 
 // NewResourceWatcher creates a new resource watcher
@@ -41,56 +51,126 @@ func NewResourceWatcher(db *database.DB, configManager *ConfigManager) (*Resourc
         return nil, fmt.Errorf("failed to create resource fetcher: %w", err)
     }
     
-    // Create HTTP client with timeout
-    httpClient := &http.Client{
-        Timeout: 10 * time.Second, // Set reasonable timeout
-    }
+    // Create HTTP client with timeout and the data source's TLS settings
+    httpClient := NewDataSourceHTTPClient(dsConfig, 10*time.Second)
     
     return &ResourceWatcher{
         db:             db,
         fetcher:        fetcher,
         configManager:  configManager,
+        sourceChanges:  configManager.ActiveSourceChanges(),
         stopChan:       make(chan struct{}),
         isRunning:      false,
         httpClient:     httpClient,
+        missingCycles:  make(map[string]int),
+        defaulted:      make(map[string]bool),
     }, nil
 }
 
 // Start begins watching for resources
 func (rw *ResourceWatcher) Start(interval time.Duration) {
+    rw.mutex.Lock()
     if rw.isRunning {
+        rw.mutex.Unlock()
         return
     }
-    
     rw.isRunning = true
+    stopChan := rw.stopChan
+    doneChan := make(chan struct{})
+    rw.doneChan = doneChan
+    rw.mutex.Unlock()
+    defer close(doneChan)
+    
     log.Printf("Resource watcher started, checking every %v", interval)
 
     ticker := time.NewTicker(interval)
     defer ticker.Stop()
 
+    // Each check starts its own trace
+    ctx := context.Background()
+
     // Do an initial check
-    if err := rw.checkResources(); err != nil {
+    rw.checkMutex.Lock()
+    if err := rw.checkResources(ctx); err != nil {
         log.Printf("Initial resource check failed: %v", err)
     }
+    rw.checkMutex.Unlock()
 
     for {
         select {
         case <-ticker.C:
-            // Check if data source config has changed
+            if err := rw.CheckNow(ctx); err != nil {
+                log.Printf("Resource check failed: %v", err)
+            }
+        case <-rw.sourceChanges:
+            // The active data source was switched, don't wait for the tick
+            rw.checkMutex.Lock()
             if err := rw.refreshFetcher(); err != nil {
+                rw.checkMutex.Unlock()
                 log.Printf("Failed to refresh resource fetcher: %v", err)
+                continue
             }
+            log.Printf("Resource fetcher switched to data source %s", rw.configManager.GetActiveSourceName())
             
-            if err := rw.checkResources(); err != nil {
+            if err := rw.checkResources(ctx); err != nil {
                 log.Printf("Resource check failed: %v", err)
             }
-        case <-rw.stopChan:
+            rw.checkMutex.Unlock()
+        case <-stopChan:
             log.Println("Resource watcher stopped")
             return
         }
     }
 }
 
+// CheckNow picks up data source config changes and checks the resources
+// right away, as the next tick would. It waits for a check in progress.
+func (rw *ResourceWatcher) CheckNow(ctx context.Context) error {
+    rw.checkMutex.Lock()
+    defer rw.checkMutex.Unlock()
+    
+    // Check if data source config has changed
+    if err := rw.refreshFetcher(); err != nil {
+        log.Printf("Failed to refresh resource fetcher: %v", err)
+    }
+    return rw.checkResources(ctx)
+}
+
+// SetDefaultService makes resources that arrive without a service point at
+// service instead of being skipped
+func (rw *ResourceWatcher) SetDefaultService(service string) {
+    rw.defaultService = service
+}
+
+// usableResource reports whether a fetched resource can be stored. Resources
+// without a host are skipped; ones without a service get the default service
+// if one is configured. Switching a resource to or from the default service
+// is logged once rather than on every check.
+func (rw *ResourceWatcher) usableResource(resource *models.Resource) bool {
+    if resource.Host == "" {
+        return false
+    }
+
+    rw.defaultedMutex.Lock()
+    defer rw.defaultedMutex.Unlock()
+    if resource.ServiceID != "" {
+        if rw.defaulted[resource.ID] {
+            log.Printf("Resource %s has a service again, no longer using default service %s", resource.ID, rw.defaultService)
+            delete(rw.defaulted, resource.ID)
+        }
+        return true
+    }
+    if rw.defaultService == "" {
+        return false
+    }
+    if !rw.defaulted[resource.ID] {
+        log.Printf("Resource %s has no service, using default service %s", resource.ID, rw.defaultService)
+        rw.defaulted[resource.ID] = true
+    }
+    resource.ServiceID = rw.defaultService
+    return true
+}
+
 // refreshFetcher updates the fetcher if the data source config has changed
 func (rw *ResourceWatcher) refreshFetcher() error {
     dsConfig, err := rw.configManager.GetActiveDataSourceConfig()
@@ -104,38 +184,67 @@ func (rw *ResourceWatcher) refreshFetcher() error {
         return fmt.Errorf("failed to create resource fetcher: %w", err)
     }
     
-    // Update the fetcher
+    // Update the fetcher and the HTTP client used for direct requests
     rw.fetcher = fetcher
+    rw.httpClient = NewDataSourceHTTPClient(dsConfig, 10*time.Second)
     return nil
 }
 
-// Stop stops the resource watcher
+// Stop stops the resource watcher and waits for its loop to return. It does
+// nothing if the watcher isn't running, and the watcher can be started again
+// afterwards.
 func (rw *ResourceWatcher) Stop() {
+    rw.mutex.Lock()
+    defer rw.mutex.Unlock()
+    
     if !rw.isRunning {
         return
     }
     
     close(rw.stopChan)
+    // Hold the mutex until the loop has returned, so a Start waiting on it
+    // can't run a second loop alongside this one
+    <-rw.doneChan
+    rw.stopChan = make(chan struct{})
     rw.isRunning = false
 }
 
 // checkResources fetches resources from the configured data source and updates the database
-func (rw *ResourceWatcher) checkResources() error {
+func (rw *ResourceWatcher) checkResources(ctx context.Context) (err error) {
     log.Println("Checking for resources using configured data source...")
     
+    ctx, span := StartSpan(ctx, "checkResources")
+    defer func() {
+        MetricResourceChecks.Inc()
+        if err != nil {
+            MetricResourceCheckErrors.Inc()
+        } else if flapErr := reportFlappingResources(rw.db); flapErr != nil {
+            log.Printf("Error checking for flapping resources: %v", flapErr)
+        }
+        span.RecordError(err)
+        span.End()
+    }()
+    
     // Create a context with timeout for the operation
-    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
     defer cancel()
     
     // Fetch resources using the configured fetcher
-    resources, err := rw.fetcher.FetchResources(ctx)
+    fetchCtx, fetchSpan := StartSpan(ctx, "FetchResources")
+    resources, err := rw.fetcher.FetchResources(fetchCtx)
+    fetchSpan.RecordError(err)
+    if resources != nil {
+        fetchSpan.SetAttribute("resources.count", strconv.Itoa(len(resources.Resources)))
+    }
+    fetchSpan.End()
     if err != nil {
         return fmt.Errorf("failed to fetch resources: %w", err)
     }
 
     // Get all existing resources from the database
     var existingResources []string
-    rows, err := rw.db.Query("SELECT id FROM resources WHERE status = 'active'")
+    // Manually-created resources aren't tied to the data source, so skip them
+    rows, err := rw.db.Query("SELECT id FROM resources WHERE status = 'active' AND COALESCE(origin, '') != ?", models.ResourceOriginManual)
     if err != nil {
         return fmt.Errorf("failed to query existing resources: %w", err)
     }
@@ -156,16 +265,9 @@ func (rw *ResourceWatcher) checkResources() error {
     // Check if there are any resources
     if len(resources.Resources) == 0 {
         log.Println("No resources found in data source")
-        // Mark all existing resources as disabled since there are no active resources
+        // Every existing resource is missing; disable them once their grace period is over
         for _, resourceID := range existingResources {
-            log.Printf("No active resources, marking resource %s as disabled", resourceID)
-            _, err := rw.db.Exec(
-                "UPDATE resources SET status = 'disabled', updated_at = ? WHERE id = ?",
-                time.Now(), resourceID,
-            )
-            if err != nil {
-                log.Printf("Error marking resource as disabled: %v", err)
-            }
+            rw.resourceMissing(resourceID)
         }
         return nil
     }
@@ -175,7 +277,7 @@ func (rw *ResourceWatcher) checkResources() error {
     // Process resources
     for _, resource := range resources.Resources {
         // Skip invalid resources
-        if resource.Host == "" || resource.ServiceID == "" {
+        if !rw.usableResource(&resource) {
             continue
         }
 
@@ -193,24 +295,73 @@ func (rw *ResourceWatcher) checkResources() error {
         foundResources[normalizedID] = true
     }
     
+    rw.clearMissing(foundResources)
+    
     // Mark resources as disabled if they no longer exist in the data source
     for _, resourceID := range existingResources {
         normalizedID := util.NormalizeID(resourceID)
         if !foundResources[normalizedID] {
-            log.Printf("Resource %s no longer exists, marking as disabled", resourceID)
-            _, err := rw.db.Exec(
-                "UPDATE resources SET status = 'disabled', updated_at = ? WHERE id = ?",
-                time.Now(), resourceID,
-            )
-            if err != nil {
-                log.Printf("Error marking resource as disabled: %v", err)
-            }
+            rw.resourceMissing(resourceID)
         }
     }
     
     return nil
 }
 
+// disableResource marks an active resource as disabled and records the transition
+func (rw *ResourceWatcher) disableResource(resourceID string) {
+    err := rw.db.WithTransaction(func(tx *sql.Tx) error {
+        if _, err := tx.Exec(
+            "UPDATE resources SET status = 'disabled', missing_since = NULL, updated_at = ? WHERE id = ?",
+            time.Now(), resourceID,
+        ); err != nil {
+            return err
+        }
+        return recordStatusTransition(tx, resourceID, "active", "disabled")
+    })
+    if err != nil {
+        log.Printf("Error marking resource as disabled: %v", err)
+    }
+}
+
+// ErrResourceNotInDataSource is returned when a resource is not present in the data source
+var ErrResourceNotInDataSource = errors.New("resource not found in data source")
+
+// RefreshResource re-fetches a single resource from the active data source and
+// updates it, without touching any other resource
+func (rw *ResourceWatcher) RefreshResource(ctx context.Context, resourceID string) error {
+    dsConfig, err := rw.configManager.GetActiveDataSourceConfig()
+    if err != nil {
+        return fmt.Errorf("failed to get data source config: %w", err)
+    }
+    
+    // Use a dedicated fetcher so we don't race with the watcher loop
+    fetcher, err := NewResourceFetcher(dsConfig)
+    if err != nil {
+        return fmt.Errorf("failed to create resource fetcher: %w", err)
+    }
+    
+    resources, err := fetcher.FetchResources(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to fetch resources: %w", err)
+    }
+    
+    normalizedID := util.NormalizeID(resourceID)
+    for _, resource := range resources.Resources {
+        if !rw.usableResource(&resource) {
+            continue
+        }
+        if util.NormalizeID(resource.ID) != normalizedID {
+            continue
+        }
+        
+        log.Printf("Refreshing resource %s from data source", resourceID)
+        return rw.updateOrCreateResource(resource)
+    }
+    
+    return ErrResourceNotInDataSource
+}
+
 // updateOrCreateResource updates an existing resource or creates a new one
 func (rw *ResourceWatcher) updateOrCreateResource(resource models.Resource) error {
     // Use our centralized normalization function
@@ -262,9 +413,9 @@ func (rw *ResourceWatcher) updateOrCreateResource(resource models.Resource) erro
     var existingID string
     err = rw.db.QueryRow(`
         SELECT id FROM resources 
-        WHERE id LIKE ? OR id LIKE ? 
+        WHERE (id LIKE ? OR id LIKE ?) AND COALESCE(origin, '') != ?
         LIMIT 1
-    `, normalizedID+"%", originalID+"%").Scan(&existingID)
+    `, normalizedID+"%", originalID+"%", models.ResourceOriginManual).Scan(&existingID)
     
     if err == nil {
         // Found a similar resource
@@ -289,22 +440,45 @@ func (rw *ResourceWatcher) updateOrCreateResource(resource models.Resource) erro
 func (rw *ResourceWatcher) updateExistingResource(id string, resource models.Resource, status string) error {
     // Use a transaction for the update
     return rw.db.WithTransaction(func(tx *sql.Tx) error {
+        // Skip the write when nothing the data source owns has changed, so
+        // updated_at only moves on real changes. A custom service assigned in
+        // resource_services is kept separately and always wins in the generator.
+        var currentHost, currentServiceID, currentSourceType string
+        var trashed bool
+        err := tx.QueryRow(
+            "SELECT host, service_id, COALESCE(source_type, ''), deleted_at IS NOT NULL FROM resources WHERE id = ?", id,
+        ).Scan(&currentHost, &currentServiceID, &currentSourceType, &trashed)
+        if err != nil {
+            return fmt.Errorf("failed to read resource %s: %w", id, err)
+        }
+        if status == "active" && !trashed && currentHost == resource.Host &&
+            currentServiceID == resource.ServiceID && currentSourceType == resource.SourceType {
+            return nil
+        }
+        
         log.Printf("Updating resource %s using existing ID %s in database", resource.ID, id)
         
-        // Update essential fields but preserve custom configuration
-        _, err := tx.Exec(`
+        // Update essential fields but preserve custom configuration. A resource
+        // in the trash is back in the data source, so it's restored as well.
+        _, err = tx.Exec(`
             UPDATE resources 
             SET host = ?, service_id = ?, status = 'active', 
-                source_type = ?, updated_at = ? 
+                source_type = ?, deleted_at = NULL, updated_at = ? 
             WHERE id = ?
         `, resource.Host, resource.ServiceID, resource.SourceType, time.Now(), id)
         
         if err != nil {
             return fmt.Errorf("failed to update resource %s: %w", id, err)
         }
+        if trashed {
+            log.Printf("Resource %s is back in the data source, restored it from the trash", id)
+        }
         
         if status == "disabled" {
             log.Printf("Resource %s was disabled but is now active again", id)
+            if err := recordStatusTransition(tx, id, status, "active"); err != nil {
+                return err
+            }
         }
         
         return nil
@@ -383,7 +557,7 @@ func (rw *ResourceWatcher) createNewResource(resource models.Resource, normalize
                     }
                     
                     log.Printf("Added new resource with alternative ID: %s (%s)", resource.Host, alternativeID)
-                    return nil
+                    return rw.assignDefaultMiddlewares(tx, alternativeID)
                 }
                 
                 return fmt.Errorf("failed to create resource due to ID conflict: %w", err)
@@ -398,7 +572,7 @@ if err != nil {
     log.Printf("Successfully updated/inserted %d rows", rowsAffected)
 }
         log.Printf("Added new resource: %s (%s)", resource.Host, resourceID)
-        return nil
+        return rw.assignDefaultMiddlewares(tx, resourceID)
     })
 }
 
@@ -410,58 +584,16 @@ func (rw *ResourceWatcher) fetchTraefikConfig(ctx context.Context) (*models.Pang
         return nil, fmt.Errorf("failed to get data source config: %w", err)
     }
     
-    // Build the URL based on data source type
-    var url string
-    if dsConfig.Type == models.PangolinAPI {
-        url = fmt.Sprintf("%s/traefik-config", dsConfig.URL)
-    } else {
+    if dsConfig.Type != models.PangolinAPI {
         return nil, fmt.Errorf("unsupported data source type for this operation: %s", dsConfig.Type)
     }
     
-    // Create a request with context
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
-    }
-    
-    // Add basic auth if configured
-    if dsConfig.BasicAuth.Username != "" {
-        req.SetBasicAuth(dsConfig.BasicAuth.Username, dsConfig.BasicAuth.Password)
-    }
-    
-    // Make the request
-    resp, err := rw.httpClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("HTTP request failed: %w", err)
-    }
-    defer resp.Body.Close()
-
-    // Check status code
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("HTTP request returned status %d", resp.StatusCode)
-    }
-
-    // Read response body with a limit to prevent memory issues
-    body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 10*1024*1024)) // 10MB limit
+    // Fetch the config, following pagination if present
+    config, err := fetchPangolinTraefikConfig(ctx, rw.httpClient, dsConfig)
     if err != nil {
-        return nil, fmt.Errorf("failed to read response body: %w", err)
-    }
-
-    // Parse JSON
-    var config models.PangolinTraefikConfig
-    if err := json.Unmarshal(body, &config); err != nil {
-        return nil, fmt.Errorf("failed to parse JSON: %w", err)
-    }
-
-    // Initialize empty maps if they're nil to prevent nil pointer dereferences
-    if config.HTTP.Routers == nil {
-        config.HTTP.Routers = make(map[string]models.PangolinRouter)
+        return nil, err
     }
-    if config.HTTP.Services == nil {
-        config.HTTP.Services = make(map[string]models.PangolinService)
-    }
-
-    return &config, nil
+    return config, nil
 }
 
 // isSystemRouter checks if a router is a system router (to be skipped)
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// Characters allowed in the entrypoint suffix of a split router's ID
+var routerSuffixPattern = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// splitRouterByEntrypoint turns a router listening on several entrypoints into
+// one router per entrypoint, so each can carry its own TLS and middlewares.
+// Routers get the entrypoint as an ID suffix; TLS is only kept on TLS
+// entrypoints, and redirectScheme middlewares, which only make sense before
+// TLS, are dropped from routers on TLS entrypoints. Routers with a single
+// entrypoint are returned unchanged.
+func (cg *ConfigGenerator) splitRouterByEntrypoint(routerID string, routerConfig map[string]interface{}, info models.Resource, config *TraefikConfig) map[string]map[string]interface{} {
+	entryPoints, _ := routerConfig["entryPoints"].([]string)
+	if len(entryPoints) < 2 {
+		return map[string]map[string]interface{}{routerID: routerConfig}
+	}
+
+	middlewares, _ := routerConfig["middlewares"].([]string)
+	routers := make(map[string]map[string]interface{}, len(entryPoints))
+	for _, entryPoint := range entryPoints {
+		split := make(map[string]interface{}, len(routerConfig))
+		for key, value := range routerConfig {
+			split[key] = value
+		}
+		split["entryPoints"] = []string{entryPoint}
+
+		delete(split, "tls")
+		if tlsConfig := cg.routerTLSConfig(info, []string{entryPoint}); tlsConfig != nil {
+			split["tls"] = tlsConfig
+		}
+
+		delete(split, "middlewares")
+		var kept []string
+		for _, middleware := range middlewares {
+			if split["tls"] != nil && isRedirectSchemeMiddleware(middleware, config) {
+				continue
+			}
+			kept = append(kept, middleware)
+		}
+		if len(kept) > 0 {
+			split["middlewares"] = kept
+		}
+
+		suffix := strings.Trim(routerSuffixPattern.ReplaceAllString(entryPoint, "-"), "-")
+		routers[fmt.Sprintf("%s-%s", routerID, suffix)] = split
+	}
+	return routers
+}
+
+// isRedirectSchemeMiddleware reports whether a router's middleware reference
+// points at a generated redirectScheme middleware
+func isRedirectSchemeMiddleware(reference string, config *TraefikConfig) bool {
+	if !strings.HasSuffix(reference, "@file") {
+		return false
+	}
+	middleware, ok := config.HTTP.Middlewares[strings.TrimSuffix(reference, "@file")].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = middleware["redirectScheme"]
+	return ok
+}
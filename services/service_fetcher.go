@@ -11,6 +11,7 @@ import (
     "time"
     
     "github.com/hhftechnology/middleware-manager/models"
+    "github.com/hhftechnology/middleware-manager/util"
 )
 
 // ServiceFetcher defines the interface for fetching services
@@ -25,6 +26,10 @@ func NewServiceFetcher(config models.DataSourceConfig) (ServiceFetcher, error) {
         return NewPangolinServiceFetcher(config), nil
     case models.TraefikAPI:
         return NewTraefikServiceFetcher(config), nil
+    case models.DockerAPI:
+        return NewDockerFetcher(config), nil
+    case models.TraefikFile:
+        return NewFileFetcher(config), nil
     default:
         return nil, fmt.Errorf("unknown data source type: %s", config.Type)
     }
@@ -39,48 +44,17 @@ type PangolinServiceFetcher struct {
 // NewPangolinServiceFetcher creates a new Pangolin API fetcher for services
 func NewPangolinServiceFetcher(config models.DataSourceConfig) *PangolinServiceFetcher {
     return &PangolinServiceFetcher{
-        config: config,
-        httpClient: &http.Client{
-            Timeout: 10 * time.Second,
-        },
+        config:     config,
+        httpClient: NewDataSourceHTTPClient(config, 10*time.Second),
     }
 }
 
 // FetchServices fetches services from Pangolin API
 func (f *PangolinServiceFetcher) FetchServices(ctx context.Context) (*models.ServiceCollection, error) {
-    // Create HTTP request
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.config.URL+"/traefik-config", nil)
+    // Fetch the Pangolin config (which includes services), following pagination if present
+    config, err := fetchPangolinTraefikConfig(ctx, f.httpClient, f.config)
     if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
-    }
-    
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
-    
-    // Execute request
-    resp, err := f.httpClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("HTTP request failed: %w", err)
-    }
-    defer resp.Body.Close()
-    
-    // Check status code
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-    }
-    
-    // Process response
-    body, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read response: %w", err)
-    }
-    
-    // Parse the Pangolin config (which includes services)
-    var config models.PangolinTraefikConfig
-    if err := json.Unmarshal(body, &config); err != nil {
-        return nil, fmt.Errorf("failed to parse JSON: %w", err)
+        return nil, err
     }
     
     // Convert Pangolin services to our internal model
@@ -105,6 +79,7 @@ func (f *PangolinServiceFetcher) FetchServices(ctx context.Context) (*models.Ser
         switch serviceType {
         case string(models.LoadBalancerType):
             if lb, ok := service.LoadBalancer.(map[string]interface{}); ok {
+                util.NormalizeServersInConfig(lb)
                 serviceConfig = lb
             }
         case string(models.WeightedType):
@@ -188,10 +163,8 @@ type TraefikServiceFetcher struct {
 // NewTraefikServiceFetcher creates a new Traefik API fetcher for services
 func NewTraefikServiceFetcher(config models.DataSourceConfig) *TraefikServiceFetcher {
     return &TraefikServiceFetcher{
-        config: config,
-        httpClient: &http.Client{
-            Timeout: 10 * time.Second,
-        },
+        config:     config,
+        httpClient: NewDataSourceHTTPClient(config, 10*time.Second),
     }
 }
 
@@ -296,10 +269,8 @@ func (f *TraefikServiceFetcher) fetchHTTPServices(ctx context.Context, baseURL s
         return nil, fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -375,10 +346,8 @@ func (f *TraefikServiceFetcher) fetchTCPServices(ctx context.Context, baseURL st
         return nil, fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -434,6 +403,7 @@ func (f *TraefikServiceFetcher) fetchTCPServices(ctx context.Context, baseURL st
                 // Try other service types if needed
                 config = serviceMap
             }
+            util.NormalizeServersInConfig(config)
             
             // Create service
             configJSON, _ := json.Marshal(config)
@@ -475,6 +445,7 @@ func (f *TraefikServiceFetcher) fetchTCPServices(ctx context.Context, baseURL st
                 // Try other service types if needed
                 config = serviceMap
             }
+            util.NormalizeServersInConfig(config)
             
             // Create service
             configJSON, _ := json.Marshal(config)
@@ -501,10 +472,8 @@ func (f *TraefikServiceFetcher) fetchUDPServices(ctx context.Context, baseURL st
         return nil, fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -562,6 +531,7 @@ func (f *TraefikServiceFetcher) fetchUDPServices(ctx context.Context, baseURL st
                 // Try other service types if needed
                 config = serviceMap
             }
+            util.NormalizeServersInConfig(config)
             
             // Create service
             configJSON, _ := json.Marshal(config)
@@ -603,6 +573,7 @@ func (f *TraefikServiceFetcher) fetchUDPServices(ctx context.Context, baseURL st
                 // Try other service types if needed
                 config = serviceMap
             }
+            util.NormalizeServersInConfig(config)
             
             // Create service
             configJSON, _ := json.Marshal(config)
@@ -638,8 +609,13 @@ func processTraefikService(traefikService models.TraefikService) *models.Service
         // Most common case: LoadBalancer
         config = make(map[string]interface{})
         
-        // Extract servers
+        // Extract servers, normalizing URLs and addresses so identical backends compare equal
         if traefikService.LoadBalancer.Servers != nil {
+            for i := range traefikService.LoadBalancer.Servers {
+                server := &traefikService.LoadBalancer.Servers[i]
+                server.URL = util.NormalizeServerURL(server.URL)
+                server.Address = util.NormalizeServerAddress(server.Address)
+            }
             config["servers"] = traefikService.LoadBalancer.Servers
         }
         
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceProviderStrategy controls which provider suffix (@file, @http,
+// @docker) routers use to reference a resource's upstream service
+type ServiceProviderStrategy string
+
+const (
+	// ServiceProviderAuto guesses the provider from the data source type
+	ServiceProviderAuto ServiceProviderStrategy = "auto"
+	// ServiceProviderForceFile always references services with @file
+	ServiceProviderForceFile ServiceProviderStrategy = "force-file"
+	// ServiceProviderForceHTTP always references services with @http
+	ServiceProviderForceHTTP ServiceProviderStrategy = "force-http"
+	// ServiceProviderForceDocker always references services with @docker
+	ServiceProviderForceDocker ServiceProviderStrategy = "force-docker"
+	// ServiceProviderLookup asks Traefik's API which provider defines the
+	// service, falling back to auto for services Traefik doesn't know
+	ServiceProviderLookup ServiceProviderStrategy = "lookup"
+)
+
+// How long service names looked up from Traefik are reused
+const serviceLookupTTL = 30 * time.Second
+
+// ParseServiceProviderStrategy parses a SERVICE_PROVIDER_STRATEGY value; empty means auto
+func ParseServiceProviderStrategy(value string) (ServiceProviderStrategy, error) {
+	strategy := ServiceProviderStrategy(strings.ToLower(strings.TrimSpace(value)))
+	switch strategy {
+	case "":
+		return ServiceProviderAuto, nil
+	case ServiceProviderAuto, ServiceProviderForceFile, ServiceProviderForceHTTP, ServiceProviderForceDocker, ServiceProviderLookup:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown service provider strategy %q, expected auto, force-file, force-http, force-docker or lookup", value)
+}
+
+// serviceNameCache holds Traefik's service names, keyed by protocol then base name
+type serviceNameCache struct {
+	mu        sync.Mutex
+	names     map[string]map[string]string
+	fetchedAt map[string]time.Time
+}
+
+// upstreamServiceReference returns the provider-qualified reference routers use
+// for a resource's upstream service. autoProvider is the provider the auto
+// strategy picks; protocol is "http" or "tcp" and selects which of Traefik's
+// service lists the lookup strategy searches.
+func (cg *ConfigGenerator) upstreamServiceReference(serviceID, protocol, autoProvider string) string {
+	baseName := normalizeServiceID(serviceID)
+
+	switch cg.serviceProviderStrategy {
+	case ServiceProviderForceFile:
+		return fmt.Sprintf("%s@file", baseName)
+	case ServiceProviderForceHTTP:
+		return fmt.Sprintf("%s@http", baseName)
+	case ServiceProviderForceDocker:
+		return fmt.Sprintf("%s@docker", baseName)
+	case ServiceProviderLookup:
+		if name, ok := cg.lookupTraefikServiceName(protocol, baseName); ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("%s@%s", baseName, autoProvider)
+}
+
+// lookupTraefikServiceName returns the full name (with provider) Traefik
+// knows the service by, refreshing the cached names once they're stale
+func (cg *ConfigGenerator) lookupTraefikServiceName(protocol, baseName string) (string, bool) {
+	cache := &cg.serviceNames
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.names == nil {
+		cache.names = make(map[string]map[string]string)
+		cache.fetchedAt = make(map[string]time.Time)
+	}
+	if time.Since(cache.fetchedAt[protocol]) > serviceLookupTTL {
+		cache.names[protocol] = cg.fetchTraefikServiceNames(protocol)
+		cache.fetchedAt[protocol] = time.Now()
+	}
+
+	name, ok := cache.names[protocol][baseName]
+	return name, ok
+}
@@ -6,7 +6,9 @@ import (
     "encoding/json"
     "fmt"
     "log"
+    "reflect"
     "strings"
+    "sync"
     "time"
 
     "github.com/hhftechnology/middleware-manager/database"
@@ -19,8 +21,12 @@ type ServiceWatcher struct {
     db              *database.DB
     fetcher         ServiceFetcher
     configManager   *ConfigManager
+    sourceChanges   <-chan struct{} // Active data source switches
     stopChan        chan struct{}
+    doneChan        chan struct{} // Closed when the running loop returns
     isRunning       bool
+    mutex           sync.Mutex // Guards isRunning, stopChan and doneChan
+    checkMutex      sync.Mutex // Serializes checks from the loop and CheckNow
 }
 
 // NewServiceWatcher creates a new service watcher
@@ -41,6 +47,7 @@ func NewServiceWatcher(db *database.DB, configManager *ConfigManager) (*ServiceW
         db:             db,
         fetcher:        fetcher,
         configManager:  configManager,
+        sourceChanges:  configManager.ActiveSourceChanges(),
         stopChan:       make(chan struct{}),
         isRunning:      false,
     }, nil
@@ -48,39 +55,70 @@ func NewServiceWatcher(db *database.DB, configManager *ConfigManager) (*ServiceW
 
 // Start begins watching for services
 func (sw *ServiceWatcher) Start(interval time.Duration) {
+    sw.mutex.Lock()
     if sw.isRunning {
+        sw.mutex.Unlock()
         return
     }
-    
     sw.isRunning = true
+    stopChan := sw.stopChan
+    doneChan := make(chan struct{})
+    sw.doneChan = doneChan
+    sw.mutex.Unlock()
+    defer close(doneChan)
+    
     log.Printf("Service watcher started, checking every %v", interval)
 
     ticker := time.NewTicker(interval)
     defer ticker.Stop()
 
     // Do an initial check
+    sw.checkMutex.Lock()
     if err := sw.checkServices(); err != nil {
         log.Printf("Initial service check failed: %v", err)
     }
+    sw.checkMutex.Unlock()
 
     for {
         select {
         case <-ticker.C:
-            // Check if data source config has changed
+            if err := sw.CheckNow(); err != nil {
+                log.Printf("Service check failed: %v", err)
+            }
+        case <-sw.sourceChanges:
+            // The active data source was switched, don't wait for the tick
+            sw.checkMutex.Lock()
             if err := sw.refreshFetcher(); err != nil {
+                sw.checkMutex.Unlock()
                 log.Printf("Failed to refresh service fetcher: %v", err)
+                continue
             }
+            log.Printf("Service fetcher switched to data source %s", sw.configManager.GetActiveSourceName())
             
             if err := sw.checkServices(); err != nil {
                 log.Printf("Service check failed: %v", err)
             }
-        case <-sw.stopChan:
+            sw.checkMutex.Unlock()
+        case <-stopChan:
             log.Println("Service watcher stopped")
             return
         }
     }
 }
 
+// CheckNow picks up data source config changes and checks the services
+// right away, as the next tick would. It waits for a check in progress.
+func (sw *ServiceWatcher) CheckNow() error {
+    sw.checkMutex.Lock()
+    defer sw.checkMutex.Unlock()
+    
+    // Check if data source config has changed
+    if err := sw.refreshFetcher(); err != nil {
+        log.Printf("Failed to refresh service fetcher: %v", err)
+    }
+    return sw.checkServices()
+}
+
 // refreshFetcher updates the fetcher if the data source config has changed
 func (sw *ServiceWatcher) refreshFetcher() error {
     dsConfig, err := sw.configManager.GetActiveDataSourceConfig()
@@ -99,20 +137,36 @@ func (sw *ServiceWatcher) refreshFetcher() error {
     return nil
 }
 
-// Stop stops the service watcher
+// Stop stops the service watcher and waits for its loop to return. It does
+// nothing if the watcher isn't running, and the watcher can be started again
+// afterwards.
 func (sw *ServiceWatcher) Stop() {
+    sw.mutex.Lock()
+    defer sw.mutex.Unlock()
+    
     if !sw.isRunning {
         return
     }
     
     close(sw.stopChan)
+    // Hold the mutex until the loop has returned, so a Start waiting on it
+    // can't run a second loop alongside this one
+    <-sw.doneChan
+    sw.stopChan = make(chan struct{})
     sw.isRunning = false
 }
 
 // checkServices fetches services from the configured data source and updates the database
-func (sw *ServiceWatcher) checkServices() error {
+func (sw *ServiceWatcher) checkServices() (err error) {
     log.Println("Checking for services using configured data source...")
     
+    defer func() {
+        MetricServiceChecks.Inc()
+        if err != nil {
+            MetricServiceCheckErrors.Inc()
+        }
+    }()
+    
     // Create a context with timeout for the operation
     ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
     defer cancel()
@@ -200,10 +254,15 @@ func (sw *ServiceWatcher) updateOrCreateService(service models.Service) error {
     ).Scan(&exists, &existingType, &existingConfig)
     
     if err == nil {
+        // Services the user has taken over only get the data-source-owned fields
+        managed := sw.isServiceManaged(normalizedID)
+        if !managed && existingType != service.Type {
+            return nil
+        }
         // Service exists, only update if it changed
-        if shouldUpdateService(sw.db, service, normalizedID) {
+        if shouldUpdateService(sw.db, service, normalizedID, managed) {
             log.Printf("Updating existing service: %s (normalized from %s)", normalizedID, originalID)
-            return sw.updateService(service, normalizedID)
+            return sw.updateService(service, normalizedID, managed)
         }
         // Service exists and hasn't changed, skip update
         return nil
@@ -229,8 +288,12 @@ func (sw *ServiceWatcher) updateOrCreateService(service models.Service) error {
         ).Scan(&altID)
         
         if err == nil {
+            managed := sw.isServiceManaged(altID)
+            if !shouldUpdateService(sw.db, service, altID, managed) {
+                return nil
+            }
             log.Printf("Found existing service with different suffix: %s - will update", altID)
-            return sw.updateService(service, altID)
+            return sw.updateService(service, altID, managed)
         }
     }
     
@@ -244,8 +307,21 @@ func (sw *ServiceWatcher) updateOrCreateService(service models.Service) error {
     return nil
 }
 
-// shouldUpdateService determines if an existing service needs to be updated
-func shouldUpdateService(db *database.DB, newService models.Service, normalizedID string) bool {
+// isServiceManaged reports whether the watcher owns the service and may overwrite it.
+// Services edited by the user are unmanaged and only get their servers updated.
+func (sw *ServiceWatcher) isServiceManaged(id string) bool {
+    var managed bool
+    err := sw.db.QueryRow("SELECT managed FROM services WHERE id = ?", id).Scan(&managed)
+    if err != nil {
+        log.Printf("Error checking managed flag for service %s: %v", id, err)
+        return false
+    }
+    return managed
+}
+
+// shouldUpdateService determines if an existing service needs to be updated.
+// Unmanaged services are compared against the merge updateService would write.
+func shouldUpdateService(db *database.DB, newService models.Service, normalizedID string, managed bool) bool {
     var existingType, existingConfig string
     
     err := db.QueryRow(
@@ -259,9 +335,9 @@ func shouldUpdateService(db *database.DB, newService models.Service, normalizedI
         return true
     }
     
-    // Check if the type has changed
+    // Check if the type has changed; a user-edited service keeps its type
     if existingType != newService.Type {
-        return true
+        return managed
     }
     
     // Check if the configuration has changed
@@ -279,99 +355,45 @@ func shouldUpdateService(db *database.DB, newService models.Service, normalizedI
         return true
     }
     
-    // Compare the configurations
-    return configsAreDifferent(existingConfigMap, newConfigMap)
+    // Managed services mirror the data source; for user-edited ones compare against
+    // what a merge would produce, so the fields they layered on don't count as changes
+    if !managed {
+        newConfigMap = mergeServiceConfig(newService.Type, existingConfigMap, newConfigMap)
+    }
+    return configsAreDifferent(newService.Type, existingConfigMap, newConfigMap)
 }
 
-// configsAreDifferent compares two service configurations
-func configsAreDifferent(config1, config2 map[string]interface{}) bool {
-    // Check for key differences
-    for key := range config1 {
-        if _, exists := config2[key]; !exists {
-            return true
-        }
-    }
-    
-    for key := range config2 {
-        if _, exists := config1[key]; !exists {
-            return true
-        }
-    }
-    
-    // Check server configurations
-    servers1, hasServers1 := config1["servers"].([]interface{})
-    servers2, hasServers2 := config2["servers"].([]interface{})
-    
-    if hasServers1 != hasServers2 {
-        return true
-    }
-    
-    if hasServers1 && hasServers2 {
-        if len(servers1) != len(servers2) {
-            return true
-        }
-        
-        // Compare each server
-        for i, server1 := range servers1 {
-            if i >= len(servers2) {
-                return true
-            }
-            
-            server1Map, ok1 := server1.(map[string]interface{})
-            server2Map, ok2 := servers2[i].(map[string]interface{})
-            
-            if !ok1 || !ok2 {
-                return true
-            }
-            
-            // Check URL/address fields
-            url1, hasURL1 := server1Map["url"].(string)
-            url2, hasURL2 := server2Map["url"].(string)
-            
-            if hasURL1 != hasURL2 || (hasURL1 && url1 != url2) {
-                return true
-            }
-            
-            addr1, hasAddr1 := server1Map["address"].(string)
-            addr2, hasAddr2 := server2Map["address"].(string)
-            
-            if hasAddr1 != hasAddr2 || (hasAddr1 && addr1 != addr2) {
-                return true
-            }
-        }
-    }
-    
-    // For other service types, we would need to check specific fields
-    // For simplicity, we'll consider them different if any common key has a different value
-    for key, val1 := range config1 {
-        if val2, exists := config2[key]; exists {
-            // Skip servers as we've handled them above
-            if key == "servers" {
-                continue
-            }
-            
-            // Handle primitive types
-            switch v1 := val1.(type) {
-            case string:
-                v2, ok := val2.(string)
-                if !ok || v1 != v2 {
-                    return true
-                }
-            case float64:
-                v2, ok := val2.(float64)
-                if !ok || v1 != v2 {
-                    return true
-                }
-            case bool:
-                v2, ok := val2.(bool)
-                if !ok || v1 != v2 {
-                    return true
-                }
-            }
+// watcherOwnedServiceKeys are the config keys the data source is authoritative for,
+// per service type. They are the only keys updated on user-edited services.
+var watcherOwnedServiceKeys = map[string][]string{
+    string(models.LoadBalancerType): {"servers"},
+}
+
+// mergeServiceConfig overlays the data-source-owned fields of incoming onto existing.
+// Owned keys missing from incoming are dropped; all other existing fields are kept.
+func mergeServiceConfig(serviceType string, existing, incoming map[string]interface{}) map[string]interface{} {
+    merged := make(map[string]interface{}, len(existing))
+    for key, val := range existing {
+        merged[key] = val
+    }
+    for _, key := range watcherOwnedServiceKeys[serviceType] {
+        if val, exists := incoming[key]; exists {
+            merged[key] = val
+        } else {
+            delete(merged, key)
         }
     }
-    
-    return false
+    return merged
+}
+
+// configsAreDifferent compares two service configurations recursively.
+// Both configs are normalized with the service type's processor first so that
+// representation differences (e.g. float64 vs int weights) don't count as changes.
+func configsAreDifferent(serviceType string, config1, config2 map[string]interface{}) bool {
+    normalized1 := models.ProcessServiceConfig(serviceType, config1)
+    normalized2 := models.ProcessServiceConfig(serviceType, config2)
+    
+    return !reflect.DeepEqual(normalized1, normalized2)
 }
 
 // createService creates a new service in the database
@@ -447,7 +469,7 @@ func (sw *ServiceWatcher) createService(service models.Service) error {
         
         // Insert the service
         _, err = tx.Exec(
-            "INSERT INTO services (id, name, type, config, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+            "INSERT INTO services (id, name, type, config, managed, created_at, updated_at) VALUES (?, ?, ?, ?, 1, ?, ?)",
             service.ID, service.Name, service.Type, string(configJSON), time.Now(), time.Now(),
         )
         
@@ -466,11 +488,12 @@ func (sw *ServiceWatcher) createService(service models.Service) error {
     })
 }
 
-// updateService updates an existing service in the database
-func (sw *ServiceWatcher) updateService(service models.Service, existingID string) error {
-    // Get the existing service to preserve the name
-    var existingName string
-    err := sw.db.QueryRow("SELECT name FROM services WHERE id = ?", existingID).Scan(&existingName)
+// updateService updates an existing service in the database. Managed services take
+// the data source's config as-is; unmanaged ones only take its owned fields.
+func (sw *ServiceWatcher) updateService(service models.Service, existingID string, managed bool) error {
+    // Get the existing service to preserve the name and user-added config
+    var existingName, existingType, existingConfig string
+    err := sw.db.QueryRow("SELECT name, type, config FROM services WHERE id = ?", existingID).Scan(&existingName, &existingType, &existingConfig)
     
     if err != nil {
         log.Printf("Error fetching existing service name for %s: %v, using provided name", existingID, err)
@@ -488,6 +511,19 @@ func (sw *ServiceWatcher) updateService(service models.Service, existingID strin
         configMap = make(map[string]interface{})
     }
     
+    // For user-edited services only take the server list from the data source
+    // and keep fields like sticky, healthCheck and passHostHeader as stored
+    if !managed {
+        if existingType != service.Type {
+            return nil
+        }
+        var existingConfigMap map[string]interface{}
+        if err := json.Unmarshal([]byte(existingConfig), &existingConfigMap); err != nil {
+            return fmt.Errorf("error parsing existing config for %s: %w", existingID, err)
+        }
+        configMap = mergeServiceConfig(service.Type, existingConfigMap, configMap)
+    }
+    
     // Apply any service-specific processing
     configMap = models.ProcessServiceConfig(service.Type, configMap)
     
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/database"
+)
+
+// StateExporter periodically writes a JSON snapshot of the state to a file
+// so external tools can read it without hitting the API or the database
+type StateExporter struct {
+	db        *database.DB
+	path      string
+	stopChan  chan struct{}
+	isRunning bool
+	mutex     sync.Mutex
+}
+
+// NewStateExporter creates a new state exporter writing to path
+func NewStateExporter(db *database.DB, path string) *StateExporter {
+	return &StateExporter{
+		db:       db,
+		path:     path,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins exporting the state at the given interval
+func (se *StateExporter) Start(interval time.Duration) {
+	se.mutex.Lock()
+	if se.isRunning {
+		se.mutex.Unlock()
+		return
+	}
+	se.isRunning = true
+	se.mutex.Unlock()
+
+	log.Printf("State exporter started, writing %s every %v", se.path, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if err := se.Export(); err != nil {
+		log.Printf("Initial state export failed: %v", err)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := se.Export(); err != nil {
+				log.Printf("State export failed: %v", err)
+			}
+		case <-se.stopChan:
+			log.Println("State exporter stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the state exporter
+func (se *StateExporter) Stop() {
+	se.mutex.Lock()
+	defer se.mutex.Unlock()
+
+	if !se.isRunning {
+		return
+	}
+	close(se.stopChan)
+	se.isRunning = false
+}
+
+// Export writes the current state to the export path. The file is written to a
+// temporary file first and renamed so readers never see a partial snapshot.
+func (se *StateExporter) Export() error {
+	bundle, err := se.db.ExportState()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(se.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, ".state-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		log.Printf("Warning: Failed to set permissions on state export: %v", err)
+	}
+	if err := os.Rename(tmpPath, se.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move state export into place: %w", err)
+	}
+
+	return nil
+}
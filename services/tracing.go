@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLP span kind and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// Spans buffered beyond this are dropped until the next export
+const maxBufferedSpans = 2048
+
+// Span times a single operation. Spans are only recorded when a trace
+// exporter has been configured with SetTraceExporter.
+type Span struct {
+	name       string
+	traceID    string
+	spanID     string
+	parentID   string
+	start      time.Time
+	attributes map[string]string
+	err        error
+	exporter   *OTLPTraceExporter
+}
+
+type spanContextKey struct{}
+
+var (
+	traceExporterMu sync.RWMutex
+	traceExporter   *OTLPTraceExporter
+)
+
+// SetTraceExporter sets the exporter that receives finished spans; nil disables tracing
+func SetTraceExporter(exporter *OTLPTraceExporter) {
+	traceExporterMu.Lock()
+	traceExporter = exporter
+	traceExporterMu.Unlock()
+}
+
+// StartSpan starts a span as a child of the span in ctx, if any, and returns
+// a context carrying the new span
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	traceExporterMu.RLock()
+	exporter := traceExporter
+	traceExporterMu.RUnlock()
+
+	span := &Span{name: name, start: time.Now(), exporter: exporter}
+	if exporter == nil {
+		return ctx, span
+	}
+
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = randomHexID(16)
+	}
+	span.spanID = randomHexID(8)
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttribute records a string attribute on the span
+func (s *Span) SetAttribute(key, value string) {
+	if s.exporter == nil {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if err != nil {
+		s.err = err
+	}
+}
+
+// End finishes the span and hands it to the exporter
+func (s *Span) End() {
+	if s.exporter == nil {
+		return
+	}
+	s.exporter.record(s.toOTLP(time.Now()))
+}
+
+// toOTLP converts the span to its OTLP JSON form
+func (s *Span) toOTLP(end time.Time) map[string]interface{} {
+	attributes := make([]map[string]interface{}, 0, len(s.attributes))
+	for key, value := range s.attributes {
+		attributes = append(attributes, map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": value},
+		})
+	}
+
+	span := map[string]interface{}{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              otlpSpanKindInternal,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+		"attributes":        attributes,
+	}
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+	if s.err != nil {
+		span["status"] = map[string]interface{}{"code": otlpStatusError, "message": s.err.Error()}
+	}
+	return span
+}
+
+// randomHexID returns n random bytes hex-encoded, as used for trace and span IDs
+func randomHexID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the clock; IDs only need to be unique enough to tell traces apart
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// OTLPTraceExporter batches finished spans and pushes them to an OpenTelemetry
+// collector using OTLP over HTTP with JSON encoding
+type OTLPTraceExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	spans       []map[string]interface{}
+	dropped     int
+	stopChan    chan struct{}
+	isRunning   bool
+	mutex       sync.Mutex
+}
+
+// NewOTLPTraceExporter creates an exporter that posts to endpoint, which must
+// be the full traces URL (e.g. http://collector:4318/v1/traces)
+func NewOTLPTraceExporter(endpoint string, headers map[string]string, serviceName string) *OTLPTraceExporter {
+	return &OTLPTraceExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// record buffers a finished span
+func (e *OTLPTraceExporter) record(span map[string]interface{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.spans) >= maxBufferedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, span)
+}
+
+// Start begins exporting buffered spans at the given interval
+func (e *OTLPTraceExporter) Start(interval time.Duration) {
+	e.mutex.Lock()
+	if e.isRunning {
+		e.mutex.Unlock()
+		return
+	}
+	e.isRunning = true
+	e.mutex.Unlock()
+
+	log.Printf("OTLP trace exporter started, sending to %s every %v", e.endpoint, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Export(); err != nil {
+				log.Printf("OTLP trace export failed: %v", err)
+			}
+		case <-e.stopChan:
+			// Flush the remaining spans before stopping
+			if err := e.Export(); err != nil {
+				log.Printf("Final OTLP trace export failed: %v", err)
+			}
+			log.Println("OTLP trace exporter stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the exporter
+func (e *OTLPTraceExporter) Stop() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.isRunning {
+		return
+	}
+	close(e.stopChan)
+	e.isRunning = false
+}
+
+// Export sends every buffered span
+func (e *OTLPTraceExporter) Export() error {
+	e.mutex.Lock()
+	spans := e.spans
+	dropped := e.dropped
+	e.spans = nil
+	e.dropped = 0
+	e.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Dropped %d spans because the export buffer was full", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": otlpResource(e.serviceName),
+			"scopeSpans": []map[string]interface{}{{
+				"scope": otlpScope,
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+	return postOTLP(e.client, e.endpoint, e.headers, payload)
+}
@@ -22,10 +22,8 @@ type TraefikFetcher struct {
 // NewTraefikFetcher creates a new Traefik API fetcher
 func NewTraefikFetcher(config models.DataSourceConfig) *TraefikFetcher {
     return &TraefikFetcher{
-        config: config,
-        httpClient: &http.Client{
-            Timeout: 10 * time.Second,
-        },
+        config:     config,
+        httpClient: NewDataSourceHTTPClient(config, 10*time.Second),
     }
 }
 
@@ -86,10 +84,8 @@ func (f *TraefikFetcher) fetchResourcesFromURL(ctx context.Context, baseURL stri
         return nil, fmt.Errorf("failed to create request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -199,10 +195,8 @@ func (f *TraefikFetcher) fetchTLSDomains(ctx context.Context, baseURL string) (m
         return nil, fmt.Errorf("failed to create TLS domains request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -260,10 +254,8 @@ func (f *TraefikFetcher) fetchTCPRouters(ctx context.Context, baseURL string) ([
         return nil, fmt.Errorf("failed to create TCP routers request: %w", err)
     }
     
-    // Add basic auth if configured
-    if f.config.BasicAuth.Username != "" {
-        req.SetBasicAuth(f.config.BasicAuth.Username, f.config.BasicAuth.Password)
-    }
+    // Add basic auth and custom headers if configured
+    f.config.ApplyToRequest(req)
     
     // Execute request
     resp, err := f.httpClient.Do(req)
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hhftechnology/middleware-manager/database"
+	"github.com/hhftechnology/middleware-manager/models"
+)
+
+// watcher is the lifecycle shared by ResourceWatcher and ServiceWatcher
+type watcher interface {
+	Start(interval time.Duration)
+	Stop()
+}
+
+// newWatcherDeps returns a database and a config manager whose active data
+// source is a test server that answers every request with 503
+func newWatcherDeps(t *testing.T) (*database.DB, *ConfigManager) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	configPath := filepath.Join(dir, "config.json")
+	config := fmt.Sprintf(`{"active_data_source": "pangolin", "data_sources": {"pangolin": {"type": "pangolin", "url": %q}}}`, server.URL)
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	configManager, err := NewConfigManager(configPath)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+	return db, configManager
+}
+
+func TestWatchersStartStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		watcher func(t *testing.T) (watcher, func() bool)
+	}{
+		{"resource", func(t *testing.T) (watcher, func() bool) {
+			rw, err := NewResourceWatcher(newWatcherDeps(t))
+			if err != nil {
+				t.Fatalf("NewResourceWatcher: %v", err)
+			}
+			return rw, func() bool {
+				rw.mutex.Lock()
+				defer rw.mutex.Unlock()
+				return rw.isRunning
+			}
+		}},
+		{"service", func(t *testing.T) (watcher, func() bool) {
+			sw, err := NewServiceWatcher(newWatcherDeps(t))
+			if err != nil {
+				t.Fatalf("NewServiceWatcher: %v", err)
+			}
+			return sw, func() bool {
+				sw.mutex.Lock()
+				defer sw.mutex.Unlock()
+				return sw.isRunning
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/stop waits for the loop", func(t *testing.T) {
+			w, running := tt.watcher(t)
+
+			for i := 0; i < 3; i++ {
+				returned := make(chan struct{})
+				go func() {
+					w.Start(time.Millisecond)
+					close(returned)
+				}()
+				waitFor(t, running)
+
+				w.Stop()
+				select {
+				case <-returned:
+				default:
+					t.Fatalf("Stop returned before the loop did (round %d)", i)
+				}
+				w.Stop() // Stopping again does nothing
+			}
+		})
+
+		t.Run(tt.name+"/concurrent double stop", func(t *testing.T) {
+			w, running := tt.watcher(t)
+
+			returned := make(chan struct{})
+			go func() {
+				w.Start(time.Millisecond)
+				close(returned)
+			}()
+			waitFor(t, running)
+
+			// Both Stops may only return once the loop has
+			var stops sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				stops.Add(1)
+				go func() {
+					defer stops.Done()
+					w.Stop()
+					select {
+					case <-returned:
+					default:
+						t.Error("Stop returned before the loop did")
+					}
+				}()
+			}
+			stops.Wait()
+			if running() {
+				t.Error("watcher still marked running after both Stops returned")
+			}
+		})
+
+		t.Run(tt.name+"/concurrent start and stop", func(t *testing.T) {
+			w, running := tt.watcher(t)
+
+			var starts, stops sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				starts.Add(1)
+				go func() {
+					defer starts.Done()
+					w.Start(time.Millisecond)
+				}()
+				stops.Add(2)
+				for j := 0; j < 2; j++ {
+					go func() {
+						defer stops.Done()
+						w.Stop()
+					}()
+				}
+			}
+			stops.Wait()
+
+			// Starts that came after the last Stop are still running
+			done := make(chan struct{})
+			go func() {
+				starts.Wait()
+				close(done)
+			}()
+			deadline := time.After(10 * time.Second)
+			for {
+				w.Stop()
+				select {
+				case <-done:
+					if running() {
+						t.Fatal("watcher still marked running after every loop returned")
+					}
+					return
+				case <-deadline:
+					t.Fatal("Start calls didn't return after Stop")
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestPangolinPagingStaysOnOrigin checks that a next page link to another
+// host fails the fetch instead of receiving the data source's credentials
+func TestPangolinPagingStaysOnOrigin(t *testing.T) {
+	var foreignHits int
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignHits++
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(foreign.Close)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"next": %q}`, foreign.URL+"/traefik-config?page=2")
+	}))
+	t.Cleanup(origin.Close)
+
+	config := models.DataSourceConfig{Type: models.PangolinAPI, URL: origin.URL}
+	config.BasicAuth.Username = "admin"
+	config.BasicAuth.Password = "secret"
+
+	_, err := fetchPangolinTraefikConfig(context.Background(), origin.Client(), config)
+	if err == nil || !strings.Contains(err.Error(), "not following") {
+		t.Fatalf("expected the foreign next link to be refused, got %v", err)
+	}
+	if foreignHits != 0 {
+		t.Fatalf("foreign host received %d requests", foreignHits)
+	}
+}
+
+// TestDataSourceClientFailsOnBadTLSFiles checks that a client certificate
+// that doesn't load fails requests instead of sending them without it
+func TestDataSourceClientFailsOnBadTLSFiles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+
+	config := models.DataSourceConfig{Type: models.TraefikAPI, URL: server.URL}
+	config.TLS.InsecureSkipVerify = true
+	config.TLS.CertFile = filepath.Join(t.TempDir(), "missing.pem")
+	config.TLS.KeyFile = filepath.Join(t.TempDir(), "missing-key.pem")
+
+	resp, err := NewDataSourceHTTPClient(config, time.Second).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request succeeded without the configured client certificate")
+	}
+}
+
+// TestReloaderCallsOnFinish checks that the finish hook has run by the time
+// the status reports the reload as done
+func TestReloaderCallsOnFinish(t *testing.T) {
+	reloader := NewReloader(nil, nil, newSecretConfigGenerator(t))
+	var mu sync.Mutex
+	finished := 0
+	reloader.OnFinish(func() {
+		mu.Lock()
+		finished++
+		mu.Unlock()
+	})
+
+	reloader.Trigger()
+	deadline := time.Now().Add(5 * time.Second)
+	for reloader.Status().Running {
+		if time.Now().After(deadline) {
+			t.Fatal("reload did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if finished != 1 {
+		t.Errorf("OnFinish called %d times, want 1", finished)
+	}
+}
+
+func TestServiceWatcherUpdatesFromUpstream(t *testing.T) {
+	sw, err := NewServiceWatcher(newWatcherDeps(t))
+	if err != nil {
+		t.Fatalf("NewServiceWatcher: %v", err)
+	}
+	upsert := func(config string) {
+		t.Helper()
+		service := models.Service{ID: "api@file", Name: "api@file", Type: string(models.LoadBalancerType), Config: config}
+		if err := sw.updateOrCreateService(service); err != nil {
+			t.Fatalf("updateOrCreateService: %v", err)
+		}
+	}
+	stored := func() string {
+		t.Helper()
+		var config string
+		if err := sw.db.QueryRow("SELECT config FROM services WHERE id = ?", "api@file").Scan(&config); err != nil {
+			t.Fatalf("read service: %v", err)
+		}
+		return config
+	}
+
+	upsert(`{"servers":[{"url":"http://a:80"}],"healthCheck":{"path":"/health"},"passHostHeader":false}`)
+	upsert(`{"servers":[{"url":"http://b:80"}]}`)
+	if config := stored(); strings.Contains(config, "healthCheck") || strings.Contains(config, "passHostHeader") || !strings.Contains(config, "http://b:80") {
+		t.Errorf("managed service config = %s, want the upstream config only", config)
+	}
+
+	// A user edit takes the service over; only its servers follow upstream then
+	if _, err := sw.db.Exec(`UPDATE services SET managed = 0, config = ? WHERE id = ?`,
+		`{"servers":[{"url":"http://b:80"}],"sticky":{"cookie":{"name":"lb"}}}`, "api@file"); err != nil {
+		t.Fatalf("edit service: %v", err)
+	}
+	upsert(`{"servers":[{"url":"http://c:80"}],"passHostHeader":false}`)
+	config := stored()
+	if !strings.Contains(config, "http://c:80") || !strings.Contains(config, "sticky") || strings.Contains(config, "passHostHeader") {
+		t.Errorf("edited service config = %s, want upstream servers and the user's sticky only", config)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Router fields whose values are commonly repeated across routers
+var anchorableRouterFields = []string{"tls", "middlewares"}
+
+// anchorSharedBlocks rewrites repeated router tls and middlewares blocks as
+// YAML anchors and aliases: the first occurrence is anchored and identical
+// later ones become aliases to it. The decoded configuration is unchanged.
+func anchorSharedBlocks(root *yaml.Node) {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	// Anchors are keyed by field and the block's serialized form
+	anchors := make(map[string]*yaml.Node)
+	counts := make(map[string]int)
+
+	for _, protocol := range []string{"http", "tcp"} {
+		routers := mappingValue(mappingValue(root, protocol), "routers")
+		if routers == nil || routers.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(routers.Content); i += 2 {
+			router := routers.Content[i]
+			if router.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(router.Content); j += 2 {
+				field := router.Content[j].Value
+				if !stringSliceContains(anchorableRouterFields, field) {
+					continue
+				}
+				block := router.Content[j+1]
+				if block.Kind != yaml.MappingNode && block.Kind != yaml.SequenceNode {
+					continue
+				}
+				serialized, err := yaml.Marshal(block)
+				if err != nil {
+					continue
+				}
+				key := field + "\x00" + string(serialized)
+
+				anchor, seen := anchors[key]
+				if !seen {
+					anchors[key] = block
+					continue
+				}
+				if anchor.Anchor == "" {
+					counts[field]++
+					anchor.Anchor = fmt.Sprintf("%s%d", field, counts[field])
+				}
+				router.Content[j+1] = &yaml.Node{Kind: yaml.AliasNode, Value: anchor.Anchor, Alias: anchor}
+			}
+		}
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Indentation of the generated YAML when YAML_INDENT is not set
+const DefaultYAMLIndent = 4
+
+// YAMLSequenceStyle controls how lists are written in the generated YAML
+type YAMLSequenceStyle string
+
+const (
+	// YAMLSequenceBlock writes every list with one "- item" line per item
+	YAMLSequenceBlock YAMLSequenceStyle = "block"
+	// YAMLSequenceFlow writes lists of plain values inline, e.g.
+	// [web, websecure]; lists of objects stay in block style
+	YAMLSequenceFlow YAMLSequenceStyle = "flow"
+)
+
+// ParseYAMLIndent parses a YAML_INDENT value; empty means DefaultYAMLIndent
+func ParseYAMLIndent(value string) (int, error) {
+	if value == "" {
+		return DefaultYAMLIndent, nil
+	}
+	indent, err := strconv.Atoi(value)
+	if err != nil || indent < 2 || indent > 8 {
+		return 0, fmt.Errorf("invalid YAML indent %q, expected a number of spaces from 2 to 8", value)
+	}
+	return indent, nil
+}
+
+// ParseYAMLSequenceStyle parses a YAML_SEQUENCE_STYLE value; empty means block
+func ParseYAMLSequenceStyle(value string) (YAMLSequenceStyle, error) {
+	switch YAMLSequenceStyle(value) {
+	case "", YAMLSequenceBlock:
+		return YAMLSequenceBlock, nil
+	case YAMLSequenceFlow:
+		return YAMLSequenceFlow, nil
+	}
+	return "", fmt.Errorf("unknown YAML sequence style %q, expected block or flow", value)
+}
+
+// SetYAMLFormat sets the indentation width and list style of the generated
+// YAML. Neither changes the decoded configuration.
+func (cg *ConfigGenerator) SetYAMLFormat(indent int, sequenceStyle YAMLSequenceStyle) {
+	cg.mutex.Lock()
+	defer cg.mutex.Unlock()
+	cg.yamlIndent = indent
+	cg.yamlSequenceStyle = sequenceStyle
+}
+
+// applySequenceStyle sets the style of every list under node, so the same
+// config is always written the same way
+func applySequenceStyle(node *yaml.Node, style YAMLSequenceStyle) {
+	if node == nil {
+		return
+	}
+	if node.Kind == yaml.SequenceNode {
+		if style == YAMLSequenceFlow && scalarsOnly(node) {
+			node.Style |= yaml.FlowStyle
+		} else {
+			node.Style &^= yaml.FlowStyle
+		}
+	}
+	for _, child := range node.Content {
+		applySequenceStyle(child, style)
+	}
+}
+
+// scalarsOnly reports whether every item of a sequence is a plain value
+func scalarsOnly(node *yaml.Node) bool {
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+// marshalYAML encodes a node with the configured indentation
+func (cg *ConfigGenerator) marshalYAML(node *yaml.Node) ([]byte, error) {
+	indent := cg.yamlIndent
+	if indent == 0 {
+		indent = DefaultYAMLIndent
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -2,6 +2,7 @@
 import React, { useState, useEffect, useCallback } from 'react'; // Added useCallback import
 import { LoadingSpinner, ErrorMessage } from '../common';
 import { useDataSource, useApp } from '../../contexts';
+import { apiFetch } from '../../services/api';
 
 /**
  * DataSourceSettings component for managing API data sources
@@ -37,7 +38,7 @@ const DataSourceSettings = ({ onClose }) => {
      setConnectionStatus(prev => ({ ...prev, [name]: { testing: true } }));
      try {
          const testConfig = { ...config, url: config.url?.replace(/\/+$/, '') };
-         const response = await fetch(`/api/datasource/${name}/test`, {
+         const response = await apiFetch(`/api/datasource/${name}/test`, {
              method: 'POST',
              headers: { 'Content-Type': 'application/json' },
              body: JSON.stringify(testConfig)
@@ -225,11 +226,13 @@ const DataSourceSettings = ({ onClose }) => {
                                         <select name="type" value={sourceForm.type} onChange={handleInputChange} className="form-input text-sm" disabled={saving}>
                                             <option value="pangolin">Pangolin API</option>
                                             <option value="traefik">Traefik API</option>
+                                            <option value="docker">Docker Labels</option>
+                                            <option value="file">Traefik Config File</option>
                                         </select>
                                     </div>
                                     <div>
                                         <label className="form-label text-xs">URL</label>
-                                        <input type="url" name="url" value={sourceForm.url} onChange={handleInputChange} className="form-input text-sm" placeholder={sourceForm.type === 'pangolin' ? 'http://pangolin:3001/api/v1' : 'http://traefik:8080'} required disabled={saving} />
+                                        <input type="url" name="url" value={sourceForm.url} onChange={handleInputChange} className="form-input text-sm" placeholder={sourceForm.type === 'pangolin' ? 'http://pangolin:3001/api/v1' : sourceForm.type === 'docker' ? 'unix:///var/run/docker.sock' : sourceForm.type === 'file' ? 'file:///etc/traefik/dynamic' : 'http://traefik:8080'} required disabled={saving} />
                                         <p className="text-xs text-gray-500 dark:text-gray-400 mt-1">Include scheme (http/https). For Docker, use container names (e.g., http://traefik:8080).</p>
                                     </div>
                                     <div className="grid grid-cols-1 sm:grid-cols-2 gap-4">
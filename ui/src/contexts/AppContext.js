@@ -1,4 +1,5 @@
 import React, { createContext, useState, useContext, useEffect } from 'react';
+import { apiFetch } from '../services/api';
 
 // Create the context
 const AppContext = createContext();
@@ -48,7 +49,7 @@ export const AppProvider = ({ children }) => {
    */
   const fetchActiveDataSource = async () => {
     try {
-      const response = await fetch('/api/datasource/active');
+      const response = await apiFetch('/api/datasource/active');
       if (response.ok) {
         const data = await response.json();
         setActiveDataSource(data.name || 'pangolin');
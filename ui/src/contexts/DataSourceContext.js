@@ -1,4 +1,5 @@
 import React, { createContext, useState, useContext, useEffect, useCallback } from 'react';
+import { apiFetch } from '../services/api';
 
 // Create the context
 const DataSourceContext = createContext();
@@ -25,7 +26,7 @@ export const DataSourceProvider = ({ children }) => {
       setLoading(true);
       setError(null);
       
-      const response = await fetch('/api/datasource');
+      const response = await apiFetch('/api/datasource');
       
       if (!response.ok) {
         throw new Error(`HTTP error ${response.status}`);
@@ -51,7 +52,7 @@ export const DataSourceProvider = ({ children }) => {
       setLoading(true);
       setError(null);
       
-      const response = await fetch('/api/datasource/active');
+      const response = await apiFetch('/api/datasource/active');
       
       if (!response.ok) {
         throw new Error(`HTTP error ${response.status}`);
@@ -79,7 +80,7 @@ export const DataSourceProvider = ({ children }) => {
       setLoading(true);
       setError(null);
       
-      const response = await fetch('/api/datasource/active', {
+      const response = await apiFetch('/api/datasource/active', {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ name })
@@ -114,7 +115,7 @@ export const DataSourceProvider = ({ children }) => {
       setLoading(true);
       setError(null);
       
-      const response = await fetch(`/api/datasource/${name}`, {
+      const response = await apiFetch(`/api/datasource/${name}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(config)
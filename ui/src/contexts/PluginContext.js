@@ -1,5 +1,6 @@
 // ui/src/contexts/PluginContext.js
 import React, { createContext, useState, useContext, useCallback, useEffect } from 'react';
+import { apiFetch } from '../services/api';
 // Ensure GlobalErrorMessage is imported correctly if you use it elsewhere in this file.
 // For now, local error display via alert/setError should suffice for these new functions.
 
@@ -18,7 +19,7 @@ export const PluginProvider = ({ children }) => {
     setLoading(true);
     setError(null);
     try {
-      const response = await fetch(API_URL);
+      const response = await apiFetch(API_URL);
       if (!response.ok) {
         const errData = await response.json().catch(() => ({ message: `HTTP error ${response.status}` }));
         throw new Error(errData.message);
@@ -38,7 +39,7 @@ export const PluginProvider = ({ children }) => {
     // setLoading(true); // Individual button will handle its loading state
     setError(null);
     try {
-      const response = await fetch(`${API_URL}/install`, {
+      const response = await apiFetch(`${API_URL}/install`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(pluginData),
@@ -65,7 +66,7 @@ export const PluginProvider = ({ children }) => {
     // setLoading(true); // Individual button will handle its loading state
     setError(null);
     try {
-      const response = await fetch(`${API_URL}/remove`, {
+      const response = await apiFetch(`${API_URL}/remove`, {
         method: 'DELETE',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ moduleName }),
@@ -93,7 +94,7 @@ export const PluginProvider = ({ children }) => {
     setFetchingPath(true);
     setError(null);
     try {
-      const response = await fetch(`${API_URL}/configpath`);
+      const response = await apiFetch(`${API_URL}/configpath`);
       if (!response.ok) {
         const errData = await response.json().catch(() => ({ message: `HTTP error ${response.status}` }));
         throw new Error(errData.message);
@@ -113,7 +114,7 @@ export const PluginProvider = ({ children }) => {
     setLoading(true);
     setError(null);
     try {
-      const response = await fetch(`${API_URL}/configpath`, {
+      const response = await apiFetch(`${API_URL}/configpath`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ path: newPath }),
@@ -7,6 +7,62 @@
 
 const API_URL = '/api';
 
+// localStorage key of the bearer token sent when the API requires API_AUTH_TOKEN
+const API_TOKEN_KEY = 'middlewareManagerApiToken';
+
+const getApiToken = () => {
+  try {
+    return window.localStorage.getItem(API_TOKEN_KEY);
+  } catch (e) {
+    return null;
+  }
+};
+
+const setApiToken = (token) => {
+  try {
+    window.localStorage.setItem(API_TOKEN_KEY, token);
+  } catch (e) {
+    console.debug("Could not store the API token.");
+  }
+};
+
+// Pending token prompt, if one is open
+let tokenPrompt = null;
+
+/**
+ * fetch for API calls: sends the stored bearer token, if any, and when the
+ * API only accepts a token, asks for it once and retries. Basic auth is
+ * handled by the browser.
+ * @param {string} url - API endpoint
+ * @param {Object} options - Fetch options
+ * @returns {Promise<Response>} - The fetch response
+ */
+export const apiFetch = async (url, options = {}, retried = false) => {
+  const token = getApiToken();
+  const headers = token ? { ...options.headers, Authorization: `Bearer ${token}` } : options.headers;
+  const response = await fetch(url, { ...options, headers });
+
+  const challenge = response.headers.get("www-authenticate") || "";
+  if (response.status === 401 && !retried && challenge.toLowerCase().startsWith("bearer")) {
+    // Requests failing together share one prompt
+    if (!tokenPrompt) {
+      tokenPrompt = Promise.resolve().then(() => {
+        const entered = window.prompt(token ? "The API token was rejected. Enter the API token:" : "Enter the API token (API_AUTH_TOKEN):");
+        if (entered) {
+          setApiToken(entered.trim());
+        }
+        return Boolean(entered);
+      }).finally(() => {
+        tokenPrompt = null;
+      });
+    }
+    if (await tokenPrompt) {
+      return apiFetch(url, options, true);
+    }
+  }
+  return response;
+};
+
 /**
  * Generic request handler with error handling
  * @param {string} url - API endpoint
@@ -15,7 +71,7 @@ const API_URL = '/api';
  */
 const request = async (url, options = {}) => {
   try {
-    const response = await fetch(url, options);
+    const response = await apiFetch(url, options);
 
     // If the response is not ok, try to parse error JSON, otherwise throw status text
     if (!response.ok) {
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultPorts maps URL schemes to the port that is implied when none is given
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"h2c":   "80",
+}
+
+// NormalizeServerURL returns a canonical form of a backend server URL so that
+// equivalent URLs compare equal. It lowercases the scheme and host, drops the
+// scheme's default port and strips trailing slashes from the path.
+// Values that cannot be parsed as an absolute URL are returned trimmed but otherwise unchanged.
+func NormalizeServerURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return trimmed
+	}
+
+	u, err := url.Parse(trimmed)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return trimmed
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port != "" && port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// Bare IPv6 literal needs brackets
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String()
+}
+
+// NormalizeServerAddress returns a canonical form of a TCP/UDP server address (host:port)
+func NormalizeServerAddress(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	host, port, err := net.SplitHostPort(trimmed)
+	if err != nil {
+		return trimmed
+	}
+	return net.JoinHostPort(strings.ToLower(host), port)
+}
+
+// NormalizeServersInConfig normalizes the url/address of every entry in a
+// service config's "servers" list in place
+func NormalizeServersInConfig(config map[string]interface{}) {
+	servers, ok := config["servers"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, server := range servers {
+		serverMap, ok := server.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if u, ok := serverMap["url"].(string); ok {
+			serverMap["url"] = NormalizeServerURL(u)
+		}
+		if addr, ok := serverMap["address"].(string); ok {
+			serverMap["address"] = NormalizeServerAddress(addr)
+		}
+	}
+}